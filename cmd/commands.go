@@ -0,0 +1,155 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"kiwi/internal/backup"
+	"kiwi/internal/config"
+	"kiwi/internal/storage"
+)
+
+// runCommand runs a command-line subcommand and returns the exit code
+func runCommand(name string, args []string) int {
+	switch name {
+	case "restore":
+		return runRestore(args)
+	case "export":
+		return runExport(args)
+	case "import":
+		return runImport(args)
+	case "migrate":
+		return runMigrate(args)
+	case "help", "-h", "--help":
+		usage()
+		return 0
+	default:
+		fmt.Fprintf(os.Stderr, "unknown command %q\n\n", name)
+		usage()
+		return 2
+	}
+}
+
+// usage prints the available subcommands
+func usage() {
+	fmt.Fprintln(os.Stderr, `Usage:
+  kiwi                 Start the server
+  kiwi restore [flags] Restore a backup into a stopped node's database
+  kiwi export [flags]  Export a collection from a running node
+  kiwi import [flags]  Import records into a collection through the master
+  kiwi migrate [flags] Upgrade a stopped node's database to the current format
+
+Run "kiwi <command> -h" for the flags of a command.`)
+}
+
+// runRestore seeds a database from a backup directory or tarball
+func runRestore(args []string) int {
+	cfg := config.Load()
+
+	fs := flag.NewFlagSet("restore", flag.ContinueOnError)
+	from := fs.String("from", "", "backup directory or tarball (required)")
+	db := fs.String("db", cfg.DatabasePath, "database directory to restore into")
+	engine := fs.String("engine", cfg.StorageEngine, "storage engine of the database")
+	collections := fs.String("collections", "", "comma-separated collections to restore (default: full restore into an empty database)")
+	incrementals := fs.String("incrementals", "", "comma-separated incremental backups to replay after the full backup, oldest first")
+	toSeq := fs.Uint64("to-seq", 0, "stop replaying after this sequence (default: replay everything)")
+	toTime := fs.String("to-time", "", "stop replaying before the first change after this RFC 3339 time")
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+	if *from == "" {
+		fmt.Fprintln(os.Stderr, "restore: -from is required")
+		fs.Usage()
+		return 2
+	}
+
+	current, previous, err := cfg.MasterKeys()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "restore: %v\n", err)
+		return 2
+	}
+
+	opts := backup.RestoreOptions{
+		Collections:   splitList(*collections),
+		Incrementals:  splitList(*incrementals),
+		ToSeq:         *toSeq,
+		Engine:        *engine,
+		EngineOptions: engineOptions(cfg),
+		Keys:          storage.MasterKeys{Current: current, Previous: previous},
+	}
+	if *toTime != "" {
+		t, err := time.Parse(time.RFC3339, *toTime)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "restore: invalid -to-time: %v\n", err)
+			return 2
+		}
+		opts.ToTime = t
+	}
+
+	res, err := backup.Restore(*from, *db, opts)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "restore failed: %v\n", err)
+		return 1
+	}
+
+	m := res.Manifest
+	switch {
+	case len(opts.Collections) > 0:
+		fmt.Printf("Restored collections %s from backup of %s (sequence %d) into %s\n", strings.Join(opts.Collections, ", "), m.NodeID, m.Sequence, *db)
+	case len(opts.Incrementals) > 0:
+		fmt.Printf("Restored full backup of %s (sequence %d) and replayed %d changes into %s, now at sequence %d\n", m.NodeID, m.Sequence, res.Replayed, *db, res.Sequence)
+		if opts.ToSeq > res.Sequence {
+			fmt.Fprintf(os.Stderr, "warning: backups end at sequence %d, before the requested %d\n", res.Sequence, opts.ToSeq)
+		}
+		for _, w := range res.Warnings {
+			fmt.Fprintf(os.Stderr, "warning: %s\n", w)
+		}
+	default:
+		fmt.Printf("Restored full backup of %s (sequence %d, %d keys) into %s\n", m.NodeID, m.Sequence, m.Keys, *db)
+	}
+	return 0
+}
+
+// runMigrate rewrites a database in the current on-disk format
+func runMigrate(args []string) int {
+	cfg := config.Load()
+
+	fs := flag.NewFlagSet("migrate", flag.ContinueOnError)
+	db := fs.String("db", cfg.DatabasePath, "database directory to migrate")
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+
+	res, err := storage.Migrate(*db)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "migrate failed: %v\n", err)
+		return 1
+	}
+
+	if res.Backup == "" {
+		fmt.Printf("%s already uses format %d, nothing to do\n", *db, storage.FormatVersion)
+		return 0
+	}
+	fmt.Printf("Migrated %s from format %d to %d (%d keys); the original is kept at %s\n", *db, res.From, res.To, res.Keys, res.Backup)
+	if res.Skipped > 0 {
+		fmt.Fprintf(os.Stderr, "warning: %d keys could not be parsed and were left out, they remain in %s\n", res.Skipped, res.Backup)
+	}
+	if res.Ambiguous > 0 {
+		fmt.Fprintf(os.Stderr, "warning: %d keys could belong to more than one collection and were given to the longest name; compare them with %s\n", res.Ambiguous, res.Backup)
+	}
+	return 0
+}
+
+// splitList splits a comma-separated flag value, dropping empty items
+func splitList(value string) []string {
+	var items []string
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
@@ -8,12 +8,20 @@ import (
 	"time"
 
 	"kiwi/internal/api"
+	"kiwi/internal/changelog"
 	"kiwi/internal/config"
 	"kiwi/internal/replication"
 	"kiwi/internal/storage"
+	"kiwi/internal/webhook"
+	pb "kiwi/proto"
 )
 
 func main() {
+	// Subcommands run instead of the server
+	if len(os.Args) > 1 {
+		os.Exit(runCommand(os.Args[1], os.Args[2:]))
+	}
+
 	// Load configuration
 	cfg := config.Load()
 
@@ -21,10 +29,51 @@ func main() {
 	log.Printf("Node ID: %s, Role: %s", cfg.NodeID, cfg.Role)
 
 	// Initialize base storage layer
-	baseStore, err := storage.NewLevelDBStore(cfg.DatabasePath)
+	baseStore, err := storage.OpenStore(cfg.StorageEngine, cfg.DatabasePath, engineOptions(cfg))
 	if err != nil {
 		log.Fatalf("Failed to initialize storage: %v", err)
 	}
+	log.Printf("Storage engine: %s", cfg.StorageEngine)
+
+	if cfg.CacheSizeMB > 0 {
+		baseStore.EnableCache(int64(cfg.CacheSizeMB) << 20)
+		log.Printf("Read cache enabled (%d MB)", cfg.CacheSizeMB)
+	}
+
+	if cfg.MaxMemoryMB > 0 {
+		if err := baseStore.EnableEviction(storage.EvictionOptions{
+			MaxMemory: int64(cfg.MaxMemoryMB) << 20,
+			Policy:    cfg.EvictionPolicy,
+			Scope:     cfg.EvictionScope,
+		}); err != nil {
+			log.Fatalf("Failed to enable eviction: %v", err)
+		}
+		log.Printf("Memory limit: %d MB (eviction: %s, %s)", cfg.MaxMemoryMB, cfg.EvictionPolicy, cfg.EvictionScope)
+	}
+
+	current, previous, err := cfg.MasterKeys()
+	if err != nil {
+		log.Fatalf("Failed to load encryption key: %v", err)
+	}
+	if err := baseStore.EnableEncryption(storage.MasterKeys{Current: current, Previous: previous}); err != nil {
+		log.Fatalf("Failed to enable encryption: %v", err)
+	}
+	if current != nil {
+		log.Printf("Encryption at rest enabled")
+	}
+
+	if cfg.ChangelogEnabled {
+		log.Printf("Changelog enabled (retention: %s, max entries: %d)", cfg.ChangelogRetention, cfg.ChangelogMaxEntries)
+		if err := baseStore.EnableChangelog(storage.ChangelogOptions{
+			Retention:  cfg.ChangelogRetention,
+			MaxEntries: cfg.ChangelogMaxEntries,
+		}); err != nil {
+			log.Fatalf("Failed to enable changelog: %v", err)
+		}
+	}
+	if err := baseStore.ResumeCollectionChanges(); err != nil {
+		log.Fatalf("Failed to finish interrupted collection changes: %v", err)
+	}
 
 	// Initialize replication components
 	var replManager *replication.Manager
@@ -45,6 +94,11 @@ func main() {
 
 	// All nodes run gRPC server (for health checks, and slaves for replication)
 	replServer = replication.NewServer(cfg, baseStore)
+
+	// Changelog consumers stream over the same gRPC port
+	clService := changelog.NewService(baseStore)
+	replServer.Register(&pb.ChangelogService_ServiceDesc, clService)
+
 	if err := replServer.Start(); err != nil {
 		log.Fatalf("Failed to start replication server: %v", err)
 	}
@@ -52,11 +106,26 @@ func main() {
 	// Create replicated store wrapper
 	store := storage.NewReplicatedStore(baseStore, cfg, replManager)
 
+	// Keys past their collection's TTL are deleted by the master
+	store.StartExpiry(cfg.ExpiryInterval)
+
+	// Keys are evicted once the node is over its memory limit
+	store.StartEviction()
+
+	// Webhooks are delivered by the master only, so each change is sent once
+	var hooks *webhook.Dispatcher
+	if cfg.IsMaster() {
+		hooks = webhook.NewDispatcher(baseStore)
+		if err := hooks.Start(); err != nil {
+			log.Fatalf("Failed to start webhook dispatcher: %v", err)
+		}
+	}
+
 	// Initialize and configure HTTP server
-	server := api.NewServer(cfg, store)
+	server := api.NewServer(cfg, store, hooks)
 
 	// Setup graceful shutdown
-	go handleShutdown(server, replServer, store)
+	go handleShutdown(server, replServer, clService, hooks, store)
 
 	// Start HTTP server
 	log.Printf("HTTP server starting on port %s", cfg.Port)
@@ -65,13 +134,29 @@ func main() {
 	}
 }
 
-func handleShutdown(server *api.Server, replServer *replication.Server, store *storage.ReplicatedStore) {
+// engineOptions returns the storage engine tuning of the configuration
+func engineOptions(cfg *config.Config) storage.EngineOptions {
+	return storage.EngineOptions{
+		LevelDB: storage.LevelDBOptions{
+			BlockCacheSize:  cfg.LevelDBBlockCacheMB << 20,
+			WriteBuffer:     cfg.LevelDBWriteBufferMB << 20,
+			BloomFilterBits: cfg.LevelDBBloomBits,
+			Compression:     cfg.LevelDBCompression,
+			OpenFiles:       cfg.LevelDBOpenFiles,
+		},
+	}
+}
+
+func handleShutdown(server *api.Server, replServer *replication.Server, clService *changelog.Service, hooks *webhook.Dispatcher, store *storage.ReplicatedStore) {
 	sigChan := make(chan os.Signal, 1)
 	signal.Notify(sigChan, os.Interrupt, syscall.SIGTERM)
 	<-sigChan
 
 	log.Println("Shutting down server...")
 
+	// Open changelog streams would keep the gRPC server from stopping
+	clService.Stop()
+
 	if replServer != nil {
 		replServer.Stop()
 	}
@@ -80,6 +165,10 @@ func handleShutdown(server *api.Server, replServer *replication.Server, store *s
 		log.Printf("Server shutdown error: %v", err)
 	}
 
+	if hooks != nil {
+		hooks.Stop()
+	}
+
 	if err := store.Close(); err != nil {
 		log.Printf("Storage close error: %v", err)
 	}
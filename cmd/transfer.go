@@ -0,0 +1,215 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+
+	"kiwi/internal/config"
+	"kiwi/internal/models"
+	"kiwi/internal/transfer"
+)
+
+// defaultAddr returns the HTTP address of the local node
+func defaultAddr(cfg *config.Config) string {
+	return "http://localhost:" + cfg.Port
+}
+
+// runExport downloads a collection from a running node
+func runExport(args []string) int {
+	cfg := config.Load()
+
+	fs := flag.NewFlagSet("export", flag.ContinueOnError)
+	addr := fs.String("addr", defaultAddr(cfg), "HTTP address of the node to export from")
+	collection := fs.String("collection", "", "collection to export (required)")
+	format := fs.String("format", "", "ndjson, csv or json (default: from -out, else ndjson)")
+	fields := fs.String("fields", "", "comma-separated CSV columns (default: every top-level field)")
+	out := fs.String("out", "-", "output file, - for stdout")
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+	if *collection == "" {
+		fmt.Fprintln(os.Stderr, "export: -collection is required")
+		fs.Usage()
+		return 2
+	}
+	if *format == "" {
+		*format = transfer.FormatOf(*out)
+	}
+	if *format == "" {
+		*format = transfer.FormatNDJSON
+	}
+
+	query := url.Values{"format": {*format}}
+	if *fields != "" {
+		query.Set("fields", *fields)
+	}
+	resp, err := http.Get(fmt.Sprintf("%s/collections/%s/export?%s", strings.TrimRight(*addr, "/"), url.PathEscape(*collection), query.Encode()))
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "export failed: %v\n", err)
+		return 1
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		fmt.Fprintf(os.Stderr, "export failed: %s\n", responseError(resp))
+		return 1
+	}
+
+	w := io.Writer(os.Stdout)
+	if *out != "-" {
+		f, err := os.Create(*out)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "export failed: %v\n", err)
+			return 1
+		}
+		defer f.Close()
+		w = f
+	}
+
+	n, err := io.Copy(w, resp.Body)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "export failed: %v\n", err)
+		return 1
+	}
+	if *out != "-" {
+		fmt.Printf("Exported collection %s to %s (%d bytes)\n", *collection, *out, n)
+	}
+	return 0
+}
+
+// runImport uploads a file into a collection through the master, in
+// requests of at most -batch records
+func runImport(args []string) int {
+	cfg := config.Load()
+
+	fs := flag.NewFlagSet("import", flag.ContinueOnError)
+	addr := fs.String("addr", defaultAddr(cfg), "HTTP address of the master")
+	collection := fs.String("collection", "", "collection to import into (required)")
+	file := fs.String("file", "", "input file, - for stdin (required)")
+	format := fs.String("format", "", "ndjson, csv or json (default: from -file, else ndjson)")
+	onConflict := fs.String("on-conflict", "overwrite", "what to do with existing keys: overwrite, skip or fail")
+	batch := fs.Int("batch", 5000, "records per request")
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+	if *collection == "" || *file == "" {
+		fmt.Fprintln(os.Stderr, "import: -collection and -file are required")
+		fs.Usage()
+		return 2
+	}
+	if *batch <= 0 {
+		fmt.Fprintln(os.Stderr, "import: -batch must be positive")
+		return 2
+	}
+	if *format == "" {
+		*format = transfer.FormatOf(*file)
+	}
+	if *format == "" {
+		*format = transfer.FormatNDJSON
+	}
+
+	in := io.Reader(os.Stdin)
+	if *file != "-" {
+		f, err := os.Open(*file)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "import failed: %v\n", err)
+			return 1
+		}
+		defer f.Close()
+		in = f
+	}
+
+	reader, err := transfer.NewReader(bufio.NewReader(in), *format)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "import failed: %v\n", err)
+		return 1
+	}
+
+	endpoint := fmt.Sprintf("%s/collections/%s/import?%s", strings.TrimRight(*addr, "/"), url.PathEscape(*collection),
+		url.Values{"format": {transfer.FormatNDJSON}, "on_conflict": {*onConflict}}.Encode())
+
+	var total models.ImportResponse
+	var chunk bytes.Buffer
+	records := 0
+	send := func() error {
+		if records == 0 {
+			return nil
+		}
+		res, err := postImport(endpoint, &chunk)
+		total.Imported += res.Imported
+		total.Skipped += res.Skipped
+		chunk.Reset()
+		records = 0
+		return err
+	}
+
+	w, _ := transfer.NewWriter(&chunk, transfer.FormatNDJSON, nil)
+	for {
+		r, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err == nil {
+			err = w.Write(r)
+		}
+		if err == nil {
+			if records++; records >= *batch {
+				err = send()
+			}
+		}
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "import failed after %d imported, %d skipped: %v\n", total.Imported, total.Skipped, err)
+			return 1
+		}
+	}
+	if err := send(); err != nil {
+		fmt.Fprintf(os.Stderr, "import failed after %d imported, %d skipped: %v\n", total.Imported, total.Skipped, err)
+		return 1
+	}
+
+	fmt.Printf("Imported %d records into %s (%d skipped)\n", total.Imported, *collection, total.Skipped)
+	return 0
+}
+
+// postImport sends one chunk of NDJSON records to the import endpoint
+func postImport(endpoint string, body io.Reader) (models.ImportResponse, error) {
+	var res models.ImportResponse
+
+	resp, err := http.Post(endpoint, transfer.ContentType(transfer.FormatNDJSON), body)
+	if err != nil {
+		return res, err
+	}
+	defer resp.Body.Close()
+
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return res, err
+	}
+	if err := json.Unmarshal(data, &res); err != nil {
+		return res, fmt.Errorf("unexpected response (%s): %s", resp.Status, data)
+	}
+	if resp.StatusCode != http.StatusOK {
+		if res.Error == "" {
+			res.Error = resp.Status
+		}
+		return res, errors.New(res.Error)
+	}
+	return res, nil
+}
+
+// responseError extracts the error message of a failed request
+func responseError(resp *http.Response) string {
+	var e models.ErrorResponse
+	if err := json.NewDecoder(resp.Body).Decode(&e); err != nil || e.Error == "" {
+		return resp.Status
+	}
+	return e.Error
+}
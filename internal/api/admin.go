@@ -0,0 +1,269 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"kiwi/internal/backup"
+	"kiwi/internal/models"
+	"kiwi/internal/storage"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// CreateBackup handles taking an online backup of this node. The backup is
+// written inside BACKUP_DIR, under a timestamped name without a path.
+// Incremental backups need the changelog and by default continue from the
+// last backup of this node.
+func (h *Handler) CreateBackup(c *fiber.Ctx) error {
+	var req models.BackupRequest
+	if len(c.Body()) > 0 {
+		if err := c.BodyParser(&req); err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(models.ErrorResponse{
+				Error: "Invalid JSON format",
+			})
+		}
+	}
+
+	if req.Format == "" {
+		req.Format = backup.FormatDir
+	}
+	if req.Type == "" {
+		req.Type = backup.TypeFull
+	}
+	if req.Path == "" {
+		name := fmt.Sprintf("kiwi-%s-%s", h.config.NodeID, time.Now().UTC().Format("20060102T150405Z"))
+		if req.Type == backup.TypeIncremental {
+			name += "-incr"
+		}
+		if req.Format == backup.FormatTar {
+			name += ".tar.gz"
+		}
+		req.Path = name
+	}
+	path, err := backupPath(h.config.BackupDir, req.Path)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(models.ErrorResponse{
+			Error: err.Error(),
+		})
+	}
+	req.Path = path
+
+	m, err := backup.Create(h.store.Underlying(), backup.Options{
+		Path:   req.Path,
+		Format: req.Format,
+		Type:   req.Type,
+		Since:  req.Since,
+		NodeID: h.config.NodeID,
+		Role:   string(h.config.Role),
+	})
+	if err != nil {
+		return c.Status(backupErrorStatus(err)).JSON(models.ErrorResponse{
+			Error: err.Error(),
+		})
+	}
+
+	return c.Status(fiber.StatusCreated).JSON(models.BackupResponse{
+		Message: "Backup created successfully",
+		Path:    req.Path,
+		Format:  req.Format,
+		Manifest: models.BackupManifest{
+			Version:     m.Version,
+			Type:        m.Type,
+			NodeID:      m.NodeID,
+			Role:        m.Role,
+			CreatedAt:   m.CreatedAt,
+			Sequence:    m.Sequence,
+			Keys:        m.Keys,
+			Collections: m.Collections,
+
+			BaseSequence: m.BaseSequence,
+			Changes:      m.Changes,
+			Encrypted:    m.Encrypted,
+		},
+	})
+}
+
+// backupPath resolves the path of a backup requested over the API. A
+// relative path is taken from dir, and the result must stay inside dir.
+func backupPath(dir, path string) (string, error) {
+	if !filepath.IsAbs(path) {
+		path = filepath.Join(dir, path)
+	}
+	absDir, err := filepath.Abs(dir)
+	if err != nil {
+		return "", err
+	}
+	absPath, err := filepath.Abs(path)
+	if err != nil {
+		return "", err
+	}
+	rel, err := filepath.Rel(absDir, absPath)
+	if err != nil || rel == "." || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return "", fmt.Errorf("backup path must be inside the backup directory %s", dir)
+	}
+	return path, nil
+}
+
+// backupErrorStatus maps backup errors to HTTP status codes
+func backupErrorStatus(err error) int {
+	switch {
+	case errors.Is(err, backup.ErrInvalidFormat), errors.Is(err, backup.ErrInvalidType),
+		errors.Is(err, storage.ErrOffsetAhead):
+		return fiber.StatusBadRequest
+	case errors.Is(err, backup.ErrExists), errors.Is(err, backup.ErrNoBaseBackup),
+		errors.Is(err, storage.ErrChangelogDisabled):
+		return fiber.StatusConflict
+	case errors.Is(err, storage.ErrOffsetExpired):
+		return fiber.StatusGone
+	default:
+		return fiber.StatusInternalServerError
+	}
+}
+
+// GetEncryption handles describing the data keys of this node and the
+// collections being re-encrypted
+func (h *Handler) GetEncryption(c *fiber.Ctx) error {
+	st := h.store.EncryptionStatus()
+
+	resp := models.EncryptionStatusResponse{
+		Enabled:   st.Enabled,
+		MasterKey: st.Fingerprint,
+		Keys:      make([]models.EncryptionKey, len(st.Keys)),
+		Rekeying:  st.Rekeying,
+	}
+	if resp.Rekeying == nil {
+		resp.Rekeying = []string{}
+	}
+	for i, k := range st.Keys {
+		resp.Keys[i] = encryptionKey(k)
+		resp.Keys[i].Current = st.Current[k.ID]
+	}
+	return c.Status(fiber.StatusOK).JSON(resp)
+}
+
+// RotateEncryptionKeys handles creating new data keys for a collection, or
+// for every collection. Values are re-encrypted in the background.
+func (h *Handler) RotateEncryptionKeys(c *fiber.Ctx) error {
+	var req models.RotateKeysRequest
+	if len(c.Body()) > 0 {
+		if err := json.Unmarshal(c.Body(), &req); err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(models.ErrorResponse{
+				Error: "Invalid JSON format",
+			})
+		}
+	}
+
+	keys, err := h.store.RotateDataKeys(req.Collection)
+	if err != nil {
+		status := fiber.StatusInternalServerError
+		switch {
+		case errors.Is(err, storage.ErrCollectionNotFound):
+			status = fiber.StatusNotFound
+		case errors.Is(err, storage.ErrEncryptionDisabled):
+			status = fiber.StatusConflict
+		}
+		return c.Status(status).JSON(models.ErrorResponse{
+			Error: err.Error(),
+		})
+	}
+
+	resp := models.RotateKeysResponse{
+		Message: "Data keys rotated, re-encryption started",
+		Keys:    make([]models.EncryptionKey, len(keys)),
+	}
+	for i, k := range keys {
+		resp.Keys[i] = encryptionKey(k)
+		resp.Keys[i].Current = true
+	}
+	return c.Status(fiber.StatusOK).JSON(resp)
+}
+
+// GetCacheStats handles reporting the hits, misses and size of the read
+// cache of this node
+func (h *Handler) GetCacheStats(c *fiber.Ctx) error {
+	st := h.store.CacheStats()
+
+	resp := models.CacheStatsResponse{
+		Enabled:       st.Enabled,
+		CapacityBytes: st.Capacity,
+		SizeBytes:     st.Size,
+		Entries:       st.Entries,
+		Hits:          st.Hits,
+		Misses:        st.Misses,
+		Evictions:     st.Evictions,
+		Invalidations: st.Invalidations,
+	}
+	if lookups := st.Hits + st.Misses; lookups > 0 {
+		resp.HitRatio = float64(st.Hits) / float64(lookups)
+	}
+	return c.Status(fiber.StatusOK).JSON(resp)
+}
+
+// GetMemoryStats handles reporting the memory limit of this node and the
+// keys evicted to stay under it
+func (h *Handler) GetMemoryStats(c *fiber.Ctx) error {
+	st := h.store.EvictionStats()
+	return c.Status(fiber.StatusOK).JSON(models.MemoryStatsResponse{
+		Enabled:        st.Enabled,
+		MaxMemoryBytes: st.MaxMemory,
+		UsedBytes:      st.Used,
+		Policy:         st.Policy,
+		Scope:          st.Scope,
+		TrackedKeys:    st.Tracked,
+		Evicted:        st.Evicted,
+	})
+}
+
+// GetStorageStats handles reporting the size of each collection and the
+// levels, compactions and files of the storage engine of this node
+func (h *Handler) GetStorageStats(c *fiber.Ctx) error {
+	st, err := h.store.StorageStats()
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(models.ErrorResponse{
+			Error: err.Error(),
+		})
+	}
+
+	resp := models.StorageStatsResponse{
+		Engine:      h.config.StorageEngine,
+		SizeBytes:   st.Size,
+		Collections: make([]models.CollectionSize, len(st.Collections)),
+	}
+	for i, info := range st.Collections {
+		resp.Collections[i] = models.CollectionSize{Name: info.Name, Keys: info.Keys, SizeBytes: info.Size}
+	}
+	if e := st.Engine; e != nil {
+		resp.EngineStats = &models.StorageEngineStats{
+			Levels:            make([]models.StorageLevel, len(e.Levels)),
+			Files:             e.Files,
+			OpenTables:        e.OpenTables,
+			BlockCacheBytes:   e.BlockCacheSize,
+			IOReadBytes:       e.IORead,
+			IOWriteBytes:      e.IOWrite,
+			WriteDelays:       e.WriteDelays,
+			WriteDelaySeconds: e.WriteDelayTime.Seconds(),
+			WritePaused:       e.WritePaused,
+		}
+		for i, l := range e.Levels {
+			resp.EngineStats.Levels[i] = models.StorageLevel{
+				Level:             l.Level,
+				Tables:            l.Tables,
+				SizeBytes:         l.Size,
+				CompactionSeconds: l.CompactionTime.Seconds(),
+				ReadBytes:         l.Read,
+				WriteBytes:        l.Write,
+			}
+		}
+	}
+	return c.Status(fiber.StatusOK).JSON(resp)
+}
+
+// encryptionKey converts a data key to its API form
+func encryptionKey(k storage.DataKey) models.EncryptionKey {
+	return models.EncryptionKey{ID: k.ID, Collection: k.Collection, Version: k.Version, CreatedAt: k.CreatedAt}
+}
@@ -0,0 +1,299 @@
+package api
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+
+	"kiwi/internal/models"
+	"kiwi/internal/storage"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// PutBlob handles storing the request body as a blob. The body is read as
+// a stream and stored in chunks, so it never has to fit in memory.
+func (h *Handler) PutBlob(c *fiber.Ctx) error {
+	key := c.Params("key")
+	collection := c.Query("collection", "default")
+
+	m, err := h.store.PutBlob(collection, key, c.Get(fiber.HeaderContentType), requestBody(c))
+	if err != nil {
+		return blobError(c, err)
+	}
+
+	return c.Status(fiber.StatusOK).JSON(blobResponse(collection, key, m))
+}
+
+// GetBlob handles downloading a blob, or a byte range of it
+func (h *Handler) GetBlob(c *fiber.Ctx) error {
+	return h.serveBlob(c, c.Query("collection", "default"), c.Params("key"))
+}
+
+// serveBlob streams the blob stored under a key. A single byte range can
+// be asked for with a Range header; other Range headers are ignored and
+// the whole blob is sent.
+func (h *Handler) serveBlob(c *fiber.Ctx, collection, key string) error {
+	blob, err := h.store.OpenBlob(collection, key)
+	if err != nil {
+		return blobError(c, err)
+	}
+	m := blob.Manifest
+
+	c.Set(fiber.HeaderContentType, m.ContentType)
+	c.Set(fiber.HeaderAcceptRanges, "bytes")
+	c.Set(fiber.HeaderETag, `"`+m.ID+`"`)
+
+	status, offset, length := fiber.StatusOK, int64(0), m.Size
+	if c.Get(fiber.HeaderRange) != "" {
+		rng, err := c.Range(int(m.Size))
+		switch {
+		case errors.Is(err, fiber.ErrRangeUnsatisfiable) && rng.Type == "bytes":
+			blob.Close()
+			c.Set(fiber.HeaderContentRange, fmt.Sprintf("bytes */%d", m.Size))
+			return c.Status(fiber.StatusRequestedRangeNotSatisfiable).JSON(models.ErrorResponse{
+				Error: "Range not satisfiable",
+			})
+		case err == nil && rng.Type == "bytes" && len(rng.Ranges) == 1:
+			r := rng.Ranges[0]
+			status, offset, length = fiber.StatusPartialContent, int64(r.Start), int64(r.End-r.Start+1)
+			c.Set(fiber.HeaderContentRange, fmt.Sprintf("bytes %d-%d/%d", r.Start, r.End, m.Size))
+		}
+	}
+	if err := blob.SetRange(offset, length); err != nil {
+		blob.Close()
+		return c.Status(fiber.StatusInternalServerError).JSON(models.ErrorResponse{
+			Error: err.Error(),
+		})
+	}
+
+	// The response closes the reader once it is sent
+	c.Status(status)
+	c.Context().SetBodyStream(blob, int(length))
+	return nil
+}
+
+// CreateUpload handles starting a resumable upload of a blob
+func (h *Handler) CreateUpload(c *fiber.Ctx) error {
+	var req models.CreateUploadRequest
+	if err := json.Unmarshal(c.Body(), &req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(models.ErrorResponse{
+			Error: "Invalid JSON format",
+		})
+	}
+	if req.Key == "" {
+		return c.Status(fiber.StatusBadRequest).JSON(models.ErrorResponse{
+			Error: "Key field is required",
+		})
+	}
+	if req.Collection == "" {
+		req.Collection = "default"
+	}
+
+	u, err := h.store.CreateUpload(req.Collection, req.Key, req.ContentType)
+	if err != nil {
+		return blobError(c, err)
+	}
+
+	return c.Status(fiber.StatusCreated).JSON(uploadResponse(u))
+}
+
+// ListUploads handles listing the uploads in progress
+func (h *Handler) ListUploads(c *fiber.Ctx) error {
+	uploads, err := h.store.Uploads()
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(models.ErrorResponse{
+			Error: err.Error(),
+		})
+	}
+
+	resp := models.UploadListResponse{
+		Count:   len(uploads),
+		Uploads: make([]models.UploadResponse, len(uploads)),
+	}
+	for i, u := range uploads {
+		resp.Uploads[i] = uploadResponse(u)
+	}
+	return c.Status(fiber.StatusOK).JSON(resp)
+}
+
+// GetUpload handles returning an upload and the parts stored so far, from
+// which an interrupted upload can be resumed
+func (h *Handler) GetUpload(c *fiber.Ctx) error {
+	u, err := h.store.GetUpload(c.Params("id"))
+	if err != nil {
+		return blobError(c, err)
+	}
+
+	return c.Status(fiber.StatusOK).JSON(uploadResponse(u))
+}
+
+// PutUploadPart handles storing one part of an upload. The body is read as
+// a stream; sending a part again replaces it.
+func (h *Handler) PutUploadPart(c *fiber.Ctx) error {
+	number, err := c.ParamsInt("part")
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(models.ErrorResponse{
+			Error: storage.ErrInvalidPart.Error(),
+		})
+	}
+
+	part, err := h.store.WritePart(c.Params("id"), number, requestBody(c))
+	if err != nil {
+		return blobError(c, err)
+	}
+
+	return c.Status(fiber.StatusOK).JSON(blobPart(part))
+}
+
+// CompleteUpload handles storing the blob of an upload under its key
+func (h *Handler) CompleteUpload(c *fiber.Ctx) error {
+	id := c.Params("id")
+	u, err := h.store.GetUpload(id)
+	if err != nil {
+		return blobError(c, err)
+	}
+
+	m, err := h.store.CompleteUpload(id)
+	if err != nil {
+		return blobError(c, err)
+	}
+
+	return c.Status(fiber.StatusOK).JSON(blobResponse(u.Collection, u.Key, m))
+}
+
+// AbortUpload handles discarding an upload and the parts stored so far
+func (h *Handler) AbortUpload(c *fiber.Ctx) error {
+	if err := h.store.AbortUpload(c.Params("id")); err != nil {
+		return blobError(c, err)
+	}
+
+	return c.Status(fiber.StatusOK).JSON(models.DeleteResponse{
+		Message: "Upload aborted successfully",
+		Key:     c.Params("id"),
+	})
+}
+
+// blobError writes the response for a failed blob or upload request
+func blobError(c *fiber.Ctx, err error) error {
+	status := fiber.StatusInternalServerError
+	switch {
+	case errors.Is(err, storage.ErrKeyNotFound):
+		return c.Status(fiber.StatusNotFound).JSON(models.ErrorResponse{
+			Error: "Key not found",
+		})
+	case errors.Is(err, storage.ErrNotBlob), errors.Is(err, storage.ErrUploadNotFound):
+		status = fiber.StatusNotFound
+	case errors.Is(err, storage.ErrUploadBusy), errors.Is(err, storage.ErrUploadIncomplete),
+		errors.Is(err, storage.ErrBlobMissing):
+		status = fiber.StatusConflict
+	case errors.Is(err, storage.ErrInvalidKey), errors.Is(err, storage.ErrInvalidPart):
+		status = fiber.StatusBadRequest
+	case errors.Is(err, storage.ErrValueTooLarge), schemaViolations(err) != nil:
+		return putError(c, err)
+	}
+	return c.Status(status).JSON(models.ErrorResponse{
+		Error: err.Error(),
+	})
+}
+
+// requestBody returns the request body as a stream
+func requestBody(c *fiber.Ctx) io.Reader {
+	if r := c.Context().RequestBodyStream(); r != nil {
+		return r
+	}
+	return bytes.NewReader(c.Body())
+}
+
+// streamsBody reports whether a request is a blob upload or an import,
+// whose body is read as a stream instead of being held in memory
+func streamsBody(c *fiber.Ctx) bool {
+	path := c.Path()
+	switch c.Method() {
+	case fiber.MethodPut:
+		return strings.HasPrefix(path, "/blobs/") || strings.HasPrefix(path, "/uploads/")
+	case fiber.MethodPost:
+		return strings.HasPrefix(path, "/collections/") && strings.HasSuffix(path, "/import")
+	}
+	return false
+}
+
+// limitBody rejects bodies over limit bytes. Request bodies are streamed
+// so blob uploads and imports can be read in chunks; every other request
+// still has its body read in full, so this keeps the usual limit for them.
+func limitBody(limit int) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		if streamsBody(c) {
+			return c.Next()
+		}
+
+		n := c.Request().Header.ContentLength()
+		if n > limit {
+			return c.Status(fiber.StatusRequestEntityTooLarge).JSON(models.ErrorResponse{
+				Error: fiber.ErrRequestEntityTooLarge.Message,
+			})
+		}
+
+		// Chunked bodies have no length up front
+		if r := c.Context().RequestBodyStream(); n < 0 && r != nil {
+			body, err := io.ReadAll(io.LimitReader(r, int64(limit)+1))
+			if err != nil {
+				return c.Status(fiber.StatusBadRequest).JSON(models.ErrorResponse{
+					Error: err.Error(),
+				})
+			}
+			if len(body) > limit {
+				return c.Status(fiber.StatusRequestEntityTooLarge).JSON(models.ErrorResponse{
+					Error: fiber.ErrRequestEntityTooLarge.Message,
+				})
+			}
+			c.Request().SetBody(body)
+		}
+		return c.Next()
+	}
+}
+
+// blobResponse converts a blob manifest to its API form
+func blobResponse(collection, key string, m storage.BlobManifest) models.BlobResponse {
+	resp := models.BlobResponse{
+		Collection:  collection,
+		Key:         key,
+		Blob:        m.ID,
+		ContentType: m.ContentType,
+		Size:        m.Size,
+		ChunkSize:   m.ChunkSize,
+		Parts:       make([]models.BlobPart, len(m.Parts)),
+		CreatedAt:   m.CreatedAt,
+	}
+	for i, part := range m.Parts {
+		resp.Parts[i] = blobPart(part)
+	}
+	return resp
+}
+
+// uploadResponse converts an upload to its API form
+func uploadResponse(u storage.Upload) models.UploadResponse {
+	resp := models.UploadResponse{
+		UploadID:    u.ID,
+		Collection:  u.Collection,
+		Key:         u.Key,
+		ContentType: u.ContentType,
+		Parts:       make([]models.BlobPart, len(u.Parts)),
+		CreatedAt:   u.CreatedAt,
+		UpdatedAt:   u.UpdatedAt,
+		ExpiresAt:   u.UpdatedAt.Add(storage.UploadTTL),
+	}
+	for i, part := range u.Parts {
+		resp.Size += part.Size
+		resp.Parts[i] = blobPart(part)
+	}
+	return resp
+}
+
+// blobPart converts a blob part to its API form
+func blobPart(p storage.BlobPart) models.BlobPart {
+	return models.BlobPart{Number: p.Number, Size: p.Size, Chunks: p.Chunks, SHA256: p.SHA256}
+}
@@ -0,0 +1,176 @@
+package api
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+
+	"kiwi/internal/models"
+	"kiwi/internal/storage"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// BulkObjects handles applying many puts and deletes in one request.
+// The body is either a JSON array of items or NDJSON (one item per line).
+func (h *Handler) BulkObjects(c *fiber.Ctx) error {
+	collection := c.Query("collection", "default")
+
+	items, parseErrs, err := parseBulkBody(c.Body())
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(models.ErrorResponse{
+			Error: err.Error(),
+		})
+	}
+
+	if len(items) == 0 {
+		return c.Status(fiber.StatusBadRequest).JSON(models.ErrorResponse{
+			Error: "Bulk request contains no operations",
+		})
+	}
+
+	// Lines that failed to parse are reported but not sent to the store
+	ops := make([]storage.BulkOp, 0, len(items))
+	opIndex := make([]int, 0, len(items))
+	for i, item := range items {
+		if parseErrs[i] != nil {
+			continue
+		}
+		itemCollection := item.Collection
+		if itemCollection == "" {
+			itemCollection = collection
+		}
+		ops = append(ops, storage.BulkOp{
+			Op:         item.Op,
+			Collection: itemCollection,
+			Key:        item.Key,
+			Value:      item.Value,
+		})
+		opIndex = append(opIndex, i)
+	}
+
+	var opErrs []error
+	if len(ops) > 0 {
+		opErrs, err = h.store.Bulk(ops)
+		if err != nil {
+			return c.Status(fiber.StatusInternalServerError).JSON(models.ErrorResponse{
+				Error: err.Error(),
+			})
+		}
+	}
+
+	resp := models.BulkResponse{Items: make([]models.BulkItemResult, len(items))}
+	for i, item := range items {
+		resp.Items[i] = models.BulkItemResult{Op: item.Op, Key: item.Key, Status: fiber.StatusOK}
+		if parseErrs[i] != nil {
+			resp.Items[i].Status = fiber.StatusBadRequest
+			resp.Items[i].Error = "Invalid JSON format"
+		}
+	}
+	for j, opErr := range opErrs {
+		if opErr == nil {
+			continue
+		}
+		result := &resp.Items[opIndex[j]]
+		result.Status = bulkErrorStatus(opErr)
+		result.Error = opErr.Error()
+		result.Violations = schemaViolations(opErr)
+	}
+	for _, result := range resp.Items {
+		if result.Status != fiber.StatusOK {
+			resp.Errors = true
+			break
+		}
+	}
+
+	return c.Status(fiber.StatusOK).JSON(resp)
+}
+
+// MGetObjects handles retrieving many keys in one request
+func (h *Handler) MGetObjects(c *fiber.Ctx) error {
+	var req models.MGetRequest
+	if err := json.Unmarshal(c.Body(), &req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(models.ErrorResponse{
+			Error: "Invalid JSON format",
+		})
+	}
+
+	if len(req.Keys) == 0 {
+		return c.Status(fiber.StatusBadRequest).JSON(models.ErrorResponse{
+			Error: "Keys field is required",
+		})
+	}
+
+	collection := c.Query("collection", "default")
+
+	values, err := h.store.GetMany(collection, req.Keys)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(models.ErrorResponse{
+			Error: err.Error(),
+		})
+	}
+
+	resp := models.MGetResponse{Objects: make([]models.MGetItem, len(req.Keys))}
+	for i, key := range req.Keys {
+		value, found := values[key]
+		resp.Objects[i] = models.MGetItem{Key: key, Found: found, Value: value}
+		if found {
+			resp.Count++
+		}
+	}
+
+	return c.Status(fiber.StatusOK).JSON(resp)
+}
+
+// parseBulkBody decodes a JSON array or NDJSON body into bulk items.
+// For NDJSON, a line that fails to decode is reported in the returned
+// error slice at the same index instead of failing the whole request.
+func parseBulkBody(body []byte) ([]models.BulkItem, []error, error) {
+	trimmed := bytes.TrimSpace(body)
+	if len(trimmed) > 0 && trimmed[0] == '[' {
+		var items []models.BulkItem
+		if err := json.Unmarshal(trimmed, &items); err != nil {
+			return nil, nil, fiber.NewError(fiber.StatusBadRequest, "Invalid JSON format")
+		}
+		return items, make([]error, len(items)), nil
+	}
+
+	var items []models.BulkItem
+	var errs []error
+	scanner := bufio.NewScanner(bytes.NewReader(trimmed))
+	scanner.Buffer(make([]byte, 64*1024), len(trimmed)+1)
+	for scanner.Scan() {
+		line := bytes.TrimSpace(scanner.Bytes())
+		if len(line) == 0 {
+			continue
+		}
+		var item models.BulkItem
+		err := json.Unmarshal(line, &item)
+		items = append(items, item)
+		errs = append(errs, err)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, nil, err
+	}
+
+	return items, errs, nil
+}
+
+// bulkErrorStatus maps a per-item store error to an HTTP status code
+func bulkErrorStatus(err error) int {
+	switch err {
+	case storage.ErrKeyNotFound:
+		return fiber.StatusNotFound
+	case storage.ErrInvalidKey, storage.ErrInvalidOperation:
+		return fiber.StatusBadRequest
+	default:
+		switch {
+		case errors.Is(err, storage.ErrValueTooLarge):
+			return fiber.StatusRequestEntityTooLarge
+		case errors.Is(err, storage.ErrSchemaViolation):
+			return fiber.StatusUnprocessableEntity
+		}
+		return fiber.StatusInternalServerError
+	}
+}
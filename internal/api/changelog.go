@@ -0,0 +1,180 @@
+package api
+
+import (
+	"errors"
+	"strconv"
+	"time"
+
+	"kiwi/internal/models"
+	"kiwi/internal/storage"
+	"kiwi/internal/watch"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+const (
+	// defaultChangelogLimit is the number of entries returned when no limit is given
+	defaultChangelogLimit = 100
+	// maxChangelogWait caps how long a read waits for new entries
+	maxChangelogWait = 60 * time.Second
+)
+
+// ReadChangelog returns changelog entries after an offset. The offset comes
+// from ?after, or from the committed offset of ?consumer. With ?wait=<seconds>
+// the request blocks until new entries arrive or the wait expires.
+func (h *Handler) ReadChangelog(c *fiber.Ctx) error {
+	collection := c.Query("collection")
+	filter := watch.Filter{Collection: collection, Prefix: c.Query("prefix")}
+
+	var seq uint64
+	var err error
+	switch after, consumer := c.Query("after"), c.Query("consumer"); {
+	case after != "":
+		if seq, err = strconv.ParseUint(after, 10, 64); err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(models.ErrorResponse{
+				Error: "Invalid after offset",
+			})
+		}
+	case consumer != "":
+		if seq, err = h.store.ConsumerStart(consumer, collection); err != nil {
+			return c.Status(changelogErrorStatus(err)).JSON(models.ErrorResponse{
+				Error: err.Error(),
+			})
+		}
+	default:
+		first, err := h.store.FirstChangelogSeq()
+		if err != nil {
+			return c.Status(changelogErrorStatus(err)).JSON(models.ErrorResponse{
+				Error: err.Error(),
+			})
+		}
+		seq = first - 1
+	}
+
+	wait := time.Duration(c.QueryInt("wait", 0)) * time.Second
+	if wait > maxChangelogWait {
+		wait = maxChangelogWait
+	}
+	timer := time.NewTimer(wait)
+	defer timer.Stop()
+
+	for {
+		changed := h.store.WaitForChanges()
+
+		page, err := h.store.ReadChangelog(seq, filter, c.QueryInt("limit", defaultChangelogLimit))
+		if err != nil {
+			return c.Status(changelogErrorStatus(err)).JSON(models.ErrorResponse{
+				Error: err.Error(),
+			})
+		}
+
+		// Keep waiting only while the whole log has been read without a match
+		if len(page.Events) == 0 && page.NextSeq >= page.LastSeq && wait > 0 {
+			seq = page.NextSeq
+			select {
+			case <-changed:
+				continue
+			case <-timer.C:
+			case <-h.closing:
+			}
+		}
+
+		first, _ := h.store.FirstChangelogSeq()
+		resp := models.ChangelogResponse{
+			Count:    len(page.Events),
+			Events:   make([]models.WatchEvent, len(page.Events)),
+			NextSeq:  page.NextSeq,
+			FirstSeq: first,
+			LastSeq:  page.LastSeq,
+		}
+		for i := range page.Events {
+			resp.Events[i] = watchEvent(&page.Events[i])
+		}
+		return c.Status(fiber.StatusOK).JSON(resp)
+	}
+}
+
+// ListConsumers handles listing changelog consumers and their offsets
+func (h *Handler) ListConsumers(c *fiber.Ctx) error {
+	offsets, err := h.store.ListConsumers()
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(models.ErrorResponse{
+			Error: err.Error(),
+		})
+	}
+
+	resp := models.ConsumerListResponse{Count: len(offsets), Consumers: make([]models.ConsumerOffset, len(offsets))}
+	for i, co := range offsets {
+		resp.Consumers[i] = consumerOffset(co)
+	}
+	return c.Status(fiber.StatusOK).JSON(resp)
+}
+
+// GetConsumer handles retrieving a consumer's committed offset
+func (h *Handler) GetConsumer(c *fiber.Ctx) error {
+	co, err := h.store.GetOffset(c.Params("name"), c.Query("collection"))
+	if err != nil {
+		return c.Status(changelogErrorStatus(err)).JSON(models.ErrorResponse{
+			Error: err.Error(),
+		})
+	}
+	return c.Status(fiber.StatusOK).JSON(consumerOffset(co))
+}
+
+// CommitOffset handles storing a consumer's offset
+func (h *Handler) CommitOffset(c *fiber.Ctx) error {
+	var req models.OffsetRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(models.ErrorResponse{
+			Error: "Invalid JSON format",
+		})
+	}
+
+	co, err := h.store.CommitOffset(c.Params("name"), req.Collection, req.Offset)
+	if err != nil {
+		return c.Status(changelogErrorStatus(err)).JSON(models.ErrorResponse{
+			Error: err.Error(),
+		})
+	}
+	return c.Status(fiber.StatusOK).JSON(consumerOffset(co))
+}
+
+// DeleteConsumer handles removing a consumer's offset
+func (h *Handler) DeleteConsumer(c *fiber.Ctx) error {
+	name := c.Params("name")
+	if err := h.store.DeleteConsumer(name, c.Query("collection")); err != nil {
+		return c.Status(changelogErrorStatus(err)).JSON(models.ErrorResponse{
+			Error: err.Error(),
+		})
+	}
+	return c.Status(fiber.StatusOK).JSON(models.DeleteResponse{
+		Message: "Consumer deleted successfully",
+		Key:     name,
+	})
+}
+
+// consumerOffset converts a stored offset to its API representation
+func consumerOffset(co storage.ConsumerOffset) models.ConsumerOffset {
+	return models.ConsumerOffset{
+		Consumer:   co.Consumer,
+		Collection: co.Collection,
+		Offset:     co.Offset,
+		UpdatedAt:  co.UpdatedAt,
+	}
+}
+
+// changelogErrorStatus maps changelog errors to HTTP status codes
+func changelogErrorStatus(err error) int {
+	switch {
+	case errors.Is(err, storage.ErrChangelogDisabled):
+		return fiber.StatusNotFound
+	case errors.Is(err, storage.ErrConsumerNotFound):
+		return fiber.StatusNotFound
+	case errors.Is(err, storage.ErrOffsetExpired):
+		return fiber.StatusGone
+	case errors.Is(err, storage.ErrOffsetAhead), errors.Is(err, storage.ErrInvalidKey):
+		return fiber.StatusBadRequest
+	default:
+		return fiber.StatusInternalServerError
+	}
+}
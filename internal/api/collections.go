@@ -0,0 +1,269 @@
+package api
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"time"
+
+	"kiwi/internal/models"
+	"kiwi/internal/storage"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// ListCollections handles listing collections with their key counts and sizes
+func (h *Handler) ListCollections(c *fiber.Ctx) error {
+	infos, err := h.store.DescribeCollections()
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(models.ErrorResponse{
+			Error: err.Error(),
+		})
+	}
+
+	resp := models.CollectionListResponse{Collections: make([]models.CollectionInfo, len(infos))}
+	for i, info := range infos {
+		resp.Collections[i] = collectionInfo(info)
+	}
+	resp.Count = len(resp.Collections)
+
+	return c.Status(fiber.StatusOK).JSON(resp)
+}
+
+// CreateCollection handles registering a new, empty collection with its
+// settings
+func (h *Handler) CreateCollection(c *fiber.Ctx) error {
+	var req models.CollectionRequest
+	if err := json.Unmarshal(c.Body(), &req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(models.ErrorResponse{
+			Error: "Invalid JSON format",
+		})
+	}
+
+	if req.Name == "" {
+		return c.Status(fiber.StatusBadRequest).JSON(models.ErrorResponse{
+			Error: "Name field is required",
+		})
+	}
+
+	meta := storage.CollectionMeta{Collection: req.Name, Replication: storage.ReplicationSync}
+	if err := applySettings(&meta, req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(models.ErrorResponse{
+			Error: err.Error(),
+		})
+	}
+
+	meta, err := h.store.CreateCollection(meta)
+	if err != nil {
+		return c.Status(collectionErrorStatus(err)).JSON(models.ErrorResponse{
+			Error: err.Error(),
+		})
+	}
+
+	return c.Status(fiber.StatusCreated).JSON(collectionSettings(meta))
+}
+
+// UpdateCollection handles changing the settings of a collection. Only the
+// fields present in the body are changed.
+func (h *Handler) UpdateCollection(c *fiber.Ctx) error {
+	var req models.CollectionRequest
+	if err := json.Unmarshal(c.Body(), &req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(models.ErrorResponse{
+			Error: "Invalid JSON format",
+		})
+	}
+
+	meta, err := h.store.UpdateCollection(c.Params("name"), func(meta *storage.CollectionMeta) error {
+		return applySettings(meta, req)
+	})
+	if err != nil {
+		return c.Status(collectionErrorStatus(err)).JSON(models.ErrorResponse{
+			Error: err.Error(),
+		})
+	}
+
+	return c.Status(fiber.StatusOK).JSON(collectionSettings(meta))
+}
+
+// GetCollection handles describing a single collection
+func (h *Handler) GetCollection(c *fiber.Ctx) error {
+	info, err := h.store.DescribeCollection(c.Params("name"))
+	if err != nil {
+		return c.Status(collectionErrorStatus(err)).JSON(models.ErrorResponse{
+			Error: err.Error(),
+		})
+	}
+
+	return c.Status(fiber.StatusOK).JSON(collectionInfo(info))
+}
+
+// DropCollection handles removing a collection and its indexes
+func (h *Handler) DropCollection(c *fiber.Ctx) error {
+	name := c.Params("name")
+
+	n, err := h.store.DropCollection(name)
+	if err != nil {
+		return c.Status(collectionErrorStatus(err)).JSON(models.ErrorResponse{
+			Error: err.Error(),
+		})
+	}
+
+	return c.Status(fiber.StatusOK).JSON(models.CollectionResponse{
+		Message:    "Collection dropped successfully",
+		Collection: name,
+		Keys:       n,
+	})
+}
+
+// TruncateCollection handles removing every key of a collection while
+// keeping its indexes
+func (h *Handler) TruncateCollection(c *fiber.Ctx) error {
+	name := c.Params("name")
+
+	n, err := h.store.TruncateCollection(name)
+	if err != nil {
+		return c.Status(collectionErrorStatus(err)).JSON(models.ErrorResponse{
+			Error: err.Error(),
+		})
+	}
+
+	return c.Status(fiber.StatusOK).JSON(models.CollectionResponse{
+		Message:    "Collection truncated successfully",
+		Collection: name,
+		Keys:       n,
+	})
+}
+
+// RenameCollection handles moving a collection to a new, empty name
+func (h *Handler) RenameCollection(c *fiber.Ctx) error {
+	return h.moveCollection(c, true)
+}
+
+// CopyCollection handles copying a collection to a new, empty name
+func (h *Handler) CopyCollection(c *fiber.Ctx) error {
+	return h.moveCollection(c, false)
+}
+
+// moveCollection implements RenameCollection and CopyCollection
+func (h *Handler) moveCollection(c *fiber.Ctx, rename bool) error {
+	var req models.CollectionTargetRequest
+	if err := json.Unmarshal(c.Body(), &req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(models.ErrorResponse{
+			Error: "Invalid JSON format",
+		})
+	}
+
+	if req.To == "" {
+		return c.Status(fiber.StatusBadRequest).JSON(models.ErrorResponse{
+			Error: "To field is required",
+		})
+	}
+
+	name := c.Params("name")
+	move, message := h.store.CopyCollection, "Collection copied successfully"
+	if rename {
+		move, message = h.store.RenameCollection, "Collection renamed successfully"
+	}
+
+	n, err := move(name, req.To)
+	if err != nil {
+		return c.Status(collectionErrorStatus(err)).JSON(models.ErrorResponse{
+			Error: err.Error(),
+		})
+	}
+
+	return c.Status(fiber.StatusOK).JSON(models.CollectionResponse{
+		Message:    message,
+		Collection: name,
+		Target:     req.To,
+		Keys:       n,
+	})
+}
+
+// applySettings copies the settings present in req onto meta
+func applySettings(meta *storage.CollectionMeta, req models.CollectionRequest) error {
+	if req.Owner != nil {
+		meta.Owner = *req.Owner
+	}
+	if req.DefaultTTLSeconds != nil {
+		if *req.DefaultTTLSeconds > math.MaxInt64/int64(time.Second) {
+			return fmt.Errorf("%w: default TTL is too long", storage.ErrInvalidSettings)
+		}
+		meta.DefaultTTL = time.Duration(*req.DefaultTTLSeconds) * time.Second
+	}
+	if req.MaxValueSize != nil {
+		meta.MaxValueSize = *req.MaxValueSize
+	}
+	if req.Schema != nil {
+		meta.Schema = req.Schema
+		if bytes.Equal(bytes.TrimSpace(req.Schema), []byte("null")) {
+			meta.Schema = nil
+		}
+	}
+	if req.Replication != nil {
+		meta.Replication = *req.Replication
+	}
+	if req.Compression != nil {
+		meta.Compression = *req.Compression
+	}
+	if req.Cache != nil {
+		meta.Cache = *req.Cache
+	}
+	return nil
+}
+
+// collectionInfo converts a storage collection description to its API form
+func collectionInfo(info storage.CollectionInfo) models.CollectionInfo {
+	out := models.CollectionInfo{
+		Name:        info.Name,
+		Keys:        info.Keys,
+		SizeBytes:   info.Size,
+		Indexes:     info.Indexes,
+		TextIndexed: info.TextIndexed,
+	}
+	if info.Meta != nil {
+		settings := collectionSettings(*info.Meta)
+		out.Settings = &settings
+	}
+	return out
+}
+
+// collectionSettings converts storage collection settings to their API form
+func collectionSettings(meta storage.CollectionMeta) models.CollectionSettings {
+	compression := meta.Compression
+	if compression == "" {
+		compression = storage.CompressionNone
+	}
+	cache := meta.Cache
+	if cache == "" {
+		cache = storage.CacheRead
+	}
+	return models.CollectionSettings{
+		Name:              meta.Collection,
+		CreatedAt:         meta.CreatedAt,
+		Owner:             meta.Owner,
+		DefaultTTLSeconds: int64(meta.DefaultTTL / time.Second),
+		MaxValueSize:      meta.MaxValueSize,
+		Schema:            meta.Schema,
+		SchemaVersion:     meta.SchemaVersion,
+		Replication:       meta.Replication,
+		Compression:       compression,
+		Cache:             cache,
+	}
+}
+
+// collectionErrorStatus maps collection errors to HTTP status codes
+func collectionErrorStatus(err error) int {
+	switch {
+	case errors.Is(err, storage.ErrCollectionNotFound), errors.Is(err, storage.ErrNoSchema):
+		return fiber.StatusNotFound
+	case errors.Is(err, storage.ErrCollectionExists), errors.Is(err, storage.ErrReplicationMismatch):
+		return fiber.StatusConflict
+	case errors.Is(err, storage.ErrInvalidCollection), errors.Is(err, storage.ErrInvalidSettings):
+		return fiber.StatusBadRequest
+	default:
+		return fiber.StatusInternalServerError
+	}
+}
@@ -1,9 +1,16 @@
 package api
 
 import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"strings"
+
 	"kiwi/internal/config"
 	"kiwi/internal/models"
+	"kiwi/internal/patch"
 	"kiwi/internal/storage"
+	"kiwi/internal/webhook"
 
 	"github.com/gofiber/fiber/v2"
 )
@@ -12,11 +19,15 @@ import (
 type Handler struct {
 	store  *storage.ReplicatedStore
 	config *config.Config
+	hooks  *webhook.Dispatcher // nil on slaves
+
+	// closing is closed on shutdown to end long-lived watch streams
+	closing chan struct{}
 }
 
 // NewHandler creates a new handler instance
-func NewHandler(store *storage.ReplicatedStore, cfg *config.Config) *Handler {
-	return &Handler{store: store, config: cfg}
+func NewHandler(store *storage.ReplicatedStore, cfg *config.Config, hooks *webhook.Dispatcher) *Handler {
+	return &Handler{store: store, config: cfg, hooks: hooks, closing: make(chan struct{})}
 }
 
 // HealthCheck handles health check requests
@@ -62,9 +73,7 @@ func (h *Handler) PutObject(c *fiber.Ctx) error {
 	collection := c.Query("collection", "default")
 
 	if err := h.store.Put(collection, req.Key, req.Value); err != nil {
-		return c.Status(fiber.StatusInternalServerError).JSON(models.ErrorResponse{
-			Error: err.Error(),
-		})
+		return putError(c, err)
 	}
 
 	return c.Status(fiber.StatusOK).JSON(models.PutResponse{
@@ -73,6 +82,63 @@ func (h *Handler) PutObject(c *fiber.Ctx) error {
 	})
 }
 
+// PutRawObject handles storing the request body as the value of a key.
+// application/json bodies are stored as JSON documents; any other body is
+// kept byte for byte together with its Content-Type.
+func (h *Handler) PutRawObject(c *fiber.Ctx) error {
+	key := c.Params("key")
+	collection := c.Query("collection", "default")
+	contentType := c.Get(fiber.HeaderContentType)
+
+	var err error
+	if mediaType(contentType) == fiber.MIMEApplicationJSON {
+		var value interface{}
+		if err := json.Unmarshal(c.Body(), &value); err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(models.ErrorResponse{
+				Error: "Invalid JSON format",
+			})
+		}
+		err = h.store.Put(collection, key, value)
+	} else {
+		err = h.store.PutRaw(collection, key, contentType, c.Body())
+	}
+	if err != nil {
+		return putError(c, err)
+	}
+
+	return c.Status(fiber.StatusOK).JSON(models.PutResponse{
+		Message: "Object stored successfully",
+		Key:     key,
+	})
+}
+
+// putError writes the response for a failed put
+func putError(c *fiber.Ctx, err error) error {
+	if violations := schemaViolations(err); violations != nil {
+		return c.Status(fiber.StatusUnprocessableEntity).JSON(models.SchemaErrorResponse{
+			Error:      err.Error(),
+			Violations: violations,
+		})
+	}
+	if errors.Is(err, storage.ErrValueTooLarge) {
+		return c.Status(fiber.StatusRequestEntityTooLarge).JSON(models.ErrorResponse{
+			Error: err.Error(),
+		})
+	}
+	return c.Status(fiber.StatusInternalServerError).JSON(models.ErrorResponse{
+		Error: err.Error(),
+	})
+}
+
+// mediaType returns the media type of a Content-Type header, without
+// parameters
+func mediaType(contentType string) string {
+	if i := strings.IndexByte(contentType, ';'); i >= 0 {
+		contentType = contentType[:i]
+	}
+	return strings.ToLower(strings.TrimSpace(contentType))
+}
+
 // GetObject handles retrieving a value by key
 func (h *Handler) GetObject(c *fiber.Ctx) error {
 	key := c.Params("key")
@@ -90,16 +156,196 @@ func (h *Handler) GetObject(c *fiber.Ctx) error {
 		})
 	}
 
+	// Raw values and blobs are returned as they were stored
+	switch v := value.(type) {
+	case *storage.RawValue:
+		c.Set(fiber.HeaderContentType, v.ContentType)
+		return c.Status(fiber.StatusOK).Send(v.Data)
+	case *storage.BlobManifest:
+		return h.serveBlob(c, collection, key)
+	}
+
 	return c.Status(fiber.StatusOK).JSON(models.GetResponse{
 		Key:   key,
 		Value: value,
 	})
 }
 
+// PatchObject handles partial updates of a stored value.
+//
+// The patch format is chosen by Content-Type:
+//   - application/json-patch+json:  RFC 6902 JSON Patch
+//   - application/merge-patch+json: RFC 7396 JSON Merge Patch
+//   - application/json:             JSON Patch if the body is an array, Merge Patch otherwise
+func (h *Handler) PatchObject(c *fiber.Ctx) error {
+	key := c.Params("key")
+	collection := c.Query("collection", "default")
+	body := c.Body()
+
+	apply := patch.ApplyMergePatch
+	contentType := strings.ToLower(c.Get(fiber.HeaderContentType))
+	switch {
+	case strings.HasPrefix(contentType, "application/json-patch+json"):
+		apply = patch.ApplyJSONPatch
+	case strings.HasPrefix(contentType, "application/merge-patch+json"):
+	default:
+		if trimmed := bytes.TrimSpace(body); len(trimmed) > 0 && trimmed[0] == '[' {
+			apply = patch.ApplyJSONPatch
+		}
+	}
+
+	value, err := h.store.Update(collection, key, func(current interface{}) (interface{}, error) {
+		// A patch would replace raw bytes or a blob manifest with JSON
+		switch current.(type) {
+		case *storage.RawValue, *storage.BlobManifest:
+			return nil, storage.ErrNotJSON
+		}
+		return apply(current, body)
+	})
+	if err != nil {
+		if violations := schemaViolations(err); violations != nil {
+			return c.Status(fiber.StatusUnprocessableEntity).JSON(models.SchemaErrorResponse{
+				Error:      err.Error(),
+				Violations: violations,
+			})
+		}
+		status := fiber.StatusInternalServerError
+		switch {
+		case err == storage.ErrKeyNotFound:
+			return c.Status(fiber.StatusNotFound).JSON(models.ErrorResponse{
+				Error: "Key not found",
+			})
+		case errors.Is(err, patch.ErrInvalidPatch):
+			status = fiber.StatusBadRequest
+		case errors.Is(err, patch.ErrTestFailed), errors.Is(err, storage.ErrNotJSON):
+			status = fiber.StatusConflict
+		case errors.Is(err, patch.ErrPathNotFound):
+			status = fiber.StatusUnprocessableEntity
+		case errors.Is(err, storage.ErrValueTooLarge):
+			status = fiber.StatusRequestEntityTooLarge
+		}
+		return c.Status(status).JSON(models.ErrorResponse{
+			Error: err.Error(),
+		})
+	}
+
+	return c.Status(fiber.StatusOK).JSON(models.GetResponse{
+		Key:   key,
+		Value: value,
+	})
+}
+
+// IncrObject handles atomically incrementing a numeric value (by 1 unless "by" is given)
+func (h *Handler) IncrObject(c *fiber.Ctx) error {
+	return h.numericOp(c, 1, true)
+}
+
+// DecrObject handles atomically decrementing a numeric value (by 1 unless "by" is given)
+func (h *Handler) DecrObject(c *fiber.Ctx) error {
+	return h.numericOp(c, -1, true)
+}
+
+// AddObject handles atomically adding an arbitrary amount to a numeric value
+func (h *Handler) AddObject(c *fiber.Ctx) error {
+	return h.numericOp(c, 1, false)
+}
+
+// numericOp applies sign * by to a numeric value or document field.
+// When byOptional is false, the request body must carry "by".
+func (h *Handler) numericOp(c *fiber.Ctx, sign int, byOptional bool) error {
+	key := c.Params("key")
+	collection := c.Query("collection", "default")
+
+	var req models.NumericRequest
+	if body := bytes.TrimSpace(c.Body()); len(body) > 0 {
+		if err := json.Unmarshal(body, &req); err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(models.ErrorResponse{
+				Error: "Invalid JSON format",
+			})
+		}
+	}
+
+	by := json.Number("1")
+	if req.By != nil {
+		by = *req.By
+	} else if !byOptional {
+		return c.Status(fiber.StatusBadRequest).JSON(models.ErrorResponse{
+			Error: "By field is required",
+		})
+	}
+
+	field := req.Field
+	if field == "" {
+		field = c.Query("field")
+	}
+
+	if sign < 0 {
+		by = negate(by)
+	}
+
+	value, err := h.store.Increment(collection, key, field, by)
+	if err != nil {
+		if err == storage.ErrInvalidKey {
+			return c.Status(fiber.StatusBadRequest).JSON(models.ErrorResponse{
+				Error: err.Error(),
+			})
+		}
+		if errors.Is(err, storage.ErrNotNumeric) {
+			return c.Status(fiber.StatusConflict).JSON(models.ErrorResponse{
+				Error: err.Error(),
+			})
+		}
+		if violations := schemaViolations(err); violations != nil {
+			return c.Status(fiber.StatusUnprocessableEntity).JSON(models.SchemaErrorResponse{
+				Error:      err.Error(),
+				Violations: violations,
+			})
+		}
+		if errors.Is(err, storage.ErrValueTooLarge) {
+			return c.Status(fiber.StatusRequestEntityTooLarge).JSON(models.ErrorResponse{
+				Error: err.Error(),
+			})
+		}
+		return c.Status(fiber.StatusInternalServerError).JSON(models.ErrorResponse{
+			Error: err.Error(),
+		})
+	}
+
+	return c.Status(fiber.StatusOK).JSON(models.NumericResponse{
+		Key:   key,
+		Field: field,
+		Value: value,
+	})
+}
+
+// negate returns -n
+func negate(n json.Number) json.Number {
+	if s, ok := strings.CutPrefix(string(n), "-"); ok {
+		return json.Number(s)
+	}
+	return "-" + n
+}
+
 // ListObjects handles listing all objects in a collection
+//
+// When any of filter, sort, fields, offset or limit is given, the request
+// is served as a query and returns an ordered list instead (see QueryObjects).
 func (h *Handler) ListObjects(c *fiber.Ctx) error {
 	collection := c.Query("collection", "default")
 
+	for _, param := range []string{"filter", "sort", "fields", "offset", "limit"} {
+		if c.Query(param) != "" {
+			return h.runQuery(c, models.QueryRequest{
+				Collection: collection,
+				Filter:     c.Query("filter"),
+				Sort:       splitList(c.Query("sort")),
+				Fields:     splitList(c.Query("fields")),
+				Offset:     c.QueryInt("offset", 0),
+				Limit:      c.QueryInt("limit", 0),
+			})
+		}
+	}
+
 	objects, err := h.store.List(collection)
 	if err != nil {
 		return c.Status(fiber.StatusInternalServerError).JSON(models.ErrorResponse{
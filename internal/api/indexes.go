@@ -0,0 +1,152 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+
+	"kiwi/internal/models"
+	"kiwi/internal/storage"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// ListIndexes handles listing secondary indexes, optionally for one collection
+func (h *Handler) ListIndexes(c *fiber.Ctx) error {
+	infos, err := h.store.ListIndexes(c.Query("collection"))
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(models.ErrorResponse{
+			Error: err.Error(),
+		})
+	}
+
+	resp := models.IndexListResponse{Indexes: make([]models.IndexInfo, len(infos))}
+	for i, info := range infos {
+		resp.Indexes[i] = models.IndexInfo{
+			Collection: info.Collection,
+			Field:      info.Field,
+			CreatedAt:  info.CreatedAt,
+		}
+	}
+	resp.Count = len(resp.Indexes)
+
+	return c.Status(fiber.StatusOK).JSON(resp)
+}
+
+// CreateIndex handles creating a secondary index on a JSON field
+func (h *Handler) CreateIndex(c *fiber.Ctx) error {
+	var req models.IndexRequest
+	if err := json.Unmarshal(c.Body(), &req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(models.ErrorResponse{
+			Error: "Invalid JSON format",
+		})
+	}
+
+	if req.Field == "" {
+		return c.Status(fiber.StatusBadRequest).JSON(models.ErrorResponse{
+			Error: "Field is required",
+		})
+	}
+
+	if req.Collection == "" {
+		req.Collection = "default"
+	}
+
+	if err := h.store.CreateIndex(req.Collection, req.Field); err != nil {
+		return c.Status(indexErrorStatus(err)).JSON(models.ErrorResponse{
+			Error: err.Error(),
+		})
+	}
+
+	return c.Status(fiber.StatusCreated).JSON(models.IndexResponse{
+		Message:    "Index created successfully",
+		Collection: req.Collection,
+		Field:      req.Field,
+	})
+}
+
+// DropIndex handles removing a secondary index
+func (h *Handler) DropIndex(c *fiber.Ctx) error {
+	field := c.Params("field")
+	collection := c.Query("collection", "default")
+
+	if err := h.store.DropIndex(collection, field); err != nil {
+		return c.Status(indexErrorStatus(err)).JSON(models.ErrorResponse{
+			Error: err.Error(),
+		})
+	}
+
+	return c.Status(fiber.StatusOK).JSON(models.IndexResponse{
+		Message:    "Index dropped successfully",
+		Collection: collection,
+		Field:      field,
+	})
+}
+
+// QueryIndex handles looking up documents by an indexed field.
+//
+// Use eq for an exact match, or any of gt/gte/lt/lte for a range.
+// Values are parsed as JSON when possible (30, true, null, "30") and
+// treated as plain strings otherwise.
+func (h *Handler) QueryIndex(c *fiber.Ctx) error {
+	collection := c.Query("collection", "default")
+	field := c.Query("field")
+	if field == "" {
+		return c.Status(fiber.StatusBadRequest).JSON(models.ErrorResponse{
+			Error: "Field is required",
+		})
+	}
+
+	q := storage.IndexQuery{Field: field, Limit: c.QueryInt("limit", 0)}
+	if eq := c.Query("eq"); eq != "" {
+		q = storage.Equal(field, parseQueryValue(eq))
+		q.Limit = c.QueryInt("limit", 0)
+	} else {
+		if v := c.Query("gte"); v != "" {
+			q.Lower = &storage.IndexBound{Value: parseQueryValue(v), Inclusive: true}
+		} else if v := c.Query("gt"); v != "" {
+			q.Lower = &storage.IndexBound{Value: parseQueryValue(v)}
+		}
+		if v := c.Query("lte"); v != "" {
+			q.Upper = &storage.IndexBound{Value: parseQueryValue(v), Inclusive: true}
+		} else if v := c.Query("lt"); v != "" {
+			q.Upper = &storage.IndexBound{Value: parseQueryValue(v)}
+		}
+	}
+
+	results, err := h.store.QueryIndex(collection, q)
+	if err != nil {
+		return c.Status(indexErrorStatus(err)).JSON(models.ErrorResponse{
+			Error: err.Error(),
+		})
+	}
+
+	resp := models.QueryResponse{Count: len(results), Objects: make([]models.ObjectItem, len(results))}
+	for i, kv := range results {
+		resp.Objects[i] = models.ObjectItem{Key: kv.Key, Value: kv.Value}
+	}
+
+	return c.Status(fiber.StatusOK).JSON(resp)
+}
+
+// parseQueryValue decodes a query-string value as JSON, falling back to a string
+func parseQueryValue(raw string) interface{} {
+	var value interface{}
+	if err := json.Unmarshal([]byte(raw), &value); err != nil {
+		return raw
+	}
+	return value
+}
+
+// indexErrorStatus maps index errors to HTTP status codes
+func indexErrorStatus(err error) int {
+	switch {
+	case errors.Is(err, storage.ErrIndexExists):
+		return fiber.StatusConflict
+	case errors.Is(err, storage.ErrIndexNotFound):
+		return fiber.StatusNotFound
+	case errors.Is(err, storage.ErrInvalidField):
+		return fiber.StatusBadRequest
+	default:
+		return fiber.StatusInternalServerError
+	}
+}
@@ -0,0 +1,163 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"strings"
+
+	"kiwi/internal/models"
+	"kiwi/internal/query"
+	"kiwi/internal/storage"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// QueryObjects handles filtering, sorting and projecting documents of a collection
+func (h *Handler) QueryObjects(c *fiber.Ctx) error {
+	var req models.QueryRequest
+	if err := json.Unmarshal(c.Body(), &req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(models.ErrorResponse{
+			Error: "Invalid JSON format",
+		})
+	}
+
+	if req.Collection == "" {
+		req.Collection = "default"
+	}
+
+	return h.runQuery(c, req)
+}
+
+// runQuery parses and executes a query request
+func (h *Handler) runQuery(c *fiber.Ctx, req models.QueryRequest) error {
+	if req.Offset < 0 || req.Limit < 0 {
+		return c.Status(fiber.StatusBadRequest).JSON(models.ErrorResponse{
+			Error: "Offset and limit must not be negative",
+		})
+	}
+
+	filter, err := query.Parse(req.Filter)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(models.ErrorResponse{
+			Error: err.Error(),
+		})
+	}
+
+	results, err := query.Execute(h.store, req.Collection, query.Request{
+		Filter: filter,
+		Sort:   query.ParseSort(req.Sort),
+		Fields: req.Fields,
+		Offset: req.Offset,
+		Limit:  req.Limit,
+	})
+	if err != nil {
+		status := fiber.StatusInternalServerError
+		if errors.Is(err, storage.ErrInvalidField) {
+			status = fiber.StatusBadRequest
+		}
+		return c.Status(status).JSON(models.ErrorResponse{
+			Error: err.Error(),
+		})
+	}
+
+	resp := models.QueryResponse{Count: len(results), Objects: make([]models.ObjectItem, len(results))}
+	for i, kv := range results {
+		resp.Objects[i] = models.ObjectItem{Key: kv.Key, Value: kv.Value}
+	}
+
+	return c.Status(fiber.StatusOK).JSON(resp)
+}
+
+// AggregateObjects handles computing count/sum/min/max/avg, optionally grouped
+func (h *Handler) AggregateObjects(c *fiber.Ctx) error {
+	var req models.AggregateRequest
+	if err := json.Unmarshal(c.Body(), &req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(models.ErrorResponse{
+			Error: "Invalid JSON format",
+		})
+	}
+
+	if req.Collection == "" {
+		req.Collection = "default"
+	}
+	if len(req.Metrics) == 0 {
+		req.Metrics = []models.AggregateMetric{{Op: query.AggCount}}
+	}
+
+	metrics := make([]query.Metric, len(req.Metrics))
+	plainCount := req.Filter == "" && len(req.GroupBy) == 0
+	for i, m := range req.Metrics {
+		metrics[i] = query.Metric{Op: m.Op, Field: m.Field, As: m.As}
+		plainCount = plainCount && m.Op == query.AggCount && m.Field == ""
+	}
+	if err := query.ValidateMetrics(metrics); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(models.ErrorResponse{
+			Error: err.Error(),
+		})
+	}
+
+	// Counting a whole collection uses the maintained key count
+	if plainCount {
+		count, err := h.store.Count(req.Collection)
+		if err != nil {
+			return c.Status(fiber.StatusInternalServerError).JSON(models.ErrorResponse{
+				Error: err.Error(),
+			})
+		}
+		values := make(map[string]interface{}, len(metrics))
+		for _, m := range metrics {
+			values[m.Name()] = count
+		}
+		return c.Status(fiber.StatusOK).JSON(models.AggregateResponse{
+			Count:  1,
+			Groups: []models.AggregateGroup{{Values: values}},
+		})
+	}
+
+	filter, err := query.Parse(req.Filter)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(models.ErrorResponse{
+			Error: err.Error(),
+		})
+	}
+
+	groups, err := query.Aggregate(h.store, req.Collection, query.AggregateRequest{
+		Filter:  filter,
+		GroupBy: req.GroupBy,
+		Metrics: metrics,
+	})
+	if err != nil {
+		status := fiber.StatusInternalServerError
+		if errors.Is(err, storage.ErrInvalidField) {
+			status = fiber.StatusBadRequest
+		}
+		return c.Status(status).JSON(models.ErrorResponse{
+			Error: err.Error(),
+		})
+	}
+
+	resp := models.AggregateResponse{Count: len(groups), Groups: make([]models.AggregateGroup, len(groups))}
+	for i, g := range groups {
+		resp.Groups[i] = models.AggregateGroup{Values: g.Values}
+		if len(req.GroupBy) > 0 {
+			resp.Groups[i].Key = g.Key
+		}
+	}
+
+	return c.Status(fiber.StatusOK).JSON(resp)
+}
+
+// splitList splits a comma-separated query parameter
+func splitList(raw string) []string {
+	if raw == "" {
+		return nil
+	}
+	parts := strings.Split(raw, ",")
+	out := make([]string, 0, len(parts))
+	for _, p := range parts {
+		if p = strings.TrimSpace(p); p != "" {
+			out = append(out, p)
+		}
+	}
+	return out
+}
@@ -0,0 +1,156 @@
+package api
+
+import (
+	"bytes"
+	"errors"
+
+	"kiwi/internal/models"
+	"kiwi/internal/schema"
+	"kiwi/internal/storage"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// defaultSchemaCheckLimit is the number of violating documents listed by a
+// dry run when no limit is given
+const defaultSchemaCheckLimit = 100
+
+// GetSchema handles returning the current schema of a collection
+func (h *Handler) GetSchema(c *fiber.Ctx) error {
+	meta, ok := h.store.CollectionMeta(c.Params("name"))
+	if !ok || len(meta.Schema) == 0 {
+		return c.Status(fiber.StatusNotFound).JSON(models.ErrorResponse{
+			Error: storage.ErrNoSchema.Error(),
+		})
+	}
+
+	return c.Status(fiber.StatusOK).JSON(schemaResponse(meta))
+}
+
+// PutSchema handles replacing the schema of a collection. The body is the
+// JSON Schema itself. With ?dry_run=true the schema is not applied; the
+// response lists the stored documents that would not match it instead.
+func (h *Handler) PutSchema(c *fiber.Ctx) error {
+	name := c.Params("name")
+	body := bytes.TrimSpace(c.Body())
+	if len(body) == 0 || bytes.Equal(body, []byte("null")) {
+		return c.Status(fiber.StatusBadRequest).JSON(models.ErrorResponse{
+			Error: "Schema is required",
+		})
+	}
+
+	if c.QueryBool("dry_run") {
+		limit := c.QueryInt("limit", defaultSchemaCheckLimit)
+		if limit < 0 {
+			return c.Status(fiber.StatusBadRequest).JSON(models.ErrorResponse{
+				Error: "Limit must not be negative",
+			})
+		}
+		if _, err := h.store.DescribeCollection(name); err != nil {
+			return c.Status(collectionErrorStatus(err)).JSON(models.ErrorResponse{
+				Error: err.Error(),
+			})
+		}
+
+		report, err := h.store.CheckSchema(name, body, limit)
+		if err != nil {
+			return c.Status(collectionErrorStatus(err)).JSON(models.ErrorResponse{
+				Error: err.Error(),
+			})
+		}
+
+		resp := models.SchemaCheckResponse{
+			Valid:     report.Violating == 0,
+			Checked:   report.Checked,
+			Violating: report.Violating,
+			Documents: make([]models.DocumentViolations, len(report.Documents)),
+		}
+		for i, doc := range report.Documents {
+			resp.Documents[i] = models.DocumentViolations{Key: doc.Key, Violations: violations(doc.Violations)}
+		}
+		return c.Status(fiber.StatusOK).JSON(resp)
+	}
+
+	meta, err := h.store.UpdateCollection(name, func(meta *storage.CollectionMeta) error {
+		meta.Schema = append([]byte(nil), body...)
+		return nil
+	})
+	if err != nil {
+		return c.Status(collectionErrorStatus(err)).JSON(models.ErrorResponse{
+			Error: err.Error(),
+		})
+	}
+
+	return c.Status(fiber.StatusOK).JSON(schemaResponse(meta))
+}
+
+// DeleteSchema handles removing the schema of a collection. Its history is
+// kept.
+func (h *Handler) DeleteSchema(c *fiber.Ctx) error {
+	meta, err := h.store.UpdateCollection(c.Params("name"), func(meta *storage.CollectionMeta) error {
+		if len(meta.Schema) == 0 {
+			return storage.ErrNoSchema
+		}
+		meta.Schema = nil
+		return nil
+	})
+	if err != nil {
+		return c.Status(collectionErrorStatus(err)).JSON(models.ErrorResponse{
+			Error: err.Error(),
+		})
+	}
+
+	return c.Status(fiber.StatusOK).JSON(schemaResponse(meta))
+}
+
+// ListSchemaVersions handles listing every schema a collection has had
+func (h *Handler) ListSchemaVersions(c *fiber.Ctx) error {
+	name := c.Params("name")
+
+	versions, err := h.store.SchemaVersions(name)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(models.ErrorResponse{
+			Error: err.Error(),
+		})
+	}
+
+	resp := models.SchemaVersionListResponse{
+		Collection: name,
+		Count:      len(versions),
+		Versions:   make([]models.SchemaVersionInfo, len(versions)),
+	}
+	for i, v := range versions {
+		resp.Versions[i] = models.SchemaVersionInfo{Version: v.Version, Schema: v.Schema, CreatedAt: v.CreatedAt}
+	}
+
+	return c.Status(fiber.StatusOK).JSON(resp)
+}
+
+// schemaResponse converts collection settings to a schema response
+func schemaResponse(meta storage.CollectionMeta) models.SchemaResponse {
+	return models.SchemaResponse{
+		Collection: meta.Collection,
+		Version:    meta.SchemaVersion,
+		Schema:     meta.Schema,
+		UpdatedAt:  meta.SchemaUpdatedAt,
+	}
+}
+
+// schemaViolations returns the violations carried by a schema error, or
+// nil for any other error
+func schemaViolations(err error) []models.SchemaViolation {
+	var schemaErr *storage.SchemaError
+	if !errors.As(err, &schemaErr) {
+		return nil
+	}
+	return violations(schemaErr.Violations)
+}
+
+// violations converts schema violations to their API form
+func violations(in []schema.Violation) []models.SchemaViolation {
+	out := make([]models.SchemaViolation, len(in))
+	for i, v := range in {
+		out[i] = models.SchemaViolation{Path: v.Path, Message: v.Message}
+	}
+	return out
+}
@@ -0,0 +1,114 @@
+package api
+
+import (
+	"encoding/json"
+
+	"kiwi/internal/models"
+	"kiwi/internal/storage"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// defaultSearchLimit is the number of hits returned when no limit is given
+const defaultSearchLimit = 10
+
+// Search handles ranked full-text queries against a collection
+func (h *Handler) Search(c *fiber.Ctx) error {
+	collection := c.Query("collection", "default")
+	q := c.Query("q")
+	if q == "" {
+		return c.Status(fiber.StatusBadRequest).JSON(models.ErrorResponse{
+			Error: "Query parameter q is required",
+		})
+	}
+
+	hits, total, err := h.store.Search(collection, q, storage.SearchOptions{
+		MatchAll: c.Query("mode") == "all",
+		Limit:    c.QueryInt("limit", defaultSearchLimit),
+	})
+	if err != nil {
+		return c.Status(indexErrorStatus(err)).JSON(models.ErrorResponse{
+			Error: err.Error(),
+		})
+	}
+
+	resp := models.SearchResponse{Total: total, Count: len(hits), Hits: make([]models.SearchHit, len(hits))}
+	for i, hit := range hits {
+		resp.Hits[i] = models.SearchHit{
+			Key:        hit.Key,
+			Score:      hit.Score,
+			Value:      hit.Value,
+			Highlights: hit.Highlights,
+		}
+	}
+
+	return c.Status(fiber.StatusOK).JSON(resp)
+}
+
+// ListTextIndexes handles listing full-text indexes
+func (h *Handler) ListTextIndexes(c *fiber.Ctx) error {
+	infos, err := h.store.ListTextIndexes()
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(models.ErrorResponse{
+			Error: err.Error(),
+		})
+	}
+
+	resp := models.TextIndexListResponse{Count: len(infos), Indexes: make([]models.TextIndexInfo, len(infos))}
+	for i, info := range infos {
+		resp.Indexes[i] = models.TextIndexInfo{
+			Collection: info.Collection,
+			Fields:     info.Fields,
+			CreatedAt:  info.CreatedAt,
+		}
+	}
+
+	return c.Status(fiber.StatusOK).JSON(resp)
+}
+
+// CreateTextIndex handles enabling full-text search on a collection
+func (h *Handler) CreateTextIndex(c *fiber.Ctx) error {
+	var req models.TextIndexRequest
+	if err := json.Unmarshal(c.Body(), &req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(models.ErrorResponse{
+			Error: "Invalid JSON format",
+		})
+	}
+
+	if len(req.Fields) == 0 {
+		return c.Status(fiber.StatusBadRequest).JSON(models.ErrorResponse{
+			Error: "Fields are required",
+		})
+	}
+
+	if req.Collection == "" {
+		req.Collection = "default"
+	}
+
+	if err := h.store.CreateTextIndex(req.Collection, req.Fields); err != nil {
+		return c.Status(indexErrorStatus(err)).JSON(models.ErrorResponse{
+			Error: err.Error(),
+		})
+	}
+
+	return c.Status(fiber.StatusCreated).JSON(models.IndexResponse{
+		Message:    "Text index created successfully",
+		Collection: req.Collection,
+	})
+}
+
+// DropTextIndex handles disabling full-text search on a collection
+func (h *Handler) DropTextIndex(c *fiber.Ctx) error {
+	collection := c.Params("collection")
+
+	if err := h.store.DropTextIndex(collection); err != nil {
+		return c.Status(indexErrorStatus(err)).JSON(models.ErrorResponse{
+			Error: err.Error(),
+		})
+	}
+
+	return c.Status(fiber.StatusOK).JSON(models.IndexResponse{
+		Message:    "Text index dropped successfully",
+		Collection: collection,
+	})
+}
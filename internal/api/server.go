@@ -4,6 +4,7 @@ import (
 	"kiwi/internal/config"
 	"kiwi/internal/models"
 	"kiwi/internal/storage"
+	"kiwi/internal/webhook"
 
 	"github.com/gofiber/fiber/v2"
 	"github.com/gofiber/fiber/v2/middleware/logger"
@@ -18,17 +19,20 @@ type Server struct {
 }
 
 // NewServer creates and configures a new HTTP server
-func NewServer(cfg *config.Config, store *storage.ReplicatedStore) *Server {
-	handler := NewHandler(store, cfg)
+func NewServer(cfg *config.Config, store *storage.ReplicatedStore, hooks *webhook.Dispatcher) *Server {
+	handler := NewHandler(store, cfg, hooks)
 
 	app := fiber.New(fiber.Config{
 		AppName:      cfg.AppName,
 		ErrorHandler: customErrorHandler,
+		// Blob uploads and imports are read as they arrive, see limitBody
+		StreamRequestBody: true,
 	})
 
 	// Apply middleware
 	app.Use(recover.New())
 	app.Use(logger.New())
+	app.Use(limitBody(fiber.DefaultBodyLimit))
 
 	server := &Server{
 		app:     app,
@@ -55,6 +59,101 @@ func (s *Server) setupRoutes() {
 	api.Get("/:key", s.handler.GetObject)
 	api.Get("/", s.handler.ListObjects)
 	api.Delete("/:key", s.handler.DeleteObject)
+	api.Put("/:key", s.handler.PutRawObject)
+	api.Post("/_bulk", s.handler.BulkObjects)
+	api.Post("/_mget", s.handler.MGetObjects)
+	api.Patch("/:key", s.handler.PatchObject)
+	api.Post("/:key/_incr", s.handler.IncrObject)
+	api.Post("/:key/_decr", s.handler.DecrObject)
+	api.Post("/:key/_add", s.handler.AddObject)
+
+	// Blob routes
+	blobs := s.app.Group("/blobs")
+
+	blobs.Put("/:key", s.handler.PutBlob)
+	blobs.Get("/:key", s.handler.GetBlob)
+
+	uploads := s.app.Group("/uploads")
+
+	uploads.Get("/", s.handler.ListUploads)
+	uploads.Post("/", s.handler.CreateUpload)
+	uploads.Get("/:id", s.handler.GetUpload)
+	uploads.Put("/:id/parts/:part", s.handler.PutUploadPart)
+	uploads.Post("/:id/_complete", s.handler.CompleteUpload)
+	uploads.Delete("/:id", s.handler.AbortUpload)
+
+	// Query and aggregation endpoints
+	s.app.Post("/query", s.handler.QueryObjects)
+	s.app.Post("/aggregate", s.handler.AggregateObjects)
+
+	// Secondary index routes
+	indexes := s.app.Group("/indexes")
+
+	indexes.Get("/", s.handler.ListIndexes)
+	indexes.Post("/", s.handler.CreateIndex)
+	indexes.Get("/query", s.handler.QueryIndex)
+	indexes.Delete("/:field", s.handler.DropIndex)
+
+	// Full-text search routes
+	s.app.Get("/search", s.handler.Search)
+	textIndexes := s.app.Group("/search/indexes")
+
+	textIndexes.Get("/", s.handler.ListTextIndexes)
+	textIndexes.Post("/", s.handler.CreateTextIndex)
+	textIndexes.Delete("/:collection", s.handler.DropTextIndex)
+
+	// Collection routes
+	collections := s.app.Group("/collections")
+
+	collections.Get("/", s.handler.ListCollections)
+	collections.Post("/", s.handler.CreateCollection)
+	collections.Get("/:name", s.handler.GetCollection)
+	collections.Patch("/:name", s.handler.UpdateCollection)
+	collections.Delete("/:name", s.handler.DropCollection)
+	collections.Post("/:name/_truncate", s.handler.TruncateCollection)
+	collections.Post("/:name/_rename", s.handler.RenameCollection)
+	collections.Post("/:name/_copy", s.handler.CopyCollection)
+	collections.Get("/:name/schema", s.handler.GetSchema)
+	collections.Put("/:name/schema", s.handler.PutSchema)
+	collections.Delete("/:name/schema", s.handler.DeleteSchema)
+	collections.Get("/:name/schema/versions", s.handler.ListSchemaVersions)
+	collections.Get("/:name/export", s.handler.ExportCollection)
+	collections.Post("/:name/import", s.handler.ImportCollection)
+
+	// Change feed
+	s.app.Get("/watch", s.handler.Watch)
+
+	// Durable changelog and consumer offsets
+	s.app.Get("/changelog", s.handler.ReadChangelog)
+	consumers := s.app.Group("/changelog/consumers")
+
+	consumers.Get("/", s.handler.ListConsumers)
+	consumers.Get("/:name", s.handler.GetConsumer)
+	consumers.Put("/:name", s.handler.CommitOffset)
+	consumers.Delete("/:name", s.handler.DeleteConsumer)
+
+	// Webhook routes
+	webhooks := s.app.Group("/webhooks")
+
+	webhooks.Get("/", s.handler.ListWebhooks)
+	webhooks.Post("/", s.handler.CreateWebhook)
+	webhooks.Get("/:id", s.handler.GetWebhook)
+	webhooks.Put("/:id", s.handler.UpdateWebhook)
+	webhooks.Delete("/:id", s.handler.DeleteWebhook)
+	webhooks.Post("/:id/_ping", s.handler.PingWebhook)
+	webhooks.Get("/:id/deadletters", s.handler.ListDeadLetters)
+	webhooks.Post("/:id/deadletters/_redeliver", s.handler.RedeliverDeadLetters)
+	webhooks.Delete("/:id/deadletters", s.handler.ClearDeadLetters)
+
+	// Admin routes
+	admin := s.app.Group("/admin")
+
+	admin.Post("/backup", s.handler.CreateBackup)
+	admin.Get("/encryption", s.handler.GetEncryption)
+	admin.Post("/encryption/rotate", s.handler.RotateEncryptionKeys)
+	admin.Get("/cache", s.handler.GetCacheStats)
+	admin.Get("/memory", s.handler.GetMemoryStats)
+	admin.Get("/storage/stats", s.handler.GetStorageStats)
 }
 
 // Start starts the HTTP server
@@ -64,6 +163,8 @@ func (s *Server) Start() error {
 
 // Shutdown gracefully shuts down the server
 func (s *Server) Shutdown() error {
+	// Watch streams never finish on their own
+	close(s.handler.closing)
 	return s.app.Shutdown()
 }
 
@@ -0,0 +1,175 @@
+package api
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+
+	"kiwi/internal/models"
+	"kiwi/internal/storage"
+	"kiwi/internal/transfer"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// importBatchSize is the number of records written per replicated batch
+const importBatchSize = 1000
+
+// ExportCollection streams every key of a collection as NDJSON, CSV or a
+// JSON array, read from a single consistent snapshot. CSV exports have a
+// column per top-level field unless ?fields= picks the columns.
+func (h *Handler) ExportCollection(c *fiber.Ctx) error {
+	collection := c.Params("name")
+	format := c.Query("format", transfer.FormatNDJSON)
+	if !transfer.ValidFormat(format) {
+		return c.Status(fiber.StatusBadRequest).JSON(models.ErrorResponse{
+			Error: transfer.ErrInvalidFormat.Error(),
+		})
+	}
+
+	snap, err := h.store.Snapshot()
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(models.ErrorResponse{
+			Error: err.Error(),
+		})
+	}
+
+	var columns []string
+	if format == transfer.FormatCSV {
+		if fields := splitList(c.Query("fields")); len(fields) > 0 {
+			columns = append([]string{transfer.KeyColumn}, fields...)
+		} else {
+			// The header has to come first, so find the fields in a first pass
+			var scanErr error
+			columns = transfer.Columns(func(fn func(value []byte)) {
+				scanErr = snap.ScanRaw(collection, func(_ string, value []byte) bool {
+					if view, err := storage.JSONValue(value); err == nil {
+						fn(view)
+					}
+					return true
+				})
+			})
+			if scanErr != nil {
+				snap.Release()
+				return c.Status(fiber.StatusInternalServerError).JSON(models.ErrorResponse{
+					Error: scanErr.Error(),
+				})
+			}
+		}
+	}
+
+	c.Set(fiber.HeaderContentType, transfer.ContentType(format))
+	c.Set(fiber.HeaderContentDisposition, fmt.Sprintf("attachment; filename=%q", collection+"."+format))
+
+	c.Context().SetBodyStreamWriter(func(w *bufio.Writer) {
+		defer snap.Release()
+
+		tw, err := transfer.NewWriter(w, format, columns)
+		if err != nil {
+			return
+		}
+		snap.ScanRaw(collection, func(key string, value []byte) bool {
+			view, err := storage.JSONValue(value)
+			if err != nil {
+				return true // skip malformed entries
+			}
+			return tw.Write(transfer.Record{Key: key, Value: view}) == nil
+		})
+		if tw.Close() == nil {
+			w.Flush()
+		}
+	})
+
+	return nil
+}
+
+// ImportCollection writes NDJSON, CSV or JSON records into a collection in
+// replicated batches. The body is read as a stream and each batch is
+// written once all of its records are parsed, so malformed input stops the
+// import without a partial batch. ?on_conflict= decides what happens to
+// keys that already exist. A batch that fails, whether to parse or to
+// write, stops the import; the batches before it stay written and are
+// counted in the response.
+func (h *Handler) ImportCollection(c *fiber.Ctx) error {
+	collection := c.Params("name")
+
+	format := c.Query("format")
+	if format == "" {
+		format = transfer.FormatOf(c.Get(fiber.HeaderContentType))
+	}
+	if format == "" {
+		format = transfer.FormatNDJSON
+	}
+
+	onConflict := c.Query("on_conflict", storage.ConflictOverwrite)
+	if !storage.ValidConflictPolicy(onConflict) {
+		return c.Status(fiber.StatusBadRequest).JSON(models.ErrorResponse{
+			Error: storage.ErrInvalidConflictPolicy.Error(),
+		})
+	}
+
+	reader, err := transfer.NewReader(requestBody(c), format)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(models.ErrorResponse{
+			Error: err.Error(),
+		})
+	}
+
+	var resp models.ImportResponse
+	items := make([]storage.ImportItem, 0, importBatchSize)
+	flush := func() error {
+		res, err := h.store.Import(collection, items, onConflict)
+		resp.Imported += res.Imported
+		resp.Skipped += res.Skipped
+		items = items[:0]
+		return err
+	}
+
+	for {
+		r, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			resp.Error = err.Error()
+			return c.Status(fiber.StatusBadRequest).JSON(resp)
+		}
+		items = append(items, storage.ImportItem{Key: r.Key, Value: r.Value})
+		if len(items) == importBatchSize {
+			if err := flush(); err != nil {
+				return importError(c, resp, err)
+			}
+		}
+	}
+	if len(items) > 0 {
+		if err := flush(); err != nil {
+			return importError(c, resp, err)
+		}
+	}
+
+	return c.Status(fiber.StatusOK).JSON(resp)
+}
+
+// importError responds to an import that failed to write a batch
+func importError(c *fiber.Ctx, resp models.ImportResponse, err error) error {
+	resp.Error = err.Error()
+	resp.Violations = schemaViolations(err)
+	return c.Status(importErrorStatus(err)).JSON(resp)
+}
+
+// importErrorStatus maps import errors to HTTP status codes
+func importErrorStatus(err error) int {
+	switch {
+	case errors.Is(err, storage.ErrKeyExists):
+		return fiber.StatusConflict
+	case errors.Is(err, storage.ErrInvalidKey):
+		return fiber.StatusBadRequest
+	case errors.Is(err, storage.ErrValueTooLarge):
+		return fiber.StatusRequestEntityTooLarge
+	case errors.Is(err, storage.ErrSchemaViolation):
+		return fiber.StatusUnprocessableEntity
+	default:
+		return fiber.StatusInternalServerError
+	}
+}
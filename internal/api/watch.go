@@ -0,0 +1,160 @@
+package api
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+
+	"kiwi/internal/models"
+	"kiwi/internal/watch"
+
+	"github.com/gofiber/contrib/websocket"
+	"github.com/gofiber/fiber/v2"
+)
+
+// watchHeartbeat is how often idle watch streams are pinged
+const watchHeartbeat = 15 * time.Second
+
+// Watch streams put and delete events over Server-Sent Events, or over a
+// WebSocket when the request asks for an upgrade. Clients resume after a
+// disconnect with ?since=<seq> or the SSE Last-Event-ID header.
+func (h *Handler) Watch(c *fiber.Ctx) error {
+	filter := watch.Filter{
+		Collection: c.Query("collection"),
+		Prefix:     c.Query("prefix"),
+	}
+
+	seq := h.store.LastSeq()
+	since := c.Query("since", c.Get("Last-Event-ID"))
+	if since != "" {
+		n, err := strconv.ParseUint(since, 10, 64)
+		if err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(models.ErrorResponse{
+				Error: "Invalid since sequence",
+			})
+		}
+		seq = n
+	}
+
+	if err := h.store.CheckSeq(seq); err != nil {
+		return c.Status(watchErrorStatus(err)).JSON(models.ErrorResponse{
+			Error: err.Error(),
+		})
+	}
+
+	if websocket.IsWebSocketUpgrade(c) {
+		return websocket.New(func(conn *websocket.Conn) {
+			h.watchWebSocket(conn, seq, filter)
+		})(c)
+	}
+
+	c.Set(fiber.HeaderContentType, "text/event-stream")
+	c.Set(fiber.HeaderCacheControl, "no-cache")
+	c.Set(fiber.HeaderConnection, "keep-alive")
+	c.Set("X-Accel-Buffering", "no")
+
+	c.Context().SetBodyStreamWriter(func(w *bufio.Writer) {
+		// Tell the client where the stream starts, so it can resume even
+		// if no event arrives before it disconnects
+		fmt.Fprintf(w, "retry: 2000\nid: %d\n\n", seq)
+		if err := w.Flush(); err != nil {
+			return
+		}
+
+		err := h.store.Watch(seq, filter, watchHeartbeat, h.closing, func(e *watch.Event) error {
+			if e == nil {
+				w.WriteString(": ping\n\n")
+				return w.Flush()
+			}
+
+			data, err := json.Marshal(watchEvent(e))
+			if err != nil {
+				return err
+			}
+			fmt.Fprintf(w, "id: %d\nevent: %s\ndata: %s\n\n", e.Seq, e.Type, data)
+			return w.Flush()
+		})
+
+		if errors.Is(err, watch.ErrSequenceExpired) {
+			data, _ := json.Marshal(models.ErrorResponse{Error: err.Error()})
+			fmt.Fprintf(w, "event: error\ndata: %s\n\n", data)
+			w.Flush()
+		}
+	})
+
+	return nil
+}
+
+// watchWebSocket streams events as JSON text messages until the client
+// goes away
+func (h *Handler) watchWebSocket(conn *websocket.Conn, seq uint64, filter watch.Filter) {
+	done := make(chan struct{})
+
+	// The client never sends anything useful, but reading is how closed
+	// connections are noticed
+	go func() {
+		defer close(done)
+		for {
+			if _, _, err := conn.ReadMessage(); err != nil {
+				return
+			}
+		}
+	}()
+
+	go func() {
+		select {
+		case <-h.closing:
+			conn.WriteControl(websocket.CloseMessage,
+				websocket.FormatCloseMessage(websocket.CloseGoingAway, "server shutting down"),
+				time.Now().Add(time.Second))
+			conn.Close()
+		case <-done:
+		}
+	}()
+
+	err := h.store.Watch(seq, filter, watchHeartbeat, done, func(e *watch.Event) error {
+		if e == nil {
+			return conn.WriteControl(websocket.PingMessage, nil, time.Now().Add(watchHeartbeat))
+		}
+		return conn.WriteJSON(watchEvent(e))
+	})
+
+	if errors.Is(err, watch.ErrSequenceExpired) {
+		conn.WriteControl(websocket.CloseMessage,
+			websocket.FormatCloseMessage(websocket.ClosePolicyViolation, err.Error()),
+			time.Now().Add(time.Second))
+	}
+	conn.Close()
+	<-done
+}
+
+// watchEvent converts a feed event to its API representation
+func watchEvent(e *watch.Event) models.WatchEvent {
+	return models.WatchEvent{
+		Seq:         e.Seq,
+		Type:        e.Type,
+		Collection:  e.Collection,
+		Key:         e.Key,
+		Value:       e.Value,
+		ContentType: e.ContentType,
+		Blob:        e.Blob,
+		Timestamp:   e.Timestamp,
+	}
+}
+
+// watchErrorStatus maps change feed errors to HTTP status codes
+func watchErrorStatus(err error) int {
+	switch {
+	case errors.Is(err, watch.ErrSequenceExpired):
+		return fiber.StatusGone
+	case errors.Is(err, watch.ErrSequenceAhead):
+		return fiber.StatusBadRequest
+	case errors.Is(err, watch.ErrClosed):
+		return fiber.StatusServiceUnavailable
+	default:
+		return fiber.StatusInternalServerError
+	}
+}
@@ -0,0 +1,256 @@
+package api
+
+import (
+	"errors"
+
+	"kiwi/internal/models"
+	"kiwi/internal/storage"
+	"kiwi/internal/webhook"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// ListWebhooks handles listing webhooks
+func (h *Handler) ListWebhooks(c *fiber.Ctx) error {
+	if h.hooks == nil {
+		return webhooksOnMaster(c)
+	}
+
+	hooks, err := h.hooks.List()
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(models.ErrorResponse{
+			Error: err.Error(),
+		})
+	}
+
+	resp := models.WebhookListResponse{Count: len(hooks), Webhooks: make([]models.WebhookInfo, len(hooks))}
+	for i, hook := range hooks {
+		resp.Webhooks[i] = webhookInfo(hook)
+	}
+	return c.Status(fiber.StatusOK).JSON(resp)
+}
+
+// GetWebhook handles retrieving a webhook
+func (h *Handler) GetWebhook(c *fiber.Ctx) error {
+	if h.hooks == nil {
+		return webhooksOnMaster(c)
+	}
+
+	hook, err := h.hooks.Get(c.Params("id"))
+	if err != nil {
+		return c.Status(webhookErrorStatus(err)).JSON(models.ErrorResponse{
+			Error: err.Error(),
+		})
+	}
+	return c.Status(fiber.StatusOK).JSON(webhookInfo(hook))
+}
+
+// CreateWebhook handles registering a webhook
+func (h *Handler) CreateWebhook(c *fiber.Ctx) error {
+	if h.hooks == nil {
+		return webhooksOnMaster(c)
+	}
+
+	var req models.WebhookRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(models.ErrorResponse{
+			Error: "Invalid JSON format",
+		})
+	}
+
+	hook := &storage.Webhook{
+		URL:         req.URL,
+		Collection:  req.Collection,
+		Prefix:      req.Prefix,
+		Events:      req.Events,
+		Secret:      req.Secret,
+		MaxAttempts: req.MaxAttempts,
+		Active:      req.Active == nil || *req.Active,
+	}
+	if err := h.hooks.Create(hook); err != nil {
+		return c.Status(webhookErrorStatus(err)).JSON(models.ErrorResponse{
+			Error: err.Error(),
+		})
+	}
+	return c.Status(fiber.StatusCreated).JSON(webhookInfo(hook))
+}
+
+// UpdateWebhook handles replacing a webhook definition. An omitted secret
+// or active flag keeps the current value.
+func (h *Handler) UpdateWebhook(c *fiber.Ctx) error {
+	if h.hooks == nil {
+		return webhooksOnMaster(c)
+	}
+
+	var req models.WebhookRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(models.ErrorResponse{
+			Error: "Invalid JSON format",
+		})
+	}
+
+	current, err := h.hooks.Get(c.Params("id"))
+	if err != nil {
+		return c.Status(webhookErrorStatus(err)).JSON(models.ErrorResponse{
+			Error: err.Error(),
+		})
+	}
+
+	hook := &storage.Webhook{
+		ID:          current.ID,
+		URL:         req.URL,
+		Collection:  req.Collection,
+		Prefix:      req.Prefix,
+		Events:      req.Events,
+		Secret:      req.Secret,
+		MaxAttempts: req.MaxAttempts,
+		Active:      current.Active,
+	}
+	if req.Secret == "" {
+		hook.Secret = current.Secret
+	}
+	if req.Active != nil {
+		hook.Active = *req.Active
+	}
+
+	if err := h.hooks.Update(hook); err != nil {
+		return c.Status(webhookErrorStatus(err)).JSON(models.ErrorResponse{
+			Error: err.Error(),
+		})
+	}
+	return c.Status(fiber.StatusOK).JSON(webhookInfo(hook))
+}
+
+// DeleteWebhook handles removing a webhook
+func (h *Handler) DeleteWebhook(c *fiber.Ctx) error {
+	if h.hooks == nil {
+		return webhooksOnMaster(c)
+	}
+
+	id := c.Params("id")
+	if err := h.hooks.Delete(id); err != nil {
+		return c.Status(webhookErrorStatus(err)).JSON(models.ErrorResponse{
+			Error: err.Error(),
+		})
+	}
+	return c.Status(fiber.StatusOK).JSON(models.DeleteResponse{
+		Message: "Webhook deleted successfully",
+		Key:     id,
+	})
+}
+
+// PingWebhook handles sending a test delivery to a webhook
+func (h *Handler) PingWebhook(c *fiber.Ctx) error {
+	if h.hooks == nil {
+		return webhooksOnMaster(c)
+	}
+
+	result, err := h.hooks.Ping(c.Params("id"))
+	if err != nil {
+		return c.Status(webhookErrorStatus(err)).JSON(models.ErrorResponse{
+			Error: err.Error(),
+		})
+	}
+	return c.Status(fiber.StatusOK).JSON(models.PingResponse{
+		Delivered:  result.Error == "",
+		Status:     result.Status,
+		Error:      result.Error,
+		DurationMs: result.Duration.Milliseconds(),
+	})
+}
+
+// ListDeadLetters handles listing the failed deliveries of a webhook
+func (h *Handler) ListDeadLetters(c *fiber.Ctx) error {
+	if h.hooks == nil {
+		return webhooksOnMaster(c)
+	}
+
+	letters, err := h.hooks.DeadLetters(c.Params("id"))
+	if err != nil {
+		return c.Status(webhookErrorStatus(err)).JSON(models.ErrorResponse{
+			Error: err.Error(),
+		})
+	}
+
+	resp := models.DeadLetterListResponse{Count: len(letters), DeadLetters: make([]models.DeadLetter, len(letters))}
+	for i, letter := range letters {
+		resp.DeadLetters[i] = models.DeadLetter{
+			Event:      watchEvent(&letter.Event),
+			Attempts:   letter.Attempts,
+			LastStatus: letter.LastStatus,
+			LastError:  letter.LastError,
+			FailedAt:   letter.FailedAt,
+		}
+	}
+	return c.Status(fiber.StatusOK).JSON(resp)
+}
+
+// RedeliverDeadLetters handles queuing the failed deliveries of a webhook again
+func (h *Handler) RedeliverDeadLetters(c *fiber.Ctx) error {
+	if h.hooks == nil {
+		return webhooksOnMaster(c)
+	}
+
+	n, err := h.hooks.Redeliver(c.Params("id"))
+	if err != nil {
+		return c.Status(webhookErrorStatus(err)).JSON(models.ErrorResponse{
+			Error: err.Error(),
+		})
+	}
+	return c.Status(fiber.StatusOK).JSON(models.RedeliverResponse{
+		Message: "Dead letters queued for delivery",
+		Count:   n,
+	})
+}
+
+// ClearDeadLetters handles discarding the failed deliveries of a webhook
+func (h *Handler) ClearDeadLetters(c *fiber.Ctx) error {
+	if h.hooks == nil {
+		return webhooksOnMaster(c)
+	}
+
+	id := c.Params("id")
+	if err := h.hooks.ClearDeadLetters(id); err != nil {
+		return c.Status(webhookErrorStatus(err)).JSON(models.ErrorResponse{
+			Error: err.Error(),
+		})
+	}
+	return c.Status(fiber.StatusOK).JSON(models.DeleteResponse{
+		Message: "Dead letters deleted successfully",
+		Key:     id,
+	})
+}
+
+// webhookInfo converts a stored webhook to its API representation
+func webhookInfo(hook *storage.Webhook) models.WebhookInfo {
+	return models.WebhookInfo{
+		ID:          hook.ID,
+		URL:         hook.URL,
+		Collection:  hook.Collection,
+		Prefix:      hook.Prefix,
+		Events:      hook.Events,
+		Signed:      hook.Secret != "",
+		MaxAttempts: hook.MaxAttempts,
+		Active:      hook.Active,
+		CreatedAt:   hook.CreatedAt,
+	}
+}
+
+// webhooksOnMaster rejects webhook requests on slaves, which do not deliver
+func webhooksOnMaster(c *fiber.Ctx) error {
+	return c.Status(fiber.StatusServiceUnavailable).JSON(models.ErrorResponse{
+		Error: "webhooks are managed on the master node, send request to master",
+	})
+}
+
+// webhookErrorStatus maps webhook errors to HTTP status codes
+func webhookErrorStatus(err error) int {
+	switch {
+	case errors.Is(err, storage.ErrWebhookNotFound):
+		return fiber.StatusNotFound
+	case errors.Is(err, webhook.ErrInvalidWebhook):
+		return fiber.StatusBadRequest
+	default:
+		return fiber.StatusInternalServerError
+	}
+}
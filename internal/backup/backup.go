@@ -0,0 +1,442 @@
+package backup
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"kiwi/internal/storage"
+)
+
+// Backup layout, both as a directory and inside a tarball
+const (
+	ManifestFile = "manifest.json"
+	DataDir      = "data"
+	KeysFile     = "keys.json"
+	DefsFile     = "definitions.json"
+
+	manifestVersion = 1
+)
+
+// Backup formats
+const (
+	FormatDir = "dir"
+	FormatTar = "tar"
+)
+
+// Backup types
+const (
+	TypeFull        = "full"
+	TypeIncremental = "incremental"
+)
+
+var (
+	// ErrInvalidFormat is returned for unknown backup formats
+	ErrInvalidFormat = errors.New("format must be dir or tar")
+	// ErrExists is returned when the backup target already exists
+	ErrExists = errors.New("backup target already exists")
+	// ErrInvalidBackup is returned when a path does not hold a kiwi backup
+	ErrInvalidBackup = errors.New("not a kiwi backup")
+	// ErrInvalidType is returned for unknown backup types
+	ErrInvalidType = errors.New("type must be full or incremental")
+	// ErrNoBaseBackup is returned for an incremental backup when no
+	// earlier backup of this node is known
+	ErrNoBaseBackup = errors.New("no earlier backup to continue from, take a full backup first")
+	// ErrBeforeBackup is returned when a restore target precedes the full backup
+	ErrBeforeBackup = errors.New("restore target is before the full backup")
+)
+
+// Manifest describes a backup
+type Manifest struct {
+	Version     int              `json:"version"`
+	Type        string           `json:"type"`
+	NodeID      string           `json:"node_id"`
+	Role        string           `json:"role"`
+	CreatedAt   time.Time        `json:"created_at"`
+	Sequence    uint64           `json:"sequence"`
+	Keys        int64            `json:"keys"`
+	Collections map[string]int64 `json:"collections"`
+
+	// Incremental backups hold the changes after BaseSequence up to and
+	// including Sequence; Collections counts changes instead of keys
+	BaseSequence uint64 `json:"base_sequence,omitempty"`
+	Changes      int64  `json:"changes,omitempty"`
+
+	// Encrypted backups can only be restored with the master key of the
+	// node they were taken on
+	Encrypted bool `json:"encrypted,omitempty"`
+}
+
+// Options controls where and how a backup is written
+type Options struct {
+	Path   string // directory, or tarball file for FormatTar
+	Format string
+	Type   string
+	NodeID string
+	Role   string
+
+	// Since is where an incremental backup starts; nil continues from the
+	// last backup of this node
+	Since *uint64
+}
+
+// RestoreOptions controls what a restore rebuilds
+type RestoreOptions struct {
+	Collections  []string  // restore only these collections from the full backup
+	Incrementals []string  // incremental backups replayed in order after the full backup
+	ToSeq        uint64    // stop after this sequence; zero replays everything
+	ToTime       time.Time // stop before the first change after this time
+	Engine       string    // engine of the restored database; LevelDB when empty
+
+	// EngineOptions tunes the engine of the restored database
+	EngineOptions storage.EngineOptions
+
+	// Keys opens encrypted backups and encrypts the restored database
+	Keys storage.MasterKeys
+}
+
+// RestoreResult describes a completed restore
+type RestoreResult struct {
+	Manifest *Manifest // manifest of the full backup
+	Sequence uint64    // sequence the database was rebuilt to
+	Replayed int64     // changes replayed from incremental backups
+	Warnings []string  // what the restore could not bring back
+}
+
+// Create writes a backup of store, together with its manifest, to
+// opts.Path. A full backup is a consistent snapshot of the database; an
+// incremental backup holds the changelog entries since an earlier backup.
+func Create(store *storage.LocalStore, opts Options) (*Manifest, error) {
+	if opts.Format == "" {
+		opts.Format = FormatDir
+	}
+	if opts.Format != FormatDir && opts.Format != FormatTar {
+		return nil, ErrInvalidFormat
+	}
+	if opts.Type == "" {
+		opts.Type = TypeFull
+	}
+
+	var since uint64
+	switch opts.Type {
+	case TypeFull:
+	case TypeIncremental:
+		var err error
+		if since, err = incrementalStart(store, opts.Since); err != nil {
+			return nil, err
+		}
+	default:
+		return nil, ErrInvalidType
+	}
+	if _, err := os.Stat(opts.Path); err == nil {
+		return nil, fmt.Errorf("%w: %s", ErrExists, opts.Path)
+	}
+
+	if err := os.MkdirAll(filepath.Dir(opts.Path), 0o755); err != nil {
+		return nil, fmt.Errorf("failed to create backup directory: %w", err)
+	}
+
+	dir := opts.Path
+	if opts.Format == FormatTar {
+		tmp, err := os.MkdirTemp(filepath.Dir(opts.Path), ".kiwi-backup-")
+		if err != nil {
+			return nil, fmt.Errorf("failed to create temporary directory: %w", err)
+		}
+		defer os.RemoveAll(tmp)
+		dir = tmp
+	} else if err := os.Mkdir(dir, 0o755); err != nil {
+		return nil, fmt.Errorf("failed to create backup directory: %w", err)
+	}
+
+	// Never leave a partial backup behind
+	ok := false
+	defer func() {
+		if !ok {
+			os.RemoveAll(opts.Path)
+		}
+	}()
+
+	m := &Manifest{Version: manifestVersion, Type: opts.Type}
+	if opts.Type == TypeFull {
+		info, err := store.Backup(filepath.Join(dir, DataDir))
+		if err != nil {
+			return nil, err
+		}
+		m.Sequence, m.Keys, m.Collections = info.Sequence, info.Keys, info.Collections
+	} else if err := writeChanges(store, dir, since, m); err != nil {
+		return nil, err
+	}
+	m.NodeID, m.Role, m.CreatedAt = opts.NodeID, opts.Role, time.Now().UTC()
+	m.Encrypted = store.Encrypted()
+
+	if err := writeManifest(dir, m); err != nil {
+		return nil, err
+	}
+
+	if opts.Format == FormatTar {
+		if err := writeTar(dir, opts.Path); err != nil {
+			return nil, err
+		}
+	}
+
+	// The next incremental backup continues from here
+	if err := store.SetLastBackupSeq(m.Sequence); err != nil {
+		return nil, err
+	}
+
+	ok = true
+	return m, nil
+}
+
+// Open makes a backup directory or tarball available for reading and
+// returns its directory and manifest. The returned cleanup function removes
+// any temporary files.
+func Open(path string) (string, *Manifest, func(), error) {
+	fi, err := os.Stat(path)
+	if err != nil {
+		return "", nil, nil, fmt.Errorf("failed to open backup: %w", err)
+	}
+
+	dir, cleanup := path, func() {}
+	if !fi.IsDir() {
+		tmp, err := os.MkdirTemp("", "kiwi-restore-")
+		if err != nil {
+			return "", nil, nil, fmt.Errorf("failed to create temporary directory: %w", err)
+		}
+		cleanup = func() { os.RemoveAll(tmp) }
+		if err := extractTar(path, tmp); err != nil {
+			cleanup()
+			return "", nil, nil, err
+		}
+		dir = tmp
+	}
+
+	m, err := ReadManifest(dir)
+	if err != nil {
+		cleanup()
+		return "", nil, nil, err
+	}
+	return dir, m, cleanup, nil
+}
+
+// Restore seeds the database at dbPath from a backup. With no collections
+// the whole backup is copied into an empty database, giving an exact copy
+// of the backed up node, and any incremental backups are replayed on top up
+// to the chosen sequence or time. Otherwise each listed collection is
+// replaced with its contents in the backup. The node must be stopped.
+func Restore(path, dbPath string, opts RestoreOptions) (*RestoreResult, error) {
+	pointInTime := len(opts.Incrementals) > 0 || opts.ToSeq > 0 || !opts.ToTime.IsZero()
+	if pointInTime && len(opts.Collections) > 0 {
+		return nil, errors.New("incremental and point-in-time restores cannot be limited to collections")
+	}
+	if opts.Engine == "" {
+		opts.Engine = storage.EngineLevelDB
+	}
+	if opts.Engine == storage.EngineMemory {
+		return nil, errors.New("the memory engine keeps no data, there is nothing to restore into")
+	}
+
+	dir, m, cleanup, err := Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer cleanup()
+
+	if m.Type != TypeFull {
+		return nil, fmt.Errorf("%w: %s is not a full backup", ErrInvalidBackup, path)
+	}
+	if opts.ToSeq > 0 && opts.ToSeq < m.Sequence {
+		return nil, fmt.Errorf("%w: sequence %d < %d", ErrBeforeBackup, opts.ToSeq, m.Sequence)
+	}
+	if !opts.ToTime.IsZero() && opts.ToTime.Before(m.CreatedAt) {
+		return nil, fmt.Errorf("%w: %s < %s", ErrBeforeBackup, opts.ToTime.Format(time.RFC3339), m.CreatedAt.Format(time.RFC3339))
+	}
+
+	src, err := storage.OpenBackup(filepath.Join(dir, DataDir))
+	if err != nil {
+		return nil, err
+	}
+	defer src.Close()
+
+	result := &RestoreResult{Manifest: m, Sequence: m.Sequence}
+	if len(opts.Collections) == 0 {
+		if _, err := storage.RestoreAll(src, opts.Engine, dbPath, opts.EngineOptions); err != nil {
+			return nil, err
+		}
+		if len(opts.Incrementals) == 0 {
+			return result, nil
+		}
+		return result, replayAll(dbPath, m, opts, result)
+	}
+
+	target, err := openTarget(dbPath, opts)
+	if err != nil {
+		return nil, err
+	}
+	defer target.Close()
+
+	for _, collection := range opts.Collections {
+		if _, ok := m.Collections[collection]; !ok {
+			return nil, fmt.Errorf("collection %q is not in the backup", collection)
+		}
+		if _, err := target.RestoreCollection(src, collection); err != nil {
+			return nil, fmt.Errorf("failed to restore collection %q: %w", collection, err)
+		}
+	}
+	return result, nil
+}
+
+// openTarget opens the database a restore writes to
+func openTarget(dbPath string, opts RestoreOptions) (*storage.LocalStore, error) {
+	target, err := storage.OpenStore(opts.Engine, dbPath, opts.EngineOptions)
+	if err != nil {
+		return nil, err
+	}
+	if err := target.EnableEncryption(opts.Keys); err != nil {
+		target.Close()
+		return nil, err
+	}
+	return target, nil
+}
+
+// ReadManifest reads the manifest of an unpacked backup
+func ReadManifest(dir string) (*Manifest, error) {
+	data, err := os.ReadFile(filepath.Join(dir, ManifestFile))
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidBackup, err)
+	}
+
+	var m Manifest
+	if err := json.Unmarshal(data, &m); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidBackup, err)
+	}
+	if m.Version != manifestVersion {
+		return nil, fmt.Errorf("%w: unsupported manifest version %d", ErrInvalidBackup, m.Version)
+	}
+	return &m, nil
+}
+
+// writeManifest writes m into dir
+func writeManifest(dir string, m *Manifest) error {
+	data, err := json.MarshalIndent(m, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to serialize manifest: %w", err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, ManifestFile), data, 0o644); err != nil {
+		return fmt.Errorf("failed to write manifest: %w", err)
+	}
+	return nil
+}
+
+// writeTar packs the files under dir into a gzip-compressed tarball
+func writeTar(dir, path string) error {
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0o644)
+	if err != nil {
+		return fmt.Errorf("failed to create tarball: %w", err)
+	}
+	defer f.Close()
+
+	gz := gzip.NewWriter(f)
+	tw := tar.NewWriter(gz)
+
+	err = filepath.Walk(dir, func(file string, fi os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		name, err := filepath.Rel(dir, file)
+		if err != nil || name == "." {
+			return err
+		}
+
+		hdr, err := tar.FileInfoHeader(fi, "")
+		if err != nil {
+			return err
+		}
+		hdr.Name = filepath.ToSlash(name)
+		if err := tw.WriteHeader(hdr); err != nil {
+			return err
+		}
+		if fi.IsDir() {
+			return nil
+		}
+
+		in, err := os.Open(file)
+		if err != nil {
+			return err
+		}
+		defer in.Close()
+		_, err = io.Copy(tw, in)
+		return err
+	})
+	if err != nil {
+		return fmt.Errorf("failed to write tarball: %w", err)
+	}
+
+	if err := tw.Close(); err != nil {
+		return fmt.Errorf("failed to write tarball: %w", err)
+	}
+	if err := gz.Close(); err != nil {
+		return fmt.Errorf("failed to write tarball: %w", err)
+	}
+	return f.Sync()
+}
+
+// extractTar unpacks a tarball written by writeTar into dir
+func extractTar(path, dir string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return fmt.Errorf("failed to open tarball: %w", err)
+	}
+	defer f.Close()
+
+	gz, err := gzip.NewReader(f)
+	if err != nil {
+		return fmt.Errorf("%w: %v", ErrInvalidBackup, err)
+	}
+	tr := tar.NewReader(gz)
+
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("failed to read tarball: %w", err)
+		}
+
+		// Refuse entries that would land outside dir
+		target := filepath.Join(dir, filepath.FromSlash(hdr.Name))
+		if !strings.HasPrefix(target, filepath.Clean(dir)+string(os.PathSeparator)) {
+			return fmt.Errorf("%w: invalid path %q", ErrInvalidBackup, hdr.Name)
+		}
+
+		switch hdr.Typeflag {
+		case tar.TypeDir:
+			if err := os.MkdirAll(target, 0o755); err != nil {
+				return err
+			}
+		case tar.TypeReg:
+			if err := os.MkdirAll(filepath.Dir(target), 0o755); err != nil {
+				return err
+			}
+			out, err := os.OpenFile(target, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0o644)
+			if err != nil {
+				return err
+			}
+			if _, err := io.Copy(out, tr); err != nil {
+				out.Close()
+				return err
+			}
+			if err := out.Close(); err != nil {
+				return err
+			}
+		}
+	}
+}
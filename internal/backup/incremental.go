@@ -0,0 +1,337 @@
+package backup
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"reflect"
+
+	"kiwi/internal/storage"
+	"kiwi/internal/watch"
+)
+
+// ChangesFile holds the changes of an incremental backup, one JSON change
+// event per line in sequence order
+const ChangesFile = "changes.ndjson"
+
+// replayBatchSize is the number of changes applied per write when replaying
+const replayBatchSize = 1000
+
+// incrementalStart returns the sequence an incremental backup starts after
+// and checks the changelog still holds every change since then
+func incrementalStart(store *storage.LocalStore, since *uint64) (uint64, error) {
+	first, err := store.FirstChangelogSeq()
+	if err != nil {
+		return 0, err
+	}
+
+	var seq uint64
+	if since != nil {
+		seq = *since
+	} else {
+		last, ok, err := store.LastBackupSeq()
+		if err != nil {
+			return 0, err
+		}
+		if !ok {
+			return 0, ErrNoBaseBackup
+		}
+		seq = last
+	}
+
+	if seq > store.Feed().LastSeq() {
+		return 0, fmt.Errorf("%w: %d", storage.ErrOffsetAhead, seq)
+	}
+	if seq+1 < first {
+		return 0, fmt.Errorf("%w: changes after %d are gone, take a full backup", storage.ErrOffsetExpired, seq)
+	}
+	return seq, nil
+}
+
+// writeChanges exports the changelog entries after since into dir and
+// fills in the manifest fields describing them
+func writeChanges(store *storage.LocalStore, dir string, since uint64, m *Manifest) error {
+	until := store.Feed().LastSeq()
+
+	f, err := os.OpenFile(filepath.Join(dir, ChangesFile), os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0o644)
+	if err != nil {
+		return fmt.Errorf("failed to create changes file: %w", err)
+	}
+	defer f.Close()
+
+	// Changes are encrypted as they are in the changelog, and the data
+	// keys needed to read them are stored alongside
+	w := bufio.NewWriter(f)
+	var out io.Writer = w
+	var sealed *storage.SealedWriter
+	if store.Encrypted() {
+		sealed = store.NewSealedWriter(w)
+		out = sealed
+		if err := writeKeys(store, dir); err != nil {
+			return err
+		}
+	}
+	enc := json.NewEncoder(out)
+
+	m.BaseSequence, m.Sequence = since, since
+	m.Collections = make(map[string]int64)
+	for m.Sequence < until {
+		page, err := store.ReadChangelog(m.Sequence, watch.Filter{}, 0)
+		if err != nil {
+			return err
+		}
+		for _, e := range page.Events {
+			if e.Seq > until {
+				break
+			}
+			if e.Seq != m.Sequence+1 {
+				return fmt.Errorf("changelog is missing sequence %d", m.Sequence+1)
+			}
+			if err := enc.Encode(e); err != nil {
+				return fmt.Errorf("failed to write changes: %w", err)
+			}
+			m.Sequence = e.Seq
+			m.Changes++
+			m.Collections[e.Collection]++
+		}
+		if len(page.Events) == 0 {
+			break
+		}
+	}
+	if m.Sequence != until {
+		return fmt.Errorf("changelog is missing sequence %d", m.Sequence+1)
+	}
+
+	// Settings and index changes are not in the changelog
+	if err := writeDefinitions(store, dir); err != nil {
+		return err
+	}
+
+	if sealed != nil {
+		if err := sealed.Close(); err != nil {
+			return fmt.Errorf("failed to write changes: %w", err)
+		}
+	}
+	if err := w.Flush(); err != nil {
+		return fmt.Errorf("failed to write changes: %w", err)
+	}
+	if err := f.Sync(); err != nil {
+		return fmt.Errorf("failed to write changes: %w", err)
+	}
+	return nil
+}
+
+// writeKeys stores the wrapped data keys of store in dir
+func writeKeys(store *storage.LocalStore, dir string) error {
+	keys, err := store.DataKeys()
+	if err != nil {
+		return err
+	}
+	data, err := json.MarshalIndent(keys, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to serialize data keys: %w", err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, KeysFile), data, 0o600); err != nil {
+		return fmt.Errorf("failed to write data keys: %w", err)
+	}
+	return nil
+}
+
+// writeDefinitions stores the collection definitions of store in dir
+func writeDefinitions(store *storage.LocalStore, dir string) error {
+	defs, err := store.Definitions()
+	if err != nil {
+		return err
+	}
+	data, err := json.MarshalIndent(defs, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to serialize collection definitions: %w", err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, DefsFile), data, 0o644); err != nil {
+		return fmt.Errorf("failed to write collection definitions: %w", err)
+	}
+	return nil
+}
+
+// readDefinitions reads the collection definitions stored in dir. Backups
+// taken before they were stored have none.
+func readDefinitions(dir string) ([]storage.CollectionDefinition, bool, error) {
+	data, err := os.ReadFile(filepath.Join(dir, DefsFile))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, false, nil
+	}
+	if err != nil {
+		return nil, false, fmt.Errorf("%w: %v", ErrInvalidBackup, err)
+	}
+	var defs []storage.CollectionDefinition
+	if err := json.Unmarshal(data, &defs); err != nil {
+		return nil, false, fmt.Errorf("%w: malformed collection definitions: %v", ErrInvalidBackup, err)
+	}
+	return defs, true, nil
+}
+
+// importKeys adds the data keys stored in dir to target
+func importKeys(target *storage.LocalStore, dir string) error {
+	data, err := os.ReadFile(filepath.Join(dir, KeysFile))
+	if err != nil {
+		return fmt.Errorf("%w: %v", ErrInvalidBackup, err)
+	}
+	var keys []storage.DataKey
+	if err := json.Unmarshal(data, &keys); err != nil {
+		return fmt.Errorf("%w: malformed data keys: %v", ErrInvalidBackup, err)
+	}
+	return target.ImportDataKeys(keys)
+}
+
+// replayAll replays the incremental backups in opts onto the database at
+// dbPath, freshly restored from the full backup base
+func replayAll(dbPath string, base *Manifest, opts RestoreOptions, result *RestoreResult) error {
+	target, err := openTarget(dbPath, opts)
+	if err != nil {
+		return err
+	}
+	defer target.Close()
+
+	for _, path := range opts.Incrementals {
+		done, err := replay(target, path, base, opts, result)
+		if err != nil {
+			return fmt.Errorf("failed to replay %s: %w", path, err)
+		}
+		if done {
+			break
+		}
+	}
+	return nil
+}
+
+// replay applies the changes of one incremental backup and reports whether
+// the restore target was reached
+func replay(target *storage.LocalStore, path string, base *Manifest, opts RestoreOptions, result *RestoreResult) (bool, error) {
+	dir, m, cleanup, err := Open(path)
+	if err != nil {
+		return false, err
+	}
+	defer cleanup()
+
+	if m.Type != TypeIncremental {
+		return false, fmt.Errorf("%w: not an incremental backup", ErrInvalidBackup)
+	}
+	if m.NodeID != base.NodeID {
+		return false, fmt.Errorf("%w: taken on node %s, full backup on %s", ErrInvalidBackup, m.NodeID, base.NodeID)
+	}
+	if m.BaseSequence > result.Sequence {
+		return false, fmt.Errorf("%w: backup starts after %d, database is at %d", storage.ErrSequenceGap, m.BaseSequence, result.Sequence)
+	}
+
+	f, err := os.Open(filepath.Join(dir, ChangesFile))
+	if err != nil {
+		return false, fmt.Errorf("%w: %v", ErrInvalidBackup, err)
+	}
+	defer f.Close()
+
+	flush := func(events []watch.Event) error {
+		n, err := target.Replay(events)
+		if err != nil {
+			return err
+		}
+		result.Replayed += int64(n)
+		result.Sequence = target.Feed().LastSeq()
+		return nil
+	}
+
+	var r io.Reader = bufio.NewReader(f)
+	if m.Encrypted {
+		if err := importKeys(target, dir); err != nil {
+			return false, err
+		}
+		r = target.NewSealedReader(r)
+	}
+
+	dec := json.NewDecoder(r)
+	events := make([]watch.Event, 0, replayBatchSize)
+	done := false
+	for {
+		var e watch.Event
+		if err := dec.Decode(&e); err != nil {
+			if errors.Is(err, io.EOF) {
+				break
+			}
+			return false, fmt.Errorf("%w: %v", ErrInvalidBackup, err)
+		}
+		if (opts.ToSeq > 0 && e.Seq > opts.ToSeq) || (!opts.ToTime.IsZero() && e.Timestamp.After(opts.ToTime)) {
+			done = true
+			break
+		}
+
+		events = append(events, e)
+		if len(events) >= replayBatchSize {
+			if err := flush(events); err != nil {
+				return false, err
+			}
+			events = events[:0]
+		}
+	}
+	if err := flush(events); err != nil {
+		return false, err
+	}
+	if err := restoreDefinitions(target, dir, path, done, result); err != nil {
+		return false, err
+	}
+	return done || (opts.ToSeq > 0 && result.Sequence >= opts.ToSeq), nil
+}
+
+// restoreDefinitions brings the settings and indexes of target to those
+// stored with an incremental backup. They are as of when the backup was
+// taken, so they are only applied once every change of it was replayed;
+// stopping part way keeps the ones replayed so far and warns if they
+// differ.
+func restoreDefinitions(target *storage.LocalStore, dir, path string, partial bool, result *RestoreResult) error {
+	defs, ok, err := readDefinitions(dir)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		result.Warnings = append(result.Warnings, fmt.Sprintf(
+			"%s holds no collection definitions; settings and indexes changed in it are not restored", path))
+		return nil
+	}
+	if !partial {
+		return target.ApplyDefinitions(defs)
+	}
+
+	current, err := target.Definitions()
+	if err != nil {
+		return err
+	}
+	if !reflect.DeepEqual(normalizeDefinitions(current), normalizeDefinitions(defs)) {
+		result.Warnings = append(result.Warnings, fmt.Sprintf(
+			"the restore stopped inside %s; settings and indexes changed in it are not restored, check them", path))
+	}
+	return nil
+}
+
+// normalizeDefinitions returns defs with empty lists as nil, for comparing
+func normalizeDefinitions(defs []storage.CollectionDefinition) []storage.CollectionDefinition {
+	out := make([]storage.CollectionDefinition, len(defs))
+	for i, d := range defs {
+		if len(d.Indexes) == 0 {
+			d.Indexes = nil
+		}
+		if len(d.TextIndex) == 0 {
+			d.TextIndex = nil
+		}
+		var settings bytes.Buffer
+		if json.Compact(&settings, d.Settings) == nil && settings.Len() > 0 {
+			d.Settings = settings.Bytes()
+		} else {
+			d.Settings = nil
+		}
+		out[i] = d
+	}
+	return out
+}
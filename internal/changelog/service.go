@@ -0,0 +1,135 @@
+package changelog
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+
+	"kiwi/internal/storage"
+	"kiwi/internal/watch"
+	pb "kiwi/proto"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// batchSize is the number of entries read from the changelog at a time
+const batchSize = 256
+
+// Service exposes the durable changelog over gRPC
+type Service struct {
+	pb.UnimplementedChangelogServiceServer
+	store *storage.LocalStore
+	done  chan struct{}
+}
+
+// NewService creates a changelog gRPC service
+func NewService(store *storage.LocalStore) *Service {
+	return &Service{store: store, done: make(chan struct{})}
+}
+
+// Stop ends all open subscriptions, so the gRPC server can stop gracefully
+func (s *Service) Stop() {
+	close(s.done)
+}
+
+// Subscribe streams changelog entries after the requested offset and keeps
+// following new entries until the client cancels
+func (s *Service) Subscribe(req *pb.SubscribeRequest, stream pb.ChangelogService_SubscribeServer) error {
+	filter := watch.Filter{Collection: req.Collection, Prefix: req.Prefix}
+
+	var seq uint64
+	switch {
+	case req.After != nil:
+		seq = *req.After
+	case req.Consumer != "":
+		var err error
+		if seq, err = s.store.ConsumerStart(req.Consumer, req.Collection); err != nil {
+			return toStatus(err)
+		}
+	default:
+		seq = s.store.Feed().LastSeq()
+	}
+
+	for {
+		// Taken before reading, so a commit between the read and the wait
+		// is not missed
+		wait := s.store.Feed().Wait()
+
+		page, err := s.store.ReadChangelog(seq, filter, batchSize)
+		if err != nil {
+			return toStatus(err)
+		}
+		for _, e := range page.Events {
+			if err := stream.Send(changeEvent(e)); err != nil {
+				return err
+			}
+		}
+
+		seq = page.NextSeq
+		if seq < page.LastSeq {
+			continue
+		}
+
+		select {
+		case <-wait:
+		case <-stream.Context().Done():
+			return nil
+		case <-s.done:
+			return status.Error(codes.Unavailable, "server shutting down")
+		}
+	}
+}
+
+// CommitOffset records the offset a consumer has processed
+func (s *Service) CommitOffset(ctx context.Context, req *pb.CommitOffsetRequest) (*pb.CommitOffsetResponse, error) {
+	co, err := s.store.CommitOffset(req.Consumer, req.Collection, req.Offset)
+	if err != nil {
+		return nil, toStatus(err)
+	}
+	return &pb.CommitOffsetResponse{Offset: co.Offset}, nil
+}
+
+// changeEvent converts a changelog entry to its protobuf form
+func changeEvent(e watch.Event) *pb.ChangeEvent {
+	op := pb.OperationType_PUT
+	switch e.Type {
+	case watch.EventDelete:
+		op = pb.OperationType_DELETE
+	case watch.EventTruncate:
+		op = pb.OperationType_TRUNCATE_COLLECTION
+	case watch.EventDrop:
+		op = pb.OperationType_DROP_COLLECTION
+	}
+	ce := &pb.ChangeEvent{
+		Seq:         e.Seq,
+		Operation:   op,
+		Collection:  e.Collection,
+		Key:         e.Key,
+		Value:       e.Value,
+		Timestamp:   e.Timestamp.UnixNano(),
+		ContentType: e.ContentType,
+		Blob:        e.Blob,
+	}
+
+	// Raw values are sent as the bytes themselves, blobs as their manifest
+	var raw storage.RawValue
+	if e.ContentType != "" && !e.Blob && json.Unmarshal(e.Value, &raw) == nil {
+		ce.Value = raw.Data
+	}
+	return ce
+}
+
+// toStatus maps changelog errors to gRPC status codes
+func toStatus(err error) error {
+	switch {
+	case errors.Is(err, storage.ErrChangelogDisabled):
+		return status.Error(codes.FailedPrecondition, err.Error())
+	case errors.Is(err, storage.ErrOffsetExpired), errors.Is(err, storage.ErrOffsetAhead):
+		return status.Error(codes.OutOfRange, err.Error())
+	case errors.Is(err, storage.ErrInvalidKey):
+		return status.Error(codes.InvalidArgument, err.Error())
+	default:
+		return status.Error(codes.Internal, err.Error())
+	}
+}
@@ -1,8 +1,12 @@
 package config
 
 import (
+	"encoding/base64"
+	"fmt"
 	"os"
+	"strconv"
 	"strings"
+	"time"
 )
 
 // Version info - set at build time via ldflags
@@ -29,12 +33,44 @@ type Config struct {
 	GitCommit    string
 	BuildTime    string
 
+	// Storage settings
+	StorageEngine  string // leveldb, pebble or memory
+	CacheSizeMB    int    // read cache size, 0 = disabled
+	MaxMemoryMB    int    // memory limit of the memory engine, 0 = none
+	EvictionPolicy string // lru, lfu or ttl
+	EvictionScope  string // replicated or local
+
+	// LevelDB tuning, 0 keeps LevelDB's default
+	LevelDBBlockCacheMB  int    // block cache size
+	LevelDBWriteBufferMB int    // memtable size
+	LevelDBBloomBits     int    // bloom filter bits per key, 0 = no filter
+	LevelDBCompression   string // snappy or none
+	LevelDBOpenFiles     int    // open files limit
+
 	// Replication settings
 	NodeID     string   // Unique identifier for this node
 	Role       Role     // master or slave
 	GRPCPort   string   // Port for gRPC replication service
 	MasterAddr string   // Master address (for slaves to connect)
 	SlaveAddrs []string // Slave addresses (for master to replicate to)
+
+	// Changelog settings
+	ChangelogEnabled    bool          // Record every committed change durably
+	ChangelogRetention  time.Duration // Drop entries older than this (0 = keep)
+	ChangelogMaxEntries int           // Keep at most this many entries (0 = no limit)
+
+	// Backup settings
+	BackupDir string // Default directory for backups
+
+	// Expiry settings
+	ExpiryInterval time.Duration // How often the master deletes expired keys
+
+	// Encryption settings. Keys are given directly or as a file holding
+	// them; the previous key is only needed while rotating the master key.
+	EncryptionKey             string // Base64 master key (32 bytes)
+	EncryptionKeyFile         string // File holding the base64 master key
+	EncryptionPreviousKey     string // Base64 master key being rotated out
+	EncryptionPreviousKeyFile string // File holding the previous master key
 }
 
 // Load reads configuration from environment variables with defaults
@@ -57,14 +93,74 @@ func Load() *Config {
 		GitCommit:    GitCommit,
 		BuildTime:    BuildTime,
 
+		StorageEngine:  getEnv("STORAGE_ENGINE", "leveldb"),
+		CacheSizeMB:    getEnvInt("CACHE_SIZE_MB", 0),
+		MaxMemoryMB:    getEnvInt("MAX_MEMORY_MB", 0),
+		EvictionPolicy: getEnv("EVICTION_POLICY", "lru"),
+		EvictionScope:  getEnv("EVICTION_SCOPE", "replicated"),
+
+		LevelDBBlockCacheMB:  getEnvInt("LEVELDB_BLOCK_CACHE_MB", 0),
+		LevelDBWriteBufferMB: getEnvInt("LEVELDB_WRITE_BUFFER_MB", 0),
+		LevelDBBloomBits:     getEnvInt("LEVELDB_BLOOM_BITS", 0),
+		LevelDBCompression:   getEnv("LEVELDB_COMPRESSION", "snappy"),
+		LevelDBOpenFiles:     getEnvInt("LEVELDB_OPEN_FILES", 0),
+
 		NodeID:     getEnv("NODE_ID", "node-1"),
 		Role:       role,
 		GRPCPort:   getEnv("GRPC_PORT", "50051"),
 		MasterAddr: getEnv("MASTER_ADDR", ""),
 		SlaveAddrs: slaveAddrs,
+
+		ChangelogEnabled:    getEnvBool("CHANGELOG_ENABLED", false),
+		ChangelogRetention:  getEnvDuration("CHANGELOG_RETENTION", 7*24*time.Hour),
+		ChangelogMaxEntries: getEnvInt("CHANGELOG_MAX_ENTRIES", 1000000),
+
+		BackupDir: getEnv("BACKUP_DIR", "./backups"),
+
+		ExpiryInterval: getEnvDuration("EXPIRY_INTERVAL", time.Second),
+
+		EncryptionKey:             getEnv("ENCRYPTION_KEY", ""),
+		EncryptionKeyFile:         getEnv("ENCRYPTION_KEY_FILE", ""),
+		EncryptionPreviousKey:     getEnv("ENCRYPTION_PREVIOUS_KEY", ""),
+		EncryptionPreviousKeyFile: getEnv("ENCRYPTION_PREVIOUS_KEY_FILE", ""),
 	}
 }
 
+// MasterKeys returns the encryption master key and the previous one, nil
+// when not configured
+func (c *Config) MasterKeys() (current, previous []byte, err error) {
+	if current, err = loadKey("ENCRYPTION_KEY", c.EncryptionKey, c.EncryptionKeyFile); err != nil {
+		return nil, nil, err
+	}
+	if previous, err = loadKey("ENCRYPTION_PREVIOUS_KEY", c.EncryptionPreviousKey, c.EncryptionPreviousKeyFile); err != nil {
+		return nil, nil, err
+	}
+	if current == nil && previous != nil {
+		return nil, nil, fmt.Errorf("ENCRYPTION_PREVIOUS_KEY is set without ENCRYPTION_KEY")
+	}
+	return current, previous, nil
+}
+
+// loadKey decodes a base64 master key given directly or read from a file
+func loadKey(name, value, file string) ([]byte, error) {
+	if value == "" && file != "" {
+		data, err := os.ReadFile(file)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read %s_FILE: %w", name, err)
+		}
+		value = strings.TrimSpace(string(data))
+	}
+	if value == "" {
+		return nil, nil
+	}
+
+	key, err := base64.StdEncoding.DecodeString(value)
+	if err != nil || len(key) != 32 {
+		return nil, fmt.Errorf("%s must be 32 bytes encoded in base64", name)
+	}
+	return key, nil
+}
+
 // IsMaster returns true if this node is the master
 func (c *Config) IsMaster() bool {
 	return c.Role == RoleMaster
@@ -82,3 +178,28 @@ func getEnv(key, defaultValue string) string {
 	}
 	return defaultValue
 }
+
+// getEnvBool retrieves a boolean environment variable or returns a default value
+func getEnvBool(key string, defaultValue bool) bool {
+	if b, err := strconv.ParseBool(os.Getenv(key)); err == nil {
+		return b
+	}
+	return defaultValue
+}
+
+// getEnvInt retrieves an integer environment variable or returns a default value
+func getEnvInt(key string, defaultValue int) int {
+	if n, err := strconv.Atoi(os.Getenv(key)); err == nil {
+		return n
+	}
+	return defaultValue
+}
+
+// getEnvDuration retrieves a duration environment variable (e.g. "24h") or
+// returns a default value
+func getEnvDuration(key string, defaultValue time.Duration) time.Duration {
+	if d, err := time.ParseDuration(os.Getenv(key)); err == nil {
+		return d
+	}
+	return defaultValue
+}
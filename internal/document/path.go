@@ -0,0 +1,91 @@
+// Package document provides helpers for navigating decoded JSON documents
+// using dotted field paths such as "user.email" or "items.0.price".
+package document
+
+import (
+	"errors"
+	"strconv"
+	"strings"
+)
+
+// ErrInvalidPath is returned when a path cannot be traversed or created
+var ErrInvalidPath = errors.New("invalid field path")
+
+// splitPath splits a dotted path into its segments
+func splitPath(path string) []string {
+	if path == "" {
+		return nil
+	}
+	return strings.Split(path, ".")
+}
+
+// Get returns the value at path and whether it exists.
+// An empty path refers to the whole document.
+func Get(doc interface{}, path string) (interface{}, bool) {
+	node := doc
+	for _, segment := range splitPath(path) {
+		switch n := node.(type) {
+		case map[string]interface{}:
+			child, ok := n[segment]
+			if !ok {
+				return nil, false
+			}
+			node = child
+		case []interface{}:
+			idx, err := strconv.Atoi(segment)
+			if err != nil || idx < 0 || idx >= len(n) {
+				return nil, false
+			}
+			node = n[idx]
+		default:
+			return nil, false
+		}
+	}
+	return node, true
+}
+
+// Set stores value at path and returns the updated document.
+// Missing intermediate objects are created; a nil document becomes an object.
+func Set(doc interface{}, path string, value interface{}) (interface{}, error) {
+	segments := splitPath(path)
+	if len(segments) == 0 {
+		return value, nil
+	}
+	return set(doc, segments, value)
+}
+
+// set is the recursive part of Set
+func set(node interface{}, segments []string, value interface{}) (interface{}, error) {
+	if len(segments) == 0 {
+		return value, nil
+	}
+
+	switch n := node.(type) {
+	case nil:
+		child, err := set(nil, segments[1:], value)
+		if err != nil {
+			return nil, err
+		}
+		return map[string]interface{}{segments[0]: child}, nil
+	case map[string]interface{}:
+		child, err := set(n[segments[0]], segments[1:], value)
+		if err != nil {
+			return nil, err
+		}
+		n[segments[0]] = child
+		return n, nil
+	case []interface{}:
+		idx, err := strconv.Atoi(segments[0])
+		if err != nil || idx < 0 || idx >= len(n) {
+			return nil, ErrInvalidPath
+		}
+		child, err := set(n[idx], segments[1:], value)
+		if err != nil {
+			return nil, err
+		}
+		n[idx] = child
+		return n, nil
+	default:
+		return nil, ErrInvalidPath
+	}
+}
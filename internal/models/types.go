@@ -1,5 +1,10 @@
 package models
 
+import (
+	"encoding/json"
+	"time"
+)
+
 // PutRequest represents the request body for storing an object
 type PutRequest struct {
 	Key   string      `json:"key" validate:"required"`
@@ -30,6 +35,557 @@ type DeleteResponse struct {
 	Key     string `json:"key"`
 }
 
+// NumericRequest represents the optional body of incr/decr/add requests
+type NumericRequest struct {
+	By    *json.Number `json:"by"`
+	Field string       `json:"field"`
+}
+
+// NumericResponse represents the result of a numeric operation
+type NumericResponse struct {
+	Key   string      `json:"key"`
+	Field string      `json:"field,omitempty"`
+	Value json.Number `json:"value"` // integers keep every digit
+}
+
+// BulkItem represents a single operation in a bulk request
+type BulkItem struct {
+	Op         string      `json:"op"`
+	Key        string      `json:"key"`
+	Value      interface{} `json:"value,omitempty"`
+	Collection string      `json:"collection,omitempty"`
+}
+
+// BulkItemResult represents the outcome of a single bulk operation
+type BulkItemResult struct {
+	Op         string            `json:"op,omitempty"`
+	Key        string            `json:"key,omitempty"`
+	Status     int               `json:"status"`
+	Error      string            `json:"error,omitempty"`
+	Violations []SchemaViolation `json:"violations,omitempty"`
+}
+
+// BulkResponse represents the response after a bulk write
+type BulkResponse struct {
+	Errors bool             `json:"errors"`
+	Items  []BulkItemResult `json:"items"`
+}
+
+// MGetRequest represents the request body for fetching many keys
+type MGetRequest struct {
+	Keys []string `json:"keys"`
+}
+
+// MGetItem represents a single key in a multi-get response
+type MGetItem struct {
+	Key   string      `json:"key"`
+	Found bool        `json:"found"`
+	Value interface{} `json:"value,omitempty"`
+}
+
+// MGetResponse represents the response when fetching many keys
+type MGetResponse struct {
+	Count   int        `json:"count"`
+	Objects []MGetItem `json:"objects"`
+}
+
+// IndexRequest represents the request body for creating an index
+type IndexRequest struct {
+	Collection string `json:"collection"`
+	Field      string `json:"field"`
+}
+
+// IndexInfo describes a secondary index
+type IndexInfo struct {
+	Collection string    `json:"collection"`
+	Field      string    `json:"field"`
+	CreatedAt  time.Time `json:"created_at"`
+}
+
+// IndexListResponse represents the response when listing indexes
+type IndexListResponse struct {
+	Count   int         `json:"count"`
+	Indexes []IndexInfo `json:"indexes"`
+}
+
+// IndexResponse represents the response after an index change
+type IndexResponse struct {
+	Message    string `json:"message"`
+	Collection string `json:"collection"`
+	Field      string `json:"field,omitempty"`
+}
+
+// QueryRequest represents the request body for querying a collection
+type QueryRequest struct {
+	Collection string   `json:"collection"`
+	Filter     string   `json:"filter"`
+	Sort       []string `json:"sort"`
+	Fields     []string `json:"fields"`
+	Offset     int      `json:"offset"`
+	Limit      int      `json:"limit"`
+}
+
+// AggregateMetric represents a single aggregate to compute
+type AggregateMetric struct {
+	Op    string `json:"op"`
+	Field string `json:"field,omitempty"`
+	As    string `json:"as,omitempty"`
+}
+
+// AggregateRequest represents the request body for aggregating a collection
+type AggregateRequest struct {
+	Collection string            `json:"collection"`
+	Filter     string            `json:"filter"`
+	GroupBy    []string          `json:"group_by"`
+	Metrics    []AggregateMetric `json:"metrics"`
+}
+
+// AggregateGroup represents the aggregates of one group
+type AggregateGroup struct {
+	Key    map[string]interface{} `json:"key,omitempty"`
+	Values map[string]interface{} `json:"values"`
+}
+
+// AggregateResponse represents the response of an aggregation
+type AggregateResponse struct {
+	Count  int              `json:"count"`
+	Groups []AggregateGroup `json:"groups"`
+}
+
+// TextIndexRequest represents the request body for enabling full-text search
+type TextIndexRequest struct {
+	Collection string   `json:"collection"`
+	Fields     []string `json:"fields"`
+}
+
+// TextIndexInfo describes a full-text index
+type TextIndexInfo struct {
+	Collection string    `json:"collection"`
+	Fields     []string  `json:"fields"`
+	CreatedAt  time.Time `json:"created_at"`
+}
+
+// TextIndexListResponse represents the response when listing full-text indexes
+type TextIndexListResponse struct {
+	Count   int             `json:"count"`
+	Indexes []TextIndexInfo `json:"indexes"`
+}
+
+// SearchHit represents a single ranked search result
+type SearchHit struct {
+	Key        string            `json:"key"`
+	Score      float64           `json:"score"`
+	Value      interface{}       `json:"value"`
+	Highlights map[string]string `json:"highlights,omitempty"`
+}
+
+// SearchResponse represents the response of a full-text search
+type SearchResponse struct {
+	Total int         `json:"total"`
+	Count int         `json:"count"`
+	Hits  []SearchHit `json:"hits"`
+}
+
+// ObjectItem represents a single key-value pair in an ordered result
+type ObjectItem struct {
+	Key   string      `json:"key"`
+	Value interface{} `json:"value"`
+}
+
+// QueryResponse represents an ordered list of matching objects
+type QueryResponse struct {
+	Count   int          `json:"count"`
+	Objects []ObjectItem `json:"objects"`
+}
+
+// WatchEvent represents a committed change streamed to watchers
+type WatchEvent struct {
+	Seq         uint64          `json:"seq"`
+	Type        string          `json:"type"`
+	Collection  string          `json:"collection"`
+	Key         string          `json:"key"`
+	Value       json.RawMessage `json:"value,omitempty"`
+	ContentType string          `json:"content_type,omitempty"`
+	Blob        bool            `json:"blob,omitempty"`
+	Timestamp   time.Time       `json:"timestamp"`
+}
+
+// ChangelogResponse represents a page of changelog entries
+type ChangelogResponse struct {
+	Count    int          `json:"count"`
+	Events   []WatchEvent `json:"events"`
+	NextSeq  uint64       `json:"next_seq"`
+	FirstSeq uint64       `json:"first_seq"`
+	LastSeq  uint64       `json:"last_seq"`
+}
+
+// OffsetRequest represents the request body for committing a consumer offset
+type OffsetRequest struct {
+	Collection string `json:"collection"`
+	Offset     uint64 `json:"offset"`
+}
+
+// ConsumerOffset represents the committed position of a changelog consumer
+type ConsumerOffset struct {
+	Consumer   string    `json:"consumer"`
+	Collection string    `json:"collection"`
+	Offset     uint64    `json:"offset"`
+	UpdatedAt  time.Time `json:"updated_at"`
+}
+
+// ConsumerListResponse represents the response for listing consumers
+type ConsumerListResponse struct {
+	Count     int              `json:"count"`
+	Consumers []ConsumerOffset `json:"consumers"`
+}
+
+// WebhookRequest represents the request body for creating or replacing a webhook
+type WebhookRequest struct {
+	URL         string   `json:"url"`
+	Collection  string   `json:"collection"`
+	Prefix      string   `json:"prefix"`
+	Events      []string `json:"events"`
+	Secret      string   `json:"secret"`
+	MaxAttempts int      `json:"max_attempts"`
+	Active      *bool    `json:"active"`
+}
+
+// WebhookInfo represents a registered webhook; the secret is never returned
+type WebhookInfo struct {
+	ID          string    `json:"id"`
+	URL         string    `json:"url"`
+	Collection  string    `json:"collection"`
+	Prefix      string    `json:"prefix"`
+	Events      []string  `json:"events"`
+	Signed      bool      `json:"signed"`
+	MaxAttempts int       `json:"max_attempts"`
+	Active      bool      `json:"active"`
+	CreatedAt   time.Time `json:"created_at"`
+}
+
+// WebhookListResponse represents the response for listing webhooks
+type WebhookListResponse struct {
+	Count    int           `json:"count"`
+	Webhooks []WebhookInfo `json:"webhooks"`
+}
+
+// DeadLetter represents a webhook delivery that failed after all retries
+type DeadLetter struct {
+	Event      WatchEvent `json:"event"`
+	Attempts   int        `json:"attempts"`
+	LastStatus int        `json:"last_status,omitempty"`
+	LastError  string     `json:"last_error"`
+	FailedAt   time.Time  `json:"failed_at"`
+}
+
+// DeadLetterListResponse represents the failed deliveries of a webhook
+type DeadLetterListResponse struct {
+	Count       int          `json:"count"`
+	DeadLetters []DeadLetter `json:"dead_letters"`
+}
+
+// RedeliverResponse represents the result of queuing dead letters again
+type RedeliverResponse struct {
+	Message string `json:"message"`
+	Count   int    `json:"count"`
+}
+
+// PingResponse represents the outcome of a test delivery
+type PingResponse struct {
+	Delivered  bool   `json:"delivered"`
+	Status     int    `json:"status,omitempty"`
+	Error      string `json:"error,omitempty"`
+	DurationMs int64  `json:"duration_ms"`
+}
+
+// BackupRequest represents the request body for taking a backup
+type BackupRequest struct {
+	Path   string  `json:"path"`
+	Format string  `json:"format"`
+	Type   string  `json:"type"`
+	Since  *uint64 `json:"since"`
+}
+
+// BackupManifest describes the contents of a backup
+type BackupManifest struct {
+	Version     int              `json:"version"`
+	Type        string           `json:"type"`
+	NodeID      string           `json:"node_id"`
+	Role        string           `json:"role"`
+	CreatedAt   time.Time        `json:"created_at"`
+	Sequence    uint64           `json:"sequence"`
+	Keys        int64            `json:"keys"`
+	Collections map[string]int64 `json:"collections"`
+
+	BaseSequence uint64 `json:"base_sequence,omitempty"`
+	Changes      int64  `json:"changes,omitempty"`
+	Encrypted    bool   `json:"encrypted,omitempty"`
+}
+
+// BackupResponse represents the result of a backup
+type BackupResponse struct {
+	Message  string         `json:"message"`
+	Path     string         `json:"path"`
+	Format   string         `json:"format"`
+	Manifest BackupManifest `json:"manifest"`
+}
+
+// EncryptionKey describes a data key, without the key itself. The key with
+// no collection is the node's own, sealing the changelog and dead letters.
+type EncryptionKey struct {
+	ID         string    `json:"id"`
+	Collection string    `json:"collection"`
+	Version    int       `json:"version"`
+	Current    bool      `json:"current"`
+	CreatedAt  time.Time `json:"created_at"`
+}
+
+// EncryptionStatusResponse describes the encryption at rest of a node
+type EncryptionStatusResponse struct {
+	Enabled   bool            `json:"enabled"`
+	MasterKey string          `json:"master_key,omitempty"` // fingerprint
+	Keys      []EncryptionKey `json:"keys"`
+	Rekeying  []string        `json:"rekeying"` // collections being re-encrypted
+}
+
+// RotateKeysRequest represents a data key rotation; without a collection
+// every key is rotated
+type RotateKeysRequest struct {
+	Collection string `json:"collection"`
+}
+
+// RotateKeysResponse lists the data keys created by a rotation
+type RotateKeysResponse struct {
+	Message string          `json:"message"`
+	Keys    []EncryptionKey `json:"keys"`
+}
+
+// CacheStatsResponse describes the read cache of a node
+type CacheStatsResponse struct {
+	Enabled       bool    `json:"enabled"`
+	CapacityBytes int64   `json:"capacity_bytes"`
+	SizeBytes     int64   `json:"size_bytes"`
+	Entries       int     `json:"entries"`
+	Hits          uint64  `json:"hits"`
+	Misses        uint64  `json:"misses"`
+	HitRatio      float64 `json:"hit_ratio"`
+	Evictions     uint64  `json:"evictions"`
+	Invalidations uint64  `json:"invalidations"`
+}
+
+// MemoryStatsResponse describes the memory limit of a node
+type MemoryStatsResponse struct {
+	Enabled        bool   `json:"enabled"`
+	MaxMemoryBytes int64  `json:"max_memory_bytes"`
+	UsedBytes      int64  `json:"used_bytes"`
+	Policy         string `json:"policy,omitempty"`
+	Scope          string `json:"scope,omitempty"`
+	TrackedKeys    int    `json:"tracked_keys"`
+	Evicted        uint64 `json:"evicted"`
+}
+
+// StorageStatsResponse describes the storage engine of a node
+type StorageStatsResponse struct {
+	Engine      string              `json:"engine"`
+	SizeBytes   int64               `json:"size_bytes"`
+	Collections []CollectionSize    `json:"collections"`
+	EngineStats *StorageEngineStats `json:"engine_stats,omitempty"` // engines that report them
+}
+
+// CollectionSize is the approximate size of a collection
+type CollectionSize struct {
+	Name      string `json:"name"`
+	Keys      int64  `json:"keys"`
+	SizeBytes int64  `json:"size_bytes"`
+}
+
+// StorageEngineStats describes the internals of a storage engine
+type StorageEngineStats struct {
+	Levels            []StorageLevel `json:"levels"`
+	Files             int            `json:"files"`
+	OpenTables        int            `json:"open_tables"`
+	BlockCacheBytes   int64          `json:"block_cache_bytes"`
+	IOReadBytes       int64          `json:"io_read_bytes"`
+	IOWriteBytes      int64          `json:"io_write_bytes"`
+	WriteDelays       int64          `json:"write_delays"`
+	WriteDelaySeconds float64        `json:"write_delay_seconds"`
+	WritePaused       bool           `json:"write_paused"`
+}
+
+// StorageLevel describes a level of the storage engine and the compactions
+// into it
+type StorageLevel struct {
+	Level             int     `json:"level"`
+	Tables            int     `json:"tables"`
+	SizeBytes         int64   `json:"size_bytes"`
+	CompactionSeconds float64 `json:"compaction_seconds"`
+	ReadBytes         int64   `json:"read_bytes"`
+	WriteBytes        int64   `json:"write_bytes"`
+}
+
+// CollectionInfo describes a collection
+type CollectionInfo struct {
+	Name        string              `json:"name"`
+	Keys        int64               `json:"keys"`
+	SizeBytes   int64               `json:"size_bytes"`
+	Indexes     []string            `json:"indexes"`
+	TextIndexed []string            `json:"text_indexed,omitempty"`
+	Settings    *CollectionSettings `json:"settings,omitempty"`
+}
+
+// CollectionSettings represents the registered settings of a collection
+type CollectionSettings struct {
+	Name              string          `json:"name"`
+	CreatedAt         time.Time       `json:"created_at"`
+	Owner             string          `json:"owner,omitempty"`
+	DefaultTTLSeconds int64           `json:"default_ttl_seconds"`
+	MaxValueSize      int64           `json:"max_value_size"`
+	Schema            json.RawMessage `json:"schema,omitempty"`
+	SchemaVersion     int             `json:"schema_version,omitempty"`
+	Replication       string          `json:"replication"`
+	Compression       string          `json:"compression"`
+	Cache             string          `json:"cache"`
+}
+
+// CollectionRequest represents the request body for creating a collection
+// or changing its settings. Omitted fields keep their current value; a null
+// schema removes the schema.
+type CollectionRequest struct {
+	Name              string          `json:"name,omitempty"`
+	Owner             *string         `json:"owner,omitempty"`
+	DefaultTTLSeconds *int64          `json:"default_ttl_seconds,omitempty"`
+	MaxValueSize      *int64          `json:"max_value_size,omitempty"`
+	Schema            json.RawMessage `json:"schema,omitempty"`
+	Replication       *string         `json:"replication,omitempty"`
+	Compression       *string         `json:"compression,omitempty"`
+	Cache             *string         `json:"cache,omitempty"`
+}
+
+// CollectionListResponse represents the response when listing collections
+type CollectionListResponse struct {
+	Count       int              `json:"count"`
+	Collections []CollectionInfo `json:"collections"`
+}
+
+// CollectionTargetRequest represents the request body for renaming or
+// copying a collection
+type CollectionTargetRequest struct {
+	To string `json:"to"`
+}
+
+// CollectionResponse represents the result of a collection operation
+type CollectionResponse struct {
+	Message    string `json:"message"`
+	Collection string `json:"collection"`
+	Target     string `json:"target,omitempty"`
+	Keys       int64  `json:"keys"`
+}
+
+// ImportResponse represents the result of an import. On failure Error is
+// set and the counts cover the batches written before it.
+type ImportResponse struct {
+	Imported   int               `json:"imported"`
+	Skipped    int               `json:"skipped"`
+	Error      string            `json:"error,omitempty"`
+	Violations []SchemaViolation `json:"violations,omitempty"`
+}
+
+// SchemaViolation represents one way a value does not match a schema. Path
+// is a JSON Pointer into the value.
+type SchemaViolation struct {
+	Path    string `json:"path"`
+	Message string `json:"message"`
+}
+
+// SchemaErrorResponse represents a write rejected by the collection schema
+type SchemaErrorResponse struct {
+	Error      string            `json:"error"`
+	Violations []SchemaViolation `json:"violations"`
+}
+
+// SchemaResponse represents the current schema of a collection
+type SchemaResponse struct {
+	Collection string          `json:"collection"`
+	Version    int             `json:"version"`
+	Schema     json.RawMessage `json:"schema,omitempty"`
+	UpdatedAt  time.Time       `json:"updated_at"`
+}
+
+// SchemaVersionInfo represents one version of a collection's schema; a
+// version without a schema records its removal
+type SchemaVersionInfo struct {
+	Version   int             `json:"version"`
+	Schema    json.RawMessage `json:"schema,omitempty"`
+	CreatedAt time.Time       `json:"created_at"`
+}
+
+// SchemaVersionListResponse represents the schema history of a collection
+type SchemaVersionListResponse struct {
+	Collection string              `json:"collection"`
+	Count      int                 `json:"count"`
+	Versions   []SchemaVersionInfo `json:"versions"`
+}
+
+// DocumentViolations represents a stored document that does not match a schema
+type DocumentViolations struct {
+	Key        string            `json:"key"`
+	Violations []SchemaViolation `json:"violations"`
+}
+
+// SchemaCheckResponse represents the result of a schema dry run
+type SchemaCheckResponse struct {
+	Valid     bool                 `json:"valid"`
+	Checked   int                  `json:"checked"`
+	Violating int                  `json:"violating"`
+	Documents []DocumentViolations `json:"documents"`
+}
+
+// BlobPart represents one stored part of a blob
+type BlobPart struct {
+	Number int    `json:"number"`
+	Size   int64  `json:"size"`
+	Chunks int    `json:"chunks"`
+	SHA256 string `json:"sha256"`
+}
+
+// BlobResponse represents a blob stored under a key
+type BlobResponse struct {
+	Collection  string     `json:"collection"`
+	Key         string     `json:"key"`
+	Blob        string     `json:"blob"`
+	ContentType string     `json:"content_type"`
+	Size        int64      `json:"size"`
+	ChunkSize   int        `json:"chunk_size"`
+	Parts       []BlobPart `json:"parts"`
+	CreatedAt   time.Time  `json:"created_at"`
+}
+
+// CreateUploadRequest represents the request body for starting an upload
+type CreateUploadRequest struct {
+	Collection  string `json:"collection"`
+	Key         string `json:"key"`
+	ContentType string `json:"content_type"`
+}
+
+// UploadResponse represents an upload in progress
+type UploadResponse struct {
+	UploadID    string     `json:"upload_id"`
+	Collection  string     `json:"collection"`
+	Key         string     `json:"key"`
+	ContentType string     `json:"content_type"`
+	Size        int64      `json:"size"` // of the parts stored so far
+	Parts       []BlobPart `json:"parts"`
+	CreatedAt   time.Time  `json:"created_at"`
+	UpdatedAt   time.Time  `json:"updated_at"`
+	ExpiresAt   time.Time  `json:"expires_at"`
+}
+
+// UploadListResponse represents the uploads in progress
+type UploadListResponse struct {
+	Count   int              `json:"count"`
+	Uploads []UploadResponse `json:"uploads"`
+}
+
 // ErrorResponse represents an error response
 type ErrorResponse struct {
 	Error string `json:"error"`
@@ -0,0 +1,405 @@
+// Package patch implements partial document updates using
+// JSON Patch (RFC 6902) and JSON Merge Patch (RFC 7396).
+//
+// Documents are the generic values produced by encoding/json
+// (map[string]interface{}, []interface{}, float64, string, bool, nil).
+// Numbers may also be json.Number, as from a decoder with UseNumber; values
+// taken from patches are decoded that way, so large integers keep every
+// digit.
+package patch
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math/big"
+	"strconv"
+	"strings"
+)
+
+var (
+	// ErrInvalidPatch is returned when a patch document is malformed
+	ErrInvalidPatch = errors.New("invalid patch")
+
+	// ErrPathNotFound is returned when a patch references a missing location
+	ErrPathNotFound = errors.New("path not found")
+
+	// ErrTestFailed is returned when a JSON Patch "test" operation fails
+	ErrTestFailed = errors.New("test operation failed")
+)
+
+// Operation is a single RFC 6902 JSON Patch operation
+type Operation struct {
+	Op    string          `json:"op"`
+	Path  string          `json:"path"`
+	From  string          `json:"from,omitempty"`
+	Value json.RawMessage `json:"value,omitempty"`
+}
+
+// ApplyJSONPatch applies an RFC 6902 JSON Patch to doc and returns the result.
+// The patch is applied in order and fails as a whole if any operation fails;
+// doc may be modified in place, so callers should discard it on error.
+func ApplyJSONPatch(doc interface{}, patchData []byte) (interface{}, error) {
+	var ops []Operation
+	if err := json.Unmarshal(patchData, &ops); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidPatch, err)
+	}
+
+	var err error
+	for i, op := range ops {
+		if doc, err = applyOperation(doc, op); err != nil {
+			return nil, fmt.Errorf("operation %d (%s %s): %w", i, op.Op, op.Path, err)
+		}
+	}
+
+	return doc, nil
+}
+
+// ApplyMergePatch applies an RFC 7396 JSON Merge Patch to doc and returns the result
+func ApplyMergePatch(doc interface{}, patchData []byte) (interface{}, error) {
+	var p interface{}
+	if err := decode(patchData, &p); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidPatch, err)
+	}
+	return mergePatch(doc, p), nil
+}
+
+// mergePatch implements the MergePatch algorithm from RFC 7396 section 2
+func mergePatch(target, p interface{}) interface{} {
+	patchObj, ok := p.(map[string]interface{})
+	if !ok {
+		return p
+	}
+
+	targetObj, ok := target.(map[string]interface{})
+	if !ok {
+		targetObj = make(map[string]interface{})
+	}
+
+	for name, value := range patchObj {
+		if value == nil {
+			delete(targetObj, name)
+		} else {
+			targetObj[name] = mergePatch(targetObj[name], value)
+		}
+	}
+
+	return targetObj
+}
+
+// applyOperation applies a single JSON Patch operation
+func applyOperation(doc interface{}, op Operation) (interface{}, error) {
+	path, err := parsePointer(op.Path)
+	if err != nil {
+		return nil, err
+	}
+
+	switch op.Op {
+	case "add", "replace", "test":
+		if len(op.Value) == 0 {
+			return nil, fmt.Errorf("%w: missing value", ErrInvalidPatch)
+		}
+		var value interface{}
+		if err := decode(op.Value, &value); err != nil {
+			return nil, fmt.Errorf("%w: %v", ErrInvalidPatch, err)
+		}
+		switch op.Op {
+		case "add":
+			return add(doc, path, value)
+		case "replace":
+			return replace(doc, path, value)
+		default:
+			current, err := get(doc, path)
+			if err != nil {
+				return nil, err
+			}
+			if !equal(current, value) {
+				return nil, ErrTestFailed
+			}
+			return doc, nil
+		}
+
+	case "remove":
+		return remove(doc, path)
+
+	case "move", "copy":
+		from, err := parsePointer(op.From)
+		if err != nil {
+			return nil, err
+		}
+		value, err := get(doc, from)
+		if err != nil {
+			return nil, err
+		}
+		if op.Op == "copy" {
+			return add(doc, path, deepCopy(value))
+		}
+		if op.From == op.Path {
+			return doc, nil
+		}
+		if strings.HasPrefix(op.Path, op.From+"/") {
+			return nil, fmt.Errorf("%w: cannot move a value into one of its children", ErrInvalidPatch)
+		}
+		if doc, err = remove(doc, from); err != nil {
+			return nil, err
+		}
+		return add(doc, path, value)
+
+	default:
+		return nil, fmt.Errorf("%w: unknown operation %q", ErrInvalidPatch, op.Op)
+	}
+}
+
+// decode unmarshals a single JSON value, keeping numbers as json.Number
+func decode(data []byte, v interface{}) error {
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+	if err := dec.Decode(v); err != nil {
+		return err
+	}
+	if _, err := dec.Token(); err != io.EOF {
+		return errors.New("unexpected data after the JSON value")
+	}
+	return nil
+}
+
+// equal reports whether two JSON values are equal as defined for the
+// "test" operation: numbers compare by value, whatever their form
+func equal(a, b interface{}) bool {
+	switch x := a.(type) {
+	case map[string]interface{}:
+		y, ok := b.(map[string]interface{})
+		if !ok || len(x) != len(y) {
+			return false
+		}
+		for k, v := range x {
+			w, ok := y[k]
+			if !ok || !equal(v, w) {
+				return false
+			}
+		}
+		return true
+	case []interface{}:
+		y, ok := b.([]interface{})
+		if !ok || len(x) != len(y) {
+			return false
+		}
+		for i := range x {
+			if !equal(x[i], y[i]) {
+				return false
+			}
+		}
+		return true
+	}
+
+	m, aNum := number(a)
+	n, bNum := number(b)
+	if aNum || bNum {
+		return aNum && bNum && m.Cmp(n) == 0
+	}
+	return a == b
+}
+
+// number returns the exact value of a JSON number
+func number(v interface{}) (*big.Rat, bool) {
+	switch n := v.(type) {
+	case json.Number:
+		return new(big.Rat).SetString(string(n))
+	case float64:
+		r := new(big.Rat).SetFloat64(n)
+		return r, r != nil
+	}
+	return nil, false
+}
+
+// parsePointer splits an RFC 6901 JSON Pointer into unescaped reference tokens
+func parsePointer(pointer string) ([]string, error) {
+	if pointer == "" {
+		return nil, nil
+	}
+	if pointer[0] != '/' {
+		return nil, fmt.Errorf("%w: pointer %q must start with '/'", ErrInvalidPatch, pointer)
+	}
+
+	tokens := strings.Split(pointer[1:], "/")
+	for i, t := range tokens {
+		tokens[i] = strings.ReplaceAll(strings.ReplaceAll(t, "~1", "/"), "~0", "~")
+	}
+	return tokens, nil
+}
+
+// arrayIndex parses an array index token; "-" and len are accepted when appending
+func arrayIndex(token string, length int, appending bool) (int, error) {
+	if appending && token == "-" {
+		return length, nil
+	}
+	if token == "" || (len(token) > 1 && token[0] == '0') {
+		return 0, fmt.Errorf("%w: invalid array index %q", ErrPathNotFound, token)
+	}
+	idx, err := strconv.Atoi(token)
+	if err != nil || idx < 0 {
+		return 0, fmt.Errorf("%w: invalid array index %q", ErrPathNotFound, token)
+	}
+	if idx > length || (!appending && idx == length) {
+		return 0, fmt.Errorf("%w: array index %d out of range", ErrPathNotFound, idx)
+	}
+	return idx, nil
+}
+
+// get returns the value referenced by path
+func get(doc interface{}, path []string) (interface{}, error) {
+	node := doc
+	for _, token := range path {
+		switch n := node.(type) {
+		case map[string]interface{}:
+			child, ok := n[token]
+			if !ok {
+				return nil, fmt.Errorf("%w: %q", ErrPathNotFound, token)
+			}
+			node = child
+		case []interface{}:
+			idx, err := arrayIndex(token, len(n), false)
+			if err != nil {
+				return nil, err
+			}
+			node = n[idx]
+		default:
+			return nil, fmt.Errorf("%w: %q", ErrPathNotFound, token)
+		}
+	}
+	return node, nil
+}
+
+// update walks to the container holding the last path token and replaces it
+// with the result of fn. Containers are rebuilt on the way back up because
+// slices may be reallocated.
+func update(node interface{}, path []string, fn func(container interface{}, token string) (interface{}, error)) (interface{}, error) {
+	if len(path) == 1 {
+		return fn(node, path[0])
+	}
+
+	switch n := node.(type) {
+	case map[string]interface{}:
+		child, ok := n[path[0]]
+		if !ok {
+			return nil, fmt.Errorf("%w: %q", ErrPathNotFound, path[0])
+		}
+		updated, err := update(child, path[1:], fn)
+		if err != nil {
+			return nil, err
+		}
+		n[path[0]] = updated
+		return n, nil
+	case []interface{}:
+		idx, err := arrayIndex(path[0], len(n), false)
+		if err != nil {
+			return nil, err
+		}
+		updated, err := update(n[idx], path[1:], fn)
+		if err != nil {
+			return nil, err
+		}
+		n[idx] = updated
+		return n, nil
+	default:
+		return nil, fmt.Errorf("%w: %q", ErrPathNotFound, path[0])
+	}
+}
+
+// add implements the "add" operation
+func add(doc interface{}, path []string, value interface{}) (interface{}, error) {
+	if len(path) == 0 {
+		return value, nil
+	}
+	return update(doc, path, func(container interface{}, token string) (interface{}, error) {
+		switch c := container.(type) {
+		case map[string]interface{}:
+			c[token] = value
+			return c, nil
+		case []interface{}:
+			idx, err := arrayIndex(token, len(c), true)
+			if err != nil {
+				return nil, err
+			}
+			c = append(c, nil)
+			copy(c[idx+1:], c[idx:])
+			c[idx] = value
+			return c, nil
+		default:
+			return nil, fmt.Errorf("%w: %q", ErrPathNotFound, token)
+		}
+	})
+}
+
+// remove implements the "remove" operation
+func remove(doc interface{}, path []string) (interface{}, error) {
+	if len(path) == 0 {
+		return nil, fmt.Errorf("%w: cannot remove the whole document", ErrInvalidPatch)
+	}
+	return update(doc, path, func(container interface{}, token string) (interface{}, error) {
+		switch c := container.(type) {
+		case map[string]interface{}:
+			if _, ok := c[token]; !ok {
+				return nil, fmt.Errorf("%w: %q", ErrPathNotFound, token)
+			}
+			delete(c, token)
+			return c, nil
+		case []interface{}:
+			idx, err := arrayIndex(token, len(c), false)
+			if err != nil {
+				return nil, err
+			}
+			return append(c[:idx], c[idx+1:]...), nil
+		default:
+			return nil, fmt.Errorf("%w: %q", ErrPathNotFound, token)
+		}
+	})
+}
+
+// replace implements the "replace" operation
+func replace(doc interface{}, path []string, value interface{}) (interface{}, error) {
+	if len(path) == 0 {
+		return value, nil
+	}
+	return update(doc, path, func(container interface{}, token string) (interface{}, error) {
+		switch c := container.(type) {
+		case map[string]interface{}:
+			if _, ok := c[token]; !ok {
+				return nil, fmt.Errorf("%w: %q", ErrPathNotFound, token)
+			}
+			c[token] = value
+			return c, nil
+		case []interface{}:
+			idx, err := arrayIndex(token, len(c), false)
+			if err != nil {
+				return nil, err
+			}
+			c[idx] = value
+			return c, nil
+		default:
+			return nil, fmt.Errorf("%w: %q", ErrPathNotFound, token)
+		}
+	})
+}
+
+// deepCopy copies a decoded JSON value so it can be inserted elsewhere
+func deepCopy(value interface{}) interface{} {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		out := make(map[string]interface{}, len(v))
+		for k, child := range v {
+			out[k] = deepCopy(child)
+		}
+		return out
+	case []interface{}:
+		out := make([]interface{}, len(v))
+		for i, child := range v {
+			out[i] = deepCopy(child)
+		}
+		return out
+	default:
+		return v
+	}
+}
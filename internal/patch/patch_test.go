@@ -0,0 +1,233 @@
+package patch
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"testing"
+)
+
+// doc decodes a JSON document the way the store hands it to a patch
+func doc(t *testing.T, data string) interface{} {
+	t.Helper()
+	var v interface{}
+	if err := decode([]byte(data), &v); err != nil {
+		t.Fatalf("malformed document %s: %v", data, err)
+	}
+	return v
+}
+
+// assertJSON checks that got serializes to the same JSON as want
+func assertJSON(t *testing.T, got interface{}, want string) {
+	t.Helper()
+	data, err := json.Marshal(got)
+	if err != nil {
+		t.Fatal(err)
+	}
+	wantData, _ := json.Marshal(doc(t, want))
+	if !bytes.Equal(data, wantData) {
+		t.Errorf("got %s, want %s", data, wantData)
+	}
+}
+
+func TestApplyJSONPatch(t *testing.T) {
+	tests := []struct {
+		name  string
+		doc   string
+		patch string
+		want  string // the patched document
+		err   error  // the error instead, if any
+	}{
+		// Examples of RFC 6902 appendix A
+		{"A.1 add an object member", `{"foo": "bar"}`,
+			`[{"op": "add", "path": "/baz", "value": "qux"}]`,
+			`{"baz": "qux", "foo": "bar"}`, nil},
+		{"A.2 add an array element", `{"foo": ["bar", "baz"]}`,
+			`[{"op": "add", "path": "/foo/1", "value": "qux"}]`,
+			`{"foo": ["bar", "qux", "baz"]}`, nil},
+		{"A.3 remove an object member", `{"baz": "qux", "foo": "bar"}`,
+			`[{"op": "remove", "path": "/baz"}]`,
+			`{"foo": "bar"}`, nil},
+		{"A.4 remove an array element", `{"foo": ["bar", "qux", "baz"]}`,
+			`[{"op": "remove", "path": "/foo/1"}]`,
+			`{"foo": ["bar", "baz"]}`, nil},
+		{"A.5 replace a value", `{"baz": "qux", "foo": "bar"}`,
+			`[{"op": "replace", "path": "/baz", "value": "boo"}]`,
+			`{"baz": "boo", "foo": "bar"}`, nil},
+		{"A.6 move a value", `{"foo": {"bar": "baz", "waldo": "fred"}, "qux": {"corge": "grault"}}`,
+			`[{"op": "move", "from": "/foo/waldo", "path": "/qux/thud"}]`,
+			`{"foo": {"bar": "baz"}, "qux": {"corge": "grault", "thud": "fred"}}`, nil},
+		{"A.7 move an array element", `{"foo": ["all", "grass", "cows", "eat"]}`,
+			`[{"op": "move", "from": "/foo/1", "path": "/foo/3"}]`,
+			`{"foo": ["all", "cows", "eat", "grass"]}`, nil},
+		{"A.8 test a value", `{"baz": "qux", "foo": ["a", 2, "c"]}`,
+			`[{"op": "test", "path": "/baz", "value": "qux"}, {"op": "test", "path": "/foo/1", "value": 2}]`,
+			`{"baz": "qux", "foo": ["a", 2, "c"]}`, nil},
+		{"A.9 failed test", `{"baz": "qux"}`,
+			`[{"op": "test", "path": "/baz", "value": "bar"}]`,
+			"", ErrTestFailed},
+		{"A.10 add a nested member object", `{"foo": "bar"}`,
+			`[{"op": "add", "path": "/child", "value": {"grandchild": {}}}]`,
+			`{"foo": "bar", "child": {"grandchild": {}}}`, nil},
+		{"A.11 ignore unrecognized elements", `{"foo": "bar"}`,
+			`[{"op": "add", "path": "/baz", "value": "qux", "xyz": 123}]`,
+			`{"foo": "bar", "baz": "qux"}`, nil},
+		{"A.12 add to a nonexistent target", `{"foo": "bar"}`,
+			`[{"op": "add", "path": "/baz/bat", "value": "qux"}]`,
+			"", ErrPathNotFound},
+		{"A.14 ~ escape ordering", `{"/": 9, "~1": 10}`,
+			`[{"op": "test", "path": "/~01", "value": 10}]`,
+			`{"/": 9, "~1": 10}`, nil},
+		{"A.15 compare strings and numbers", `{"/": 9, "~1": 10}`,
+			`[{"op": "test", "path": "/~01", "value": "10"}]`,
+			"", ErrTestFailed},
+		{"A.16 add an array value", `{"foo": ["bar"]}`,
+			`[{"op": "add", "path": "/foo/-", "value": ["abc", "def"]}]`,
+			`{"foo": ["bar", ["abc", "def"]]}`, nil},
+
+		// Array indexes
+		{"append with -", `[1, 2]`,
+			`[{"op": "add", "path": "/-", "value": 3}]`,
+			`[1, 2, 3]`, nil},
+		{"append at the length", `[1, 2]`,
+			`[{"op": "add", "path": "/2", "value": 3}]`,
+			`[1, 2, 3]`, nil},
+		{"add past the length", `[1, 2]`,
+			`[{"op": "add", "path": "/3", "value": 3}]`,
+			"", ErrPathNotFound},
+		{"remove -", `[1, 2]`,
+			`[{"op": "remove", "path": "/-"}]`,
+			"", ErrPathNotFound},
+		{"replace -", `[1, 2]`,
+			`[{"op": "replace", "path": "/-", "value": 3}]`,
+			"", ErrPathNotFound},
+		{"index with a leading zero", `[1, 2]`,
+			`[{"op": "remove", "path": "/01"}]`,
+			"", ErrPathNotFound},
+		{"negative index", `[1, 2]`,
+			`[{"op": "remove", "path": "/-1"}]`,
+			"", ErrPathNotFound},
+
+		// move and copy
+		{"move into a child of itself", `{"a": {"b": 1}}`,
+			`[{"op": "move", "from": "/a", "path": "/a/c"}]`,
+			"", ErrInvalidPatch},
+		{"move to a sibling sharing a prefix", `{"a": 1}`,
+			`[{"op": "move", "from": "/a", "path": "/ab"}]`,
+			`{"ab": 1}`, nil},
+		{"move onto itself", `{"a": {"b": 1}}`,
+			`[{"op": "move", "from": "/a", "path": "/a"}]`,
+			`{"a": {"b": 1}}`, nil},
+		{"copy is independent of its source", `{"a": {"b": 1}}`,
+			`[{"op": "copy", "from": "/a", "path": "/c"}, {"op": "replace", "path": "/c/b", "value": 2}]`,
+			`{"a": {"b": 1}, "c": {"b": 2}}`, nil},
+		{"move from a missing path", `{"a": 1}`,
+			`[{"op": "move", "from": "/b", "path": "/c"}]`,
+			"", ErrPathNotFound},
+
+		// test equality
+		{"test numbers by value", `{"n": 1}`,
+			`[{"op": "test", "path": "/n", "value": 1.0}, {"op": "test", "path": "/n", "value": 1e0}]`,
+			`{"n": 1}`, nil},
+		{"test integers beyond 2^53", `{"n": 9007199254740993}`,
+			`[{"op": "test", "path": "/n", "value": 9007199254740992}]`,
+			"", ErrTestFailed},
+		{"test objects regardless of member order", `{"o": {"a": 1, "b": [true, null]}}`,
+			`[{"op": "test", "path": "/o", "value": {"b": [true, null], "a": 1}}]`,
+			`{"o": {"a": 1, "b": [true, null]}}`, nil},
+		{"test arrays in order", `{"a": [1, 2]}`,
+			`[{"op": "test", "path": "/a", "value": [2, 1]}]`,
+			"", ErrTestFailed},
+		{"test null against a missing member", `{"o": {}}`,
+			`[{"op": "test", "path": "/o/a", "value": null}]`,
+			"", ErrPathNotFound},
+		{"test the whole document", `{"a": 1}`,
+			`[{"op": "test", "path": "", "value": {"a": 1}}]`,
+			`{"a": 1}`, nil},
+
+		// Other operations and errors
+		{"replace the whole document", `{"a": 1}`,
+			`[{"op": "replace", "path": "", "value": [1]}]`,
+			`[1]`, nil},
+		{"replace a missing member", `{"a": 1}`,
+			`[{"op": "replace", "path": "/b", "value": 2}]`,
+			"", ErrPathNotFound},
+		{"remove the whole document", `{"a": 1}`,
+			`[{"op": "remove", "path": ""}]`,
+			"", ErrInvalidPatch},
+		{"operations apply in order", `{}`,
+			`[{"op": "add", "path": "/a", "value": 1}, {"op": "move", "from": "/a", "path": "/b"}]`,
+			`{"b": 1}`, nil},
+		{"one failing operation fails the patch", `{"a": 1}`,
+			`[{"op": "add", "path": "/b", "value": 2}, {"op": "test", "path": "/a", "value": 2}]`,
+			"", ErrTestFailed},
+		{"unknown operation", `{}`, `[{"op": "merge", "path": "/a"}]`, "", ErrInvalidPatch},
+		{"missing value", `{}`, `[{"op": "add", "path": "/a"}]`, "", ErrInvalidPatch},
+		{"pointer without a slash", `{}`, `[{"op": "add", "path": "a", "value": 1}]`, "", ErrInvalidPatch},
+		{"not an array", `{}`, `{"op": "add", "path": "/a", "value": 1}`, "", ErrInvalidPatch},
+		{"keeps large integers", `{"id": 9007199254740993, "n": 1}`,
+			`[{"op": "replace", "path": "/n", "value": 18446744073709551617}]`,
+			`{"id": 9007199254740993, "n": 18446744073709551617}`, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ApplyJSONPatch(doc(t, tt.doc), []byte(tt.patch))
+			if tt.err != nil {
+				if !errors.Is(err, tt.err) {
+					t.Fatalf("got error %v, want %v", err, tt.err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			assertJSON(t, got, tt.want)
+		})
+	}
+}
+
+func TestApplyMergePatch(t *testing.T) {
+	tests := []struct {
+		name  string
+		doc   string
+		patch string
+		want  string
+	}{
+		// Examples of RFC 7396 appendix A
+		{"replace a member", `{"a": "b"}`, `{"a": "c"}`, `{"a": "c"}`},
+		{"add a member", `{"a": "b"}`, `{"b": "c"}`, `{"a": "b", "b": "c"}`},
+		{"delete a member", `{"a": "b"}`, `{"a": null}`, `{}`},
+		{"delete one of two members", `{"a": "b", "b": "c"}`, `{"a": null}`, `{"b": "c"}`},
+		{"replace an array with a string", `{"a": ["b"]}`, `{"a": "c"}`, `{"a": "c"}`},
+		{"replace a string with an array", `{"a": "c"}`, `{"a": ["b"]}`, `{"a": ["b"]}`},
+		{"merge nested objects", `{"a": {"b": "c"}}`, `{"a": {"b": "d", "c": null}}`, `{"a": {"b": "d"}}`},
+		{"replace arrays whole", `{"a": [{"b": "c"}]}`, `{"a": [1]}`, `{"a": [1]}`},
+		{"array patch", `["a", "b"]`, `["c", "d"]`, `["c", "d"]`},
+		{"array patch on an object", `{"a": "b"}`, `["c"]`, `["c"]`},
+		{"null patch", `{"a": "foo"}`, `null`, `null`},
+		{"string patch", `{"a": "foo"}`, `"bar"`, `"bar"`},
+		{"keep stored nulls", `{"e": null}`, `{"a": 1}`, `{"e": null, "a": 1}`},
+		{"object patch on an array", `[1, 2]`, `{"a": "b", "c": null}`, `{"a": "b"}`},
+		{"nulls create no members", `{}`, `{"a": {"bb": {"ccc": null}}}`, `{"a": {"bb": {}}}`},
+
+		{"delete a missing member", `{"a": 1}`, `{"b": null}`, `{"a": 1}`},
+		{"keeps large integers", `{"id": 9007199254740993, "n": 1}`, `{"n": 2}`, `{"id": 9007199254740993, "n": 2}`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ApplyMergePatch(doc(t, tt.doc), []byte(tt.patch))
+			if err != nil {
+				t.Fatal(err)
+			}
+			assertJSON(t, got, tt.want)
+		})
+	}
+}
+
+func TestApplyMergePatchInvalid(t *testing.T) {
+	for _, p := range []string{``, `{`, `{"a": 1} {"b": 2}`} {
+		if _, err := ApplyMergePatch(doc(t, `{}`), []byte(p)); !errors.Is(err, ErrInvalidPatch) {
+			t.Errorf("ApplyMergePatch(%q) = %v, want ErrInvalidPatch", p, err)
+		}
+	}
+}
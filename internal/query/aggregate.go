@@ -0,0 +1,204 @@
+package query
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+
+	"kiwi/internal/document"
+)
+
+// Aggregation operators
+const (
+	AggCount = "count"
+	AggSum   = "sum"
+	AggMin   = "min"
+	AggMax   = "max"
+	AggAvg   = "avg"
+)
+
+// Metric is a single aggregate computed per group
+type Metric struct {
+	Op    string // count, sum, min, max or avg
+	Field string // dotted path; optional for count
+	As    string // result name; defaults to "<op>_<field>" or "count"
+}
+
+// Name returns the result name of the metric
+func (m Metric) Name() string {
+	if m.As != "" {
+		return m.As
+	}
+	if m.Field == "" {
+		return m.Op
+	}
+	return m.Op + "_" + m.Field
+}
+
+// AggregateRequest describes an aggregation over a collection
+type AggregateRequest struct {
+	Filter  Expr     // nil matches every document
+	GroupBy []string // field paths; empty aggregates everything into one group
+	Metrics []Metric
+}
+
+// Group is the aggregate result for one combination of group-by values
+type Group struct {
+	Key    map[string]interface{}
+	Values map[string]interface{}
+}
+
+// accumulator holds the running state of one metric in one group
+type accumulator struct {
+	count int
+	sum   float64
+	min   interface{}
+	max   interface{}
+	seen  bool
+}
+
+// groupState is the running state of one group
+type groupState struct {
+	key  []interface{}
+	accs []accumulator
+}
+
+// ValidateMetrics checks that every metric has a known operator and the fields it needs
+func ValidateMetrics(metrics []Metric) error {
+	for _, m := range metrics {
+		switch m.Op {
+		case AggCount:
+		case AggSum, AggMin, AggMax, AggAvg:
+			if m.Field == "" {
+				return fmt.Errorf("%w: %s requires a field", ErrInvalidQuery, m.Op)
+			}
+		default:
+			return fmt.Errorf("%w: unknown aggregate %q", ErrInvalidQuery, m.Op)
+		}
+	}
+	return nil
+}
+
+// Aggregate computes grouped metrics in a single streaming pass over the
+// matching documents; only per-group state is kept in memory.
+func Aggregate(src Source, collection string, req AggregateRequest) ([]Group, error) {
+	if err := ValidateMetrics(req.Metrics); err != nil {
+		return nil, err
+	}
+
+	groups := make(map[string]*groupState)
+	var order []string
+
+	err := Each(src, collection, req.Filter, func(_ string, value interface{}) bool {
+		key := make([]interface{}, len(req.GroupBy))
+		for i, path := range req.GroupBy {
+			key[i], _ = document.Get(value, path)
+		}
+		id, err := json.Marshal(key)
+		if err != nil {
+			return true
+		}
+
+		g, ok := groups[string(id)]
+		if !ok {
+			g = &groupState{key: key, accs: make([]accumulator, len(req.Metrics))}
+			groups[string(id)] = g
+			order = append(order, string(id))
+		}
+
+		for i, m := range req.Metrics {
+			accumulate(&g.accs[i], m, value)
+		}
+		return true
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	// Without grouping, an empty collection still yields one result
+	if len(req.GroupBy) == 0 && len(groups) == 0 {
+		groups[""] = &groupState{accs: make([]accumulator, len(req.Metrics))}
+		order = append(order, "")
+	}
+
+	result := make([]Group, 0, len(order))
+	for _, id := range order {
+		g := groups[id]
+		group := Group{
+			Key:    make(map[string]interface{}, len(req.GroupBy)),
+			Values: make(map[string]interface{}, len(req.Metrics)),
+		}
+		for i, path := range req.GroupBy {
+			group.Key[path] = g.key[i]
+		}
+		for i, m := range req.Metrics {
+			group.Values[m.Name()] = finalize(g.accs[i], m)
+		}
+		result = append(result, group)
+	}
+
+	sort.SliceStable(result, func(i, j int) bool {
+		for _, path := range req.GroupBy {
+			if c := compareValues(result[i].Key[path], true, result[j].Key[path], true); c != 0 {
+				return c < 0
+			}
+		}
+		return false
+	})
+
+	return result, nil
+}
+
+// accumulate folds one document into a metric's running state
+func accumulate(acc *accumulator, m Metric, doc interface{}) {
+	if m.Field == "" {
+		acc.count++
+		return
+	}
+
+	value, ok := document.Get(doc, m.Field)
+	if !ok || value == nil {
+		return
+	}
+
+	switch m.Op {
+	case AggCount:
+		acc.count++
+	case AggSum, AggAvg:
+		if n, isNum := value.(float64); isNum {
+			acc.sum += n
+			acc.count++
+		}
+	case AggMin:
+		if !acc.seen || compareValues(value, true, acc.min, true) < 0 {
+			acc.min = value
+		}
+		acc.seen = true
+	case AggMax:
+		if !acc.seen || compareValues(value, true, acc.max, true) > 0 {
+			acc.max = value
+		}
+		acc.seen = true
+	}
+}
+
+// finalize converts a metric's running state into its result value
+func finalize(acc accumulator, m Metric) interface{} {
+	switch m.Op {
+	case AggCount:
+		return acc.count
+	case AggSum:
+		return acc.sum
+	case AggAvg:
+		if acc.count == 0 {
+			return nil
+		}
+		return acc.sum / float64(acc.count)
+	case AggMin:
+		return acc.min
+	case AggMax:
+		return acc.max
+	default:
+		return nil
+	}
+}
@@ -0,0 +1,153 @@
+package query
+
+import (
+	"kiwi/internal/document"
+)
+
+// Expr is a parsed filter expression
+type Expr interface {
+	// Match reports whether a document satisfies the expression
+	Match(doc interface{}) bool
+}
+
+type andExpr struct {
+	terms []Expr
+}
+
+func (e *andExpr) Match(doc interface{}) bool {
+	for _, t := range e.terms {
+		if !t.Match(doc) {
+			return false
+		}
+	}
+	return true
+}
+
+type orExpr struct {
+	terms []Expr
+}
+
+func (e *orExpr) Match(doc interface{}) bool {
+	for _, t := range e.terms {
+		if t.Match(doc) {
+			return true
+		}
+	}
+	return false
+}
+
+type notExpr struct {
+	inner Expr
+}
+
+func (e *notExpr) Match(doc interface{}) bool {
+	return !e.inner.Match(doc)
+}
+
+type existsExpr struct {
+	path string
+}
+
+func (e *existsExpr) Match(doc interface{}) bool {
+	_, ok := document.Get(doc, e.path)
+	return ok
+}
+
+// compareExpr compares a field with a literal. Fields holding an array
+// match when any element matches, mirroring how arrays are indexed.
+type compareExpr struct {
+	path  string
+	op    string
+	value interface{}
+}
+
+func (e *compareExpr) Match(doc interface{}) bool {
+	field, ok := document.Get(doc, e.path)
+	if !ok {
+		return e.op == "!="
+	}
+
+	if arr, isArr := field.([]interface{}); isArr {
+		if e.op == "!=" {
+			for _, elem := range arr {
+				if !compare(elem, e.op, e.value) {
+					return false
+				}
+			}
+			return true
+		}
+		for _, elem := range arr {
+			if compare(elem, e.op, e.value) {
+				return true
+			}
+		}
+		return false
+	}
+
+	return compare(field, e.op, e.value)
+}
+
+type inExpr struct {
+	path   string
+	values []interface{}
+}
+
+func (e *inExpr) Match(doc interface{}) bool {
+	for _, v := range e.values {
+		if (&compareExpr{path: e.path, op: "=", value: v}).Match(doc) {
+			return true
+		}
+	}
+	return false
+}
+
+// compare applies op to two scalars. Values of different types are never
+// ordered against each other, so only "!=" can succeed across types.
+func compare(a interface{}, op string, b interface{}) bool {
+	if op == "!=" {
+		return !compare(a, "=", b)
+	}
+
+	switch av := a.(type) {
+	case float64:
+		bv, ok := b.(float64)
+		if !ok {
+			return false
+		}
+		return ordered(op, av < bv, av == bv)
+	case string:
+		bv, ok := b.(string)
+		if !ok {
+			return false
+		}
+		return ordered(op, av < bv, av == bv)
+	case bool:
+		bv, ok := b.(bool)
+		if !ok {
+			return false
+		}
+		return ordered(op, !av && bv, av == bv)
+	case nil:
+		return b == nil && (op == "=" || op == "<=" || op == ">=")
+	default:
+		return false
+	}
+}
+
+// ordered evaluates op given whether a < b and a == b
+func ordered(op string, less, equal bool) bool {
+	switch op {
+	case "=":
+		return equal
+	case "<":
+		return less
+	case "<=":
+		return less || equal
+	case ">":
+		return !less && !equal
+	case ">=":
+		return !less
+	default:
+		return false
+	}
+}
@@ -0,0 +1,341 @@
+// Package query implements kiwi's filter expression language and
+// evaluates queries over a collection, using secondary indexes when possible.
+//
+// Grammar:
+//
+//	expr      := or
+//	or        := and { ("or" | "||") and }
+//	and       := unary { ("and" | "&&") unary }
+//	unary     := ("not" | "!") unary | "(" expr ")" | predicate
+//	predicate := path op literal
+//	           | path "in" "[" literal { "," literal } "]"
+//	           | path "exists"
+//	           | "exists" "(" path ")"
+//	op        := "=" | "==" | "!=" | "<" | "<=" | ">" | ">="
+//	literal   := number | "string" | 'string' | true | false | null
+//	path      := dotted JSON path, e.g. user.address.city or items.0.price
+//
+// Example:
+//
+//	age >= 18 and (role = "admin" or tags in ["vip", "staff"]) and not deleted exists
+package query
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"unicode"
+)
+
+// ErrInvalidQuery is returned when a filter expression cannot be parsed
+var ErrInvalidQuery = errors.New("invalid query")
+
+type tokenKind int
+
+const (
+	tokEOF tokenKind = iota
+	tokIdent
+	tokString
+	tokNumber
+	tokOp
+	tokPunct
+)
+
+type token struct {
+	kind tokenKind
+	text string
+	pos  int
+}
+
+// tokenize splits a filter expression into tokens
+func tokenize(input string) ([]token, error) {
+	var tokens []token
+	i := 0
+	for i < len(input) {
+		ch := input[i]
+		switch {
+		case ch == ' ' || ch == '\t' || ch == '\n' || ch == '\r':
+			i++
+
+		case strings.IndexByte("()[],", ch) >= 0:
+			tokens = append(tokens, token{tokPunct, string(ch), i})
+			i++
+
+		case ch == '=' || ch == '!' || ch == '<' || ch == '>' || ch == '&' || ch == '|':
+			start := i
+			i++
+			if i < len(input) && (input[i] == '=' || (ch == '&' && input[i] == '&') || (ch == '|' && input[i] == '|')) {
+				i++
+			}
+			op := input[start:i]
+			if op == "&" || op == "|" {
+				return nil, fmt.Errorf("%w: unexpected %q at position %d", ErrInvalidQuery, op, start)
+			}
+			tokens = append(tokens, token{tokOp, op, start})
+
+		case ch == '"' || ch == '\'':
+			start := i
+			i++
+			var sb strings.Builder
+			closed := false
+			for i < len(input) {
+				c := input[i]
+				if c == '\\' && i+1 < len(input) {
+					sb.WriteByte(input[i+1])
+					i += 2
+					continue
+				}
+				if c == ch {
+					closed = true
+					i++
+					break
+				}
+				sb.WriteByte(c)
+				i++
+			}
+			if !closed {
+				return nil, fmt.Errorf("%w: unterminated string at position %d", ErrInvalidQuery, start)
+			}
+			tokens = append(tokens, token{tokString, sb.String(), start})
+
+		case ch == '-' || (ch >= '0' && ch <= '9'):
+			start := i
+			i++
+			for i < len(input) && strings.IndexByte("0123456789.eE+-", input[i]) >= 0 {
+				i++
+			}
+			tokens = append(tokens, token{tokNumber, input[start:i], start})
+
+		case ch == '_' || unicode.IsLetter(rune(ch)):
+			start := i
+			for i < len(input) && (input[i] == '_' || input[i] == '.' || input[i] == '-' ||
+				unicode.IsLetter(rune(input[i])) || unicode.IsDigit(rune(input[i]))) {
+				i++
+			}
+			tokens = append(tokens, token{tokIdent, input[start:i], start})
+
+		default:
+			return nil, fmt.Errorf("%w: unexpected character %q at position %d", ErrInvalidQuery, ch, i)
+		}
+	}
+	return append(tokens, token{tokEOF, "", len(input)}), nil
+}
+
+// parser is a recursive-descent parser over a token stream
+type parser struct {
+	tokens []token
+	pos    int
+}
+
+// Parse parses a filter expression. An empty filter matches every document.
+func Parse(filter string) (Expr, error) {
+	if strings.TrimSpace(filter) == "" {
+		return nil, nil
+	}
+
+	tokens, err := tokenize(filter)
+	if err != nil {
+		return nil, err
+	}
+
+	p := &parser{tokens: tokens}
+	expr, err := p.parseOr()
+	if err != nil {
+		return nil, err
+	}
+	if tok := p.peek(); tok.kind != tokEOF {
+		return nil, p.errorf(tok, "unexpected %q", tok.text)
+	}
+	return expr, nil
+}
+
+func (p *parser) peek() token {
+	return p.tokens[p.pos]
+}
+
+func (p *parser) next() token {
+	tok := p.tokens[p.pos]
+	if tok.kind != tokEOF {
+		p.pos++
+	}
+	return tok
+}
+
+// keyword reports whether tok is the given case-insensitive keyword
+func keyword(tok token, word string) bool {
+	return tok.kind == tokIdent && strings.EqualFold(tok.text, word)
+}
+
+func (p *parser) errorf(tok token, format string, args ...interface{}) error {
+	return fmt.Errorf("%w: %s at position %d", ErrInvalidQuery, fmt.Sprintf(format, args...), tok.pos)
+}
+
+func (p *parser) expectPunct(punct string) error {
+	tok := p.next()
+	if tok.kind != tokPunct || tok.text != punct {
+		return p.errorf(tok, "expected %q", punct)
+	}
+	return nil
+}
+
+func (p *parser) parseOr() (Expr, error) {
+	left, err := p.parseAnd()
+	if err != nil {
+		return nil, err
+	}
+	terms := []Expr{left}
+	for {
+		tok := p.peek()
+		if !keyword(tok, "or") && !(tok.kind == tokOp && tok.text == "||") {
+			break
+		}
+		p.next()
+		right, err := p.parseAnd()
+		if err != nil {
+			return nil, err
+		}
+		terms = append(terms, right)
+	}
+	if len(terms) == 1 {
+		return left, nil
+	}
+	return &orExpr{terms: terms}, nil
+}
+
+func (p *parser) parseAnd() (Expr, error) {
+	left, err := p.parseUnary()
+	if err != nil {
+		return nil, err
+	}
+	terms := []Expr{left}
+	for {
+		tok := p.peek()
+		if !keyword(tok, "and") && !(tok.kind == tokOp && tok.text == "&&") {
+			break
+		}
+		p.next()
+		right, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		terms = append(terms, right)
+	}
+	if len(terms) == 1 {
+		return left, nil
+	}
+	return &andExpr{terms: terms}, nil
+}
+
+func (p *parser) parseUnary() (Expr, error) {
+	tok := p.peek()
+
+	if keyword(tok, "not") || (tok.kind == tokOp && tok.text == "!") {
+		p.next()
+		inner, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		return &notExpr{inner: inner}, nil
+	}
+
+	if tok.kind == tokPunct && tok.text == "(" {
+		p.next()
+		inner, err := p.parseOr()
+		if err != nil {
+			return nil, err
+		}
+		if err := p.expectPunct(")"); err != nil {
+			return nil, err
+		}
+		return inner, nil
+	}
+
+	if keyword(tok, "exists") && p.tokens[p.pos+1].kind == tokPunct && p.tokens[p.pos+1].text == "(" {
+		p.next()
+		p.next()
+		path := p.next()
+		if path.kind != tokIdent {
+			return nil, p.errorf(path, "expected field path")
+		}
+		if err := p.expectPunct(")"); err != nil {
+			return nil, err
+		}
+		return &existsExpr{path: path.text}, nil
+	}
+
+	return p.parsePredicate()
+}
+
+func (p *parser) parsePredicate() (Expr, error) {
+	path := p.next()
+	if path.kind != tokIdent {
+		return nil, p.errorf(path, "expected field path")
+	}
+
+	tok := p.next()
+	switch {
+	case keyword(tok, "exists"):
+		return &existsExpr{path: path.text}, nil
+
+	case keyword(tok, "in"):
+		if err := p.expectPunct("["); err != nil {
+			return nil, err
+		}
+		var values []interface{}
+		for {
+			value, err := p.parseLiteral()
+			if err != nil {
+				return nil, err
+			}
+			values = append(values, value)
+			sep := p.next()
+			if sep.kind == tokPunct && sep.text == "]" {
+				break
+			}
+			if sep.kind != tokPunct || sep.text != "," {
+				return nil, p.errorf(sep, "expected ',' or ']'")
+			}
+		}
+		return &inExpr{path: path.text, values: values}, nil
+
+	case tok.kind == tokOp:
+		op := tok.text
+		if op == "==" {
+			op = "="
+		}
+		if op == "!" || op == "&&" || op == "||" {
+			return nil, p.errorf(tok, "expected comparison operator")
+		}
+		value, err := p.parseLiteral()
+		if err != nil {
+			return nil, err
+		}
+		return &compareExpr{path: path.text, op: op, value: value}, nil
+
+	default:
+		return nil, p.errorf(tok, "expected operator after %q", path.text)
+	}
+}
+
+func (p *parser) parseLiteral() (interface{}, error) {
+	tok := p.next()
+	switch {
+	case tok.kind == tokString:
+		return tok.text, nil
+	case tok.kind == tokNumber:
+		var n float64
+		if err := json.Unmarshal([]byte(tok.text), &n); err != nil {
+			return nil, p.errorf(tok, "invalid number %q", tok.text)
+		}
+		return n, nil
+	case keyword(tok, "true"):
+		return true, nil
+	case keyword(tok, "false"):
+		return false, nil
+	case keyword(tok, "null"):
+		return nil, nil
+	default:
+		return nil, p.errorf(tok, "expected literal value")
+	}
+}
@@ -0,0 +1,222 @@
+package query
+
+import (
+	"sort"
+	"strings"
+
+	"kiwi/internal/document"
+	"kiwi/internal/storage"
+)
+
+// Source is the storage a query runs against
+type Source interface {
+	// Scan calls fn for every document in collection in key order until fn returns false
+	Scan(collection string, fn func(key string, value interface{}) bool) error
+
+	// HasIndex reports whether field is indexed in collection
+	HasIndex(collection, field string) bool
+
+	// QueryIndex returns documents matching an index range
+	QueryIndex(collection string, q storage.IndexQuery) ([]storage.KeyValue, error)
+}
+
+// SortKey orders results by a field path
+type SortKey struct {
+	Path string
+	Desc bool
+}
+
+// Request describes a query over a single collection
+type Request struct {
+	Filter Expr      // nil matches every document
+	Sort   []SortKey // empty keeps key (or index) order
+	Fields []string  // projection; empty returns whole documents
+	Offset int
+	Limit  int // 0 = no limit
+}
+
+// ParseSort parses sort fields; a leading '-' sorts descending
+func ParseSort(fields []string) []SortKey {
+	keys := make([]SortKey, 0, len(fields))
+	for _, f := range fields {
+		f = strings.TrimSpace(f)
+		if f == "" {
+			continue
+		}
+		if strings.HasPrefix(f, "-") {
+			keys = append(keys, SortKey{Path: f[1:], Desc: true})
+		} else {
+			keys = append(keys, SortKey{Path: strings.TrimPrefix(f, "+")})
+		}
+	}
+	return keys
+}
+
+// Execute runs a query against src
+func Execute(src Source, collection string, req Request) ([]storage.KeyValue, error) {
+	// Without sorting, scanning can stop as soon as enough results are found
+	want := 0
+	if len(req.Sort) == 0 && req.Limit > 0 {
+		want = req.Offset + req.Limit
+	}
+
+	var matches []storage.KeyValue
+	err := Each(src, collection, req.Filter, func(key string, value interface{}) bool {
+		matches = append(matches, storage.KeyValue{Key: key, Value: value})
+		return want == 0 || len(matches) < want
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if len(req.Sort) > 0 {
+		sort.SliceStable(matches, func(i, j int) bool {
+			return lessBySort(matches[i].Value, matches[j].Value, req.Sort)
+		})
+	}
+
+	if req.Offset > 0 {
+		if req.Offset >= len(matches) {
+			matches = nil
+		} else {
+			matches = matches[req.Offset:]
+		}
+	}
+	if req.Limit > 0 && len(matches) > req.Limit {
+		matches = matches[:req.Limit]
+	}
+
+	if len(req.Fields) > 0 {
+		for i := range matches {
+			matches[i].Value = project(matches[i].Value, req.Fields)
+		}
+	}
+
+	if matches == nil {
+		matches = []storage.KeyValue{}
+	}
+	return matches, nil
+}
+
+// Each streams the documents matching filter to fn until fn returns false.
+// An index is used to narrow the candidates when the filter allows it.
+func Each(src Source, collection string, filter Expr, fn func(key string, value interface{}) bool) error {
+	if iq, ok := indexQueryFor(src, collection, filter); ok {
+		candidates, err := src.QueryIndex(collection, iq)
+		if err != nil {
+			return err
+		}
+		for _, kv := range candidates {
+			if filter.Match(kv.Value) && !fn(kv.Key, kv.Value) {
+				break
+			}
+		}
+		return nil
+	}
+
+	return src.Scan(collection, func(key string, value interface{}) bool {
+		if filter == nil || filter.Match(value) {
+			return fn(key, value)
+		}
+		return true
+	})
+}
+
+// indexQueryFor picks an indexed comparison that every match must satisfy:
+// the filter itself or one of the terms of a top-level "and". The index
+// only narrows the candidates; the full filter is still applied to each.
+func indexQueryFor(src Source, collection string, filter Expr) (storage.IndexQuery, bool) {
+	terms := []Expr{filter}
+	if and, ok := filter.(*andExpr); ok {
+		terms = and.terms
+	}
+
+	for _, term := range terms {
+		cmp, ok := term.(*compareExpr)
+		if !ok || cmp.op == "!=" || !src.HasIndex(collection, cmp.path) {
+			continue
+		}
+		bound := &storage.IndexBound{Value: cmp.value, Inclusive: cmp.op != "<" && cmp.op != ">"}
+		q := storage.IndexQuery{Field: cmp.path}
+		switch cmp.op {
+		case "=":
+			q.Lower, q.Upper = bound, bound
+		case ">", ">=":
+			q.Lower = bound
+		case "<", "<=":
+			q.Upper = bound
+		}
+		return q, true
+	}
+
+	return storage.IndexQuery{}, false
+}
+
+// typeRank orders values of different JSON types, matching index order
+func typeRank(v interface{}, present bool) int {
+	if !present {
+		return 0
+	}
+	switch v.(type) {
+	case nil:
+		return 1
+	case bool:
+		return 2
+	case float64:
+		return 3
+	case string:
+		return 4
+	default:
+		return 5
+	}
+}
+
+// lessBySort compares two documents by the sort keys
+func lessBySort(a, b interface{}, keys []SortKey) bool {
+	for _, k := range keys {
+		av, aok := document.Get(a, k.Path)
+		bv, bok := document.Get(b, k.Path)
+
+		c := compareValues(av, aok, bv, bok)
+		if c == 0 {
+			continue
+		}
+		if k.Desc {
+			return c > 0
+		}
+		return c < 0
+	}
+	return false
+}
+
+// compareValues returns -1, 0 or 1; missing values sort first
+func compareValues(a interface{}, aok bool, b interface{}, bok bool) int {
+	ra, rb := typeRank(a, aok), typeRank(b, bok)
+	if ra != rb {
+		if ra < rb {
+			return -1
+		}
+		return 1
+	}
+	switch {
+	case compare(a, "<", b):
+		return -1
+	case compare(a, ">", b):
+		return 1
+	default:
+		return 0
+	}
+}
+
+// project keeps only the given field paths of a document
+func project(doc interface{}, fields []string) interface{} {
+	var out interface{} = map[string]interface{}{}
+	for _, f := range fields {
+		if v, ok := document.Get(doc, f); ok {
+			if updated, err := document.Set(out, f, v); err == nil {
+				out = updated
+			}
+		}
+	}
+	return out
+}
@@ -0,0 +1,414 @@
+package query
+
+import (
+	"encoding/json"
+	"errors"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+
+	"kiwi/internal/storage"
+)
+
+func TestParse(t *testing.T) {
+	cmp := func(path, op string, value interface{}) Expr {
+		return &compareExpr{path: path, op: op, value: value}
+	}
+	tests := []struct {
+		filter string
+		want   Expr
+	}{
+		{"", nil},
+		{"  ", nil},
+		{"age = 30", cmp("age", "=", 30.0)},
+		{"age == 30", cmp("age", "=", 30.0)},
+		{"age != 30", cmp("age", "!=", 30.0)},
+		{"age<30", cmp("age", "<", 30.0)},
+		{"age <= -1.5e2", cmp("age", "<=", -150.0)},
+		{"age > 0.5", cmp("age", ">", 0.5)},
+		{"age >= 18", cmp("age", ">=", 18.0)},
+		{`name = "Ann"`, cmp("name", "=", "Ann")},
+		{`name = 'it\'s'`, cmp("name", "=", "it's")},
+		{`name = "say \"hi\""`, cmp("name", "=", `say "hi"`)},
+		{"active = true", cmp("active", "=", true)},
+		{"active = FALSE", cmp("active", "=", false)},
+		{"nick = null", cmp("nick", "=", nil)},
+		{"user.address.city = 'Oslo'", cmp("user.address.city", "=", "Oslo")},
+		{"items.0.price < 10", cmp("items.0.price", "<", 10.0)},
+		{"first-name = 'a'", cmp("first-name", "=", "a")},
+
+		{`tags in ["vip", 1, true, null]`, &inExpr{path: "tags", values: []interface{}{"vip", 1.0, true, nil}}},
+		{`tags IN ['a']`, &inExpr{path: "tags", values: []interface{}{"a"}}},
+		{"nick exists", &existsExpr{path: "nick"}},
+		{"exists(nick)", &existsExpr{path: "nick"}},
+		{"EXISTS ( user.nick )", &existsExpr{path: "user.nick"}},
+		{"exists = 1", cmp("exists", "=", 1.0)},
+
+		// and binds tighter than or, and both flatten
+		{"a = 1 or b = 2 and c = 3", &orExpr{terms: []Expr{
+			cmp("a", "=", 1.0),
+			&andExpr{terms: []Expr{cmp("b", "=", 2.0), cmp("c", "=", 3.0)}},
+		}}},
+		{"a = 1 and b = 2 or c = 3", &orExpr{terms: []Expr{
+			&andExpr{terms: []Expr{cmp("a", "=", 1.0), cmp("b", "=", 2.0)}},
+			cmp("c", "=", 3.0),
+		}}},
+		{"a = 1 and b = 2 AND c = 3", &andExpr{terms: []Expr{
+			cmp("a", "=", 1.0), cmp("b", "=", 2.0), cmp("c", "=", 3.0),
+		}}},
+		{"a = 1 || b = 2 || c = 3", &orExpr{terms: []Expr{
+			cmp("a", "=", 1.0), cmp("b", "=", 2.0), cmp("c", "=", 3.0),
+		}}},
+		{"(a = 1 or b = 2) && c = 3", &andExpr{terms: []Expr{
+			&orExpr{terms: []Expr{cmp("a", "=", 1.0), cmp("b", "=", 2.0)}},
+			cmp("c", "=", 3.0),
+		}}},
+
+		// not binds tighter than and
+		{"not a = 1 and b = 2", &andExpr{terms: []Expr{
+			&notExpr{inner: cmp("a", "=", 1.0)},
+			cmp("b", "=", 2.0),
+		}}},
+		{"!(a = 1 || b = 2)", &notExpr{inner: &orExpr{terms: []Expr{
+			cmp("a", "=", 1.0), cmp("b", "=", 2.0),
+		}}}},
+		{"not not deleted exists", &notExpr{inner: &notExpr{inner: &existsExpr{path: "deleted"}}}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.filter, func(t *testing.T) {
+			got, err := Parse(tt.filter)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Parse(%q) = %s, want %s", tt.filter, dump(got), dump(tt.want))
+			}
+		})
+	}
+}
+
+func TestParseErrors(t *testing.T) {
+	tests := []struct {
+		filter string
+		want   string // part of the error
+	}{
+		{"age", "expected operator after \"age\" at position 3"},
+		{"age =", "expected literal value at position 5"},
+		{"age = 1 and", "expected field path at position 11"},
+		{"age = 1 age = 2", "unexpected \"age\" at position 8"},
+		{"(age = 1", "expected \")\" at position 8"},
+		{"age = 1)", "unexpected \")\" at position 7"},
+		{"= 1", "expected field path at position 0"},
+		{"age ! 1", "expected comparison operator at position 4"},
+		{"age = name", "expected literal value at position 6"},
+		{"age = 1e", "invalid number \"1e\" at position 6"},
+		{"tags in []", "expected literal value at position 9"},
+		{"tags in [1 2]", "expected ',' or ']' at position 11"},
+		{"tags in 1", "expected \"[\" at position 8"},
+		{"exists(1)", "expected field path at position 7"},
+		{"exists(nick", "expected \")\" at position 11"},
+		{"a & b", "unexpected \"&\" at position 2"},
+		{"a = 1 | b = 2", "unexpected \"|\" at position 6"},
+		{`name = "Ann`, "unterminated string at position 7"},
+		{"age = #", "unexpected character '#' at position 6"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.filter, func(t *testing.T) {
+			_, err := Parse(tt.filter)
+			if err == nil {
+				t.Fatalf("Parse(%q) succeeded, want an error containing %q", tt.filter, tt.want)
+			}
+			if !errors.Is(err, ErrInvalidQuery) {
+				t.Errorf("error %v does not wrap ErrInvalidQuery", err)
+			}
+			if !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("error %q does not contain %q", err, tt.want)
+			}
+		})
+	}
+}
+
+// dump formats an expression for failure messages
+func dump(e Expr) string {
+	switch e := e.(type) {
+	case nil:
+		return "nil"
+	case *andExpr:
+		return "and" + dumpTerms(e.terms)
+	case *orExpr:
+		return "or" + dumpTerms(e.terms)
+	case *notExpr:
+		return "not(" + dump(e.inner) + ")"
+	case *existsExpr:
+		return "exists(" + e.path + ")"
+	case *compareExpr:
+		v, _ := json.Marshal(e.value)
+		return e.path + " " + e.op + " " + string(v)
+	case *inExpr:
+		v, _ := json.Marshal(e.values)
+		return e.path + " in " + string(v)
+	}
+	return "?"
+}
+
+func dumpTerms(terms []Expr) string {
+	parts := make([]string, len(terms))
+	for i, t := range terms {
+		parts[i] = dump(t)
+	}
+	return "[" + strings.Join(parts, ", ") + "]"
+}
+
+func TestMatch(t *testing.T) {
+	doc := map[string]interface{}{
+		"age":    30.0,
+		"name":   "Ann",
+		"tags":   []interface{}{"vip", "staff"},
+		"active": true,
+		"nick":   nil,
+		"user":   map[string]interface{}{"city": "Oslo"},
+	}
+	tests := []struct {
+		filter string
+		want   bool
+	}{
+		{"age = 30", true},
+		{"age != 30", false},
+		{"age < 30.5", true},
+		{"age >= 31", false},
+		{`age = "30"`, false},
+		{`age != "30"`, true},
+		{`age < "a"`, false},
+		{"name > 'Al'", true},
+		{"active = true", true},
+		{"active > false", true},
+		{"nick = null", true},
+		{"nick <= null", true},
+		{"nick < null", false},
+		{"nick exists", true},
+		{"missing exists", false},
+		{"missing = null", false},
+		{"missing != 1", true},
+		{"tags = 'vip'", true},
+		{"tags != 'vip'", false},
+		{"tags != 'other'", true},
+		{"tags < 't'", true},
+		{`tags in ["a", "staff"]`, true},
+		{`tags in ["a", "b"]`, false},
+		{`age in [1, 30]`, true},
+		{"user.city = 'Oslo'", true},
+		{"user.city.x exists", false},
+		{"age = 30 and name = 'Bob'", false},
+		{"age = 30 or name = 'Bob'", true},
+		{"not age = 30 or name = 'Ann'", true},
+		{"not (age = 30 or name = 'Ann')", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.filter, func(t *testing.T) {
+			e, err := Parse(tt.filter)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := e.Match(doc); got != tt.want {
+				t.Errorf("Match = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+// indexSource counts the index queries made through a store
+type indexSource struct {
+	*storage.LocalStore
+	queries int
+}
+
+func (s *indexSource) QueryIndex(collection string, q storage.IndexQuery) ([]storage.KeyValue, error) {
+	s.queries++
+	return s.LocalStore.QueryIndex(collection, q)
+}
+
+func TestExecuteIndexedMatchesScan(t *testing.T) {
+	store, err := storage.OpenStore("memory", "", storage.EngineOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer store.Close()
+
+	docs := map[string]string{
+		"a": `{"age": 30, "name": "Ann", "role": "admin", "tags": ["vip", "staff"], "active": true, "user": {"city": "Oslo"}}`,
+		"b": `{"age": 17, "name": "bob", "role": "staff", "tags": ["new"], "active": false, "nick": null}`,
+		"c": `{"age": 30.5, "name": "Cid", "role": "admin", "tags": [], "nick": "cc", "user": {"city": "Rome"}}`,
+		"d": `{"age": "40", "name": "Dee", "deleted": true, "tags": "vip"}`,
+		"e": `{"age": [10, 40], "name": "eve", "role": "user", "nick": null}`,
+		"f": `{"age": -3, "name": "", "active": true, "user": {"city": "Oslo"}}`,
+		"g": `{"age": null, "role": "staff", "tags": ["staff", "vip", "vip"]}`,
+		"h": `{"name": "Hal", "active": "yes", "user": "none"}`,
+		"i": `{"age": 18, "name": "Ivy", "role": "admin", "deleted": false}`,
+		"j": `"just a string"`,
+		"k": `[1, 2, 3]`,
+	}
+	for key, data := range docs {
+		var v interface{}
+		if err := json.Unmarshal([]byte(data), &v); err != nil {
+			t.Fatalf("%s: %v", key, err)
+		}
+		if err := store.Put("people", key, v); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	filters := []string{
+		"age = 30",
+		"age > 30",
+		"age >= 30",
+		"age < 30",
+		"age <= 18",
+		"age < 0",
+		"age > -100",
+		"age = 40",
+		`age = "40"`,
+		`age > "3"`,
+		"age = null",
+		"age <= null",
+		"age >= null",
+		"age != 30",
+		"name = 'Ann'",
+		"name > 'b'",
+		"name < 'D'",
+		"name >= ''",
+		"name <= ''",
+		"role = 'admin'",
+		"tags = 'vip'",
+		"tags < 'o'",
+		"tags >= 'staff'",
+		"active = true",
+		"active >= false",
+		"active < true",
+		"active = 'yes'",
+		"nick = null",
+		"nick > null",
+		"user.city = 'Oslo'",
+		"user.city < 'P'",
+		"age >= 18 and role = 'admin'",
+		"role = 'staff' and age < 20",
+		"age >= 18 and not deleted exists",
+		"age < 18 or role = 'staff'",
+		`tags in ["vip", "new"]`,
+		`role in ["admin", "user"] and age > 20`,
+		"exists(nick) and nick = null",
+		"not active = true and name > 'a'",
+	}
+	indexed := []string{"age", "name", "role", "tags", "active", "nick", "user.city"}
+
+	run := func(src Source, filter string) []string {
+		t.Helper()
+		e, err := Parse(filter)
+		if err != nil {
+			t.Fatal(err)
+		}
+		kvs, err := Execute(src, "people", Request{Filter: e})
+		if err != nil {
+			t.Fatalf("%s: %v", filter, err)
+		}
+		keys := make([]string, len(kvs))
+		for i, kv := range kvs {
+			keys[i] = kv.Key
+		}
+		sort.Strings(keys)
+		return keys
+	}
+
+	scanned := make(map[string][]string, len(filters))
+	for _, f := range filters {
+		scanned[f] = run(store, f)
+	}
+
+	for _, field := range indexed {
+		if err := store.CreateIndex("people", field); err != nil {
+			t.Fatal(err)
+		}
+	}
+	src := &indexSource{LocalStore: store}
+	for _, f := range filters {
+		t.Run(f, func(t *testing.T) {
+			before := src.queries
+			got := run(src, f)
+			if !reflect.DeepEqual(got, scanned[f]) {
+				t.Errorf("with indexes got %v, scanning got %v", got, scanned[f])
+			}
+
+			e, _ := Parse(f)
+			_, usable := indexQueryFor(src, "people", e)
+			if used := src.queries > before; used != usable {
+				t.Errorf("index used = %v, want %v", used, usable)
+			}
+		})
+	}
+
+	// A few results are known in advance, so a scan that is wrong in the
+	// same way as the index cannot pass
+	for filter, want := range map[string][]string{
+		"age >= 30":                    {"a", "c", "e"},
+		"tags = 'vip'":                 {"a", "d", "g"},
+		"age >= 18 and role = 'admin'": {"a", "c", "i"},
+		"nick = null":                  {"b", "e"},
+		"age != 30":                    {"b", "c", "d", "e", "f", "g", "h", "i", "j", "k"},
+	} {
+		if got := scanned[filter]; !reflect.DeepEqual(got, want) {
+			t.Errorf("%s: got %v, want %v", filter, got, want)
+		}
+	}
+}
+
+func TestExecuteSortAndPage(t *testing.T) {
+	store, err := storage.OpenStore("memory", "", storage.EngineOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer store.Close()
+
+	for key, age := range map[string]interface{}{"a": 30.0, "b": 10.0, "c": 20.0, "d": "x", "e": nil} {
+		if err := store.Put("people", key, map[string]interface{}{"age": age, "name": key}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := store.Put("people", "f", map[string]interface{}{"name": "f"}); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		req  Request
+		want []string
+	}{
+		{Request{}, []string{"a", "b", "c", "d", "e", "f"}},
+		{Request{Limit: 2}, []string{"a", "b"}},
+		{Request{Offset: 4}, []string{"e", "f"}},
+		{Request{Offset: 10}, []string{}},
+		{Request{Sort: ParseSort([]string{"age"})}, []string{"f", "e", "b", "c", "a", "d"}},
+		{Request{Sort: ParseSort([]string{"-age"})}, []string{"d", "a", "c", "b", "e", "f"}},
+		{Request{Sort: ParseSort([]string{"-age"}), Offset: 1, Limit: 2}, []string{"a", "c"}},
+	}
+	for _, tt := range tests {
+		kvs, err := Execute(store, "people", tt.req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		got := make([]string, len(kvs))
+		for i, kv := range kvs {
+			got[i] = kv.Key
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%+v: got %v, want %v", tt.req, got, tt.want)
+		}
+	}
+
+	e, _ := Parse("age >= 20")
+	kvs, err := Execute(store, "people", Request{Filter: e, Fields: []string{"name"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(kvs) != 2 || !reflect.DeepEqual(kvs[0].Value, map[string]interface{}{"name": "a"}) {
+		t.Errorf("projection got %v", kvs)
+	}
+}
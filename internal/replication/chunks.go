@@ -0,0 +1,150 @@
+package replication
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+
+	pb "kiwi/proto"
+)
+
+// chunkTimeout bounds discarding chunks on the slaves
+const chunkTimeout = 30 * time.Second
+
+// ChunkWriter streams the chunks of one blob part to every slave. Chunks
+// are written without 2PC: they are only reachable through a blob manifest,
+// which is replicated with 2PC once every part is stored.
+type ChunkWriter struct {
+	collection string
+	blobID     string
+	part       uint32
+	streams    []chunkStream
+	cancel     context.CancelFunc
+}
+
+// chunkStream is the open WriteChunks call to one slave
+type chunkStream struct {
+	client *Client
+	stream pb.ReplicationService_WriteChunksClient
+}
+
+// WriteChunks opens a chunk stream for part of a blob to every slave
+func (m *Manager) WriteChunks(collection, blobID string, part uint32) (*ChunkWriter, error) {
+	ctx, cancel := context.WithCancel(context.Background())
+	w := &ChunkWriter{collection: collection, blobID: blobID, part: part, cancel: cancel}
+
+	for _, client := range m.clients {
+		stream, err := client.client.WriteChunks(ctx)
+		if err != nil {
+			cancel()
+			return nil, fmt.Errorf("chunk stream to %s failed: %w", client.addr, err)
+		}
+		w.streams = append(w.streams, chunkStream{client: client, stream: stream})
+	}
+	return w, nil
+}
+
+// Write sends chunk index of the part to every slave in parallel
+func (w *ChunkWriter) Write(index uint32, data []byte) error {
+	chunk := &pb.Chunk{
+		Collection: w.collection,
+		BlobId:     w.blobID,
+		Part:       w.part,
+		Index:      index,
+		Data:       data,
+	}
+
+	errs := make(chan error, len(w.streams))
+	for _, s := range w.streams {
+		go func(s chunkStream) {
+			if err := s.stream.Send(chunk); err != nil {
+				errs <- fmt.Errorf("chunk write to %s failed: %w", s.client.addr, err)
+				return
+			}
+			errs <- nil
+		}(s)
+	}
+
+	var first error
+	for range w.streams {
+		if err := <-errs; err != nil && first == nil {
+			first = err
+		}
+	}
+	if first != nil {
+		w.Abort()
+	}
+	return first
+}
+
+// Close ends the streams and waits until every slave has stored the chunks
+func (w *ChunkWriter) Close() error {
+	defer w.cancel()
+
+	errs := make(chan error, len(w.streams))
+	for _, s := range w.streams {
+		go func(s chunkStream) {
+			resp, err := s.stream.CloseAndRecv()
+			switch {
+			case err != nil:
+				errs <- fmt.Errorf("chunk stream to %s failed: %w", s.client.addr, err)
+			case !resp.Success:
+				errs <- fmt.Errorf("chunks rejected by %s: %s", s.client.addr, resp.Error)
+			default:
+				errs <- nil
+			}
+		}(s)
+	}
+
+	var first error
+	for range w.streams {
+		if err := <-errs; err != nil && first == nil {
+			first = err
+		}
+	}
+	return first
+}
+
+// Abort cancels the streams; slaves keep the chunks received so far until
+// they are discarded
+func (w *ChunkWriter) Abort() {
+	w.cancel()
+}
+
+// DiscardChunks removes the chunks of a blob part from every slave, or of
+// every part when part is 0
+func (m *Manager) DiscardChunks(collection, blobID string, part uint32) error {
+	ctx, cancel := context.WithTimeout(context.Background(), chunkTimeout)
+	defer cancel()
+
+	req := &pb.DiscardChunksRequest{Collection: collection, BlobId: blobID, Part: part}
+
+	var wg sync.WaitGroup
+	errs := make(chan error, len(m.clients))
+	for _, client := range m.clients {
+		wg.Add(1)
+		go func(c *Client) {
+			defer wg.Done()
+			resp, err := c.client.DiscardChunks(ctx, req)
+			switch {
+			case err != nil:
+				errs <- fmt.Errorf("discard on %s failed: %w", c.addr, err)
+			case !resp.Success:
+				errs <- fmt.Errorf("discard rejected by %s: %s", c.addr, resp.Error)
+			}
+		}(client)
+	}
+	wg.Wait()
+	close(errs)
+
+	var first error
+	for err := range errs {
+		log.Printf("[Replication] %v", err)
+		if first == nil {
+			first = err
+		}
+	}
+	return first
+}
@@ -14,6 +14,24 @@ import (
 	"google.golang.org/grpc/credentials/insecure"
 )
 
+// rebuildCommitTimeout bounds the commit phase of operations that index,
+// copy or delete a whole collection on the slaves, which takes time in
+// proportion to its size. Other 2PC rounds must finish within 10 seconds.
+const rebuildCommitTimeout = time.Hour
+
+// rebuilds reports whether an operation works through a whole collection
+// when a slave commits it
+func rebuilds(op pb.OperationType) bool {
+	switch op {
+	case pb.OperationType_CREATE_INDEX, pb.OperationType_DROP_INDEX,
+		pb.OperationType_CREATE_TEXT_INDEX, pb.OperationType_DROP_TEXT_INDEX,
+		pb.OperationType_TRUNCATE_COLLECTION, pb.OperationType_DROP_COLLECTION,
+		pb.OperationType_COPY_COLLECTION, pb.OperationType_RENAME_COLLECTION:
+		return true
+	}
+	return false
+}
+
 // Client handles outgoing replication requests to a single slave
 type Client struct {
 	addr   string
@@ -51,18 +69,11 @@ func (c *Client) Close() error {
 }
 
 // Prepare sends Phase 1 of 2PC to the slave
-func (c *Client) Prepare(ctx context.Context, txnID string, op pb.OperationType, collection, key string, value []byte, seq uint64) (bool, error) {
+func (c *Client) Prepare(ctx context.Context, req *pb.PrepareRequest) (bool, error) {
 	c.mu.RLock()
 	defer c.mu.RUnlock()
 
-	resp, err := c.client.Prepare(ctx, &pb.PrepareRequest{
-		TransactionId: txnID,
-		Operation:     op,
-		Collection:    collection,
-		Key:           key,
-		Value:         value,
-		Sequence:      seq,
-	})
+	resp, err := c.client.Prepare(ctx, req)
 	if err != nil {
 		return false, fmt.Errorf("prepare to %s failed: %w", c.addr, err)
 	}
@@ -114,6 +125,14 @@ func (c *Client) Address() string {
 	return c.addr
 }
 
+// Mutation is a single put or delete inside a replicated batch
+type Mutation struct {
+	Delete     bool
+	Collection string
+	Key        string
+	Value      []byte
+}
+
 // Manager manages replication to all slaves using 2PC (runs on master)
 type Manager struct {
 	clients []*Client
@@ -167,26 +186,148 @@ func (m *Manager) nextSeq() uint64 {
 
 // ReplicatePut replicates a PUT operation using 2PC
 func (m *Manager) ReplicatePut(collection, key string, value []byte) error {
-	return m.replicate2PC(pb.OperationType_PUT, collection, key, value)
+	return m.replicate2PC(&pb.PrepareRequest{
+		Operation:  pb.OperationType_PUT,
+		Collection: collection,
+		Key:        key,
+		Value:      value,
+	})
 }
 
 // ReplicateDelete replicates a DELETE operation using 2PC
 func (m *Manager) ReplicateDelete(collection, key string) error {
-	return m.replicate2PC(pb.OperationType_DELETE, collection, key, nil)
+	return m.replicate2PC(&pb.PrepareRequest{
+		Operation:  pb.OperationType_DELETE,
+		Collection: collection,
+		Key:        key,
+	})
+}
+
+// ReplicateBatch replicates a group of mutations as a single 2PC transaction
+func (m *Manager) ReplicateBatch(mutations []Mutation) error {
+	req := &pb.PrepareRequest{
+		Operation: pb.OperationType_BATCH,
+		Mutations: make([]*pb.Mutation, 0, len(mutations)),
+	}
+	for _, mut := range mutations {
+		op := pb.OperationType_PUT
+		if mut.Delete {
+			op = pb.OperationType_DELETE
+		}
+		req.Mutations = append(req.Mutations, &pb.Mutation{
+			Operation:  op,
+			Collection: mut.Collection,
+			Key:        mut.Key,
+			Value:      mut.Value,
+		})
+	}
+	return m.replicate2PC(req)
+}
+
+// ReplicateCreateIndex replicates the creation of a secondary index using 2PC
+func (m *Manager) ReplicateCreateIndex(collection, field string) error {
+	return m.replicate2PC(&pb.PrepareRequest{
+		Operation:  pb.OperationType_CREATE_INDEX,
+		Collection: collection,
+		Field:      field,
+	})
+}
+
+// ReplicateDropIndex replicates the removal of a secondary index using 2PC
+func (m *Manager) ReplicateDropIndex(collection, field string) error {
+	return m.replicate2PC(&pb.PrepareRequest{
+		Operation:  pb.OperationType_DROP_INDEX,
+		Collection: collection,
+		Field:      field,
+	})
+}
+
+// ReplicateCreateTextIndex replicates the creation of a full-text index using 2PC
+func (m *Manager) ReplicateCreateTextIndex(collection string, fields []string) error {
+	return m.replicate2PC(&pb.PrepareRequest{
+		Operation:  pb.OperationType_CREATE_TEXT_INDEX,
+		Collection: collection,
+		Fields:     fields,
+	})
 }
 
-// replicate2PC performs Two-Phase Commit across all slaves
-func (m *Manager) replicate2PC(op pb.OperationType, collection, key string, value []byte) error {
+// ReplicateDropTextIndex replicates the removal of a full-text index using 2PC
+func (m *Manager) ReplicateDropTextIndex(collection string) error {
+	return m.replicate2PC(&pb.PrepareRequest{
+		Operation:  pb.OperationType_DROP_TEXT_INDEX,
+		Collection: collection,
+	})
+}
+
+// ReplicateTruncateCollection replicates removing every key of a collection using 2PC
+func (m *Manager) ReplicateTruncateCollection(collection string) error {
+	return m.replicate2PC(&pb.PrepareRequest{
+		Operation:  pb.OperationType_TRUNCATE_COLLECTION,
+		Collection: collection,
+	})
+}
+
+// ReplicateDropCollection replicates dropping a collection and its indexes using 2PC
+func (m *Manager) ReplicateDropCollection(collection string) error {
+	return m.replicate2PC(&pb.PrepareRequest{
+		Operation:  pb.OperationType_DROP_COLLECTION,
+		Collection: collection,
+	})
+}
+
+// ReplicateCopyCollection replicates copying a collection using 2PC
+func (m *Manager) ReplicateCopyCollection(src, dst string) error {
+	return m.replicate2PC(&pb.PrepareRequest{
+		Operation:  pb.OperationType_COPY_COLLECTION,
+		Collection: src,
+		Target:     dst,
+	})
+}
+
+// ReplicateRenameCollection replicates renaming a collection using 2PC
+func (m *Manager) ReplicateRenameCollection(src, dst string) error {
+	return m.replicate2PC(&pb.PrepareRequest{
+		Operation:  pb.OperationType_RENAME_COLLECTION,
+		Collection: src,
+		Target:     dst,
+	})
+}
+
+// ReplicateSetCollectionMeta replicates a collection's settings using 2PC
+func (m *Manager) ReplicateSetCollectionMeta(collection string, data []byte) error {
+	return m.replicate2PC(&pb.PrepareRequest{
+		Operation:  pb.OperationType_SET_COLLECTION_META,
+		Collection: collection,
+		Value:      data,
+	})
+}
+
+// ReplicatePutDataKey replicates a wrapped encryption data key using 2PC
+func (m *Manager) ReplicatePutDataKey(data []byte) error {
+	return m.replicate2PC(&pb.PrepareRequest{
+		Operation: pb.OperationType_PUT_DATA_KEY,
+		Value:     data,
+	})
+}
+
+// replicate2PC performs Two-Phase Commit across all slaves.
+// The transaction ID and sequence number are assigned here.
+func (m *Manager) replicate2PC(req *pb.PrepareRequest) error {
 	if len(m.clients) == 0 {
 		return nil
 	}
 
 	txnID := m.generateTxnID()
-	seq := m.nextSeq()
+	req.TransactionId = txnID
+	req.Sequence = m.nextSeq()
 	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
 	defer cancel()
 
-	log.Printf("[2PC] Starting transaction %s: op=%v collection=%s key=%s", txnID, op, collection, key)
+	if req.Operation == pb.OperationType_BATCH {
+		log.Printf("[2PC] Starting transaction %s: op=%v mutations=%d", txnID, req.Operation, len(req.Mutations))
+	} else {
+		log.Printf("[2PC] Starting transaction %s: op=%v collection=%s key=%s", txnID, req.Operation, req.Collection, req.Key)
+	}
 
 	// ==================== PHASE 1: PREPARE ====================
 	// Send prepare to all slaves in parallel
@@ -199,7 +340,7 @@ func (m *Manager) replicate2PC(op pb.OperationType, collection, key string, valu
 	prepareChan := make(chan prepareResult, len(m.clients))
 	for _, client := range m.clients {
 		go func(c *Client) {
-			ready, err := c.Prepare(ctx, txnID, op, collection, key, value, seq)
+			ready, err := c.Prepare(ctx, req)
 			prepareChan <- prepareResult{client: c, ready: ready, err: err}
 		}(client)
 	}
@@ -239,10 +380,17 @@ func (m *Manager) replicate2PC(op pb.OperationType, collection, key string, valu
 	// All slaves are ready - send commit to all
 	log.Printf("[2PC] Transaction %s: all slaves ready, committing", txnID)
 
+	commitCtx := ctx
+	if rebuilds(req.Operation) {
+		var cancelCommit context.CancelFunc
+		commitCtx, cancelCommit = context.WithTimeout(context.Background(), rebuildCommitTimeout)
+		defer cancelCommit()
+	}
+
 	commitChan := make(chan error, len(m.clients))
 	for _, client := range m.clients {
 		go func(c *Client) {
-			commitChan <- c.Commit(ctx, txnID)
+			commitChan <- c.Commit(commitCtx, txnID)
 		}(client)
 	}
 
@@ -3,6 +3,7 @@ package replication
 import (
 	"context"
 	"fmt"
+	"io"
 	"log"
 	"net"
 	"sync"
@@ -17,6 +18,19 @@ import (
 type StorageBackend interface {
 	PutDirect(collection, key string, value []byte) error
 	DeleteDirect(collection, key string) error
+	ApplyBatch(mutations []Mutation) error
+	CreateIndex(collection, field string) error
+	DropIndex(collection, field string) error
+	CreateTextIndex(collection string, fields []string) error
+	DropTextIndex(collection string) error
+	TruncateCollection(collection string) (int64, error)
+	DropCollection(collection string) (int64, error)
+	CopyCollection(src, dst string) (int64, error)
+	RenameCollection(src, dst string) (int64, error)
+	SetCollectionMetaDirect(collection string, data []byte) error
+	PutDataKeyDirect(data []byte) error
+	PutChunk(collection, blobID string, part, index uint32, data []byte) error
+	DiscardChunks(collection, blobID string, part uint32) error
 }
 
 // PendingTransaction holds a prepared but not yet committed transaction
@@ -25,6 +39,10 @@ type PendingTransaction struct {
 	Collection string
 	Key        string
 	Value      []byte
+	Mutations  []Mutation // set for BATCH operations
+	Field      string     // set for index operations
+	Fields     []string   // set for text index operations
+	Target     string     // set for collection copy and rename
 }
 
 // Server handles incoming replication requests (runs on slaves)
@@ -37,6 +55,13 @@ type Server struct {
 	mu       sync.RWMutex
 	seq      uint64
 	pending  map[string]*PendingTransaction // transaction_id -> pending transaction
+	services []service                      // additional services served on the same port
+}
+
+// service is an extra gRPC service registered with Register
+type service struct {
+	desc *grpc.ServiceDesc
+	impl interface{}
 }
 
 // NewServer creates a new replication gRPC server
@@ -57,6 +82,9 @@ func (s *Server) Start() error {
 
 	s.server = grpc.NewServer()
 	pb.RegisterReplicationServiceServer(s.server, s)
+	for _, svc := range s.services {
+		s.server.RegisterService(svc.desc, svc.impl)
+	}
 
 	log.Printf("[Replication] gRPC server starting on port %s (role: %s)", s.config.GRPCPort, s.config.Role)
 
@@ -69,6 +97,12 @@ func (s *Server) Start() error {
 	return nil
 }
 
+// Register serves another gRPC service on the replication port. It must be
+// called before Start.
+func (s *Server) Register(desc *grpc.ServiceDesc, impl interface{}) {
+	s.services = append(s.services, service{desc: desc, impl: impl})
+}
+
 // Stop gracefully stops the gRPC server
 func (s *Server) Stop() {
 	if s.server != nil {
@@ -95,12 +129,24 @@ func (s *Server) Prepare(ctx context.Context, req *pb.PrepareRequest) (*pb.Prepa
 	// For DELETE: check if key exists (optional, we can skip this for simplicity)
 
 	// Stage the transaction
-	s.pending[req.TransactionId] = &PendingTransaction{
+	txn := &PendingTransaction{
 		Operation:  req.Operation,
 		Collection: req.Collection,
 		Key:        req.Key,
 		Value:      req.Value,
+		Field:      req.Field,
+		Fields:     req.Fields,
+		Target:     req.Target,
+	}
+	for _, m := range req.Mutations {
+		txn.Mutations = append(txn.Mutations, Mutation{
+			Delete:     m.Operation == pb.OperationType_DELETE,
+			Collection: m.Collection,
+			Key:        m.Key,
+			Value:      m.Value,
+		})
 	}
+	s.pending[req.TransactionId] = txn
 
 	log.Printf("[2PC] PREPARE successful: txn=%s - ready to commit", req.TransactionId)
 	return &pb.PrepareResponse{Ready: true}, nil
@@ -127,6 +173,28 @@ func (s *Server) Commit(ctx context.Context, req *pb.CommitRequest) (*pb.CommitR
 		err = s.storage.PutDirect(txn.Collection, txn.Key, txn.Value)
 	case pb.OperationType_DELETE:
 		err = s.storage.DeleteDirect(txn.Collection, txn.Key)
+	case pb.OperationType_BATCH:
+		err = s.storage.ApplyBatch(txn.Mutations)
+	case pb.OperationType_CREATE_INDEX:
+		err = s.storage.CreateIndex(txn.Collection, txn.Field)
+	case pb.OperationType_DROP_INDEX:
+		err = s.storage.DropIndex(txn.Collection, txn.Field)
+	case pb.OperationType_CREATE_TEXT_INDEX:
+		err = s.storage.CreateTextIndex(txn.Collection, txn.Fields)
+	case pb.OperationType_DROP_TEXT_INDEX:
+		err = s.storage.DropTextIndex(txn.Collection)
+	case pb.OperationType_TRUNCATE_COLLECTION:
+		_, err = s.storage.TruncateCollection(txn.Collection)
+	case pb.OperationType_DROP_COLLECTION:
+		_, err = s.storage.DropCollection(txn.Collection)
+	case pb.OperationType_COPY_COLLECTION:
+		_, err = s.storage.CopyCollection(txn.Collection, txn.Target)
+	case pb.OperationType_RENAME_COLLECTION:
+		_, err = s.storage.RenameCollection(txn.Collection, txn.Target)
+	case pb.OperationType_SET_COLLECTION_META:
+		err = s.storage.SetCollectionMetaDirect(txn.Collection, txn.Value)
+	case pb.OperationType_PUT_DATA_KEY:
+		err = s.storage.PutDataKeyDirect(txn.Value)
 	}
 
 	if err != nil {
@@ -164,3 +232,32 @@ func (s *Server) HealthCheck(ctx context.Context, req *pb.HealthCheckRequest) (*
 		Role:    string(s.config.Role),
 	}, nil
 }
+
+// WriteChunks stores the chunks of a blob part as they arrive
+func (s *Server) WriteChunks(stream pb.ReplicationService_WriteChunksServer) error {
+	n := 0
+	for {
+		chunk, err := stream.Recv()
+		if err == io.EOF {
+			log.Printf("[Replication] Stored %d chunks", n)
+			return stream.SendAndClose(&pb.ChunkResponse{Success: true})
+		}
+		if err != nil {
+			return err
+		}
+
+		if err := s.storage.PutChunk(chunk.Collection, chunk.BlobId, chunk.Part, chunk.Index, chunk.Data); err != nil {
+			log.Printf("[Replication] Chunk write failed: blob=%s part=%d chunk=%d error=%v", chunk.BlobId, chunk.Part, chunk.Index, err)
+			return stream.SendAndClose(&pb.ChunkResponse{Success: false, Error: err.Error()})
+		}
+		n++
+	}
+}
+
+// DiscardChunks removes the chunks of a blob part, or of a whole blob
+func (s *Server) DiscardChunks(ctx context.Context, req *pb.DiscardChunksRequest) (*pb.ChunkResponse, error) {
+	if err := s.storage.DiscardChunks(req.Collection, req.BlobId, req.Part); err != nil {
+		return &pb.ChunkResponse{Success: false, Error: err.Error()}, nil
+	}
+	return &pb.ChunkResponse{Success: true}, nil
+}
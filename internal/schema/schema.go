@@ -0,0 +1,543 @@
+// Package schema validates JSON documents against JSON Schema.
+//
+// It supports the validation keywords shared by drafts 7 and 2020-12:
+// type, enum, const, the numeric, string, array and object constraints,
+// the allOf/anyOf/oneOf/not combinators, and $ref to locations inside the
+// same schema ("#/$defs/name", "#/definitions/name", "#"). Draft 4 style
+// boolean exclusiveMinimum/exclusiveMaximum and array-form items are
+// accepted too. format and other annotations are ignored, as are unknown
+// keywords. Remote references are not supported, nor are references that
+// lead back to the same schema without descending into the document, such
+// as {"$ref": "#"}.
+//
+// Documents are the generic values produced by encoding/json
+// (map[string]interface{}, []interface{}, float64, string, bool, nil).
+package schema
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// ErrInvalidSchema is returned when a schema document cannot be compiled
+var ErrInvalidSchema = errors.New("invalid schema")
+
+// Schema is a compiled JSON Schema
+type Schema struct {
+	root *node
+}
+
+// node is a compiled schema object or boolean schema
+type node struct {
+	always *bool // set for the boolean schemas true and false
+
+	types    []string
+	enum     []interface{}
+	constant *interface{}
+
+	minimum, maximum                   *float64
+	exclusiveMinimum, exclusiveMaximum *float64
+	multipleOf                         *float64
+
+	minLength, maxLength *int
+	pattern              *regexp.Regexp
+
+	items              *node
+	prefixItems        []*node
+	minItems, maxItems *int
+	uniqueItems        bool
+
+	properties       map[string]*node
+	patternProps     []patternProperty
+	additional       *node
+	propertyNames    *node
+	required         []string
+	dependentRequire map[string][]string
+	minProps         *int
+	maxProps         *int
+
+	allOf, anyOf, oneOf []*node
+	not                 *node
+	ref                 *node
+}
+
+// patternProperty applies a schema to properties whose names match a regexp
+type patternProperty struct {
+	re     *regexp.Regexp
+	schema *node
+}
+
+// knownTypes are the values allowed in "type"
+var knownTypes = map[string]bool{
+	"null": true, "boolean": true, "object": true, "array": true,
+	"number": true, "integer": true, "string": true,
+}
+
+// Compile parses and compiles a schema document
+func Compile(data []byte) (*Schema, error) {
+	var doc interface{}
+	if err := json.Unmarshal(data, &doc); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidSchema, err)
+	}
+	if _, ok := doc.(map[string]interface{}); !ok {
+		return nil, fmt.Errorf("%w: must be a JSON object", ErrInvalidSchema)
+	}
+
+	c := &compiler{root: doc, refs: make(map[string]*node)}
+	root, err := c.compile(doc, "")
+	if err != nil {
+		return nil, err
+	}
+	if err := c.checkCycles(); err != nil {
+		return nil, err
+	}
+	return &Schema{root: root}, nil
+}
+
+// compiler tracks the document being compiled so references can be resolved
+type compiler struct {
+	root interface{}
+	refs map[string]*node // JSON Pointer -> compiled node
+}
+
+// compile compiles the schema found at ptr
+func (c *compiler) compile(v interface{}, ptr string) (*node, error) {
+	if n, ok := c.refs[ptr]; ok {
+		return n, nil
+	}
+
+	n := &node{}
+	c.refs[ptr] = n // registered first, so recursive references terminate
+
+	switch s := v.(type) {
+	case bool:
+		n.always = &s
+		return n, nil
+	case map[string]interface{}:
+		if err := c.compileObject(n, s, ptr); err != nil {
+			return nil, err
+		}
+		return n, nil
+	default:
+		return nil, c.errorf(ptr, "schema must be an object or a boolean")
+	}
+}
+
+// checkCycles rejects schemas that apply to a value through a chain of
+// $ref, allOf, anyOf, oneOf and not leading back to themselves. Validating
+// a value against them would never end, as no keyword in the chain moves on
+// to a nested value.
+func (c *compiler) checkCycles() error {
+	const (
+		visiting = 1
+		visited  = 2
+	)
+	ptrs := make([]string, 0, len(c.refs))
+	for ptr := range c.refs {
+		ptrs = append(ptrs, ptr)
+	}
+	sort.Strings(ptrs) // so the reported location is stable
+	at := make(map[*node]string, len(c.refs))
+	for i := len(ptrs) - 1; i >= 0; i-- {
+		at[c.refs[ptrs[i]]] = ptrs[i]
+	}
+
+	state := make(map[*node]int, len(c.refs))
+	var visit func(n *node) error
+	visit = func(n *node) error {
+		switch state[n] {
+		case visiting:
+			return c.errorf(at[n], "reference cycle that never descends into the document")
+		case visited:
+			return nil
+		}
+		state[n] = visiting
+		for _, next := range n.inPlace() {
+			if err := visit(next); err != nil {
+				return err
+			}
+		}
+		state[n] = visited
+		return nil
+	}
+	for _, ptr := range ptrs {
+		if err := visit(c.refs[ptr]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// inPlace returns the schemas n applies to the same value it is given
+func (n *node) inPlace() []*node {
+	var out []*node
+	if n.ref != nil {
+		out = append(out, n.ref)
+	}
+	out = append(out, n.allOf...)
+	out = append(out, n.anyOf...)
+	out = append(out, n.oneOf...)
+	if n.not != nil {
+		out = append(out, n.not)
+	}
+	return out
+}
+
+// compileObject fills n from the keywords of a schema object
+func (c *compiler) compileObject(n *node, s map[string]interface{}, ptr string) error {
+	var err error
+
+	if ref, ok := s["$ref"]; ok {
+		str, ok := ref.(string)
+		if !ok || (str != "#" && !strings.HasPrefix(str, "#/")) {
+			return c.errorf(ptr+"/$ref", "only references within the schema are supported")
+		}
+		target, found := resolve(c.root, strings.TrimPrefix(str, "#"))
+		if !found {
+			return c.errorf(ptr+"/$ref", "cannot resolve %s", str)
+		}
+		if n.ref, err = c.compile(target, strings.TrimPrefix(str, "#")); err != nil {
+			return err
+		}
+	}
+
+	if t, ok := s["type"]; ok {
+		switch t := t.(type) {
+		case string:
+			n.types = []string{t}
+		case []interface{}:
+			for _, e := range t {
+				str, ok := e.(string)
+				if !ok {
+					return c.errorf(ptr+"/type", "must be a string or an array of strings")
+				}
+				n.types = append(n.types, str)
+			}
+		default:
+			return c.errorf(ptr+"/type", "must be a string or an array of strings")
+		}
+		for _, t := range n.types {
+			if !knownTypes[t] {
+				return c.errorf(ptr+"/type", "unknown type %q", t)
+			}
+		}
+	}
+
+	if e, ok := s["enum"]; ok {
+		arr, ok := e.([]interface{})
+		if !ok {
+			return c.errorf(ptr+"/enum", "must be an array")
+		}
+		n.enum = arr
+	}
+	if v, ok := s["const"]; ok {
+		n.constant = &v
+	}
+
+	for _, kw := range []struct {
+		name string
+		dst  **float64
+	}{
+		{"minimum", &n.minimum},
+		{"maximum", &n.maximum},
+		{"multipleOf", &n.multipleOf},
+	} {
+		if *kw.dst, err = c.number(s, kw.name, ptr); err != nil {
+			return err
+		}
+	}
+	if n.multipleOf != nil && *n.multipleOf <= 0 {
+		return c.errorf(ptr+"/multipleOf", "must be greater than 0")
+	}
+	if n.exclusiveMinimum, err = c.exclusive(s, "exclusiveMinimum", n.minimum, ptr); err != nil {
+		return err
+	}
+	if n.exclusiveMaximum, err = c.exclusive(s, "exclusiveMaximum", n.maximum, ptr); err != nil {
+		return err
+	}
+
+	for _, kw := range []struct {
+		name string
+		dst  **int
+	}{
+		{"minLength", &n.minLength},
+		{"maxLength", &n.maxLength},
+		{"minItems", &n.minItems},
+		{"maxItems", &n.maxItems},
+		{"minProperties", &n.minProps},
+		{"maxProperties", &n.maxProps},
+	} {
+		if *kw.dst, err = c.count(s, kw.name, ptr); err != nil {
+			return err
+		}
+	}
+
+	if p, ok := s["pattern"]; ok {
+		str, ok := p.(string)
+		if !ok {
+			return c.errorf(ptr+"/pattern", "must be a string")
+		}
+		if n.pattern, err = regexp.Compile(str); err != nil {
+			return c.errorf(ptr+"/pattern", "%v", err)
+		}
+	}
+
+	if u, ok := s["uniqueItems"]; ok {
+		b, ok := u.(bool)
+		if !ok {
+			return c.errorf(ptr+"/uniqueItems", "must be a boolean")
+		}
+		n.uniqueItems = b
+	}
+
+	if err := c.compileItems(n, s, ptr); err != nil {
+		return err
+	}
+	if err := c.compileProperties(n, s, ptr); err != nil {
+		return err
+	}
+
+	for _, kw := range []struct {
+		name string
+		dst  *[]*node
+	}{
+		{"allOf", &n.allOf},
+		{"anyOf", &n.anyOf},
+		{"oneOf", &n.oneOf},
+	} {
+		if *kw.dst, err = c.schemaList(s, kw.name, ptr); err != nil {
+			return err
+		}
+	}
+	if v, ok := s["not"]; ok {
+		if n.not, err = c.compile(v, ptr+"/not"); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// compileItems compiles the array keywords items and prefixItems
+func (c *compiler) compileItems(n *node, s map[string]interface{}, ptr string) error {
+	var err error
+	if n.prefixItems, err = c.schemaList(s, "prefixItems", ptr); err != nil {
+		return err
+	}
+
+	items, ok := s["items"]
+	if !ok {
+		return nil
+	}
+	if arr, ok := items.([]interface{}); ok {
+		// Draft 4-7 tuple form; additionalItems then covers the rest
+		if n.prefixItems, err = c.schemaList(s, "items", ptr); err != nil {
+			return err
+		}
+		if extra, ok := s["additionalItems"]; ok && len(arr) > 0 {
+			n.items, err = c.compile(extra, ptr+"/additionalItems")
+		}
+		return err
+	}
+	n.items, err = c.compile(items, ptr+"/items")
+	return err
+}
+
+// compileProperties compiles the object keywords
+func (c *compiler) compileProperties(n *node, s map[string]interface{}, ptr string) error {
+	var err error
+
+	if p, ok := s["properties"]; ok {
+		props, ok := p.(map[string]interface{})
+		if !ok {
+			return c.errorf(ptr+"/properties", "must be an object")
+		}
+		n.properties = make(map[string]*node, len(props))
+		for name, sub := range props {
+			if n.properties[name], err = c.compile(sub, ptr+"/properties/"+escape(name)); err != nil {
+				return err
+			}
+		}
+	}
+
+	if p, ok := s["patternProperties"]; ok {
+		props, ok := p.(map[string]interface{})
+		if !ok {
+			return c.errorf(ptr+"/patternProperties", "must be an object")
+		}
+		for _, expr := range sortedKeys(props) {
+			re, err := regexp.Compile(expr)
+			if err != nil {
+				return c.errorf(ptr+"/patternProperties", "%v", err)
+			}
+			sub, err := c.compile(props[expr], ptr+"/patternProperties/"+escape(expr))
+			if err != nil {
+				return err
+			}
+			n.patternProps = append(n.patternProps, patternProperty{re: re, schema: sub})
+		}
+	}
+
+	if a, ok := s["additionalProperties"]; ok {
+		if n.additional, err = c.compile(a, ptr+"/additionalProperties"); err != nil {
+			return err
+		}
+	}
+	if p, ok := s["propertyNames"]; ok {
+		if n.propertyNames, err = c.compile(p, ptr+"/propertyNames"); err != nil {
+			return err
+		}
+	}
+
+	if r, ok := s["required"]; ok {
+		if n.required, err = c.strings(r, ptr+"/required"); err != nil {
+			return err
+		}
+	}
+	if d, ok := s["dependentRequired"]; ok {
+		deps, ok := d.(map[string]interface{})
+		if !ok {
+			return c.errorf(ptr+"/dependentRequired", "must be an object")
+		}
+		n.dependentRequire = make(map[string][]string, len(deps))
+		for name, r := range deps {
+			if n.dependentRequire[name], err = c.strings(r, ptr+"/dependentRequired/"+escape(name)); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// number reads an optional numeric keyword
+func (c *compiler) number(s map[string]interface{}, name, ptr string) (*float64, error) {
+	v, ok := s[name]
+	if !ok {
+		return nil, nil
+	}
+	f, ok := v.(float64)
+	if !ok {
+		return nil, c.errorf(ptr+"/"+name, "must be a number")
+	}
+	return &f, nil
+}
+
+// exclusive reads exclusiveMinimum or exclusiveMaximum, which is a number
+// since draft 6 and a boolean modifying bound before that
+func (c *compiler) exclusive(s map[string]interface{}, name string, bound *float64, ptr string) (*float64, error) {
+	if b, ok := s[name].(bool); ok {
+		if b && bound != nil {
+			v := *bound
+			return &v, nil
+		}
+		return nil, nil
+	}
+	return c.number(s, name, ptr)
+}
+
+// count reads an optional non-negative integer keyword
+func (c *compiler) count(s map[string]interface{}, name, ptr string) (*int, error) {
+	v, ok := s[name]
+	if !ok {
+		return nil, nil
+	}
+	f, ok := v.(float64)
+	if !ok || f < 0 || f != math.Trunc(f) || f > math.MaxInt32 {
+		return nil, c.errorf(ptr+"/"+name, "must be a non-negative integer")
+	}
+	i := int(f)
+	return &i, nil
+}
+
+// strings reads an array of strings
+func (c *compiler) strings(v interface{}, ptr string) ([]string, error) {
+	arr, ok := v.([]interface{})
+	if !ok {
+		return nil, c.errorf(ptr, "must be an array of strings")
+	}
+	out := make([]string, 0, len(arr))
+	for _, e := range arr {
+		str, ok := e.(string)
+		if !ok {
+			return nil, c.errorf(ptr, "must be an array of strings")
+		}
+		out = append(out, str)
+	}
+	return out, nil
+}
+
+// schemaList compiles a keyword holding an array of schemas
+func (c *compiler) schemaList(s map[string]interface{}, name, ptr string) ([]*node, error) {
+	v, ok := s[name]
+	if !ok {
+		return nil, nil
+	}
+	arr, ok := v.([]interface{})
+	if !ok || len(arr) == 0 {
+		return nil, c.errorf(ptr+"/"+name, "must be a non-empty array of schemas")
+	}
+	out := make([]*node, len(arr))
+	for i, sub := range arr {
+		var err error
+		if out[i], err = c.compile(sub, ptr+"/"+name+"/"+strconv.Itoa(i)); err != nil {
+			return nil, err
+		}
+	}
+	return out, nil
+}
+
+// errorf reports a problem with the schema at ptr
+func (c *compiler) errorf(ptr, format string, args ...interface{}) error {
+	if ptr == "" {
+		ptr = "/"
+	}
+	return fmt.Errorf("%w at %s: %s", ErrInvalidSchema, ptr, fmt.Sprintf(format, args...))
+}
+
+// resolve returns the value at a JSON Pointer inside doc
+func resolve(doc interface{}, ptr string) (interface{}, bool) {
+	if ptr == "" {
+		return doc, true
+	}
+	cur := doc
+	for _, tok := range strings.Split(ptr[1:], "/") {
+		tok = strings.ReplaceAll(strings.ReplaceAll(tok, "~1", "/"), "~0", "~")
+		switch v := cur.(type) {
+		case map[string]interface{}:
+			next, ok := v[tok]
+			if !ok {
+				return nil, false
+			}
+			cur = next
+		case []interface{}:
+			i, err := strconv.Atoi(tok)
+			if err != nil || i < 0 || i >= len(v) {
+				return nil, false
+			}
+			cur = v[i]
+		default:
+			return nil, false
+		}
+	}
+	return cur, true
+}
+
+// escape encodes a property name as a JSON Pointer token
+func escape(name string) string {
+	return strings.ReplaceAll(strings.ReplaceAll(name, "~", "~0"), "/", "~1")
+}
+
+// sortedKeys returns the keys of m in order
+func sortedKeys(m map[string]interface{}) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
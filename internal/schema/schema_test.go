@@ -0,0 +1,217 @@
+package schema
+
+import (
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestCompileErrors(t *testing.T) {
+	tests := []struct {
+		name   string
+		schema string
+		want   string // part of the error
+	}{
+		{"invalid json", `{`, "invalid schema"},
+		{"not an object", `[]`, "must be a JSON object"},
+		{"boolean root", `true`, "must be a JSON object"},
+		{"subschema not a schema", `{"not": 1}`, "at /not: schema must be an object or a boolean"},
+		{"type not a string", `{"type": 1}`, "at /type: must be a string or an array of strings"},
+		{"type array of numbers", `{"type": [1]}`, "at /type: must be a string or an array of strings"},
+		{"unknown type", `{"type": "float"}`, `unknown type "float"`},
+		{"enum not an array", `{"enum": 1}`, "at /enum: must be an array"},
+		{"minimum not a number", `{"minimum": "1"}`, "at /minimum: must be a number"},
+		{"exclusiveMaximum not a number", `{"exclusiveMaximum": "1"}`, "at /exclusiveMaximum: must be a number"},
+		{"multipleOf zero", `{"multipleOf": 0}`, "at /multipleOf: must be greater than 0"},
+		{"negative minLength", `{"minLength": -1}`, "at /minLength: must be a non-negative integer"},
+		{"fractional maxItems", `{"maxItems": 1.5}`, "at /maxItems: must be a non-negative integer"},
+		{"pattern not a string", `{"pattern": 1}`, "at /pattern: must be a string"},
+		{"invalid pattern", `{"pattern": "("}`, "at /pattern:"},
+		{"uniqueItems not a boolean", `{"uniqueItems": 1}`, "at /uniqueItems: must be a boolean"},
+		{"properties not an object", `{"properties": []}`, "at /properties: must be an object"},
+		{"invalid property schema", `{"properties": {"a/b": 1}}`, "at /properties/a~1b:"},
+		{"invalid patternProperties", `{"patternProperties": {"(": {}}}`, "at /patternProperties:"},
+		{"required not strings", `{"required": [1]}`, "at /required: must be an array of strings"},
+		{"dependentRequired not an object", `{"dependentRequired": []}`, "at /dependentRequired: must be an object"},
+		{"empty allOf", `{"allOf": []}`, "at /allOf: must be a non-empty array of schemas"},
+		{"anyOf not an array", `{"anyOf": {}}`, "at /anyOf: must be a non-empty array of schemas"},
+		{"remote ref", `{"$ref": "other.json#/a"}`, "only references within the schema are supported"},
+		{"ref not a string", `{"$ref": 1}`, "only references within the schema are supported"},
+		{"unresolvable ref", `{"$ref": "#/$defs/missing"}`, "cannot resolve #/$defs/missing"},
+		{"ref to itself", `{"$ref": "#"}`, "reference cycle"},
+		{"ref cycle through defs", `{"$defs": {"a": {"$ref": "#/$defs/a"}}, "$ref": "#/$defs/a"}`, "at /$defs/a: reference cycle"},
+		{"ref cycle of two", `{"$defs": {"a": {"$ref": "#/$defs/b"}, "b": {"$ref": "#/$defs/a"}}, "$ref": "#/$defs/a"}`, "reference cycle"},
+		{"ref cycle through allOf", `{"allOf": [{"$ref": "#"}]}`, "reference cycle"},
+		{"ref cycle through anyOf", `{"anyOf": [{"type": "string"}, {"$ref": "#"}]}`, "reference cycle"},
+		{"ref cycle through oneOf", `{"$defs": {"a": {"oneOf": [{"$ref": "#/$defs/a"}]}}, "properties": {"x": {"$ref": "#/$defs/a"}}}`, "at /$defs/a: reference cycle"},
+		{"ref cycle through not", `{"not": {"$ref": "#"}}`, "reference cycle"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := Compile([]byte(tt.schema))
+			if err == nil {
+				t.Fatalf("Compile(%s) succeeded, want an error containing %q", tt.schema, tt.want)
+			}
+			if !errors.Is(err, ErrInvalidSchema) {
+				t.Errorf("error %v does not wrap ErrInvalidSchema", err)
+			}
+			if !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("error %q does not contain %q", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestValidate(t *testing.T) {
+	tests := []struct {
+		name   string
+		schema string
+		doc    string
+		want   []string // violations as "path: message", nil when valid
+	}{
+		// Boolean schemas
+		{"true schema", `{"properties": {"a": true}}`, `{"a": 1}`, nil},
+		{"false schema", `{"properties": {"a": false}}`, `{"a": 1}`, []string{"/a: is not allowed"}},
+		{"empty schema", `{}`, `[1, "a", null]`, nil},
+		{"unknown keywords ignored", `{"format": "email", "title": "x"}`, `"a"`, nil},
+
+		// type
+		{"type string", `{"type": "string"}`, `"a"`, nil},
+		{"type string mismatch", `{"type": "string"}`, `1`, []string{"expected string, got integer"}},
+		{"type integer", `{"type": "integer"}`, `2.0`, nil},
+		{"type integer fraction", `{"type": "integer"}`, `2.5`, []string{"expected integer, got number"}},
+		{"type number accepts integer", `{"type": "number"}`, `2`, nil},
+		{"type null", `{"type": "null"}`, `null`, nil},
+		{"type boolean", `{"type": "boolean"}`, `"true"`, []string{"expected boolean, got string"}},
+		{"type object", `{"type": "object"}`, `[]`, []string{"expected object, got array"}},
+		{"type array", `{"type": "array"}`, `[]`, nil},
+		{"type list", `{"type": ["string", "null"]}`, `null`, nil},
+		{"type list mismatch", `{"type": ["string", "null"]}`, `true`, []string{"expected string or null, got boolean"}},
+		{"type mismatch skips other keywords", `{"type": "string", "minLength": 5}`, `1`, []string{"expected string, got integer"}},
+
+		// enum and const
+		{"enum", `{"enum": ["a", 1, null]}`, `1`, nil},
+		{"enum object", `{"enum": [{"a": [1]}]}`, `{"a": [1]}`, nil},
+		{"enum mismatch", `{"enum": ["a", 1, null]}`, `"b"`, []string{`must be one of ["a", 1, null]`}},
+		{"const", `{"const": "a"}`, `"a"`, nil},
+		{"const mismatch", `{"const": 3}`, `4`, []string{"must be 3"}},
+		{"const null", `{"const": null}`, `0`, []string{"must be null"}},
+
+		// Numbers
+		{"minimum", `{"minimum": 2}`, `2`, nil},
+		{"minimum below", `{"minimum": 2}`, `1.5`, []string{"must be at least 2"}},
+		{"maximum", `{"maximum": 2}`, `2`, nil},
+		{"maximum above", `{"maximum": 2}`, `3`, []string{"must be at most 2"}},
+		{"exclusiveMinimum", `{"exclusiveMinimum": 2}`, `2`, []string{"must be greater than 2"}},
+		{"exclusiveMinimum above", `{"exclusiveMinimum": 2}`, `2.1`, nil},
+		{"exclusiveMaximum", `{"exclusiveMaximum": 2}`, `2`, []string{"must be less than 2"}},
+		{"exclusiveMaximum below", `{"exclusiveMaximum": 2}`, `1.9`, nil},
+		{"draft 4 exclusiveMinimum", `{"minimum": 2, "exclusiveMinimum": true}`, `2`, []string{"must be greater than 2"}},
+		{"draft 4 exclusiveMaximum", `{"maximum": 2, "exclusiveMaximum": true}`, `2`, []string{"must be less than 2"}},
+		{"draft 4 exclusive false", `{"maximum": 2, "exclusiveMaximum": false}`, `2`, nil},
+		{"multipleOf", `{"multipleOf": 3}`, `9`, nil},
+		{"multipleOf mismatch", `{"multipleOf": 3}`, `10`, []string{"must be a multiple of 3"}},
+		{"multipleOf fraction", `{"multipleOf": 0.1}`, `0.3`, nil},
+		{"numeric keywords ignore strings", `{"minimum": 2}`, `"1"`, nil},
+
+		// Strings
+		{"minLength", `{"minLength": 2}`, `"ab"`, nil},
+		{"minLength short", `{"minLength": 2}`, `"a"`, []string{"must be at least 2 characters long"}},
+		{"maxLength", `{"maxLength": 2}`, `"abc"`, []string{"must be at most 2 characters long"}},
+		{"maxLength counts characters", `{"maxLength": 2}`, `"éé"`, nil},
+		{"pattern", `{"pattern": "^[a-z]+$"}`, `"abc"`, nil},
+		{"pattern mismatch", `{"pattern": "^[a-z]+$"}`, `"ab1"`, []string{`must match pattern "^[a-z]+$"`}},
+		{"pattern unanchored", `{"pattern": "b"}`, `"abc"`, nil},
+		{"string keywords ignore numbers", `{"minLength": 2}`, `1`, nil},
+
+		// Arrays
+		{"items", `{"items": {"type": "integer"}}`, `[1, 2]`, nil},
+		{"items mismatch", `{"items": {"type": "integer"}}`, `[1, "a", 2.5]`, []string{"/1: expected integer, got string", "/2: expected integer, got number"}},
+		{"prefixItems", `{"prefixItems": [{"type": "string"}, {"type": "integer"}]}`, `["a", 1, true]`, nil},
+		{"prefixItems mismatch", `{"prefixItems": [{"type": "string"}]}`, `[1]`, []string{"/0: expected string, got integer"}},
+		{"prefixItems then items", `{"prefixItems": [{"type": "string"}], "items": {"type": "integer"}}`, `["a", 1, "b"]`, []string{"/2: expected integer, got string"}},
+		{"tuple items", `{"items": [{"type": "string"}], "additionalItems": false}`, `["a"]`, nil},
+		{"tuple items mismatch", `{"items": [{"type": "string"}], "additionalItems": false}`, `[1, 2]`, []string{"/0: expected string, got integer", "/1: is not allowed"}},
+		{"tuple items without additionalItems", `{"items": [{"type": "string"}]}`, `["a", 2]`, nil},
+		{"minItems", `{"minItems": 2}`, `[1]`, []string{"must have at least 2 items"}},
+		{"maxItems", `{"maxItems": 2}`, `[1, 2, 3]`, []string{"must have at most 2 items"}},
+		{"uniqueItems", `{"uniqueItems": true}`, `[1, "1", [1], {"a": 1}]`, nil},
+		{"uniqueItems duplicate", `{"uniqueItems": true}`, `[1, 2, {"a": 1}, {"a": 1}]`, []string{"items 2 and 3 are equal, items must be unique"}},
+		{"uniqueItems false", `{"uniqueItems": false}`, `[1, 1]`, nil},
+
+		// Objects
+		{"properties", `{"properties": {"a": {"type": "string"}}}`, `{"a": "x", "b": 1}`, nil},
+		{"properties mismatch", `{"properties": {"a": {"type": "string"}}}`, `{"a": 1}`, []string{"/a: expected string, got integer"}},
+		{"property path escaped", `{"properties": {"a/b~c": {"type": "string"}}}`, `{"a/b~c": 1}`, []string{"/a~1b~0c: expected string, got integer"}},
+		{"patternProperties", `{"patternProperties": {"^n_": {"type": "number"}}}`, `{"n_a": 1, "s": "x"}`, nil},
+		{"patternProperties mismatch", `{"patternProperties": {"^n_": {"type": "number"}}}`, `{"n_a": "x"}`, []string{"/n_a: expected number, got string"}},
+		{"additionalProperties false", `{"properties": {"a": {}}, "patternProperties": {"^x": {}}, "additionalProperties": false}`, `{"a": 1, "xy": 2, "b": 3}`, []string{"/b: is not an allowed property"}},
+		{"additionalProperties schema", `{"properties": {"a": {}}, "additionalProperties": {"type": "integer"}}`, `{"a": "x", "b": "y"}`, []string{"/b: expected integer, got string"}},
+		{"propertyNames", `{"propertyNames": {"maxLength": 3}}`, `{"abc": 1}`, nil},
+		{"propertyNames mismatch", `{"propertyNames": {"maxLength": 3}}`, `{"abcd": 1}`, []string{"/abcd: property name is not allowed"}},
+		{"required", `{"required": ["a", "b"]}`, `{"a": null, "b": 0}`, nil},
+		{"required missing", `{"required": ["a", "b"]}`, `{"a": 1}`, []string{"/b: is required"}},
+		{"dependentRequired", `{"dependentRequired": {"card": ["cvv"]}}`, `{"card": 1, "cvv": 2}`, nil},
+		{"dependentRequired absent trigger", `{"dependentRequired": {"card": ["cvv"]}}`, `{"name": 1}`, nil},
+		{"dependentRequired missing", `{"dependentRequired": {"card": ["cvv"]}}`, `{"card": 1}`, []string{`/cvv: is required when "card" is present`}},
+		{"minProperties", `{"minProperties": 2}`, `{"a": 1}`, []string{"must have at least 2 properties"}},
+		{"maxProperties", `{"maxProperties": 1}`, `{"a": 1, "b": 2}`, []string{"must have at most 1 properties"}},
+		{"object keywords ignore arrays", `{"required": ["a"]}`, `[]`, nil},
+
+		// Combinators
+		{"allOf", `{"allOf": [{"type": "integer"}, {"minimum": 2}]}`, `3`, nil},
+		{"allOf mismatch", `{"allOf": [{"type": "integer"}, {"minimum": 2}]}`, `1.5`, []string{"expected integer, got number", "must be at least 2"}},
+		{"anyOf", `{"anyOf": [{"type": "string"}, {"minimum": 2}]}`, `3`, nil},
+		{"anyOf mismatch", `{"anyOf": [{"type": "string"}, {"minimum": 2}]}`, `1`, []string{"does not match any of the allowed schemas"}},
+		{"oneOf", `{"oneOf": [{"type": "string"}, {"type": "number", "minimum": 2}]}`, `"a"`, nil},
+		{"oneOf none", `{"oneOf": [{"type": "string"}, {"type": "number", "minimum": 2}]}`, `1`, []string{"matches 0 of the oneOf schemas, expected exactly one"}},
+		{"oneOf several", `{"oneOf": [{"type": "integer"}, {"minimum": 2}]}`, `3`, []string{"matches 2 of the oneOf schemas, expected exactly one"}},
+		{"not", `{"not": {"type": "string"}}`, `1`, nil},
+		{"not mismatch", `{"not": {"type": "string"}}`, `"a"`, []string{"must not match the schema in not"}},
+
+		// $ref
+		{"ref to $defs", `{"$defs": {"id": {"type": "integer"}}, "properties": {"a": {"$ref": "#/$defs/id"}}}`, `{"a": "x"}`, []string{"/a: expected integer, got string"}},
+		{"ref to definitions", `{"definitions": {"id": {"type": "integer"}}, "items": {"$ref": "#/definitions/id"}}`, `[1, 2]`, nil},
+		{"ref to a property", `{"properties": {"a": {"type": "string"}, "b": {"$ref": "#/properties/a"}}}`, `{"b": 1}`, []string{"/b: expected string, got integer"}},
+		{"ref with escaped token", `{"$defs": {"a/b": {"const": 1}}, "$ref": "#/$defs/a~1b"}`, `2`, []string{"must be 1"}},
+		{"ref next to other keywords", `{"$defs": {"s": {"type": "string"}}, "$ref": "#/$defs/s", "maxLength": 1}`, `"ab"`, []string{"must be at most 1 characters long"}},
+		{"recursive ref to root", `{"type": "object", "properties": {"child": {"$ref": "#"}}, "required": ["name"]}`, `{"name": "a", "child": {"name": "b", "child": {}}}`, []string{"/child/child/name: is required"}},
+		{"recursive ref through items", `{"$defs": {"tree": {"type": "array", "items": {"$ref": "#/$defs/tree"}}}, "$ref": "#/$defs/tree"}`, `[[], [[]], [[1]]]`, []string{"/2/0/0: expected array, got integer"}},
+		{"recursive ref through anyOf", `{"$defs": {"list": {"anyOf": [{"type": "null"}, {"type": "object", "properties": {"next": {"$ref": "#/$defs/list"}}}]}}, "$ref": "#/$defs/list"}`, `{"next": {"next": null}}`, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s, err := Compile([]byte(tt.schema))
+			if err != nil {
+				t.Fatalf("Compile(%s): %v", tt.schema, err)
+			}
+			var doc interface{}
+			if err := json.Unmarshal([]byte(tt.doc), &doc); err != nil {
+				t.Fatalf("invalid test document %s: %v", tt.doc, err)
+			}
+
+			var got []string
+			for _, v := range s.Validate(doc) {
+				got = append(got, v.String())
+			}
+			if strings.Join(got, "\n") != strings.Join(tt.want, "\n") {
+				t.Errorf("Validate(%s) = %q, want %q", tt.doc, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestValidateLimitsViolations(t *testing.T) {
+	s, err := Compile([]byte(`{"items": {"type": "string"}}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	doc := make([]interface{}, 2*maxViolations)
+	for i := range doc {
+		doc[i] = float64(i)
+	}
+	if got := len(s.Validate(doc)); got != maxViolations {
+		t.Errorf("got %d violations, want %d", got, maxViolations)
+	}
+}
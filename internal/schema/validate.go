@@ -0,0 +1,325 @@
+package schema
+
+import (
+	"fmt"
+	"math"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// maxViolations caps the violations reported for one document
+const maxViolations = 100
+
+// Violation is one way a document does not match a schema. Path is a JSON
+// Pointer to the offending value, "" for the document itself.
+type Violation struct {
+	Path    string `json:"path"`
+	Message string `json:"message"`
+}
+
+// String formats the violation for error messages
+func (v Violation) String() string {
+	if v.Path == "" {
+		return v.Message
+	}
+	return v.Path + ": " + v.Message
+}
+
+// Validate checks doc against the schema and returns every violation found,
+// up to a limit. A nil result means doc is valid.
+func (s *Schema) Validate(doc interface{}) []Violation {
+	v := &validator{}
+	v.validate(s.root, doc, "")
+	return v.out
+}
+
+// validator collects violations
+type validator struct {
+	out []Violation
+}
+
+// report records a violation at path
+func (v *validator) report(path, format string, args ...interface{}) {
+	if len(v.out) < maxViolations {
+		v.out = append(v.out, Violation{Path: path, Message: fmt.Sprintf(format, args...)})
+	}
+}
+
+// matches reports whether doc matches n, without recording violations
+func matches(n *node, doc interface{}, path string) bool {
+	v := &validator{}
+	v.validate(n, doc, path)
+	return len(v.out) == 0
+}
+
+// validate checks doc, found at path, against n
+func (v *validator) validate(n *node, doc interface{}, path string) {
+	if n.always != nil {
+		if !*n.always {
+			v.report(path, "is not allowed")
+		}
+		return
+	}
+
+	if n.ref != nil {
+		v.validate(n.ref, doc, path)
+	}
+
+	if len(n.types) > 0 && !hasType(doc, n.types) {
+		v.report(path, "expected %s, got %s", strings.Join(n.types, " or "), typeOf(doc))
+		return // the remaining keywords assume the right type
+	}
+
+	if n.enum != nil {
+		found := false
+		for _, e := range n.enum {
+			if equal(e, doc) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			v.report(path, "must be one of %s", list(n.enum))
+		}
+	}
+	if n.constant != nil && !equal(*n.constant, doc) {
+		v.report(path, "must be %s", literal(*n.constant))
+	}
+
+	switch d := doc.(type) {
+	case float64:
+		v.validateNumber(n, d, path)
+	case string:
+		v.validateString(n, d, path)
+	case []interface{}:
+		v.validateArray(n, d, path)
+	case map[string]interface{}:
+		v.validateObject(n, d, path)
+	}
+
+	for _, sub := range n.allOf {
+		v.validate(sub, doc, path)
+	}
+	if n.anyOf != nil {
+		found := false
+		for _, sub := range n.anyOf {
+			if matches(sub, doc, path) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			v.report(path, "does not match any of the allowed schemas")
+		}
+	}
+	if n.oneOf != nil {
+		count := 0
+		for _, sub := range n.oneOf {
+			if matches(sub, doc, path) {
+				count++
+			}
+		}
+		if count != 1 {
+			v.report(path, "matches %d of the oneOf schemas, expected exactly one", count)
+		}
+	}
+	if n.not != nil && matches(n.not, doc, path) {
+		v.report(path, "must not match the schema in not")
+	}
+}
+
+// validateNumber applies the numeric keywords
+func (v *validator) validateNumber(n *node, d float64, path string) {
+	if n.minimum != nil && d < *n.minimum {
+		v.report(path, "must be at least %s", number(*n.minimum))
+	}
+	if n.maximum != nil && d > *n.maximum {
+		v.report(path, "must be at most %s", number(*n.maximum))
+	}
+	if n.exclusiveMinimum != nil && d <= *n.exclusiveMinimum {
+		v.report(path, "must be greater than %s", number(*n.exclusiveMinimum))
+	}
+	if n.exclusiveMaximum != nil && d >= *n.exclusiveMaximum {
+		v.report(path, "must be less than %s", number(*n.exclusiveMaximum))
+	}
+	if n.multipleOf != nil {
+		q := d / *n.multipleOf
+		if math.IsInf(q, 0) || math.Abs(q-math.Round(q)) > 1e-9 {
+			v.report(path, "must be a multiple of %s", number(*n.multipleOf))
+		}
+	}
+}
+
+// validateString applies the string keywords
+func (v *validator) validateString(n *node, d string, path string) {
+	length := utf8.RuneCountInString(d)
+	if n.minLength != nil && length < *n.minLength {
+		v.report(path, "must be at least %d characters long", *n.minLength)
+	}
+	if n.maxLength != nil && length > *n.maxLength {
+		v.report(path, "must be at most %d characters long", *n.maxLength)
+	}
+	if n.pattern != nil && !n.pattern.MatchString(d) {
+		v.report(path, "must match pattern %q", n.pattern.String())
+	}
+}
+
+// validateArray applies the array keywords
+func (v *validator) validateArray(n *node, d []interface{}, path string) {
+	if n.minItems != nil && len(d) < *n.minItems {
+		v.report(path, "must have at least %d items", *n.minItems)
+	}
+	if n.maxItems != nil && len(d) > *n.maxItems {
+		v.report(path, "must have at most %d items", *n.maxItems)
+	}
+	if n.uniqueItems {
+	outer:
+		for i := range d {
+			for j := 0; j < i; j++ {
+				if equal(d[i], d[j]) {
+					v.report(path, "items %d and %d are equal, items must be unique", j, i)
+					break outer
+				}
+			}
+		}
+	}
+
+	for i, item := range d {
+		itemPath := path + "/" + strconv.Itoa(i)
+		switch {
+		case i < len(n.prefixItems):
+			v.validate(n.prefixItems[i], item, itemPath)
+		case n.items != nil:
+			v.validate(n.items, item, itemPath)
+		}
+	}
+}
+
+// validateObject applies the object keywords
+func (v *validator) validateObject(n *node, d map[string]interface{}, path string) {
+	if n.minProps != nil && len(d) < *n.minProps {
+		v.report(path, "must have at least %d properties", *n.minProps)
+	}
+	if n.maxProps != nil && len(d) > *n.maxProps {
+		v.report(path, "must have at most %d properties", *n.maxProps)
+	}
+	for _, name := range n.required {
+		if _, ok := d[name]; !ok {
+			v.report(path+"/"+escape(name), "is required")
+		}
+	}
+	triggers := make([]string, 0, len(n.dependentRequire))
+	for name := range n.dependentRequire {
+		triggers = append(triggers, name)
+	}
+	sort.Strings(triggers)
+	for _, name := range triggers {
+		if _, ok := d[name]; !ok {
+			continue
+		}
+		for _, dep := range n.dependentRequire[name] {
+			if _, ok := d[dep]; !ok {
+				v.report(path+"/"+escape(dep), "is required when %q is present", name)
+			}
+		}
+	}
+
+	// Properties in order, so reports are stable
+	for _, name := range sortedKeys(d) {
+		value := d[name]
+		propPath := path + "/" + escape(name)
+
+		if n.propertyNames != nil && !matches(n.propertyNames, name, propPath) {
+			v.report(propPath, "property name is not allowed")
+		}
+
+		known := false
+		if sub, ok := n.properties[name]; ok {
+			known = true
+			v.validate(sub, value, propPath)
+		}
+		for _, pp := range n.patternProps {
+			if pp.re.MatchString(name) {
+				known = true
+				v.validate(pp.schema, value, propPath)
+			}
+		}
+		if !known && n.additional != nil {
+			if n.additional.always != nil && !*n.additional.always {
+				v.report(propPath, "is not an allowed property")
+				continue
+			}
+			v.validate(n.additional, value, propPath)
+		}
+	}
+}
+
+// hasType reports whether doc is one of types
+func hasType(doc interface{}, types []string) bool {
+	actual := typeOf(doc)
+	for _, t := range types {
+		if t == actual || (t == "number" && actual == "integer") {
+			return true
+		}
+	}
+	return false
+}
+
+// typeOf returns the JSON Schema type of doc; whole numbers are integers
+func typeOf(doc interface{}) string {
+	switch d := doc.(type) {
+	case nil:
+		return "null"
+	case bool:
+		return "boolean"
+	case float64:
+		if d == math.Trunc(d) && !math.IsInf(d, 0) {
+			return "integer"
+		}
+		return "number"
+	case string:
+		return "string"
+	case []interface{}:
+		return "array"
+	case map[string]interface{}:
+		return "object"
+	default:
+		return reflect.TypeOf(doc).String()
+	}
+}
+
+// equal compares two documents; numbers compare by value
+func equal(a, b interface{}) bool {
+	return reflect.DeepEqual(a, b)
+}
+
+// number formats a number without a trailing fraction when whole
+func number(f float64) string {
+	return strconv.FormatFloat(f, 'f', -1, 64)
+}
+
+// literal formats a value as compact JSON-like text for messages
+func literal(v interface{}) string {
+	switch d := v.(type) {
+	case nil:
+		return "null"
+	case string:
+		return strconv.Quote(d)
+	case float64:
+		return number(d)
+	default:
+		return fmt.Sprint(d)
+	}
+}
+
+// list formats enum values for messages
+func list(values []interface{}) string {
+	parts := make([]string, len(values))
+	for i, e := range values {
+		parts[i] = literal(e)
+	}
+	return "[" + strings.Join(parts, ", ") + "]"
+}
@@ -0,0 +1,147 @@
+// Package search implements text analysis for kiwi's full-text index:
+// tokenization, lowercasing, stop word removal, stemming and highlighting.
+package search
+
+import (
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// stopWords are common English words that are not indexed
+var stopWords = map[string]bool{
+	"a": true, "an": true, "and": true, "are": true, "as": true, "at": true,
+	"be": true, "but": true, "by": true, "for": true, "if": true, "in": true,
+	"into": true, "is": true, "it": true, "no": true, "not": true, "of": true,
+	"on": true, "or": true, "such": true, "that": true, "the": true, "their": true,
+	"then": true, "there": true, "these": true, "they": true, "this": true,
+	"to": true, "was": true, "will": true, "with": true,
+}
+
+// Token is a word found in a text
+type Token struct {
+	Term  string // analyzed (lowercased, stemmed) form
+	Start int    // byte offset of the word in the original text
+	End   int
+}
+
+// Tokenize splits text into analyzed tokens, skipping stop words
+func Tokenize(text string) []Token {
+	var tokens []Token
+	start := -1
+	for i, r := range text {
+		isWord := unicode.IsLetter(r) || unicode.IsDigit(r)
+		if isWord && start < 0 {
+			start = i
+		}
+		if !isWord && start >= 0 {
+			tokens = appendToken(tokens, text, start, i)
+			start = -1
+		}
+	}
+	if start >= 0 {
+		tokens = appendToken(tokens, text, start, len(text))
+	}
+	return tokens
+}
+
+// appendToken analyzes text[start:end] and appends it unless it is a stop word
+func appendToken(tokens []Token, text string, start, end int) []Token {
+	word := strings.ToLower(text[start:end])
+	if stopWords[word] {
+		return tokens
+	}
+	return append(tokens, Token{Term: Stem(word), Start: start, End: end})
+}
+
+// Terms returns the distinct analyzed terms of a text, in order of appearance
+func Terms(text string) []string {
+	seen := make(map[string]bool)
+	var terms []string
+	for _, t := range Tokenize(text) {
+		if !seen[t.Term] {
+			seen[t.Term] = true
+			terms = append(terms, t.Term)
+		}
+	}
+	return terms
+}
+
+// Stem reduces an English word to an approximate root by stripping common
+// inflectional suffixes ("running" -> "run", "stories" -> "stori").
+// It is a light stemmer: cheap and predictable rather than linguistically complete.
+func Stem(word string) string {
+	if utf8.RuneCountInString(word) <= 3 {
+		return word
+	}
+
+	switch {
+	case strings.HasSuffix(word, "ies") && len(word) > 4:
+		word = word[:len(word)-3] + "y"
+	case strings.HasSuffix(word, "sses"):
+		word = word[:len(word)-2]
+	case strings.HasSuffix(word, "ss") || strings.HasSuffix(word, "us") || strings.HasSuffix(word, "is"):
+	case strings.HasSuffix(word, "es") && hasSibilantStem(word[:len(word)-2]):
+		word = word[:len(word)-2]
+	case strings.HasSuffix(word, "s"):
+		word = word[:len(word)-1]
+	}
+
+	for _, suffix := range []string{"ingly", "edly", "ing", "ed"} {
+		if strings.HasSuffix(word, suffix) {
+			root := word[:len(word)-len(suffix)]
+			if containsVowel(root) && len(root) >= 3 {
+				word = undouble(root)
+			}
+			break
+		}
+	}
+
+	for _, suffix := range []string{"ational", "fulness", "iveness", "ization", "ation", "ness", "ment", "ful", "ly"} {
+		if strings.HasSuffix(word, suffix) {
+			root := word[:len(word)-len(suffix)]
+			if containsVowel(root) && len(root) >= 3 {
+				switch suffix {
+				case "ational":
+					word = root + "ate"
+				case "ization":
+					word = root + "ize"
+				default:
+					word = root
+				}
+			}
+			break
+		}
+	}
+
+	// Normalize a final y so that "happy" and "happily" share a stem
+	if n := len(word); n > 3 && word[n-1] == 'y' && containsVowel(word[:n-1]) {
+		word = word[:n-1] + "i"
+	}
+
+	return word
+}
+
+// hasSibilantStem reports whether "es" after root is a plural ending ("boxes", "wishes")
+func hasSibilantStem(root string) bool {
+	for _, end := range []string{"x", "z", "ch", "sh", "s", "o"} {
+		if strings.HasSuffix(root, end) {
+			return true
+		}
+	}
+	return false
+}
+
+// containsVowel reports whether s contains a vowel
+func containsVowel(s string) bool {
+	return strings.ContainsAny(s, "aeiouy")
+}
+
+// undouble removes a doubled final consonant ("runn" -> "run")
+func undouble(s string) string {
+	n := len(s)
+	if n >= 2 && s[n-1] == s[n-2] && !strings.ContainsRune("aeioulsz", rune(s[n-1])) {
+		return s[:n-1]
+	}
+	return s
+}
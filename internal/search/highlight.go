@@ -0,0 +1,75 @@
+package search
+
+import (
+	"html"
+	"strings"
+	"unicode/utf8"
+)
+
+// Highlight markers wrapped around matched words
+const (
+	HighlightPre  = "<em>"
+	HighlightPost = "</em>"
+)
+
+// fragmentRadius is the number of bytes of context kept around the first match
+const fragmentRadius = 80
+
+// Highlight returns a fragment of text around the first matched term with
+// every matched word wrapped in highlight markers. The text itself is HTML
+// escaped, so the markers are the only markup in the fragment. It returns
+// false when none of the terms occur in text.
+func Highlight(text string, terms map[string]bool) (string, bool) {
+	var matches []Token
+	for _, t := range Tokenize(text) {
+		if terms[t.Term] {
+			matches = append(matches, t)
+		}
+	}
+	if len(matches) == 0 {
+		return "", false
+	}
+
+	// Cut a window around the first match on word boundaries
+	start := matches[0].Start - fragmentRadius
+	if start < 0 {
+		start = 0
+	} else if i := strings.IndexByte(text[start:], ' '); i >= 0 && start+i < matches[0].Start {
+		start += i + 1
+	}
+	end := matches[0].End + fragmentRadius
+	if end > len(text) {
+		end = len(text)
+	} else if i := strings.LastIndexByte(text[:end], ' '); i > matches[0].End {
+		end = i
+	}
+	// Without a space to cut at, keep whole characters
+	for start > 0 && !utf8.RuneStart(text[start]) {
+		start++
+	}
+	for end < len(text) && !utf8.RuneStart(text[end]) {
+		end--
+	}
+
+	var sb strings.Builder
+	if start > 0 {
+		sb.WriteString("...")
+	}
+	pos := start
+	for _, m := range matches {
+		if m.Start < start || m.End > end {
+			continue
+		}
+		sb.WriteString(html.EscapeString(text[pos:m.Start]))
+		sb.WriteString(HighlightPre)
+		sb.WriteString(html.EscapeString(text[m.Start:m.End]))
+		sb.WriteString(HighlightPost)
+		pos = m.End
+	}
+	sb.WriteString(html.EscapeString(text[pos:end]))
+	if end < len(text) {
+		sb.WriteString("...")
+	}
+
+	return sb.String(), true
+}
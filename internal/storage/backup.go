@@ -0,0 +1,369 @@
+package storage
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"os"
+
+	"kiwi/internal/watch"
+
+	"github.com/syndtr/goleveldb/leveldb/opt"
+)
+
+// backupBatchSize is the number of entries written per batch when copying
+const backupBatchSize = 1000
+
+var (
+	// ErrTargetNotEmpty is returned when restoring a full backup over existing data
+	ErrTargetNotEmpty = errors.New("target database is not empty")
+	// ErrSequenceGap is returned when replayed changes do not follow on from
+	// the last change in the database
+	ErrSequenceGap = errors.New("changes do not follow on from the database sequence")
+)
+
+// BackupInfo summarizes the contents of a backup
+type BackupInfo struct {
+	Sequence    uint64           // last change sequence included
+	Keys        int64            // number of database entries copied
+	Collections map[string]int64 // collection -> number of keys
+}
+
+// Backup copies a consistent snapshot of the whole database, including
+// indexes and metadata, into a new LevelDB database at dir, whatever the
+// engine of the store. The copy can be opened directly as a DB_PATH of the
+// LevelDB engine, or restored into any engine.
+func (s *LocalStore) Backup(dir string) (BackupInfo, error) {
+	snap, err := s.db.GetSnapshot()
+	if err != nil {
+		return BackupInfo{}, fmt.Errorf("failed to acquire snapshot: %w", err)
+	}
+	defer snap.Release()
+
+	info := BackupInfo{Collections: make(map[string]int64)}
+	if data, err := snap.Get(feedSeqKey); err == nil && len(data) == 8 {
+		info.Sequence = binary.BigEndian.Uint64(data)
+	}
+
+	target, err := openLevelDBWith(dir, &opt.Options{ErrorIfExist: true})
+	if err != nil {
+		return BackupInfo{}, fmt.Errorf("failed to create backup database: %w", err)
+	}
+	defer target.Close()
+
+	iter := snap.NewIterator(nil)
+	defer iter.Release()
+
+	batch := new(WriteBatch)
+	for iter.Next() {
+		key := iter.Key()
+		batch.Put(key, iter.Value())
+		info.Keys++
+
+		if collection, _, ok := parseDataKey(key); ok {
+			info.Collections[collection]++
+		}
+
+		if batch.Len() >= backupBatchSize {
+			if err := target.Write(batch); err != nil {
+				return BackupInfo{}, fmt.Errorf("failed to write backup: %w", err)
+			}
+			batch.Reset()
+		}
+	}
+	if err := iter.Error(); err != nil {
+		return BackupInfo{}, fmt.Errorf("iterator error: %w", err)
+	}
+	if err := target.Write(batch); err != nil {
+		return BackupInfo{}, fmt.Errorf("failed to write backup: %w", err)
+	}
+
+	// Compact so the backup is a tidy set of table files
+	if err := target.compact(); err != nil {
+		return BackupInfo{}, fmt.Errorf("failed to compact backup: %w", err)
+	}
+	return info, nil
+}
+
+// LastBackupSeq returns the last change sequence included in a backup of
+// this node
+func (s *LocalStore) LastBackupSeq() (uint64, bool, error) {
+	data, err := s.db.Get(lastBackupKey)
+	if err == ErrNotFound {
+		return 0, false, nil
+	}
+	if err != nil {
+		return 0, false, fmt.Errorf("failed to read backup sequence: %w", err)
+	}
+	if len(data) != 8 {
+		return 0, false, nil
+	}
+	return binary.BigEndian.Uint64(data), true, nil
+}
+
+// SetLastBackupSeq records the last change sequence included in a backup,
+// where the next incremental backup starts
+func (s *LocalStore) SetLastBackupSeq(seq uint64) error {
+	return s.db.Put(lastBackupKey, binary.BigEndian.AppendUint64(nil, seq))
+}
+
+// OpenBackup opens a backup database read-only
+func OpenBackup(dir string) (*LocalStore, error) {
+	if _, err := os.Stat(dir); err != nil {
+		return nil, fmt.Errorf("failed to open backup: %w", err)
+	}
+
+	db, err := openLevelDBWith(dir, &opt.Options{ReadOnly: true, ErrorIfMissing: true})
+	if err != nil {
+		return nil, fmt.Errorf("failed to open backup: %w", err)
+	}
+
+	format, err := readFormat(db)
+	if err != nil {
+		db.Close()
+		return nil, err
+	}
+	if format == 0 {
+		format = FormatVersion // nothing to read either way
+	}
+
+	s := &LocalStore{db: db, format: format}
+	if err := s.loadIndexes(); err != nil {
+		db.Close()
+		return nil, err
+	}
+	if err := s.loadTextIndexes(); err != nil {
+		db.Close()
+		return nil, err
+	}
+	if err := s.loadCounts(); err != nil {
+		db.Close()
+		return nil, err
+	}
+	if err := s.loadFeed(); err != nil {
+		db.Close()
+		return nil, err
+	}
+	return s, nil
+}
+
+// RestoreAll copies every entry of src into a database of engine at dir,
+// which must not contain any data yet. The result is an exact copy of the
+// backed up node.
+func RestoreAll(src *LocalStore, engine, dir string, opts EngineOptions) (int64, error) {
+	target, err := OpenEngine(engine, dir, opts)
+	if err != nil {
+		return 0, err
+	}
+	defer target.Close()
+
+	// A database that was opened once holds nothing but its format version
+	check := target.NewIterator(nil)
+	for check.Next() {
+		if !bytes.Equal(check.Key(), formatVersionKey) {
+			check.Release()
+			return 0, ErrTargetNotEmpty
+		}
+	}
+	check.Release()
+
+	// The copy takes the format of the backup
+	if err := target.Delete(formatVersionKey); err != nil {
+		return 0, fmt.Errorf("failed to write database: %w", err)
+	}
+
+	iter := src.db.NewIterator(nil)
+	defer iter.Release()
+
+	var n int64
+	batch := new(WriteBatch)
+	for iter.Next() {
+		batch.Put(iter.Key(), iter.Value())
+		n++
+		if batch.Len() >= backupBatchSize {
+			if err := target.Write(batch); err != nil {
+				return n, fmt.Errorf("failed to write database: %w", err)
+			}
+			batch.Reset()
+		}
+	}
+	if err := iter.Error(); err != nil {
+		return n, fmt.Errorf("iterator error: %w", err)
+	}
+	if err := target.Write(batch); err != nil {
+		return n, fmt.Errorf("failed to write database: %w", err)
+	}
+	return n, nil
+}
+
+// RestoreCollection replaces the contents of a collection with its
+// contents in src. Writes go through the normal write path, so counts and
+// indexes stay consistent; index definitions from src are created if they
+// do not exist yet. Settings registered in src replace the current ones.
+// Blob chunks are copied as they are. Encrypted values are copied along
+// with the data keys they were sealed with.
+func (s *LocalStore) RestoreCollection(src *LocalStore, collection string) (int64, error) {
+	if src.format != FormatVersion {
+		return 0, fmt.Errorf("%w: backup has format %d, restore it in full and run \"kiwi migrate\"", ErrFormatMismatch, src.format)
+	}
+
+	keys, err := src.readDataKeys()
+	if err != nil {
+		return 0, err
+	}
+	if err := s.ImportDataKeys(keys); err != nil {
+		return 0, err
+	}
+
+	prefix := collectionPrefix(collection)
+	keyStart := len(prefix.Start)
+
+	// Settings first, so restored keys get the TTL of the backup
+	if meta, ok := src.CollectionMeta(collection); ok {
+		if err := s.SetCollectionMeta(meta); err != nil {
+			return 0, err
+		}
+	}
+
+	// Chunks before the blobs referring to them
+	if err := s.copyChunks(src, collection); err != nil {
+		return 0, err
+	}
+
+	// Remove keys that are not in the backup
+	batch := s.NewBatch()
+	iter := s.db.NewIterator(prefix)
+	for iter.Next() {
+		key := string(iter.Key()[keyStart:])
+		if exists, err := src.Has(collection, key); err != nil || exists {
+			continue
+		}
+		batch.Delete(collection, key)
+		if batch.Len() >= backupBatchSize {
+			if err := batch.Commit(); err != nil {
+				iter.Release()
+				return 0, err
+			}
+			batch = s.NewBatch()
+		}
+	}
+	iter.Release()
+	if err := iter.Error(); err != nil {
+		return 0, fmt.Errorf("iterator error: %w", err)
+	}
+	if err := batch.Commit(); err != nil {
+		return 0, err
+	}
+
+	var n int64
+	batch = s.NewBatch()
+	iter = src.db.NewIterator(prefix)
+	defer iter.Release()
+	for iter.Next() {
+		value := append([]byte(nil), iter.Value()...)
+		batch.PutDirect(collection, string(iter.Key()[keyStart:]), value)
+		n++
+		if batch.Len() >= backupBatchSize {
+			if err := batch.Commit(); err != nil {
+				return n, err
+			}
+			batch = s.NewBatch()
+		}
+	}
+	if err := iter.Error(); err != nil {
+		return n, fmt.Errorf("iterator error: %w", err)
+	}
+	if err := batch.Commit(); err != nil {
+		return n, err
+	}
+
+	for _, field := range src.indexedFields(collection) {
+		if err := s.CreateIndex(collection, field); err != nil && !errors.Is(err, ErrIndexExists) {
+			return n, err
+		}
+	}
+	if text := src.textIndex(collection); text != nil {
+		if err := s.CreateTextIndex(collection, text.Fields); err != nil {
+			return n, err
+		}
+	}
+	return n, nil
+}
+
+// Replay applies changes recorded in the changelog of another copy of this
+// database. Changes at or below the current sequence are skipped; the rest
+// must follow on without gaps, so they keep their sequence numbers.
+// Settings and index changes are not in the changelog; ApplyDefinitions
+// brings them over.
+func (s *LocalStore) Replay(events []watch.Event) (int, error) {
+	next := s.feed.LastSeq() + 1
+
+	applied := 0
+	batch := s.NewBatch()
+	commit := func() error {
+		if batch.Len() == 0 {
+			return nil
+		}
+		if err := batch.Commit(); err != nil {
+			return err
+		}
+		applied += batch.Len()
+		next += uint64(batch.Len())
+		batch = s.NewBatch()
+		return nil
+	}
+
+	for _, e := range events {
+		if e.Seq < next {
+			continue
+		}
+		if want := next + uint64(batch.Len()); e.Seq != want {
+			return applied, fmt.Errorf("%w: expected %d, got %d", ErrSequenceGap, want, e.Seq)
+		}
+
+		switch e.Type {
+		case watch.EventPut:
+			value := []byte(e.Value)
+			switch {
+			case e.Blob:
+				// Chunks are not in the changelog; they must already be here
+				var m BlobManifest
+				var err error
+				if value, m, err = decodeBlobView(e.Value); err != nil {
+					return applied, err
+				}
+				if err := s.checkChunks(e.Collection, m); err != nil {
+					return applied, fmt.Errorf("change %d stores a blob: %w; take a full backup after uploading blobs", e.Seq, err)
+				}
+			case e.ContentType != "":
+				var err error
+				if value, err = decodeRawView(e.Value); err != nil {
+					return applied, err
+				}
+			}
+			batch.PutDirect(e.Collection, e.Key, value)
+		case watch.EventDelete:
+			batch.Delete(e.Collection, e.Key)
+		case watch.EventTruncate, watch.EventDrop:
+			// Collection-wide changes take a sequence number of their own
+			if err := commit(); err != nil {
+				return applied, err
+			}
+			var err error
+			if e.Type == watch.EventDrop {
+				_, err = s.DropCollection(e.Collection)
+			} else {
+				_, err = s.TruncateCollection(e.Collection)
+			}
+			if err != nil {
+				return applied, err
+			}
+			applied++
+			next++
+		default:
+			return applied, fmt.Errorf("unknown change type %q at sequence %d", e.Type, e.Seq)
+		}
+	}
+	return applied, commit()
+}
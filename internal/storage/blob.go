@@ -0,0 +1,390 @@
+package storage
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"time"
+)
+
+// blobValueMarker starts every stored blob manifest. Like rawValueMarker,
+// no JSON document starts with it.
+//
+// Layout: \x01 <manifest as JSON>
+const blobValueMarker byte = 0x01
+
+const (
+	// BlobChunkSize is the size of every chunk of a blob part but the last
+	BlobChunkSize = 1 << 20
+	// MaxBlobParts is the highest part number of an upload
+	MaxBlobParts = 10000
+	// blobIDLength is the length of a blob ID in hex characters
+	blobIDLength = 32
+	// copyBlobBatchSize is the number of chunks written per batch when
+	// copying blobs
+	copyBlobBatchSize = 16
+)
+
+var (
+	// ErrNotBlob is returned when reading a blob from a key holding
+	// another kind of value
+	ErrNotBlob = errors.New("key does not hold a blob")
+	// ErrBlobMissing is returned when chunks of a blob are not in the
+	// database
+	ErrBlobMissing = errors.New("blob chunks are missing")
+	// ErrInvalidBlobID is returned for malformed blob IDs
+	ErrInvalidBlobID = errors.New("invalid blob id")
+)
+
+// BlobPart is one part of a blob, stored as consecutive chunks of
+// BlobChunkSize bytes
+type BlobPart struct {
+	Number int    `json:"number"`
+	Size   int64  `json:"size"`
+	Chunks int    `json:"chunks"`
+	SHA256 string `json:"sha256"` // hex digest of the part
+}
+
+// BlobManifest is the value stored under the key of a blob. It lists the
+// parts of the blob in order; the contents live in chunks under the
+// reserved keyspace, so committing the manifest makes a blob visible in a
+// single write.
+type BlobManifest struct {
+	ID          string     `json:"blob"`
+	ContentType string     `json:"content_type"`
+	Size        int64      `json:"size"`
+	ChunkSize   int        `json:"chunk_size"`
+	Parts       []BlobPart `json:"parts"`
+	CreatedAt   time.Time  `json:"created_at"`
+}
+
+// newBlobID returns a random blob ID
+func newBlobID() (string, error) {
+	b := make([]byte, blobIDLength/2)
+	if _, err := rand.Read(b); err != nil {
+		return "", fmt.Errorf("failed to generate blob id: %w", err)
+	}
+	return hex.EncodeToString(b), nil
+}
+
+// copiedBlobID returns the ID of the copy of a blob in another collection.
+// It is derived from the original, so every node copies to the same ID.
+func copiedBlobID(id, collection string) string {
+	sum := sha256.Sum256([]byte(id + "\x00" + collection))
+	return hex.EncodeToString(sum[:])[:blobIDLength]
+}
+
+// validBlobID reports whether id is a well-formed blob ID
+func validBlobID(id string) bool {
+	if len(id) != blobIDLength {
+		return false
+	}
+	_, err := hex.DecodeString(id)
+	return err == nil
+}
+
+// blobCollectionPrefix returns the prefix of every chunk of a collection
+func blobCollectionPrefix(collection string) []byte {
+	return joinKey(blobChunkPrefix, []byte(collection), []byte{0})
+}
+
+// blobPrefix returns the prefix of every chunk of a blob
+func blobPrefix(collection, id string) []byte {
+	return joinKey(blobCollectionPrefix(collection), []byte(id))
+}
+
+// blobPartPrefix returns the prefix of every chunk of a blob part
+func blobPartPrefix(collection, id string, part uint32) []byte {
+	return binary.BigEndian.AppendUint32(blobPrefix(collection, id), part)
+}
+
+// chunkKey returns the key holding one chunk of a blob part
+func chunkKey(collection, id string, part, index uint32) []byte {
+	return binary.BigEndian.AppendUint32(blobPartPrefix(collection, id, part), index)
+}
+
+// encodeBlob serializes a blob manifest for storage
+func encodeBlob(m BlobManifest) ([]byte, error) {
+	data, err := json.Marshal(m)
+	if err != nil {
+		return nil, fmt.Errorf("failed to serialize blob manifest: %w", err)
+	}
+	return append([]byte{blobValueMarker}, data...), nil
+}
+
+// decodeBlob parses a stored blob manifest; ok is false for other values
+func decodeBlob(data []byte) (m BlobManifest, ok bool, err error) {
+	if len(data) == 0 || data[0] != blobValueMarker {
+		return BlobManifest{}, false, nil
+	}
+	if err := json.Unmarshal(data[1:], &m); err != nil {
+		return BlobManifest{}, true, fmt.Errorf("malformed blob manifest: %w", err)
+	}
+	return m, true, nil
+}
+
+// decodeBlobView turns the JSON form of a blob manifest back into its
+// stored encoding
+func decodeBlobView(view []byte) ([]byte, BlobManifest, error) {
+	var m BlobManifest
+	if err := json.Unmarshal(view, &m); err != nil {
+		return nil, m, fmt.Errorf("malformed blob manifest: %w", err)
+	}
+	data, err := encodeBlob(m)
+	return data, m, err
+}
+
+// PutChunk stores one chunk of a blob part (used for replication)
+func (s *LocalStore) PutChunk(collection, blobID string, part, index uint32, data []byte) error {
+	if !validBlobID(blobID) {
+		return ErrInvalidBlobID
+	}
+
+	// Under s.mu, as re-encryption must not put back an older chunk
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err := s.db.Put(chunkKey(collection, blobID, part, index), data); err != nil {
+		return fmt.Errorf("failed to store chunk: %w", err)
+	}
+	return nil
+}
+
+// DiscardChunks removes the chunks of a blob part, or of every part when
+// part is 0 (used for replication)
+func (s *LocalStore) DiscardChunks(collection, blobID string, part uint32) error {
+	if !validBlobID(blobID) {
+		return ErrInvalidBlobID
+	}
+	prefix := blobPrefix(collection, blobID)
+	if part > 0 {
+		prefix = blobPartPrefix(collection, blobID, part)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.deleteRange(bytesPrefix(prefix))
+}
+
+// checkChunks makes sure every chunk of a blob is in the database
+func (s *LocalStore) checkChunks(collection string, m BlobManifest) error {
+	for _, part := range m.Parts {
+		for i := 0; i < part.Chunks; i++ {
+			ok, err := s.db.Has(chunkKey(collection, m.ID, uint32(part.Number), uint32(i)))
+			if err != nil {
+				return fmt.Errorf("failed to read chunk: %w", err)
+			}
+			if !ok {
+				return fmt.Errorf("%w: blob %s part %d chunk %d", ErrBlobMissing, m.ID, part.Number, i)
+			}
+		}
+	}
+	return nil
+}
+
+// discardReplacedBlob deletes in batch the chunks of the blob that op
+// overwrites or deletes, unless op stores that same blob again. The caller
+// must hold s.mu.
+func (s *LocalStore) discardReplacedBlob(batch *WriteBatch, op writeOp, prev []byte) error {
+	old, ok, err := decodeBlob(prev)
+	if !ok || err != nil {
+		return nil
+	}
+	if !op.delete {
+		if m, ok, _ := decodeBlob(op.value); ok && m.ID == old.ID {
+			return nil
+		}
+	}
+
+	iter := s.db.NewIterator(bytesPrefix(blobPrefix(op.collection, old.ID)))
+	defer iter.Release()
+	for iter.Next() {
+		batch.Delete(append([]byte(nil), iter.Key()...))
+	}
+	if err := iter.Error(); err != nil {
+		return fmt.Errorf("iterator error: %w", err)
+	}
+	return nil
+}
+
+// copyBlob copies the chunks of a blob of src, as seen by snap, to a blob
+// of dst and returns the manifest of the copy
+func (s *LocalStore) copyBlob(snap EngineSnapshot, src, dst string, m BlobManifest) ([]byte, error) {
+	prefix := blobPrefix(src, m.ID)
+	m.ID = copiedBlobID(m.ID, dst)
+	target := blobPrefix(dst, m.ID)
+
+	iter := snap.NewIterator(bytesPrefix(prefix))
+	defer iter.Release()
+
+	batch := new(WriteBatch)
+	for iter.Next() {
+		batch.Put(joinKey(target, iter.Key()[len(prefix):]), iter.Value())
+		if batch.Len() >= copyBlobBatchSize {
+			if err := s.db.Write(batch); err != nil {
+				return nil, fmt.Errorf("failed to copy blob: %w", err)
+			}
+			batch.Reset()
+		}
+	}
+	if err := iter.Error(); err != nil {
+		return nil, fmt.Errorf("iterator error: %w", err)
+	}
+	if err := s.db.Write(batch); err != nil {
+		return nil, fmt.Errorf("failed to copy blob: %w", err)
+	}
+	return encodeBlob(m)
+}
+
+// copyChunks copies every chunk of a collection from src, unchanged
+func (s *LocalStore) copyChunks(src *LocalStore, collection string) error {
+	iter := src.db.NewIterator(bytesPrefix(blobCollectionPrefix(collection)))
+	defer iter.Release()
+
+	batch := new(WriteBatch)
+	for iter.Next() {
+		batch.Put(iter.Key(), iter.Value())
+		if batch.Len() >= copyBlobBatchSize {
+			if err := s.db.Write(batch); err != nil {
+				return fmt.Errorf("failed to copy chunks: %w", err)
+			}
+			batch.Reset()
+		}
+	}
+	if err := iter.Error(); err != nil {
+		return fmt.Errorf("iterator error: %w", err)
+	}
+	if err := s.db.Write(batch); err != nil {
+		return fmt.Errorf("failed to copy chunks: %w", err)
+	}
+	return nil
+}
+
+// BlobReader reads the contents of a blob from a consistent snapshot, so
+// a blob overwritten meanwhile is still read in full. It must be closed.
+type BlobReader struct {
+	Manifest BlobManifest
+
+	store      *LocalStore
+	collection string
+	snap       EngineSnapshot
+	starts     []int64 // offset of each part
+
+	offset int64  // next byte to read
+	end    int64  // end of the range being read
+	buf    []byte // unread rest of the current chunk
+}
+
+// OpenBlob opens the blob stored under a key for reading
+func (s *LocalStore) OpenBlob(collection, key string) (*BlobReader, error) {
+	if key == "" {
+		return nil, ErrInvalidKey
+	}
+
+	snap, err := s.db.GetSnapshot()
+	if err != nil {
+		return nil, fmt.Errorf("failed to acquire snapshot: %w", err)
+	}
+
+	data, err := snap.Get(dataKey(collection, key))
+	if err != nil {
+		snap.Release()
+		if err == ErrNotFound {
+			return nil, ErrKeyNotFound
+		}
+		return nil, fmt.Errorf("failed to retrieve value: %w", err)
+	}
+	if data, err = s.decrypt(data); err != nil {
+		snap.Release()
+		return nil, err
+	}
+	m, ok, err := decodeBlob(data)
+	if err == nil && ok && m.ChunkSize <= 0 {
+		err = fmt.Errorf("malformed blob manifest: chunk size %d", m.ChunkSize)
+	}
+	if !ok || err != nil {
+		snap.Release()
+		if err == nil {
+			err = ErrNotBlob
+		}
+		return nil, err
+	}
+
+	r := &BlobReader{Manifest: m, store: s, collection: collection, snap: snap, end: m.Size}
+	var offset int64
+	for _, part := range m.Parts {
+		r.starts = append(r.starts, offset)
+		offset += part.Size
+	}
+	return r, nil
+}
+
+// SetRange limits reading to length bytes from offset. It must be called
+// before the first Read.
+func (r *BlobReader) SetRange(offset, length int64) error {
+	if offset < 0 || length < 0 || offset+length > r.Manifest.Size {
+		return fmt.Errorf("range %d+%d is outside the blob", offset, length)
+	}
+	r.offset, r.end = offset, offset+length
+	return nil
+}
+
+// Read implements io.Reader
+func (r *BlobReader) Read(p []byte) (int, error) {
+	if len(r.buf) == 0 {
+		if r.offset >= r.end {
+			return 0, io.EOF
+		}
+		if err := r.load(); err != nil {
+			return 0, err
+		}
+	}
+
+	n := copy(p, r.buf)
+	r.buf = r.buf[n:]
+	r.offset += int64(n)
+	return n, nil
+}
+
+// load reads the chunk holding the next byte into buf
+func (r *BlobReader) load() error {
+	// Last part starting at or before offset, which skips empty parts
+	i := sort.Search(len(r.starts), func(j int) bool { return r.starts[j] > r.offset }) - 1
+	part := r.Manifest.Parts[i]
+
+	within := r.offset - r.starts[i]
+	chunkSize := int64(r.Manifest.ChunkSize)
+	index := within / chunkSize
+
+	data, err := r.snap.Get(chunkKey(r.collection, r.Manifest.ID, uint32(part.Number), uint32(index)))
+	if err == ErrNotFound {
+		return fmt.Errorf("%w: blob %s part %d chunk %d", ErrBlobMissing, r.Manifest.ID, part.Number, index)
+	}
+	if err == nil {
+		data, err = r.store.decrypt(data)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to read chunk: %w", err)
+	}
+
+	skip := within - index*chunkSize
+	if skip >= int64(len(data)) {
+		return fmt.Errorf("%w: blob %s part %d chunk %d is short", ErrBlobMissing, r.Manifest.ID, part.Number, index)
+	}
+	r.buf = data[skip:]
+	if rest := r.end - r.offset; int64(len(r.buf)) > rest {
+		r.buf = r.buf[:rest]
+	}
+	return nil
+}
+
+// Close releases the snapshot
+func (r *BlobReader) Close() error {
+	r.snap.Release()
+	return nil
+}
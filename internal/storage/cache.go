@@ -0,0 +1,312 @@
+package storage
+
+import (
+	"container/list"
+	"hash/fnv"
+	"strings"
+	"sync"
+)
+
+// Read cache policies of a collection
+const (
+	CacheRead         = "read"          // values are cached when read (default)
+	CacheWriteThrough = "write-through" // values are also cached when written
+	CacheNone         = "none"          // values are never cached
+)
+
+// cacheStripes is the number of epochs keys are spread over, see readCache
+const cacheStripes = 64
+
+// cacheEntryOverhead approximates the memory an entry takes besides its
+// key and value
+const cacheEntryOverhead = 128
+
+// ValidCachePolicy reports whether policy is a known read cache policy. An
+// empty policy means read.
+func ValidCachePolicy(policy string) bool {
+	switch policy {
+	case "", CacheRead, CacheWriteThrough, CacheNone:
+		return true
+	}
+	return false
+}
+
+// CacheStats describes the read cache of a store
+type CacheStats struct {
+	Enabled       bool
+	Capacity      int64 // bytes
+	Size          int64 // bytes
+	Entries       int
+	Hits          uint64
+	Misses        uint64
+	Evictions     uint64 // entries dropped to make room
+	Invalidations uint64 // entries dropped because their key was written
+}
+
+// cacheKey identifies a cached value
+type cacheKey struct {
+	collection string
+	key        string
+}
+
+// cacheEntry is a cached value and the bytes it is accounted for
+type cacheEntry struct {
+	key   cacheKey
+	value interface{}
+	size  int64
+}
+
+// readCache is a size-bounded LRU cache of decoded values. A value read
+// from the engine is only added if no write touched its key meanwhile:
+// every write bumps the epoch of the stripe its key hashes to, and a
+// value is added only if that epoch is still the one seen before the read.
+type readCache struct {
+	mu       sync.Mutex
+	capacity int64
+	size     int64
+	lru      *list.List // front is the most recently used
+	entries  map[cacheKey]*list.Element
+	epochs   [cacheStripes]uint64
+
+	hits, misses, evictions, invalidations uint64
+}
+
+// newReadCache creates a cache holding up to capacity bytes of values
+func newReadCache(capacity int64) *readCache {
+	return &readCache{
+		capacity: capacity,
+		lru:      list.New(),
+		entries:  make(map[cacheKey]*list.Element),
+	}
+}
+
+// stripe returns the epoch stripe of a key
+func stripe(k cacheKey) int {
+	h := fnv.New32a()
+	h.Write([]byte(k.collection))
+	h.Write([]byte{0})
+	h.Write([]byte(k.key))
+	return int(h.Sum32() % cacheStripes)
+}
+
+// get returns a copy of a cached value
+func (c *readCache) get(collection, key string) (interface{}, bool) {
+	c.mu.Lock()
+	e, ok := c.entries[cacheKey{collection, key}]
+	if !ok {
+		c.misses++
+		c.mu.Unlock()
+		return nil, false
+	}
+	c.hits++
+	c.lru.MoveToFront(e)
+	value := e.Value.(*cacheEntry).value
+	c.mu.Unlock()
+	return cloneValue(value), true
+}
+
+// epoch returns the epoch to pass to add for a value about to be read
+func (c *readCache) epoch(collection, key string) uint64 {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.epochs[stripe(cacheKey{collection, key})]
+}
+
+// add caches a value read from the engine, unless a write touched its
+// stripe since epoch was taken. The cache keeps value; it must not be
+// changed afterwards.
+func (c *readCache) add(collection, key string, value interface{}, size int, epoch uint64) {
+	k := cacheKey{collection, key}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.epochs[stripe(k)] != epoch {
+		return
+	}
+	c.store(k, value, size)
+}
+
+// put caches a value that was just written
+func (c *readCache) put(collection, key string, value interface{}, size int) {
+	k := cacheKey{collection, key}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.epochs[stripe(k)]++
+	c.store(k, value, size)
+}
+
+// store adds or replaces an entry and evicts the least recently used ones
+// until the cache fits its capacity
+func (c *readCache) store(k cacheKey, value interface{}, size int) {
+	n := int64(len(k.collection)+len(k.key)+size) + cacheEntryOverhead
+	if n > c.capacity {
+		c.remove(k)
+		return
+	}
+
+	if e, ok := c.entries[k]; ok {
+		entry := e.Value.(*cacheEntry)
+		c.size += n - entry.size
+		entry.value, entry.size = value, n
+		c.lru.MoveToFront(e)
+	} else {
+		// Kept in memory past the request
+		k = cacheKey{strings.Clone(k.collection), strings.Clone(k.key)}
+		c.entries[k] = c.lru.PushFront(&cacheEntry{key: k, value: value, size: n})
+		c.size += n
+	}
+
+	for c.size > c.capacity {
+		oldest := c.lru.Back()
+		c.drop(oldest)
+		c.evictions++
+	}
+}
+
+// remove drops the entry of a key, if cached
+func (c *readCache) remove(k cacheKey) bool {
+	e, ok := c.entries[k]
+	if ok {
+		c.drop(e)
+	}
+	return ok
+}
+
+// drop removes an entry
+func (c *readCache) drop(e *list.Element) {
+	entry := c.lru.Remove(e).(*cacheEntry)
+	delete(c.entries, entry.key)
+	c.size -= entry.size
+}
+
+// invalidate drops the cached value of a key that was written
+func (c *readCache) invalidate(collection, key string) {
+	k := cacheKey{collection, key}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.epochs[stripe(k)]++
+	if c.remove(k) {
+		c.invalidations++
+	}
+}
+
+// invalidateCollection drops every cached value of a collection
+func (c *readCache) invalidateCollection(collection string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for i := range c.epochs {
+		c.epochs[i]++
+	}
+	for e := c.lru.Front(); e != nil; {
+		next := e.Next()
+		if e.Value.(*cacheEntry).key.collection == collection {
+			c.drop(e)
+			c.invalidations++
+		}
+		e = next
+	}
+}
+
+// stats returns the counters of the cache
+func (c *readCache) stats() CacheStats {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return CacheStats{
+		Enabled:       true,
+		Capacity:      c.capacity,
+		Size:          c.size,
+		Entries:       len(c.entries),
+		Hits:          c.hits,
+		Misses:        c.misses,
+		Evictions:     c.evictions,
+		Invalidations: c.invalidations,
+	}
+}
+
+// cloneValue returns a deep copy of a decoded value, so callers can change
+// what they get without changing the cached value
+func cloneValue(value interface{}) interface{} {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		m := make(map[string]interface{}, len(v))
+		for k, e := range v {
+			m[k] = cloneValue(e)
+		}
+		return m
+	case []interface{}:
+		s := make([]interface{}, len(v))
+		for i, e := range v {
+			s[i] = cloneValue(e)
+		}
+		return s
+	case *RawValue:
+		raw := *v
+		raw.Data = append([]byte(nil), v.Data...)
+		return &raw
+	case *BlobManifest:
+		m := *v
+		m.Parts = append([]BlobPart(nil), v.Parts...)
+		return &m
+	}
+	// Strings, numbers, booleans and nil
+	return value
+}
+
+// EnableCache starts caching decoded values read with Get, up to capacity
+// bytes of serialized values. Collections can opt out or cache on writes
+// too with their cache setting.
+func (s *LocalStore) EnableCache(capacity int64) {
+	s.cache = newReadCache(capacity)
+}
+
+// CacheStats returns the counters of the read cache
+func (s *LocalStore) CacheStats() CacheStats {
+	if s.cache == nil {
+		return CacheStats{}
+	}
+	return s.cache.stats()
+}
+
+// cachePolicy returns the read cache policy of a collection, none when the
+// cache is disabled
+func (s *LocalStore) cachePolicy(collection string) string {
+	if s.cache == nil {
+		return CacheNone
+	}
+	meta, _ := s.CollectionMeta(collection)
+	if meta.Cache == "" {
+		return CacheRead
+	}
+	return meta.Cache
+}
+
+// updateCache drops the cached values of committed ops, or replaces them
+// for write-through collections. values holds the plain value of each put.
+func (s *LocalStore) updateCache(ops []writeOp, values [][]byte) {
+	if s.cache == nil {
+		return
+	}
+	for i, op := range ops {
+		if op.delete || s.cachePolicy(op.collection) != CacheWriteThrough {
+			s.cache.invalidate(op.collection, op.key)
+			continue
+		}
+		value, err := decodeValue(values[i])
+		if err != nil {
+			s.cache.invalidate(op.collection, op.key)
+			continue
+		}
+		s.cache.put(op.collection, op.key, value, len(values[i]))
+	}
+}
+
+// invalidateCollection drops the cached values of a collection
+func (s *LocalStore) invalidateCollection(collection string) {
+	if s.cache != nil {
+		s.cache.invalidateCollection(collection)
+	}
+}
+
+// CacheStats returns the counters of the read cache
+func (s *ReplicatedStore) CacheStats() CacheStats {
+	return s.store.CacheStats()
+}
@@ -0,0 +1,361 @@
+package storage
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"kiwi/internal/watch"
+)
+
+var (
+	// ErrChangelogDisabled is returned when the changelog is not enabled
+	ErrChangelogDisabled = errors.New("changelog is not enabled")
+	// ErrOffsetExpired is returned when reading entries that have been
+	// removed by retention
+	ErrOffsetExpired = errors.New("offset is older than the retained changelog")
+	// ErrOffsetAhead is returned when an offset is past the last entry
+	ErrOffsetAhead = errors.New("offset is ahead of the changelog")
+	// ErrConsumerNotFound is returned when a consumer has no stored offset
+	ErrConsumerNotFound = errors.New("consumer not found")
+)
+
+// changelogTrimInterval is how often retention is enforced
+const changelogTrimInterval = time.Minute
+
+// changelogScanLimit caps the entries examined by one read, so filtered
+// reads over a long log return in bounded time
+const changelogScanLimit = 10000
+
+// ChangelogOptions configures changelog retention. Zero values keep
+// entries forever.
+type ChangelogOptions struct {
+	Retention  time.Duration // drop entries older than this
+	MaxEntries int           // keep at most this many entries
+}
+
+// ChangelogPage is the result of a changelog read
+type ChangelogPage struct {
+	Events  []watch.Event
+	NextSeq uint64 // offset to continue reading from
+	LastSeq uint64 // sequence of the newest entry
+}
+
+// ConsumerOffset is the position a named consumer has committed
+type ConsumerOffset struct {
+	Consumer   string    `json:"consumer"`
+	Collection string    `json:"collection"`
+	Offset     uint64    `json:"offset"`
+	UpdatedAt  time.Time `json:"updated_at"`
+}
+
+// changelog holds the state of an enabled changelog
+type changelog struct {
+	opts  ChangelogOptions
+	first atomic.Uint64 // oldest retained sequence
+	stop  chan struct{}
+}
+
+// logKey returns the key of the changelog entry with sequence seq
+func logKey(seq uint64) []byte {
+	key := make([]byte, len(logEntryPrefix)+8)
+	copy(key, logEntryPrefix)
+	binary.BigEndian.PutUint64(key[len(logEntryPrefix):], seq)
+	return key
+}
+
+// consumerKey returns the key holding a consumer's offset for a collection
+func consumerKey(consumer, collection string) []byte {
+	return joinKey(consumerOffsetPrefix, []byte(collection), []byte{0}, []byte(consumer))
+}
+
+// EnableChangelog starts recording every committed change in a durable,
+// ordered log. It must be called before the store accepts writes.
+func (s *LocalStore) EnableChangelog(opts ChangelogOptions) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.changelog != nil {
+		return nil
+	}
+
+	cl := &changelog{opts: opts, stop: make(chan struct{})}
+	cl.first.Store(s.seq + 1)
+
+	// Entries left from an earlier run are only usable if nothing was
+	// written while the changelog was off
+	var first, last uint64
+	iter := s.db.NewIterator(bytesPrefix(logEntryPrefix))
+	if iter.First() {
+		first = binary.BigEndian.Uint64(iter.Key()[len(logEntryPrefix):])
+		iter.Last()
+		last = binary.BigEndian.Uint64(iter.Key()[len(logEntryPrefix):])
+	}
+	iter.Release()
+	if err := iter.Error(); err != nil {
+		return fmt.Errorf("iterator error: %w", err)
+	}
+
+	if first > 0 {
+		if last == s.seq {
+			cl.first.Store(first)
+		} else if err := s.deleteRange(bytesPrefix(logEntryPrefix)); err != nil {
+			return err
+		}
+	}
+
+	s.changelog = cl
+	go s.trimLoop(cl)
+	return nil
+}
+
+// ChangelogEnabled reports whether changes are being recorded
+func (s *LocalStore) ChangelogEnabled() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.changelog != nil
+}
+
+// logEvents adds changelog entries for events to batch. The caller must
+// hold s.mu.
+func (s *LocalStore) logEvents(batch *WriteBatch, events []watch.Event) error {
+	if s.changelog == nil {
+		return nil
+	}
+	for _, e := range events {
+		data, err := json.Marshal(e)
+		if err != nil {
+			return fmt.Errorf("failed to serialize change: %w", err)
+		}
+		if data, err = s.sealLocal(data); err != nil {
+			return err
+		}
+		batch.Put(logKey(e.Seq), data)
+	}
+	return nil
+}
+
+// trimLoop enforces retention until the store is closed
+func (s *LocalStore) trimLoop(cl *changelog) {
+	ticker := time.NewTicker(changelogTrimInterval)
+	defer ticker.Stop()
+
+	for {
+		if err := s.trimChangelog(cl); err != nil {
+			log.Printf("[Changelog] retention failed: %v", err)
+		}
+		select {
+		case <-cl.stop:
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// trimChangelog deletes entries that fall outside the retention limits
+func (s *LocalStore) trimChangelog(cl *changelog) error {
+	last := s.feed.LastSeq()
+	first := cl.first.Load()
+	cutoff := first // entries below cutoff are removed
+
+	if cl.opts.MaxEntries > 0 && last >= uint64(cl.opts.MaxEntries) {
+		if seq := last - uint64(cl.opts.MaxEntries) + 1; seq > cutoff {
+			cutoff = seq
+		}
+	}
+
+	if cl.opts.Retention > 0 {
+		deadline := time.Now().Add(-cl.opts.Retention)
+		iter := s.db.NewIterator(&KeyRange{Start: logKey(cutoff), Limit: logKey(last + 1)})
+		for iter.Next() {
+			e, err := s.readChange(iter.Value())
+			if err != nil || !e.Timestamp.Before(deadline) {
+				break
+			}
+			cutoff = e.Seq + 1
+		}
+		iter.Release()
+		if err := iter.Error(); err != nil {
+			return fmt.Errorf("iterator error: %w", err)
+		}
+	}
+
+	if cutoff <= first {
+		return nil
+	}
+
+	// Move the start first so readers never see a partially trimmed range.
+	// Deleting from the start of the log also removes entries that
+	// re-encryption wrote back while they were being trimmed.
+	cl.first.Store(cutoff)
+	return s.deleteRange(&KeyRange{Start: logEntryPrefix, Limit: logKey(cutoff)})
+}
+
+// ReadChangelog returns up to limit entries after seq that match filter
+func (s *LocalStore) ReadChangelog(seq uint64, filter watch.Filter, limit int) (ChangelogPage, error) {
+	s.mu.Lock()
+	cl := s.changelog
+	s.mu.Unlock()
+	if cl == nil {
+		return ChangelogPage{}, ErrChangelogDisabled
+	}
+
+	last := s.feed.LastSeq()
+	page := ChangelogPage{NextSeq: seq, LastSeq: last}
+	if seq > last {
+		return page, ErrOffsetAhead
+	}
+	if seq+1 < cl.first.Load() {
+		return page, ErrOffsetExpired
+	}
+
+	iter := s.db.NewIterator(&KeyRange{Start: logKey(seq + 1), Limit: logKey(last + 1)})
+	defer iter.Release()
+
+	for scanned := 0; scanned < changelogScanLimit && iter.Next(); scanned++ {
+		e, err := s.readChange(iter.Value())
+		if err != nil {
+			return page, err
+		}
+		page.NextSeq = e.Seq
+
+		if !filter.Match(e) {
+			continue
+		}
+		page.Events = append(page.Events, e)
+		if limit > 0 && len(page.Events) >= limit {
+			break
+		}
+	}
+	if err := iter.Error(); err != nil {
+		return page, fmt.Errorf("iterator error: %w", err)
+	}
+
+	// Retention may have removed entries while they were being read
+	if seq+1 < cl.first.Load() {
+		return page, ErrOffsetExpired
+	}
+	return page, nil
+}
+
+// readChange decodes a changelog entry
+func (s *LocalStore) readChange(data []byte) (watch.Event, error) {
+	var e watch.Event
+	data, err := s.decrypt(data)
+	if err == nil {
+		err = json.Unmarshal(data, &e)
+	}
+	if err != nil {
+		return e, fmt.Errorf("failed to decode change: %w", err)
+	}
+	return e, nil
+}
+
+// FirstChangelogSeq returns the oldest retained sequence
+func (s *LocalStore) FirstChangelogSeq() (uint64, error) {
+	s.mu.Lock()
+	cl := s.changelog
+	s.mu.Unlock()
+	if cl == nil {
+		return 0, ErrChangelogDisabled
+	}
+	return cl.first.Load(), nil
+}
+
+// ConsumerStart returns the offset a consumer resumes from: its committed
+// offset, or the start of the retained changelog for new consumers
+func (s *LocalStore) ConsumerStart(consumer, collection string) (uint64, error) {
+	co, err := s.GetOffset(consumer, collection)
+	if err == nil {
+		return co.Offset, nil
+	}
+	if err != ErrConsumerNotFound {
+		return 0, err
+	}
+
+	first, err := s.FirstChangelogSeq()
+	if err != nil {
+		return 0, err
+	}
+	return first - 1, nil
+}
+
+// CommitOffset stores the offset a consumer has processed up to in a
+// collection; an empty collection means the whole changelog
+func (s *LocalStore) CommitOffset(consumer, collection string, offset uint64) (ConsumerOffset, error) {
+	if consumer == "" || strings.IndexByte(consumer, 0) >= 0 {
+		return ConsumerOffset{}, ErrInvalidKey
+	}
+	if offset > s.feed.LastSeq() {
+		return ConsumerOffset{}, ErrOffsetAhead
+	}
+
+	co := ConsumerOffset{
+		Consumer:   consumer,
+		Collection: collection,
+		Offset:     offset,
+		UpdatedAt:  time.Now().UTC(),
+	}
+	data, err := json.Marshal(co)
+	if err != nil {
+		return ConsumerOffset{}, fmt.Errorf("failed to serialize offset: %w", err)
+	}
+	if err := s.db.Put(consumerKey(consumer, collection), data); err != nil {
+		return ConsumerOffset{}, fmt.Errorf("failed to store offset: %w", err)
+	}
+	return co, nil
+}
+
+// GetOffset returns the committed offset of a consumer
+func (s *LocalStore) GetOffset(consumer, collection string) (ConsumerOffset, error) {
+	data, err := s.db.Get(consumerKey(consumer, collection))
+	if err == ErrNotFound {
+		return ConsumerOffset{}, ErrConsumerNotFound
+	}
+	if err != nil {
+		return ConsumerOffset{}, fmt.Errorf("failed to read offset: %w", err)
+	}
+
+	var co ConsumerOffset
+	if err := json.Unmarshal(data, &co); err != nil {
+		return ConsumerOffset{}, fmt.Errorf("failed to decode offset: %w", err)
+	}
+	return co, nil
+}
+
+// DeleteConsumer removes a consumer's committed offset
+func (s *LocalStore) DeleteConsumer(consumer, collection string) error {
+	key := consumerKey(consumer, collection)
+	exists, err := s.db.Has(key)
+	if err != nil {
+		return fmt.Errorf("failed to read offset: %w", err)
+	}
+	if !exists {
+		return ErrConsumerNotFound
+	}
+	return s.db.Delete(key)
+}
+
+// ListConsumers returns all committed consumer offsets
+func (s *LocalStore) ListConsumers() ([]ConsumerOffset, error) {
+	iter := s.db.NewIterator(bytesPrefix(consumerOffsetPrefix))
+	defer iter.Release()
+
+	consumers := []ConsumerOffset{}
+	for iter.Next() {
+		var co ConsumerOffset
+		if err := json.Unmarshal(iter.Value(), &co); err != nil {
+			continue
+		}
+		consumers = append(consumers, co)
+	}
+	if err := iter.Error(); err != nil {
+		return nil, fmt.Errorf("iterator error: %w", err)
+	}
+	return consumers, nil
+}
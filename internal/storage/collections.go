@@ -0,0 +1,410 @@
+package storage
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"sort"
+	"strings"
+
+	"kiwi/internal/watch"
+)
+
+// copyBatchSize is the number of keys written per batch when copying
+const copyBatchSize = 1000
+
+var (
+	// ErrCollectionNotFound is returned when a collection has no keys and
+	// is not registered
+	ErrCollectionNotFound = errors.New("collection not found")
+	// ErrCollectionExists is returned when the target of a copy or rename
+	// already has keys
+	ErrCollectionExists = errors.New("collection already exists")
+	// ErrInvalidCollection is returned for unusable collection names
+	ErrInvalidCollection = errors.New("invalid collection name")
+)
+
+// CollectionInfo describes a collection
+type CollectionInfo struct {
+	Name        string
+	Keys        int64
+	Size        int64           // approximate bytes on disk, including blob chunks but not indexes
+	Indexes     []string        // indexed field paths
+	TextIndexed []string        // full-text indexed field paths
+	Meta        *CollectionMeta // registered settings, nil if not registered
+}
+
+// ValidateCollection checks that name can be used as a collection name
+func ValidateCollection(name string) error {
+	if name == "" || strings.ContainsRune(name, 0) {
+		return fmt.Errorf("%w: must be non-empty and must not contain NUL", ErrInvalidCollection)
+	}
+	return nil
+}
+
+// collectionPrefix returns the common prefix of all keys of a collection
+func collectionPrefix(collection string) *KeyRange {
+	return bytesPrefix(collectionKeyPrefix(collection))
+}
+
+// DescribeCollection returns the key count, size, indexes and settings of a
+// collection
+func (s *LocalStore) DescribeCollection(collection string) (CollectionInfo, error) {
+	s.countMu.RLock()
+	n, ok := s.counts[collection]
+	s.countMu.RUnlock()
+	meta, registered := s.CollectionMeta(collection)
+	if !ok && !registered {
+		return CollectionInfo{}, ErrCollectionNotFound
+	}
+
+	info := CollectionInfo{Name: collection, Keys: n, Indexes: s.indexedFields(collection)}
+	if registered {
+		info.Meta = &meta
+	}
+	if info.Indexes == nil {
+		info.Indexes = []string{}
+	}
+	if text := s.textIndex(collection); text != nil {
+		info.TextIndexed = text.Fields
+	}
+
+	sizes, err := s.db.SizeOf([]KeyRange{
+		*collectionPrefix(collection),
+		*bytesPrefix(blobCollectionPrefix(collection)),
+	})
+	if err != nil {
+		return CollectionInfo{}, fmt.Errorf("failed to estimate size: %w", err)
+	}
+	info.Size = sizes[0] + sizes[1]
+	return info, nil
+}
+
+// DescribeCollections returns every collection that has keys or is
+// registered, by name
+func (s *LocalStore) DescribeCollections() ([]CollectionInfo, error) {
+	seen := make(map[string]bool)
+	s.countMu.RLock()
+	for name := range s.counts {
+		seen[name] = true
+	}
+	s.countMu.RUnlock()
+	for _, name := range s.registeredCollections() {
+		seen[name] = true
+	}
+
+	names := make([]string, 0, len(seen))
+	for name := range seen {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	infos := make([]CollectionInfo, 0, len(names))
+	for _, name := range names {
+		info, err := s.DescribeCollection(name)
+		if errors.Is(err, ErrCollectionNotFound) {
+			continue // dropped meanwhile
+		}
+		if err != nil {
+			return nil, err
+		}
+		infos = append(infos, info)
+	}
+	return infos, nil
+}
+
+// TruncateCollection removes every key of a collection with range deletes,
+// together with their index entries. Index definitions and settings are
+// kept. Watchers see a single truncate event. Returns the number of keys
+// removed.
+func (s *LocalStore) TruncateCollection(collection string) (int64, error) {
+	return s.clearCollection(collection, false)
+}
+
+// DropCollection removes a collection's keys, its index definitions, its
+// settings and its schema history. Watchers see a single drop event.
+// Returns the number of keys removed.
+func (s *LocalStore) DropCollection(collection string) (int64, error) {
+	return s.clearCollection(collection, true)
+}
+
+// clearKey returns the marker of a collection being truncated or dropped
+func clearKey(collection string) []byte {
+	return joinKey(clearPrefix, []byte(collection))
+}
+
+// renameKey returns the marker of a collection being renamed
+func renameKey(collection string) []byte {
+	return joinKey(renamePrefix, []byte(collection))
+}
+
+// clearCollection implements TruncateCollection and DropCollection. A
+// marker records the clear until its final batch, so a crash part way
+// leaves it to be finished by ResumeCollectionChanges.
+func (s *LocalStore) clearCollection(collection string, drop bool) (int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	typ := watch.EventTruncate
+	if drop {
+		typ = watch.EventDrop
+	}
+	if err := s.db.Put(clearKey(collection), []byte(typ)); err != nil {
+		return 0, fmt.Errorf("failed to clear collection: %w", err)
+	}
+	return s.finishClear(collection, drop)
+}
+
+// finishClear removes the keys of a collection being cleared, and its
+// definitions when dropping, then removes the marker. The caller must
+// hold s.mu.
+func (s *LocalStore) finishClear(collection string, drop bool) (int64, error) {
+	// Even when clearing fails part way, some keys may be gone already
+	defer s.invalidateCollection(collection)
+	defer s.forgetCollection(collection)
+
+	s.countMu.RLock()
+	n := s.counts[collection]
+	s.countMu.RUnlock()
+
+	// Data first, so a crash part way never leaves index entries pointing
+	// at keys that are gone while the keys themselves remain
+	ranges := []*KeyRange{
+		collectionPrefix(collection),
+		bytesPrefix(blobCollectionPrefix(collection)),
+		bytesPrefix(joinKey(indexEntryPrefix, []byte(collection), []byte{0})),
+		bytesPrefix(textCollectionPrefix(collection)),
+	}
+	if drop {
+		ranges = append(ranges, bytesPrefix(joinKey(schemaVersionPrefix, []byte(collection), []byte{0})))
+	}
+	for _, r := range ranges {
+		if err := s.deleteRange(r); err != nil {
+			return 0, err
+		}
+	}
+	if err := s.clearExpiries(collection); err != nil {
+		return 0, err
+	}
+
+	batch := new(WriteBatch)
+	batch.Delete(clearKey(collection))
+	batch.Delete(countKey(collection))
+	batch.Delete(textStatsKey(collection))
+
+	typ := watch.EventTruncate
+	if drop {
+		typ = watch.EventDrop
+		for _, field := range s.indexedFields(collection) {
+			batch.Delete(indexDefKey(collection, field))
+		}
+		batch.Delete(textDefKey(collection))
+		batch.Delete(collectionMetaKey(collection))
+	}
+
+	event, err := s.collectionEvent(batch, typ, collection)
+	if err != nil {
+		return 0, err
+	}
+	if err := s.db.Write(batch); err != nil {
+		return 0, fmt.Errorf("failed to clear collection: %w", err)
+	}
+
+	s.countMu.Lock()
+	delete(s.counts, collection)
+	s.countMu.Unlock()
+
+	s.textMu.Lock()
+	delete(s.textStats, collection)
+	s.textMu.Unlock()
+
+	if drop {
+		s.indexMu.Lock()
+		delete(s.indexes, collection)
+		delete(s.textIndexes, collection)
+		s.indexMu.Unlock()
+
+		s.metaMu.Lock()
+		delete(s.meta, collection)
+		s.metaMu.Unlock()
+	}
+
+	s.seq++
+	s.feed.Publish([]watch.Event{event})
+	return n, nil
+}
+
+// CopyCollection copies every key of src into dst, which must be empty, and
+// creates the same indexes on dst. Settings are not copied. Keys are
+// written through the normal write path, so watchers see a put for each
+// copied key. Blobs are copied with their chunks. Returns the number of
+// keys copied.
+func (s *LocalStore) CopyCollection(src, dst string) (int64, error) {
+	if src == dst {
+		return 0, ErrCollectionExists
+	}
+	count, err := s.Count(dst)
+	if err != nil {
+		return 0, err
+	}
+	if count > 0 {
+		return 0, ErrCollectionExists
+	}
+	return s.copyCollection(src, dst)
+}
+
+// copyCollection copies every key of src into dst, replacing keys dst
+// already has
+func (s *LocalStore) copyCollection(src, dst string) (int64, error) {
+	// Indexes first, so copied keys are indexed as they are written
+	for _, field := range s.indexedFields(src) {
+		if err := s.CreateIndex(dst, field); err != nil {
+			return 0, err
+		}
+	}
+	if text := s.textIndex(src); text != nil {
+		if err := s.CreateTextIndex(dst, text.Fields); err != nil {
+			return 0, err
+		}
+	}
+
+	snap, err := s.db.GetSnapshot()
+	if err != nil {
+		return 0, fmt.Errorf("failed to acquire snapshot: %w", err)
+	}
+	defer snap.Release()
+
+	prefix := collectionKeyPrefix(src)
+	iter := snap.NewIterator(bytesPrefix(prefix))
+	defer iter.Release()
+
+	var n int64
+	batch := s.NewBatch()
+	for iter.Next() {
+		key := string(iter.Key()[len(prefix):])
+		value := append([]byte(nil), iter.Value()...)
+		// Manifests are rewritten for the copied blob, and sealed again
+		// with the key of dst when written
+		plain, err := s.decrypt(value)
+		if err != nil {
+			return n, err
+		}
+		if m, ok, err := decodeBlob(plain); ok && err == nil {
+			if value, err = s.copyBlob(snap, src, dst, m); err != nil {
+				return n, err
+			}
+		}
+		batch.PutDirect(dst, key, value)
+		n++
+		if batch.Len() >= copyBatchSize {
+			if err := batch.Commit(); err != nil {
+				return n, err
+			}
+			batch = s.NewBatch()
+		}
+	}
+	if err := iter.Error(); err != nil {
+		return n, fmt.Errorf("iterator error: %w", err)
+	}
+	if batch.Len() > 0 {
+		if err := batch.Commit(); err != nil {
+			return n, err
+		}
+	}
+	return n, nil
+}
+
+// RenameCollection moves every key of src to dst, which must be empty,
+// along with its indexes. It copies and then drops src, so watchers see a
+// put for each key in dst followed by a drop of src. Returns the number of
+// keys moved. A marker records the rename until src is dropped, so a crash
+// part way leaves it to be finished by ResumeCollectionChanges.
+func (s *LocalStore) RenameCollection(src, dst string) (int64, error) {
+	if src == dst {
+		return 0, ErrCollectionExists
+	}
+	count, err := s.Count(dst)
+	if err != nil {
+		return 0, err
+	}
+	if count > 0 {
+		return 0, ErrCollectionExists
+	}
+	if err := s.db.Put(renameKey(src), []byte(dst)); err != nil {
+		return 0, fmt.Errorf("failed to rename collection: %w", err)
+	}
+	return s.finishRename(src, dst)
+}
+
+// finishRename copies src into dst, drops src and removes the marker.
+// Copying again what a crash left in dst is harmless, as src does not
+// change meanwhile.
+func (s *LocalStore) finishRename(src, dst string) (int64, error) {
+	n, err := s.copyCollection(src, dst)
+	if err != nil {
+		return n, err
+	}
+	if _, err := s.DropCollection(src); err != nil {
+		return n, err
+	}
+	if err := s.db.Delete(renameKey(src)); err != nil {
+		return n, fmt.Errorf("failed to rename collection: %w", err)
+	}
+	return n, nil
+}
+
+// ResumeCollectionChanges finishes the truncates, drops and renames a
+// crash interrupted. They write change events, so it must be called once
+// the changelog is enabled and before the store takes writes.
+func (s *LocalStore) ResumeCollectionChanges() error {
+	clears, err := s.markers(clearPrefix)
+	if err != nil {
+		return err
+	}
+	for _, m := range clears {
+		log.Printf("[Collections] finishing interrupted %s of %s", m.value, m.collection)
+		s.mu.Lock()
+		_, err := s.finishClear(m.collection, m.value == watch.EventDrop)
+		s.mu.Unlock()
+		if err != nil {
+			return fmt.Errorf("failed to finish clearing %s: %w", m.collection, err)
+		}
+	}
+
+	// After the clears, as the last step of a rename is a drop
+	renames, err := s.markers(renamePrefix)
+	if err != nil {
+		return err
+	}
+	for _, m := range renames {
+		log.Printf("[Collections] finishing interrupted rename of %s to %s", m.collection, m.value)
+		if _, err := s.finishRename(m.collection, m.value); err != nil {
+			return fmt.Errorf("failed to finish renaming %s: %w", m.collection, err)
+		}
+	}
+	return nil
+}
+
+// marker is a collection marked as in the middle of a change
+type marker struct {
+	collection string
+	value      string
+}
+
+// markers returns the collections marked under prefix
+func (s *LocalStore) markers(prefix []byte) ([]marker, error) {
+	iter := s.db.NewIterator(bytesPrefix(prefix))
+	defer iter.Release()
+
+	var out []marker
+	for iter.Next() {
+		out = append(out, marker{
+			collection: string(iter.Key()[len(prefix):]),
+			value:      string(iter.Value()),
+		})
+	}
+	if err := iter.Error(); err != nil {
+		return nil, fmt.Errorf("iterator error: %w", err)
+	}
+	return out, nil
+}
@@ -0,0 +1,131 @@
+package storage
+
+import (
+	"bytes"
+	"compress/gzip"
+	"fmt"
+	"io"
+
+	"github.com/golang/snappy"
+	"github.com/klauspost/compress/zstd"
+)
+
+// compressedValueMarker starts every compressed value. The codec tag that
+// follows tells how to restore the value's regular encoding, so values
+// written with different codecs, or before compression was enabled, can be
+// read side by side.
+//
+// Layout: \x02 <codec tag> <compressed JSON, raw value>
+const compressedValueMarker byte = 0x02
+
+// Compression codecs of a collection
+const (
+	CompressionNone   = "none"
+	CompressionSnappy = "snappy"
+	CompressionZstd   = "zstd"
+	CompressionGzip   = "gzip"
+)
+
+// Codec tags stored after compressedValueMarker
+const (
+	snappyTag byte = 1
+	zstdTag   byte = 2
+	gzipTag   byte = 3
+)
+
+// The zstd encoder and decoder are safe for concurrent use and expensive
+// to create; with default options they never fail to
+var (
+	zstdEncoder, _ = zstd.NewWriter(nil)
+	zstdDecoder, _ = zstd.NewReader(nil)
+)
+
+// ValidCompression reports whether codec is a known compression codec. An
+// empty codec means none.
+func ValidCompression(codec string) bool {
+	switch codec {
+	case "", CompressionNone, CompressionSnappy, CompressionZstd, CompressionGzip:
+		return true
+	}
+	return false
+}
+
+// compressValue compresses a serialized value with codec. Blob manifests,
+// and values that do not get smaller, are returned unchanged.
+func compressValue(codec string, data []byte) ([]byte, error) {
+	if len(data) == 0 || data[0] == blobValueMarker {
+		return data, nil
+	}
+
+	var tag byte
+	out := []byte{compressedValueMarker, 0}
+	switch codec {
+	case CompressionSnappy:
+		tag = snappyTag
+		out = append(out, snappy.Encode(nil, data)...)
+	case CompressionZstd:
+		tag = zstdTag
+		out = zstdEncoder.EncodeAll(data, out)
+	case CompressionGzip:
+		tag = gzipTag
+		buf := bytes.NewBuffer(out)
+		w := gzip.NewWriter(buf)
+		if _, err := w.Write(data); err != nil {
+			return nil, fmt.Errorf("failed to compress value: %w", err)
+		}
+		if err := w.Close(); err != nil {
+			return nil, fmt.Errorf("failed to compress value: %w", err)
+		}
+		out = buf.Bytes()
+	default:
+		return data, nil
+	}
+
+	if len(out) >= len(data) {
+		return data, nil
+	}
+	out[1] = tag
+	return out, nil
+}
+
+// decompressValue returns the regular encoding of a stored value. Values
+// that are not compressed are returned as they are.
+func decompressValue(data []byte) ([]byte, error) {
+	if len(data) == 0 || data[0] != compressedValueMarker {
+		return data, nil
+	}
+	if len(data) < 2 {
+		return nil, fmt.Errorf("malformed compressed value")
+	}
+
+	var (
+		out []byte
+		err error
+	)
+	switch payload := data[2:]; data[1] {
+	case snappyTag:
+		out, err = snappy.Decode(nil, payload)
+	case zstdTag:
+		out, err = zstdDecoder.DecodeAll(payload, nil)
+	case gzipTag:
+		var r *gzip.Reader
+		if r, err = gzip.NewReader(bytes.NewReader(payload)); err == nil {
+			out, err = io.ReadAll(r)
+		}
+	default:
+		return nil, fmt.Errorf("unknown compression codec %d", data[1])
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to decompress value: %w", err)
+	}
+	return out, nil
+}
+
+// compress compresses a serialized value with the codec of its collection
+func (s *ReplicatedStore) compress(collection string, data []byte) ([]byte, error) {
+	meta, ok := s.store.CollectionMeta(collection)
+	if !ok {
+		return data, nil
+	}
+	return compressValue(meta.Compression, data)
+}
@@ -0,0 +1,117 @@
+package storage
+
+import (
+	"encoding/binary"
+	"fmt"
+	"strings"
+)
+
+// countKey returns the key holding the number of keys in a collection
+func countKey(collection string) []byte {
+	return joinKey(countPrefix, []byte(collection))
+}
+
+// loadCounts reads per-collection key counts into memory. Databases created
+// before counts were tracked are counted once with a full scan.
+func (s *LocalStore) loadCounts() error {
+	s.counts = make(map[string]int64)
+
+	ready, err := s.db.Has(countsReadyKey)
+	if err != nil {
+		return fmt.Errorf("failed to read count marker: %w", err)
+	}
+	if !ready {
+		return s.rebuildCounts()
+	}
+
+	iter := s.db.NewIterator(bytesPrefix(countPrefix))
+	defer iter.Release()
+
+	for iter.Next() {
+		if len(iter.Value()) != 8 {
+			continue
+		}
+		collection := string(iter.Key()[len(countPrefix):])
+		s.counts[collection] = int64(binary.BigEndian.Uint64(iter.Value()))
+	}
+	if err := iter.Error(); err != nil {
+		return fmt.Errorf("iterator error: %w", err)
+	}
+	return nil
+}
+
+// rebuildCounts counts every collection with a full scan and persists the result
+func (s *LocalStore) rebuildCounts() error {
+	iter := s.db.NewIterator(bytesPrefix([]byte{dataKeyPrefix}))
+	for iter.Next() {
+		if collection, _, ok := parseDataKey(iter.Key()); ok {
+			s.counts[collection]++
+		}
+	}
+	iter.Release()
+	if err := iter.Error(); err != nil {
+		return fmt.Errorf("iterator error: %w", err)
+	}
+
+	batch := new(WriteBatch)
+	for collection, n := range s.counts {
+		batch.Put(countKey(collection), encodeCount(n))
+	}
+	batch.Put(countsReadyKey, []byte{1})
+	if err := s.db.Write(batch); err != nil {
+		return fmt.Errorf("failed to store counts: %w", err)
+	}
+	return nil
+}
+
+// applyCountDeltas adds updated count records to batch and returns the new
+// counts, which are published with storeCounts once the batch is written.
+// The caller must hold s.mu.
+func (s *LocalStore) applyCountDeltas(batch *WriteBatch, deltas map[string]int64) map[string]int64 {
+	s.countMu.RLock()
+	defer s.countMu.RUnlock()
+
+	updated := make(map[string]int64, len(deltas))
+	for collection, delta := range deltas {
+		if delta == 0 {
+			continue
+		}
+		n := s.counts[collection] + delta
+		if n < 0 {
+			n = 0
+		}
+		if n == 0 {
+			batch.Delete(countKey(collection))
+		} else {
+			batch.Put(countKey(collection), encodeCount(n))
+		}
+		updated[collection] = n
+	}
+	return updated
+}
+
+// storeCounts publishes counts computed by applyCountDeltas
+func (s *LocalStore) storeCounts(counts map[string]int64) {
+	if len(counts) == 0 {
+		return
+	}
+
+	s.countMu.Lock()
+	defer s.countMu.Unlock()
+	for collection, n := range counts {
+		if n == 0 {
+			delete(s.counts, collection)
+		} else {
+			// The map outlives the request, while collection may point
+			// into a reused request buffer
+			s.counts[strings.Clone(collection)] = n
+		}
+	}
+}
+
+// encodeCount encodes a key count as 8 big-endian bytes
+func encodeCount(n int64) []byte {
+	out := make([]byte, 8)
+	binary.BigEndian.PutUint64(out, uint64(n))
+	return out
+}
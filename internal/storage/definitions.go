@@ -0,0 +1,137 @@
+package storage
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+)
+
+// CollectionDefinition holds what defines a collection besides its keys:
+// its settings and the fields it is indexed on. Changes to them are not
+// in the changelog, so incremental backups carry the definitions as of
+// when they were taken.
+type CollectionDefinition struct {
+	Collection string          `json:"collection"`
+	Settings   json.RawMessage `json:"settings,omitempty"`   // serialized CollectionMeta
+	Indexes    []string        `json:"indexes,omitempty"`    // indexed fields
+	TextIndex  []string        `json:"text_index,omitempty"` // fields of the text index
+}
+
+// Definitions returns the definition of every collection that has
+// settings or indexes, by name
+func (s *LocalStore) Definitions() ([]CollectionDefinition, error) {
+	defs := make(map[string]*CollectionDefinition)
+	def := func(collection string) *CollectionDefinition {
+		d, ok := defs[collection]
+		if !ok {
+			d = &CollectionDefinition{Collection: collection}
+			defs[collection] = d
+		}
+		return d
+	}
+
+	for _, name := range s.registeredCollections() {
+		meta, ok := s.CollectionMeta(name)
+		if !ok {
+			continue
+		}
+		data, err := json.Marshal(meta)
+		if err != nil {
+			return nil, fmt.Errorf("failed to serialize collection settings: %w", err)
+		}
+		def(name).Settings = data
+	}
+
+	s.indexMu.RLock()
+	for collection, fields := range s.indexes {
+		if len(fields) > 0 {
+			d := def(collection)
+			d.Indexes = append([]string(nil), fields...)
+			sort.Strings(d.Indexes)
+		}
+	}
+	for collection, info := range s.textIndexes {
+		def(collection).TextIndex = append([]string(nil), info.Fields...)
+	}
+	s.indexMu.RUnlock()
+
+	out := make([]CollectionDefinition, 0, len(defs))
+	for _, d := range defs {
+		out = append(out, *d)
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Collection < out[j].Collection })
+	return out, nil
+}
+
+// ApplyDefinitions changes the settings and indexes of this database to
+// match defs, taken from another copy of it such as a backup. Indexes are
+// created and dropped as needed; settings are replaced where they differ.
+// Collections missing from defs lose their indexes.
+func (s *LocalStore) ApplyDefinitions(defs []CollectionDefinition) error {
+	current, err := s.Definitions()
+	if err != nil {
+		return err
+	}
+	want := make(map[string]CollectionDefinition, len(defs))
+	for _, d := range defs {
+		want[d.Collection] = d
+	}
+
+	for _, d := range defs {
+		if len(d.Settings) > 0 {
+			meta, _ := s.CollectionMeta(d.Collection)
+			data, err := json.Marshal(meta)
+			if err != nil {
+				return fmt.Errorf("failed to serialize collection settings: %w", err)
+			}
+			var settings bytes.Buffer
+			if err := json.Compact(&settings, d.Settings); err != nil {
+				return fmt.Errorf("malformed settings of %s: %w", d.Collection, err)
+			}
+			if !bytes.Equal(data, settings.Bytes()) {
+				if err := s.SetCollectionMetaDirect(d.Collection, d.Settings); err != nil {
+					return err
+				}
+			}
+		}
+		for _, field := range d.Indexes {
+			if err := s.CreateIndex(d.Collection, field); err != nil && !errors.Is(err, ErrIndexExists) {
+				return err
+			}
+		}
+		if len(d.TextIndex) > 0 {
+			if err := s.CreateTextIndex(d.Collection, d.TextIndex); err != nil {
+				return err
+			}
+		}
+	}
+
+	for _, cur := range current {
+		d := want[cur.Collection]
+		for _, field := range cur.Indexes {
+			if !containsString(d.Indexes, field) {
+				if err := s.DropIndex(cur.Collection, field); err != nil {
+					return err
+				}
+			}
+		}
+		if len(cur.TextIndex) > 0 && len(d.TextIndex) == 0 {
+			if err := s.DropTextIndex(cur.Collection); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// containsString reports whether list holds s
+func containsString(list []string, s string) bool {
+	for _, e := range list {
+		if e == s {
+			return true
+		}
+	}
+	return false
+}
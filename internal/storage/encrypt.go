@@ -0,0 +1,592 @@
+package storage
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// encryptedValueMarker starts every encrypted value. Values are sealed
+// with AES-GCM under a data key of their collection; the id of that key
+// follows the marker, so values sealed before a rotation stay readable
+// until they are re-encrypted. The header is authenticated along with the
+// value.
+//
+// Layout: \x03 <data key id, 8 bytes> <nonce, 12 bytes> <sealed value>
+const encryptedValueMarker byte = 0x03
+
+// Sizes of the parts of a sealed value
+const (
+	dataKeyIDSize = 8
+	sealedHeader  = 1 + dataKeyIDSize
+	nonceSize     = 12
+	dataKeySize   = 32
+)
+
+var (
+	// ErrEncryptionKeyMissing is returned when encrypted data is read
+	// without a master key
+	ErrEncryptionKeyMissing = errors.New("database holds encrypted data but no encryption key is configured")
+	// ErrWrongMasterKey is returned when data keys were wrapped with another master key
+	ErrWrongMasterKey = errors.New("encryption key does not match the key the data keys were wrapped with")
+	// ErrEncryptionDisabled is returned by key operations when no master key is configured
+	ErrEncryptionDisabled = errors.New("encryption is not enabled")
+	// ErrUnknownDataKey is returned for values sealed with a data key this node does not have
+	ErrUnknownDataKey = errors.New("value is encrypted with an unknown data key")
+)
+
+// MasterKeys are the keys data keys are wrapped with. Previous is only set
+// while the master key is rotated: data keys wrapped with it are rewrapped
+// with Current when encryption is enabled.
+type MasterKeys struct {
+	Current  []byte
+	Previous []byte
+}
+
+// DataKey is a data key as stored, wrapped by the master key. Collection
+// is empty for the key a node seals its change log and dead letters with.
+type DataKey struct {
+	ID         string    `json:"id"`
+	Collection string    `json:"collection"`
+	Version    int       `json:"version"`
+	Master     string    `json:"master"` // fingerprint of the wrapping master key
+	Wrapped    []byte    `json:"wrapped"`
+	CreatedAt  time.Time `json:"created_at"`
+}
+
+// keyring holds the unwrapped data keys of a store
+type keyring struct {
+	master      cipher.AEAD
+	fingerprint string
+
+	// The master key being rotated out, if any
+	previous            cipher.AEAD
+	previousFingerprint string
+
+	mu      sync.RWMutex
+	ciphers map[string]cipher.AEAD // raw key id -> data key
+	records map[string]DataKey     // raw key id -> stored form
+	current map[string]string      // collection -> raw id of its newest key
+}
+
+// dekKey returns the key holding a wrapped data key
+func dekKey(id string) []byte {
+	return joinKey(dekPrefix, []byte(id))
+}
+
+// rawKeyID converts the hex id of a data key to its stored form
+func rawKeyID(id string) (string, error) {
+	raw, err := hex.DecodeString(id)
+	if err != nil || len(raw) != dataKeyIDSize {
+		return "", fmt.Errorf("malformed data key id %q", id)
+	}
+	return string(raw), nil
+}
+
+// newGCM returns AES-GCM keyed with key
+func newGCM(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+// keyFingerprint identifies a master key without revealing it
+func keyFingerprint(key []byte) string {
+	sum := sha256.Sum256(key)
+	return hex.EncodeToString(sum[:8])
+}
+
+// seal encrypts data with a data key; nonce and tag are prepended and
+// appended as gcm does
+func seal(id string, gcm cipher.AEAD, data []byte) ([]byte, error) {
+	out := make([]byte, sealedHeader+nonceSize, sealedHeader+nonceSize+len(data)+gcm.Overhead())
+	out[0] = encryptedValueMarker
+	copy(out[1:], id)
+	if _, err := rand.Read(out[sealedHeader:]); err != nil {
+		return nil, fmt.Errorf("failed to encrypt value: %w", err)
+	}
+	return gcm.Seal(out, out[sealedHeader:], data, out[:sealedHeader]), nil
+}
+
+// sealedWith returns the raw id of the data key a value is sealed with
+func sealedWith(data []byte) (string, bool) {
+	if len(data) < sealedHeader || data[0] != encryptedValueMarker {
+		return "", false
+	}
+	return string(data[1:sealedHeader]), true
+}
+
+// wrap seals a data key with the master key
+func (k *keyring) wrap(id string, key []byte) ([]byte, error) {
+	nonce := make([]byte, nonceSize)
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, fmt.Errorf("failed to wrap data key: %w", err)
+	}
+	return k.master.Seal(nonce, nonce, key, []byte(id)), nil
+}
+
+// unwrap opens a stored data key and returns its raw id and the key. Keys
+// wrapped with the previous master key are returned rewrapped with the
+// current one, to be stored again.
+func (k *keyring) unwrap(rec DataKey) (string, []byte, DataKey, error) {
+	id, err := rawKeyID(rec.ID)
+	if err != nil {
+		return "", nil, rec, err
+	}
+
+	master := k.master
+	if rec.Master != k.fingerprint {
+		if k.previous == nil || rec.Master != k.previousFingerprint {
+			return "", nil, rec, fmt.Errorf("%w: data key %s", ErrWrongMasterKey, rec.ID)
+		}
+		master = k.previous
+	}
+	if len(rec.Wrapped) < nonceSize {
+		return "", nil, rec, fmt.Errorf("malformed data key %s", rec.ID)
+	}
+	key, err := master.Open(nil, rec.Wrapped[:nonceSize], rec.Wrapped[nonceSize:], []byte(id))
+	if err != nil {
+		return "", nil, rec, fmt.Errorf("failed to unwrap data key %s: %w", rec.ID, err)
+	}
+
+	if rec.Master != k.fingerprint {
+		if rec.Wrapped, err = k.wrap(id, key); err != nil {
+			return "", nil, rec, err
+		}
+		rec.Master = k.fingerprint
+	}
+	return id, key, rec, nil
+}
+
+// add makes an unwrapped data key available; the key with the highest
+// version becomes the current key of its collection
+func (k *keyring) add(id string, rec DataKey, key []byte) error {
+	gcm, err := newGCM(key)
+	if err != nil {
+		return fmt.Errorf("invalid data key %s: %w", rec.ID, err)
+	}
+
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	k.ciphers[id] = gcm
+	k.records[id] = rec
+	if cur, ok := k.records[k.current[rec.Collection]]; !ok || rec.Version > cur.Version ||
+		(rec.Version == cur.Version && rec.CreatedAt.After(cur.CreatedAt)) {
+		k.current[rec.Collection] = id
+	}
+	return nil
+}
+
+// lookup returns the data key with a raw id
+func (k *keyring) lookup(id string) (cipher.AEAD, bool) {
+	k.mu.RLock()
+	defer k.mu.RUnlock()
+	gcm, ok := k.ciphers[id]
+	return gcm, ok
+}
+
+// currentKey returns the raw id and the data key new values of a
+// collection are sealed with
+func (k *keyring) currentKey(collection string) (string, cipher.AEAD, bool) {
+	k.mu.RLock()
+	defer k.mu.RUnlock()
+	id, ok := k.current[collection]
+	return id, k.ciphers[id], ok
+}
+
+// generate creates the next data key of a collection, wrapped and ready
+// to be stored
+func (k *keyring) generate(collection string) (string, DataKey, []byte, error) {
+	raw := make([]byte, dataKeyIDSize+dataKeySize)
+	if _, err := rand.Read(raw); err != nil {
+		return "", DataKey{}, nil, fmt.Errorf("failed to generate data key: %w", err)
+	}
+	id, key := string(raw[:dataKeyIDSize]), raw[dataKeyIDSize:]
+
+	version := 1
+	k.mu.RLock()
+	if cur, ok := k.records[k.current[collection]]; ok {
+		version = cur.Version + 1
+	}
+	k.mu.RUnlock()
+
+	wrapped, err := k.wrap(id, key)
+	if err != nil {
+		return "", DataKey{}, nil, err
+	}
+	rec := DataKey{
+		ID:         hex.EncodeToString([]byte(id)),
+		Collection: strings.Clone(collection), // kept in memory past the request
+		Version:    version,
+		Master:     k.fingerprint,
+		Wrapped:    wrapped,
+		CreatedAt:  time.Now().UTC(),
+	}
+	return id, rec, key, nil
+}
+
+// list returns the stored form of every data key by collection and version
+func (k *keyring) list() []DataKey {
+	k.mu.RLock()
+	defer k.mu.RUnlock()
+	keys := make([]DataKey, 0, len(k.records))
+	for _, rec := range k.records {
+		keys = append(keys, rec)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		if keys[i].Collection != keys[j].Collection {
+			return keys[i].Collection < keys[j].Collection
+		}
+		return keys[i].Version < keys[j].Version
+	})
+	return keys
+}
+
+// EnableEncryption unwraps the data keys of the store with the master
+// keys, so encrypted values can be read and new ones written. Without a
+// master key it only checks that the database holds no data keys. Data
+// keys wrapped with the previous master key are rewrapped with the current
+// one. Re-encryption interrupted by a restart is resumed. It must be called
+// before the store is used.
+func (s *LocalStore) EnableEncryption(keys MasterKeys) error {
+	records, err := s.readDataKeys()
+	if err != nil {
+		return err
+	}
+	if keys.Current == nil {
+		if len(records) > 0 {
+			return ErrEncryptionKeyMissing
+		}
+		return nil
+	}
+
+	master, err := newGCM(keys.Current)
+	if err != nil {
+		return fmt.Errorf("invalid encryption key: %w", err)
+	}
+	k := &keyring{
+		master:      master,
+		fingerprint: keyFingerprint(keys.Current),
+		ciphers:     make(map[string]cipher.AEAD),
+		records:     make(map[string]DataKey),
+		current:     make(map[string]string),
+	}
+	if keys.Previous != nil {
+		if k.previous, err = newGCM(keys.Previous); err != nil {
+			return fmt.Errorf("invalid previous encryption key: %w", err)
+		}
+		k.previousFingerprint = keyFingerprint(keys.Previous)
+	}
+
+	batch := new(WriteBatch)
+	for _, rec := range records {
+		id, key, rewrapped, err := k.unwrap(rec)
+		if err != nil {
+			return err
+		}
+		if rewrapped.Master != rec.Master {
+			data, _ := json.Marshal(rewrapped)
+			batch.Put(dekKey(id), data)
+		}
+		if err := k.add(id, rewrapped, key); err != nil {
+			return err
+		}
+	}
+
+	// The node's own key, for the change log and dead letters
+	if _, _, ok := k.currentKey(""); !ok {
+		id, rec, key, err := k.generate("")
+		if err != nil {
+			return err
+		}
+		data, _ := json.Marshal(rec)
+		batch.Put(dekKey(id), data)
+		if err := k.add(id, rec, key); err != nil {
+			return err
+		}
+	}
+
+	if err := s.db.Write(batch); err != nil {
+		return fmt.Errorf("failed to store data keys: %w", err)
+	}
+	s.keys = k
+	return s.resumeRekeys()
+}
+
+// Encrypted reports whether the store encrypts what it writes
+func (s *LocalStore) Encrypted() bool {
+	return s.keys != nil
+}
+
+// readDataKeys reads the stored form of every data key
+func (s *LocalStore) readDataKeys() ([]DataKey, error) {
+	iter := s.db.NewIterator(bytesPrefix(dekPrefix))
+	defer iter.Release()
+
+	var records []DataKey
+	for iter.Next() {
+		var rec DataKey
+		if err := json.Unmarshal(iter.Value(), &rec); err != nil {
+			return nil, fmt.Errorf("malformed data key: %w", err)
+		}
+		records = append(records, rec)
+	}
+	if err := iter.Error(); err != nil {
+		return nil, fmt.Errorf("iterator error: %w", err)
+	}
+	return records, nil
+}
+
+// DataKeys returns the stored form of every data key, wrapped by the
+// master key
+func (s *LocalStore) DataKeys() ([]DataKey, error) {
+	if s.keys != nil {
+		return s.keys.list(), nil
+	}
+	return s.readDataKeys()
+}
+
+// PutDataKeyDirect stores a data key created on the master (used for
+// replication). A key newer than the current one of its collection starts
+// re-encrypting the collection.
+func (s *LocalStore) PutDataKeyDirect(data []byte) error {
+	var rec DataKey
+	if err := json.Unmarshal(data, &rec); err != nil {
+		return fmt.Errorf("malformed data key: %w", err)
+	}
+	return s.putDataKey(rec, true)
+}
+
+// ImportDataKeys stores data keys of another copy of this database, such
+// as a backup, that are not here yet
+func (s *LocalStore) ImportDataKeys(keys []DataKey) error {
+	for _, rec := range keys {
+		if err := s.putDataKey(rec, false); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// putDataKey unwraps and stores a data key unless it is already known
+func (s *LocalStore) putDataKey(rec DataKey, rekey bool) error {
+	if s.keys == nil {
+		return ErrEncryptionKeyMissing
+	}
+	id, err := rawKeyID(rec.ID)
+	if err != nil {
+		return err
+	}
+	if _, ok := s.keys.lookup(id); ok {
+		return nil
+	}
+	_, key, rec, err := s.keys.unwrap(rec)
+	if err != nil {
+		return err
+	}
+	return s.storeDataKey(id, rec, key, rekey)
+}
+
+// storeDataKey stores a wrapped data key and adds it to the keyring. When
+// rekey is set and the key becomes current for a collection, the values of
+// the collection are re-encrypted with it in the background.
+func (s *LocalStore) storeDataKey(id string, rec DataKey, key []byte, rekey bool) error {
+	data, err := json.Marshal(rec)
+	if err != nil {
+		return fmt.Errorf("failed to serialize data key: %w", err)
+	}
+	if err := s.db.Put(dekKey(id), data); err != nil {
+		return fmt.Errorf("failed to store data key: %w", err)
+	}
+	if err := s.keys.add(id, rec, key); err != nil {
+		return err
+	}
+
+	if current, _, _ := s.keys.currentKey(rec.Collection); rekey && current == id {
+		return s.startRekey(rec.Collection)
+	}
+	return nil
+}
+
+// encrypt seals a serialized value with the current data key of its
+// collection. Values are returned unchanged when they are sealed already,
+// or when encryption is disabled or the collection has no key yet.
+func (s *LocalStore) encrypt(collection string, data []byte) ([]byte, error) {
+	if s.keys == nil || len(data) == 0 || data[0] == encryptedValueMarker {
+		return data, nil
+	}
+	id, gcm, ok := s.keys.currentKey(collection)
+	if !ok {
+		return data, nil
+	}
+	return seal(id, gcm, data)
+}
+
+// decrypt returns the serialized form of a stored value, which may still
+// be compressed. Values that are not encrypted are returned as they are.
+func (s *LocalStore) decrypt(data []byte) ([]byte, error) {
+	id, ok := sealedWith(data)
+	if !ok {
+		if len(data) > 0 && data[0] == encryptedValueMarker {
+			return nil, fmt.Errorf("malformed encrypted value")
+		}
+		return data, nil
+	}
+	if s.keys == nil {
+		return nil, ErrEncryptionKeyMissing
+	}
+	gcm, ok := s.keys.lookup(id)
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", ErrUnknownDataKey, hex.EncodeToString([]byte(id)))
+	}
+	if len(data) < sealedHeader+nonceSize {
+		return nil, fmt.Errorf("malformed encrypted value")
+	}
+	out, err := gcm.Open(nil, data[sealedHeader:sealedHeader+nonceSize], data[sealedHeader+nonceSize:], data[:sealedHeader])
+	if err != nil {
+		return nil, fmt.Errorf("failed to decrypt value: %w", err)
+	}
+	return out, nil
+}
+
+// openValue returns the regular encoding of a stored value, decrypted and
+// decompressed
+func (s *LocalStore) openValue(data []byte) ([]byte, error) {
+	data, err := s.decrypt(data)
+	if err != nil {
+		return nil, err
+	}
+	return decompressValue(data)
+}
+
+// readValue deserializes a stored value, see decodeValue
+func (s *LocalStore) readValue(data []byte) (interface{}, error) {
+	data, err := s.decrypt(data)
+	if err != nil {
+		return nil, err
+	}
+	return decodeValue(data)
+}
+
+// sealOp returns the value of a put both as it is to be stored, encrypted
+// if its collection has a data key, and decrypted for everything else the
+// write maintains
+func (s *LocalStore) sealOp(op writeOp) (stored, open []byte, err error) {
+	if _, ok := sealedWith(op.value); ok {
+		open, err = s.decrypt(op.value)
+		return op.value, open, err
+	}
+	stored, err = s.encrypt(op.collection, op.value)
+	return stored, op.value, err
+}
+
+// sealLocal seals data private to this node, such as change log entries,
+// with the node's own data key
+func (s *LocalStore) sealLocal(data []byte) ([]byte, error) {
+	return s.encrypt("", data)
+}
+
+// sealedBlockSize is the most plaintext sealed as one block of a stream
+const sealedBlockSize = 64 << 10
+
+// SealedWriter encrypts a stream in blocks with the node's own data key.
+// Each block is written as its length, a big-endian uint32, followed by
+// the sealed block. It must be closed to write the last block.
+type SealedWriter struct {
+	store *LocalStore
+	w     io.Writer
+	buf   bytes.Buffer
+}
+
+// NewSealedWriter returns a writer encrypting to w, to be read back with
+// NewSealedReader
+func (s *LocalStore) NewSealedWriter(w io.Writer) *SealedWriter {
+	return &SealedWriter{store: s, w: w}
+}
+
+// Write implements io.Writer
+func (w *SealedWriter) Write(p []byte) (int, error) {
+	w.buf.Write(p)
+	for w.buf.Len() >= sealedBlockSize {
+		if err := w.flush(w.buf.Next(sealedBlockSize)); err != nil {
+			return 0, err
+		}
+	}
+	return len(p), nil
+}
+
+// Close writes the last block
+func (w *SealedWriter) Close() error {
+	if w.buf.Len() == 0 {
+		return nil
+	}
+	return w.flush(w.buf.Next(w.buf.Len()))
+}
+
+// flush seals and writes one block
+func (w *SealedWriter) flush(block []byte) error {
+	sealed, err := w.store.sealLocal(block)
+	if err != nil {
+		return err
+	}
+	size := make([]byte, 4)
+	binary.BigEndian.PutUint32(size, uint32(len(sealed)))
+	if _, err := w.w.Write(size); err != nil {
+		return err
+	}
+	_, err = w.w.Write(sealed)
+	return err
+}
+
+// sealedReader decrypts a stream written by a SealedWriter
+type sealedReader struct {
+	store *LocalStore
+	r     io.Reader
+	buf   []byte // unread rest of the current block
+}
+
+// NewSealedReader returns a reader decrypting a stream written by a
+// SealedWriter. The data keys it was sealed with must be in the store.
+func (s *LocalStore) NewSealedReader(r io.Reader) io.Reader {
+	return &sealedReader{store: s, r: r}
+}
+
+// Read implements io.Reader
+func (r *sealedReader) Read(p []byte) (int, error) {
+	for len(r.buf) == 0 {
+		size := make([]byte, 4)
+		if _, err := io.ReadFull(r.r, size); err != nil {
+			if err == io.ErrUnexpectedEOF {
+				err = fmt.Errorf("truncated encrypted stream")
+			}
+			return 0, err
+		}
+		sealed := make([]byte, binary.BigEndian.Uint32(size))
+		if _, err := io.ReadFull(r.r, sealed); err != nil {
+			return 0, fmt.Errorf("truncated encrypted stream")
+		}
+		block, err := r.store.decrypt(sealed)
+		if err != nil {
+			return 0, err
+		}
+		r.buf = block
+	}
+
+	n := copy(p, r.buf)
+	r.buf = r.buf[n:]
+	return n, nil
+}
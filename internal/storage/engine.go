@@ -0,0 +1,231 @@
+package storage
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+)
+
+// Storage engines
+const (
+	EngineLevelDB = "leveldb"
+	EngineMemory  = "memory"
+	EnginePebble  = "pebble"
+)
+
+var (
+	// ErrNotFound is returned by engines when a key does not exist. The
+	// store reports missing keys to its callers as ErrKeyNotFound.
+	ErrNotFound = errors.New("not found")
+
+	// ErrUnknownEngine is returned when opening an engine that is not registered
+	ErrUnknownEngine = errors.New("unknown storage engine")
+)
+
+// Engine is an ordered key-value store the LocalStore keeps all its data
+// in: values, indexes and metadata alike. Keys are compared bytewise.
+type Engine interface {
+	Reader
+
+	// Put stores a value under a key
+	Put(key, value []byte) error
+
+	// Delete removes a key; deleting a missing key is not an error
+	Delete(key []byte) error
+
+	// Write applies a batch atomically
+	Write(batch *WriteBatch) error
+
+	// GetSnapshot returns a consistent read view of the engine
+	GetSnapshot() (EngineSnapshot, error)
+
+	// SizeOf returns the approximate space the entries of each range take
+	SizeOf(ranges []KeyRange) ([]int64, error)
+
+	// Close releases the engine
+	Close() error
+}
+
+// Reader reads from an engine or one of its snapshots
+type Reader interface {
+	// Get returns the value of a key, or ErrNotFound
+	Get(key []byte) ([]byte, error)
+
+	// Has reports whether a key exists
+	Has(key []byte) (bool, error)
+
+	// NewIterator returns an iterator over a key range; nil means every key
+	NewIterator(r *KeyRange) Iterator
+}
+
+// EngineSnapshot is a read view of an engine at one point in time. It must
+// be released when done.
+type EngineSnapshot interface {
+	Reader
+	Release()
+}
+
+// Iterator walks the entries of a key range in key order. It starts before
+// the first entry, so Next moves to it. Key and Value are only valid until
+// the iterator moves.
+type Iterator interface {
+	First() bool
+	Last() bool
+	Seek(key []byte) bool
+	Next() bool
+	Key() []byte
+	Value() []byte
+	Error() error
+	Release()
+}
+
+// KeyRange is the range of keys from Start up to, but not including,
+// Limit. A nil Start or Limit leaves that end open.
+type KeyRange struct {
+	Start []byte
+	Limit []byte
+}
+
+// bytesPrefix returns the range of keys starting with prefix
+func bytesPrefix(prefix []byte) *KeyRange {
+	var limit []byte
+	for i := len(prefix) - 1; i >= 0; i-- {
+		if c := prefix[i]; c < 0xff {
+			limit = make([]byte, i+1)
+			copy(limit, prefix)
+			limit[i] = c + 1
+			break
+		}
+	}
+	return &KeyRange{Start: prefix, Limit: limit}
+}
+
+// WriteBatch collects puts and deletes for an engine to apply atomically,
+// in the order they were added. Keys and values are copied, so buffers can
+// be reused once added.
+type WriteBatch struct {
+	ops []BatchOp
+}
+
+// BatchOp is a single put or delete of a WriteBatch
+type BatchOp struct {
+	Key    []byte
+	Value  []byte
+	Delete bool
+}
+
+// Put adds storing value under key to the batch
+func (b *WriteBatch) Put(key, value []byte) {
+	b.ops = append(b.ops, BatchOp{
+		Key:   append([]byte(nil), key...),
+		Value: append([]byte{}, value...),
+	})
+}
+
+// Delete adds removing key to the batch
+func (b *WriteBatch) Delete(key []byte) {
+	b.ops = append(b.ops, BatchOp{Key: append([]byte(nil), key...), Delete: true})
+}
+
+// Len returns the number of operations in the batch
+func (b *WriteBatch) Len() int {
+	return len(b.ops)
+}
+
+// Reset empties the batch
+func (b *WriteBatch) Reset() {
+	b.ops = b.ops[:0]
+}
+
+// Ops returns the operations of the batch in order
+func (b *WriteBatch) Ops() []BatchOp {
+	return b.ops
+}
+
+// EngineOptions tunes the engines. Each engine reads its own settings;
+// zero values keep the engine's defaults.
+type EngineOptions struct {
+	LevelDB LevelDBOptions
+}
+
+// EngineStats describes the internals of an engine, for engines that
+// report them
+type EngineStats struct {
+	Levels         []LevelStats
+	Files          int   // files in the database directory
+	OpenTables     int   // tables with an open file
+	BlockCacheSize int64 // bytes of blocks in the block cache
+	IORead         int64 // bytes read from disk since the engine was opened
+	IOWrite        int64 // bytes written to disk since the engine was opened
+	WriteDelays    int64 // writes slowed down to let compactions catch up
+	WriteDelayTime time.Duration
+	WritePaused    bool // writes are stopped until compactions catch up
+}
+
+// LevelStats describes one level of a log-structured merge tree. Reads,
+// writes and time are those of the compactions into the level since the
+// engine was opened.
+type LevelStats struct {
+	Level          int
+	Tables         int
+	Size           int64
+	CompactionTime time.Duration
+	Read           int64
+	Write          int64
+}
+
+// statsEngine is an engine that reports its internals
+type statsEngine interface {
+	Stats() (EngineStats, error)
+}
+
+// EngineOpener opens an engine on the data at path
+type EngineOpener func(path string, opts EngineOptions) (Engine, error)
+
+var (
+	enginesMu sync.RWMutex
+	engines   = map[string]EngineOpener{
+		EngineLevelDB: openLevelDB,
+		EngineMemory:  openMemory,
+		EnginePebble:  openPebble,
+	}
+)
+
+// RegisterEngine makes an engine available under name, replacing any
+// engine registered under it before
+func RegisterEngine(name string, open EngineOpener) {
+	enginesMu.Lock()
+	defer enginesMu.Unlock()
+	engines[name] = open
+}
+
+// Engines returns the names of the registered engines
+func Engines() []string {
+	enginesMu.RLock()
+	defer enginesMu.RUnlock()
+
+	names := make([]string, 0, len(engines))
+	for name := range engines {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// OpenEngine opens the engine registered under name on the data at path
+func OpenEngine(name, path string, opts EngineOptions) (Engine, error) {
+	enginesMu.RLock()
+	open, ok := engines[name]
+	enginesMu.RUnlock()
+	if !ok {
+		return nil, fmt.Errorf("%w %q (available: %v)", ErrUnknownEngine, name, Engines())
+	}
+
+	db, err := open(path, opts)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open database: %w", err)
+	}
+	return db, nil
+}
@@ -0,0 +1,212 @@
+package storage
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/syndtr/goleveldb/leveldb"
+	"github.com/syndtr/goleveldb/leveldb/filter"
+	"github.com/syndtr/goleveldb/leveldb/opt"
+	"github.com/syndtr/goleveldb/leveldb/util"
+)
+
+// Table compressions of the LevelDB engine
+const (
+	LevelDBCompressionSnappy = "snappy"
+	LevelDBCompressionNone   = "none"
+)
+
+// LevelDBOptions tunes the LevelDB engine. Zero values keep LevelDB's
+// defaults.
+type LevelDBOptions struct {
+	BlockCacheSize  int    // bytes of table blocks cached in memory
+	WriteBuffer     int    // bytes buffered in the memtable before it is written to a table
+	BloomFilterBits int    // bits per key of the bloom filter of tables, 0 = no filter
+	Compression     string // compression of table blocks, snappy or none
+	OpenFiles       int    // tables kept open at once
+}
+
+// options converts the tuning to LevelDB options
+func (o LevelDBOptions) options() (*opt.Options, error) {
+	if o.BlockCacheSize < 0 || o.WriteBuffer < 0 || o.BloomFilterBits < 0 || o.OpenFiles < 0 {
+		return nil, fmt.Errorf("LevelDB options must not be negative")
+	}
+
+	opts := &opt.Options{
+		BlockCacheCapacity:     o.BlockCacheSize,
+		WriteBuffer:            o.WriteBuffer,
+		OpenFilesCacheCapacity: o.OpenFiles,
+	}
+	if o.BloomFilterBits > 0 {
+		opts.Filter = filter.NewBloomFilter(o.BloomFilterBits)
+	}
+	switch o.Compression {
+	case "", LevelDBCompressionSnappy:
+		opts.Compression = opt.SnappyCompression
+	case LevelDBCompressionNone:
+		opts.Compression = opt.NoCompression
+	default:
+		return nil, fmt.Errorf("unknown LevelDB compression %q (available: %s, %s)",
+			o.Compression, LevelDBCompressionSnappy, LevelDBCompressionNone)
+	}
+	return opts, nil
+}
+
+// levelDB is the LevelDB engine, the default
+type levelDB struct {
+	db   *leveldb.DB
+	path string
+}
+
+// levelDBSnapshot is a LevelDB snapshot
+type levelDBSnapshot struct {
+	snap *leveldb.Snapshot
+}
+
+// openLevelDB opens or creates a LevelDB database at path
+func openLevelDB(path string, opts EngineOptions) (Engine, error) {
+	o, err := opts.LevelDB.options()
+	if err != nil {
+		return nil, err
+	}
+	return openLevelDBWith(path, o)
+}
+
+// openLevelDBWith opens a LevelDB database at path with options
+func openLevelDBWith(path string, o *opt.Options) (*levelDB, error) {
+	db, err := leveldb.OpenFile(path, o)
+	if err != nil {
+		return nil, err
+	}
+	return &levelDB{db: db, path: path}, nil
+}
+
+// levelDBRange converts a key range to its LevelDB form
+func levelDBRange(r *KeyRange) *util.Range {
+	if r == nil {
+		return nil
+	}
+	return &util.Range{Start: r.Start, Limit: r.Limit}
+}
+
+// levelDBError maps LevelDB's missing key error to ErrNotFound
+func levelDBError(err error) error {
+	if err == leveldb.ErrNotFound {
+		return ErrNotFound
+	}
+	return err
+}
+
+func (e *levelDB) Get(key []byte) ([]byte, error) {
+	value, err := e.db.Get(key, nil)
+	return value, levelDBError(err)
+}
+
+func (e *levelDB) Has(key []byte) (bool, error) {
+	return e.db.Has(key, nil)
+}
+
+func (e *levelDB) NewIterator(r *KeyRange) Iterator {
+	return e.db.NewIterator(levelDBRange(r), nil)
+}
+
+func (e *levelDB) Put(key, value []byte) error {
+	return e.db.Put(key, value, nil)
+}
+
+func (e *levelDB) Delete(key []byte) error {
+	return e.db.Delete(key, nil)
+}
+
+func (e *levelDB) Write(batch *WriteBatch) error {
+	b := new(leveldb.Batch)
+	for _, op := range batch.Ops() {
+		if op.Delete {
+			b.Delete(op.Key)
+		} else {
+			b.Put(op.Key, op.Value)
+		}
+	}
+	return e.db.Write(b, nil)
+}
+
+func (e *levelDB) GetSnapshot() (EngineSnapshot, error) {
+	snap, err := e.db.GetSnapshot()
+	if err != nil {
+		return nil, err
+	}
+	return &levelDBSnapshot{snap: snap}, nil
+}
+
+func (e *levelDB) SizeOf(ranges []KeyRange) ([]int64, error) {
+	rs := make([]util.Range, len(ranges))
+	for i, r := range ranges {
+		rs[i] = util.Range{Start: r.Start, Limit: r.Limit}
+	}
+	sizes, err := e.db.SizeOf(rs)
+	if err != nil {
+		return nil, err
+	}
+	return sizes, nil
+}
+
+func (e *levelDB) Stats() (EngineStats, error) {
+	var st leveldb.DBStats
+	if err := e.db.Stats(&st); err != nil {
+		return EngineStats{}, err
+	}
+
+	files, err := os.ReadDir(e.path)
+	if err != nil {
+		return EngineStats{}, fmt.Errorf("failed to list database files: %w", err)
+	}
+
+	stats := EngineStats{
+		Levels:         make([]LevelStats, len(st.LevelSizes)),
+		Files:          len(files),
+		OpenTables:     st.OpenedTablesCount,
+		BlockCacheSize: int64(st.BlockCacheSize),
+		IORead:         int64(st.IORead),
+		IOWrite:        int64(st.IOWrite),
+		WriteDelays:    int64(st.WriteDelayCount),
+		WriteDelayTime: st.WriteDelayDuration,
+		WritePaused:    st.WritePaused,
+	}
+	for i := range stats.Levels {
+		stats.Levels[i] = LevelStats{
+			Level:          i,
+			Tables:         st.LevelTablesCounts[i],
+			Size:           st.LevelSizes[i],
+			CompactionTime: st.LevelDurations[i],
+			Read:           st.LevelRead[i],
+			Write:          st.LevelWrite[i],
+		}
+	}
+	return stats, nil
+}
+
+// compact compacts the whole database
+func (e *levelDB) compact() error {
+	return e.db.CompactRange(util.Range{})
+}
+
+func (e *levelDB) Close() error {
+	return e.db.Close()
+}
+
+func (s *levelDBSnapshot) Get(key []byte) ([]byte, error) {
+	value, err := s.snap.Get(key, nil)
+	return value, levelDBError(err)
+}
+
+func (s *levelDBSnapshot) Has(key []byte) (bool, error) {
+	return s.snap.Has(key, nil)
+}
+
+func (s *levelDBSnapshot) NewIterator(r *KeyRange) Iterator {
+	return s.snap.NewIterator(levelDBRange(r), nil)
+}
+
+func (s *levelDBSnapshot) Release() {
+	s.snap.Release()
+}
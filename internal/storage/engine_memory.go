@@ -0,0 +1,274 @@
+package storage
+
+import (
+	"bytes"
+	"errors"
+	"sync"
+
+	"github.com/google/btree"
+)
+
+// memoryIteratorBatch is the number of entries a memory iterator reads
+// ahead when moving forward
+const memoryIteratorBatch = 64
+
+// errEngineClosed is returned by a memory engine after it was closed
+var errEngineClosed = errors.New("engine is closed")
+
+// memEntry is a key and its value in a memory engine
+type memEntry struct {
+	key   []byte
+	value []byte
+}
+
+func memLess(a, b memEntry) bool {
+	return bytes.Compare(a.key, b.key) < 0
+}
+
+// memoryDB is an engine that keeps everything in memory, for tests and
+// caches; nothing survives a restart. Entries are held in a copy-on-write
+// B-tree, so snapshots and iterators get a private view of the tree
+// without copying it.
+type memoryDB struct {
+	mu     sync.RWMutex
+	tree   *btree.BTreeG[memEntry]
+	size   int64 // bytes of keys and values
+	closed bool
+}
+
+// memorySnapshot is a read view of a memory engine
+type memorySnapshot struct {
+	tree *btree.BTreeG[memEntry]
+}
+
+// openMemory creates an empty memory engine; path and options are not used
+func openMemory(string, EngineOptions) (Engine, error) {
+	return newMemoryDB(), nil
+}
+
+// newMemoryDB creates an empty memory engine
+func newMemoryDB() *memoryDB {
+	return &memoryDB{tree: btree.NewG(32, memLess)}
+}
+
+// clone returns a view of the tree that later writes do not change
+func (e *memoryDB) clone() *btree.BTreeG[memEntry] {
+	// Cloning marks the nodes of the tree as shared, so it writes too
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	return e.tree.Clone()
+}
+
+func (e *memoryDB) Get(key []byte) ([]byte, error) {
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+	return memGet(e.tree, key)
+}
+
+func (e *memoryDB) Has(key []byte) (bool, error) {
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+	return e.tree.Has(memEntry{key: key}), nil
+}
+
+func (e *memoryDB) NewIterator(r *KeyRange) Iterator {
+	return newMemIterator(e.clone(), r)
+}
+
+func (e *memoryDB) Put(key, value []byte) error {
+	batch := new(WriteBatch)
+	batch.Put(key, value)
+	return e.Write(batch)
+}
+
+func (e *memoryDB) Delete(key []byte) error {
+	batch := new(WriteBatch)
+	batch.Delete(key)
+	return e.Write(batch)
+}
+
+func (e *memoryDB) Write(batch *WriteBatch) error {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if e.closed {
+		return errEngineClosed
+	}
+
+	// Batches copy their keys and values, so they can be kept as they are
+	for _, op := range batch.Ops() {
+		if op.Delete {
+			if old, ok := e.tree.Delete(memEntry{key: op.Key}); ok {
+				e.size -= int64(len(old.key) + len(old.value))
+			}
+			continue
+		}
+		if old, ok := e.tree.ReplaceOrInsert(memEntry{key: op.Key, value: op.Value}); ok {
+			e.size -= int64(len(old.key) + len(old.value))
+		}
+		e.size += int64(len(op.Key) + len(op.Value))
+	}
+	return nil
+}
+
+// Size returns the bytes of keys and values the engine holds
+func (e *memoryDB) Size() int64 {
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+	return e.size
+}
+
+func (e *memoryDB) GetSnapshot() (EngineSnapshot, error) {
+	return &memorySnapshot{tree: e.clone()}, nil
+}
+
+func (e *memoryDB) SizeOf(ranges []KeyRange) ([]int64, error) {
+	tree := e.clone()
+	sizes := make([]int64, len(ranges))
+	for i, r := range ranges {
+		iter := newMemIterator(tree, &r)
+		for iter.Next() {
+			sizes[i] += int64(len(iter.Key()) + len(iter.Value()))
+		}
+	}
+	return sizes, nil
+}
+
+func (e *memoryDB) Close() error {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.closed = true
+	e.tree = btree.NewG(32, memLess)
+	e.size = 0
+	return nil
+}
+
+func (s *memorySnapshot) Get(key []byte) ([]byte, error) {
+	return memGet(s.tree, key)
+}
+
+func (s *memorySnapshot) Has(key []byte) (bool, error) {
+	return s.tree.Has(memEntry{key: key}), nil
+}
+
+func (s *memorySnapshot) NewIterator(r *KeyRange) Iterator {
+	return newMemIterator(s.tree, r)
+}
+
+func (s *memorySnapshot) Release() {}
+
+// memGet returns a copy of the value of a key
+func memGet(tree *btree.BTreeG[memEntry], key []byte) ([]byte, error) {
+	entry, ok := tree.Get(memEntry{key: key})
+	if !ok {
+		return nil, ErrNotFound
+	}
+	return append([]byte{}, entry.value...), nil
+}
+
+// memIterator iterates over a view of a memory engine that does not change
+type memIterator struct {
+	tree *btree.BTreeG[memEntry]
+	r    KeyRange
+
+	started bool
+	buf     []memEntry // buf[0] is the current entry
+}
+
+func newMemIterator(tree *btree.BTreeG[memEntry], r *KeyRange) *memIterator {
+	it := &memIterator{tree: tree}
+	if r != nil {
+		it.r = *r
+	}
+	return it
+}
+
+// belowLimit reports whether key is below the limit of the range
+func (it *memIterator) belowLimit(key []byte) bool {
+	return it.r.Limit == nil || bytes.Compare(key, it.r.Limit) < 0
+}
+
+// fill reads entries from pivot on, skipping pivot itself unless inclusive
+func (it *memIterator) fill(pivot []byte, inclusive bool) bool {
+	it.started = true
+	it.buf = it.buf[:0]
+	it.tree.AscendGreaterOrEqual(memEntry{key: pivot}, func(e memEntry) bool {
+		if !inclusive && bytes.Equal(e.key, pivot) {
+			return true
+		}
+		if !it.belowLimit(e.key) {
+			return false
+		}
+		it.buf = append(it.buf, e)
+		return len(it.buf) < memoryIteratorBatch
+	})
+	return len(it.buf) > 0
+}
+
+func (it *memIterator) First() bool {
+	return it.fill(it.r.Start, true)
+}
+
+func (it *memIterator) Last() bool {
+	it.started = true
+	it.buf = it.buf[:0]
+	visit := func(e memEntry) bool {
+		if !it.belowLimit(e.key) {
+			return true
+		}
+		if bytes.Compare(e.key, it.r.Start) >= 0 {
+			it.buf = append(it.buf, e)
+		}
+		return false
+	}
+	if it.r.Limit == nil {
+		it.tree.Descend(visit)
+	} else {
+		it.tree.DescendLessOrEqual(memEntry{key: it.r.Limit}, visit)
+	}
+	return len(it.buf) > 0
+}
+
+func (it *memIterator) Seek(key []byte) bool {
+	if bytes.Compare(key, it.r.Start) < 0 {
+		key = it.r.Start
+	}
+	return it.fill(key, true)
+}
+
+func (it *memIterator) Next() bool {
+	switch {
+	case !it.started:
+		return it.First()
+	case len(it.buf) == 0:
+		return false
+	case len(it.buf) > 1:
+		it.buf = it.buf[1:]
+		return true
+	}
+	return it.fill(it.buf[0].key, false)
+}
+
+func (it *memIterator) Key() []byte {
+	if len(it.buf) == 0 {
+		return nil
+	}
+	k := it.buf[0].key
+	return k[:len(k):len(k)]
+}
+
+func (it *memIterator) Value() []byte {
+	if len(it.buf) == 0 {
+		return nil
+	}
+	v := it.buf[0].value
+	return v[:len(v):len(v)]
+}
+
+func (it *memIterator) Error() error {
+	return nil
+}
+
+func (it *memIterator) Release() {
+	it.buf = nil
+	it.started = true
+}
@@ -0,0 +1,223 @@
+package storage
+
+import (
+	"errors"
+	"io"
+
+	"github.com/cockroachdb/pebble"
+)
+
+// pebbleDB is the Pebble engine
+type pebbleDB struct {
+	db *pebble.DB
+}
+
+// pebbleSnapshot is a Pebble snapshot
+type pebbleSnapshot struct {
+	snap *pebble.Snapshot
+}
+
+// pebbleReader is what Pebble databases and snapshots read with
+type pebbleReader interface {
+	Get(key []byte) ([]byte, io.Closer, error)
+	NewIter(o *pebble.IterOptions) (*pebble.Iterator, error)
+}
+
+// openPebble opens or creates a Pebble database at path. Pebble has no
+// options yet.
+func openPebble(path string, _ EngineOptions) (Engine, error) {
+	db, err := pebble.Open(path, &pebble.Options{})
+	if err != nil {
+		return nil, err
+	}
+	return &pebbleDB{db: db}, nil
+}
+
+// pebbleGet returns a copy of the value of a key; Pebble's own is only
+// valid until its closer is called
+func pebbleGet(r pebbleReader, key []byte) ([]byte, error) {
+	value, closer, err := r.Get(key)
+	if errors.Is(err, pebble.ErrNotFound) {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer closer.Close()
+	return append([]byte{}, value...), nil
+}
+
+// pebbleHas reports whether a key exists
+func pebbleHas(r pebbleReader, key []byte) (bool, error) {
+	_, closer, err := r.Get(key)
+	if errors.Is(err, pebble.ErrNotFound) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	closer.Close()
+	return true, nil
+}
+
+// pebbleIterator adapts a Pebble iterator to the positioning of Iterator
+func pebbleIterator(r pebbleReader, kr *KeyRange) Iterator {
+	opts := &pebble.IterOptions{}
+	if kr != nil {
+		opts.LowerBound, opts.UpperBound = kr.Start, kr.Limit
+	}
+	iter, err := r.NewIter(opts)
+	return &pebbleIter{iter: iter, err: err}
+}
+
+func (e *pebbleDB) Get(key []byte) ([]byte, error) {
+	return pebbleGet(e.db, key)
+}
+
+func (e *pebbleDB) Has(key []byte) (bool, error) {
+	return pebbleHas(e.db, key)
+}
+
+func (e *pebbleDB) NewIterator(r *KeyRange) Iterator {
+	return pebbleIterator(e.db, r)
+}
+
+// Writes are not synced, as with LevelDB's defaults
+
+func (e *pebbleDB) Put(key, value []byte) error {
+	return e.db.Set(key, value, pebble.NoSync)
+}
+
+func (e *pebbleDB) Delete(key []byte) error {
+	return e.db.Delete(key, pebble.NoSync)
+}
+
+func (e *pebbleDB) Write(batch *WriteBatch) error {
+	b := e.db.NewBatch()
+	defer b.Close()
+	for _, op := range batch.Ops() {
+		var err error
+		if op.Delete {
+			err = b.Delete(op.Key, nil)
+		} else {
+			err = b.Set(op.Key, op.Value, nil)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return b.Commit(pebble.NoSync)
+}
+
+func (e *pebbleDB) GetSnapshot() (EngineSnapshot, error) {
+	return &pebbleSnapshot{snap: e.db.NewSnapshot()}, nil
+}
+
+func (e *pebbleDB) SizeOf(ranges []KeyRange) ([]int64, error) {
+	sizes := make([]int64, len(ranges))
+	for i, r := range ranges {
+		limit := r.Limit
+		if limit == nil {
+			// Every key of the store sorts below this
+			limit = []byte{0xff}
+		}
+		size, err := e.db.EstimateDiskUsage(r.Start, limit)
+		if err != nil {
+			return nil, err
+		}
+		sizes[i] = int64(size)
+	}
+	return sizes, nil
+}
+
+func (e *pebbleDB) Close() error {
+	return e.db.Close()
+}
+
+func (s *pebbleSnapshot) Get(key []byte) ([]byte, error) {
+	return pebbleGet(s.snap, key)
+}
+
+func (s *pebbleSnapshot) Has(key []byte) (bool, error) {
+	return pebbleHas(s.snap, key)
+}
+
+func (s *pebbleSnapshot) NewIterator(r *KeyRange) Iterator {
+	return pebbleIterator(s.snap, r)
+}
+
+func (s *pebbleSnapshot) Release() {
+	s.snap.Close()
+}
+
+// pebbleIter is a Pebble iterator that, like a LevelDB one, starts before
+// the first entry
+type pebbleIter struct {
+	iter    *pebble.Iterator
+	err     error
+	started bool
+}
+
+func (it *pebbleIter) First() bool {
+	if it.iter == nil {
+		return false
+	}
+	it.started = true
+	return it.iter.First()
+}
+
+func (it *pebbleIter) Last() bool {
+	if it.iter == nil {
+		return false
+	}
+	it.started = true
+	return it.iter.Last()
+}
+
+func (it *pebbleIter) Seek(key []byte) bool {
+	if it.iter == nil {
+		return false
+	}
+	it.started = true
+	return it.iter.SeekGE(key)
+}
+
+func (it *pebbleIter) Next() bool {
+	if it.iter == nil {
+		return false
+	}
+	if !it.started {
+		return it.First()
+	}
+	return it.iter.Valid() && it.iter.Next()
+}
+
+func (it *pebbleIter) Key() []byte {
+	if it.iter == nil || !it.iter.Valid() {
+		return nil
+	}
+	return it.iter.Key()
+}
+
+func (it *pebbleIter) Value() []byte {
+	if it.iter == nil || !it.iter.Valid() {
+		return nil
+	}
+	return it.iter.Value()
+}
+
+func (it *pebbleIter) Error() error {
+	if it.err != nil || it.iter == nil {
+		return it.err
+	}
+	return it.iter.Error()
+}
+
+func (it *pebbleIter) Release() {
+	if it.iter != nil {
+		if err := it.iter.Close(); err != nil && it.err == nil {
+			it.err = err
+		}
+		it.iter = nil
+	}
+}
@@ -0,0 +1,428 @@
+package storage
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"math"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"kiwi/internal/replication"
+)
+
+// Eviction policies of a node with a memory limit
+const (
+	EvictLRU = "lru" // least recently used keys first
+	EvictLFU = "lfu" // least frequently used keys first
+	EvictTTL = "ttl" // keys closest to expiring first, then as lru
+)
+
+// Where evictions apply
+const (
+	EvictReplicated = "replicated" // the master evicts and replicates the deletes
+	EvictLocal      = "local"      // every node evicts on its own
+)
+
+const (
+	// evictBatchSize is the number of keys deleted per eviction round
+	evictBatchSize = 100
+
+	// evictSamples is the number of tracked keys looked at per victim;
+	// more samples pick victims closer to the exact policy
+	evictSamples = 5
+
+	// evictInterval is how often the limit is checked besides after writes
+	evictInterval = time.Second
+)
+
+// ErrEvictionUnsupported is returned when enabling eviction on an engine
+// that does not know its size
+var ErrEvictionUnsupported = errors.New("a memory limit needs the memory engine")
+
+// EvictionOptions configures keeping a store under a memory limit
+type EvictionOptions struct {
+	MaxMemory int64  // bytes of keys and values, metadata included
+	Policy    string // lru, lfu or ttl
+	Scope     string // replicated or local
+}
+
+// EvictionStats describes the memory limit of a store
+type EvictionStats struct {
+	Enabled   bool
+	MaxMemory int64
+	Used      int64
+	Policy    string
+	Scope     string
+	Tracked   int    // keys eviction can pick from
+	Evicted   uint64 // keys evicted by this node
+}
+
+// sizedEngine is an engine that knows how many bytes it holds
+type sizedEngine interface {
+	Size() int64
+}
+
+// keyAccess is when and how often a key was used
+type keyAccess struct {
+	last uint64 // tick of the last use
+	hits uint32
+}
+
+// evictor tracks the use of every key and picks the ones to evict. Only
+// single-key reads and writes count as a use.
+type evictor struct {
+	opts EvictionOptions
+	db   sizedEngine
+
+	mu    sync.Mutex
+	keys  map[cacheKey]*keyAccess
+	tick  uint64
+	count uint64 // keys evicted
+
+	wake    chan struct{}
+	stop    chan struct{}
+	stopped chan struct{}
+}
+
+// EnableEviction keeps the store under a memory limit by deleting keys as
+// the policy picks them. Eviction runs once the ReplicatedStore starts it.
+func (s *LocalStore) EnableEviction(opts EvictionOptions) error {
+	db, ok := s.db.(sizedEngine)
+	if !ok {
+		return ErrEvictionUnsupported
+	}
+	switch opts.Policy {
+	case EvictLRU, EvictLFU, EvictTTL:
+	default:
+		return fmt.Errorf("unknown eviction policy %q (available: %s, %s, %s)", opts.Policy, EvictLRU, EvictLFU, EvictTTL)
+	}
+	switch opts.Scope {
+	case EvictReplicated, EvictLocal:
+	default:
+		return fmt.Errorf("unknown eviction scope %q (available: %s, %s)", opts.Scope, EvictReplicated, EvictLocal)
+	}
+	if opts.MaxMemory <= 0 {
+		return fmt.Errorf("memory limit must be positive")
+	}
+
+	s.evict = &evictor{
+		opts: opts,
+		db:   db,
+		keys: make(map[cacheKey]*keyAccess),
+		wake: make(chan struct{}, 1),
+	}
+	return nil
+}
+
+// EvictionStats returns the memory limit of the store and its use
+func (s *LocalStore) EvictionStats() EvictionStats {
+	ev := s.evict
+	if ev == nil {
+		return EvictionStats{}
+	}
+	ev.mu.Lock()
+	defer ev.mu.Unlock()
+	return EvictionStats{
+		Enabled:   true,
+		MaxMemory: ev.opts.MaxMemory,
+		Used:      ev.db.Size(),
+		Policy:    ev.opts.Policy,
+		Scope:     ev.opts.Scope,
+		Tracked:   len(ev.keys),
+		Evicted:   ev.count,
+	}
+}
+
+// touchKey records a use of a key that exists
+func (s *LocalStore) touchKey(collection, key string) {
+	if s.evict != nil {
+		s.evict.mu.Lock()
+		s.evict.touch(collection, key)
+		s.evict.mu.Unlock()
+	}
+}
+
+// touch records a use of a key; the caller must hold e.mu
+func (e *evictor) touch(collection, key string) {
+	e.tick++
+	a, ok := e.keys[cacheKey{collection, key}]
+	if !ok {
+		// Kept in memory past the request
+		a = &keyAccess{}
+		e.keys[cacheKey{strings.Clone(collection), strings.Clone(key)}] = a
+	}
+	a.last = e.tick
+	if a.hits < math.MaxUint32 {
+		a.hits++
+	}
+}
+
+// trackWrites records committed ops and wakes eviction once the store is
+// over its limit
+func (s *LocalStore) trackWrites(ops []writeOp) {
+	ev := s.evict
+	if ev == nil {
+		return
+	}
+	ev.mu.Lock()
+	for _, op := range ops {
+		if op.delete {
+			delete(ev.keys, cacheKey{op.collection, op.key})
+		} else {
+			ev.touch(op.collection, op.key)
+		}
+	}
+	ev.mu.Unlock()
+
+	if ev.over() {
+		select {
+		case ev.wake <- struct{}{}:
+		default:
+		}
+	}
+}
+
+// forgetCollection stops tracking the keys of a cleared collection
+func (s *LocalStore) forgetCollection(collection string) {
+	ev := s.evict
+	if ev == nil {
+		return
+	}
+	ev.mu.Lock()
+	defer ev.mu.Unlock()
+	for k := range ev.keys {
+		if k.collection == collection {
+			delete(ev.keys, k)
+		}
+	}
+}
+
+// over reports whether the store holds more than its limit
+func (e *evictor) over() bool {
+	return e.excess() > 0
+}
+
+// excess returns the bytes the store holds beyond its limit
+func (e *evictor) excess() int64 {
+	return e.db.Size() - e.opts.MaxMemory
+}
+
+// less reports whether a should be evicted before b
+func (e *evictor) less(a, b *keyAccess) bool {
+	if e.opts.Policy == EvictLFU && a.hits != b.hits {
+		return a.hits < b.hits
+	}
+	return a.last < b.last
+}
+
+// sample returns up to n tracked keys to evict, picked among n times
+// evictSamples keys. Map iteration starts at a random entry, so the
+// sample changes from call to call.
+func (e *evictor) sample(n int) []cacheKey {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	type candidate struct {
+		key    cacheKey
+		access keyAccess
+	}
+	candidates := make([]candidate, 0, n*evictSamples)
+	for k, a := range e.keys {
+		candidates = append(candidates, candidate{k, *a})
+		if len(candidates) == cap(candidates) {
+			break
+		}
+	}
+	sort.Slice(candidates, func(i, j int) bool {
+		return e.less(&candidates[i].access, &candidates[j].access)
+	})
+
+	if len(candidates) > n {
+		candidates = candidates[:n]
+	}
+	keys := make([]cacheKey, len(candidates))
+	for i, c := range candidates {
+		keys[i] = c.key
+	}
+	return keys
+}
+
+// evictionVictims returns up to n keys to evict next. With the ttl
+// policy, keys that expire soonest go first.
+func (s *LocalStore) evictionVictims(n int) ([]cacheKey, error) {
+	var victims []cacheKey
+	if s.evict.opts.Policy == EvictTTL {
+		expiring, err := s.DueExpiries(time.Unix(0, math.MaxInt64), n)
+		if err != nil {
+			return nil, err
+		}
+		for _, e := range expiring {
+			if at, err := s.ExpiryOf(e.Collection, e.Key); err != nil || at != e.At {
+				continue // stale entry of the expiry queue
+			}
+			victims = append(victims, cacheKey{e.Collection, e.Key})
+		}
+	}
+	if len(victims) < n {
+		picked := make(map[cacheKey]bool, len(victims))
+		for _, v := range victims {
+			picked[v] = true
+		}
+		for _, v := range s.evict.sample(n - len(victims)) {
+			if !picked[v] {
+				victims = append(victims, v)
+			}
+		}
+	}
+	return victims, nil
+}
+
+// storedSize returns the bytes a key and its value take in the engine, or
+// 0 if the key does not exist
+func (s *LocalStore) storedSize(collection, key string) (int64, error) {
+	dbKey := s.makeKey(collection, key)
+	value, err := s.db.Get([]byte(dbKey))
+	if err == ErrNotFound {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, err
+	}
+	return int64(len(dbKey) + len(value)), nil
+}
+
+// StartEviction keeps the store under its memory limit until the store is
+// closed, if eviction is enabled. With replicated eviction only the master
+// evicts; slaves receive the deletes through replication.
+func (s *ReplicatedStore) StartEviction() {
+	ev := s.store.evict
+	if ev == nil || ev.stop != nil {
+		return
+	}
+	if ev.opts.Scope == EvictReplicated && s.config.IsSlave() {
+		return
+	}
+	ev.stop = make(chan struct{})
+	ev.stopped = make(chan struct{})
+	go s.evictionLoop(ev)
+}
+
+// stopEviction stops eviction and waits for it to return
+func (s *ReplicatedStore) stopEviction() {
+	if ev := s.store.evict; ev != nil && ev.stop != nil {
+		close(ev.stop)
+		<-ev.stopped
+	}
+}
+
+// evictionLoop runs Evict after writes that go over the limit, and every
+// evictInterval, until the store is closed
+func (s *ReplicatedStore) evictionLoop(ev *evictor) {
+	defer close(ev.stopped)
+
+	ticker := time.NewTicker(evictInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ev.stop:
+			return
+		case <-ev.wake:
+		case <-ticker.C:
+		}
+		if _, err := s.Evict(); err != nil {
+			log.Printf("[Eviction] failed to evict keys: %v", err)
+		}
+	}
+}
+
+// Evict deletes keys as the eviction policy picks them until the store
+// fits its memory limit, and returns the number of keys deleted
+func (s *ReplicatedStore) Evict() (int, error) {
+	ev := s.store.evict
+	if ev == nil {
+		return 0, nil
+	}
+
+	total := 0
+	for excess := ev.excess(); excess > 0; excess = ev.excess() {
+		victims, err := s.store.evictionVictims(evictBatchSize)
+		if err != nil || len(victims) == 0 {
+			// Whatever is left is not evictable, such as metadata
+			return total, err
+		}
+
+		n, err := s.evictKeys(victims, excess)
+		total += n
+		if err != nil || n == 0 {
+			return total, err
+		}
+	}
+	return total, nil
+}
+
+// evictKeys deletes victims in order in a single batch, replicated with
+// 2PC unless eviction is local, until about excess bytes are freed
+func (s *ReplicatedStore) evictKeys(victims []cacheKey, excess int64) (int, error) {
+	ev := s.store.evict
+	ops := make([]BulkOp, len(victims))
+	for i, v := range victims {
+		ops[i] = BulkOp{Op: OpDelete, Collection: v.collection, Key: v.key}
+	}
+
+	unlock := s.locks.lockBulk(ops)
+	defer unlock()
+
+	batch := s.store.NewBatch()
+	var mutations []replication.Mutation
+	var freed int64
+	for _, v := range victims {
+		if freed >= excess {
+			break
+		}
+		size, err := s.store.storedSize(v.collection, v.key)
+		if err != nil {
+			return 0, err
+		}
+		if size == 0 {
+			continue // deleted since it was picked
+		}
+		freed += size
+
+		batch.Delete(v.collection, v.key)
+		if ev.opts.Scope == EvictReplicated && s.replicates(v.collection) {
+			mutations = append(mutations, replication.Mutation{
+				Delete:     true,
+				Collection: v.collection,
+				Key:        v.key,
+			})
+		}
+	}
+
+	if batch.Len() == 0 {
+		return 0, nil
+	}
+
+	if len(mutations) > 0 {
+		if err := s.manager.ReplicateBatch(mutations); err != nil {
+			return 0, fmt.Errorf("replication failed: %w", err)
+		}
+	}
+
+	if err := batch.Commit(); err != nil {
+		return 0, fmt.Errorf("local batch write failed after replication (inconsistency possible): %w", err)
+	}
+
+	ev.mu.Lock()
+	ev.count += uint64(batch.Len())
+	ev.mu.Unlock()
+	return batch.Len(), nil
+}
+
+// EvictionStats returns the memory limit of the store and its use
+func (s *ReplicatedStore) EvictionStats() EvictionStats {
+	return s.store.EvictionStats()
+}
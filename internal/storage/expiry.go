@@ -0,0 +1,269 @@
+package storage
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"log"
+	"time"
+
+	"kiwi/internal/replication"
+)
+
+// expireBatchSize is the number of expired keys deleted per batch
+const expireBatchSize = 1000
+
+// Expiry is a key that is due to expire
+type Expiry struct {
+	Collection string
+	Key        string
+	At         uint64 // unix nanoseconds
+}
+
+// expiryKey returns the key holding the expiry of a key
+func expiryKey(collection, key string) []byte {
+	return joinKey(expiryPrefix, []byte(collection), []byte{0}, []byte(key))
+}
+
+// expiryQueueKey returns the key that orders a key by its expiry
+func expiryQueueKey(at uint64, collection, key string) []byte {
+	ts := make([]byte, 8)
+	binary.BigEndian.PutUint64(ts, at)
+	return joinKey(expiryQueuePrefix, ts, []byte(collection), []byte{0}, []byte(key))
+}
+
+// readExpiry returns the expiry stored under ek, or 0 if there is none
+func (s *LocalStore) readExpiry(ek []byte) (uint64, error) {
+	data, err := s.db.Get(ek)
+	if err == ErrNotFound {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, fmt.Errorf("failed to read expiry: %w", err)
+	}
+	if len(data) != 8 {
+		return 0, nil
+	}
+	return binary.BigEndian.Uint64(data), nil
+}
+
+// ExpiryOf returns when a key expires, or 0 if it does not
+func (s *LocalStore) ExpiryOf(collection, key string) (uint64, error) {
+	return s.readExpiry(expiryKey(collection, key))
+}
+
+// expireValue updates the expiry of a key written by op. Puts into a
+// collection with a default TTL expire that long after now; any other
+// write clears the expiry. Only registered collections can have a TTL, so
+// others are skipped. pending holds expiries already changed in this
+// batch. The caller must hold s.mu.
+func (s *LocalStore) expireValue(batch *WriteBatch, op writeOp, pending map[string]uint64, now time.Time) error {
+	meta, ok := s.CollectionMeta(op.collection)
+	if !ok {
+		return nil
+	}
+
+	ek := expiryKey(op.collection, op.key)
+	prev, seen := pending[string(ek)]
+	if !seen {
+		var err error
+		if prev, err = s.readExpiry(ek); err != nil {
+			return err
+		}
+	}
+	if prev != 0 {
+		batch.Delete(expiryQueueKey(prev, op.collection, op.key))
+		batch.Delete(ek)
+	}
+
+	var at uint64
+	if !op.delete && meta.DefaultTTL > 0 {
+		at = uint64(now.Add(meta.DefaultTTL).UnixNano())
+		ts := make([]byte, 8)
+		binary.BigEndian.PutUint64(ts, at)
+		batch.Put(ek, ts)
+		batch.Put(expiryQueueKey(at, op.collection, op.key), nil)
+	}
+	pending[string(ek)] = at
+	return nil
+}
+
+// DueExpiries returns up to limit keys that expire at or before now, the
+// earliest first
+func (s *LocalStore) DueExpiries(now time.Time, limit int) ([]Expiry, error) {
+	end := make([]byte, 8)
+	binary.BigEndian.PutUint64(end, uint64(now.UnixNano())+1)
+
+	iter := s.db.NewIterator(&KeyRange{
+		Start: expiryQueuePrefix,
+		Limit: joinKey(expiryQueuePrefix, end),
+	})
+	defer iter.Release()
+
+	var due []Expiry
+	for iter.Next() && len(due) < limit {
+		k := iter.Key()[len(expiryQueuePrefix):]
+		if len(k) < 8 {
+			continue
+		}
+		rest := k[8:]
+		i := bytes.IndexByte(rest, 0)
+		if i < 0 {
+			continue
+		}
+		due = append(due, Expiry{
+			Collection: string(rest[:i]),
+			Key:        string(rest[i+1:]),
+			At:         binary.BigEndian.Uint64(k[:8]),
+		})
+	}
+	if err := iter.Error(); err != nil {
+		return nil, fmt.Errorf("iterator error: %w", err)
+	}
+	return due, nil
+}
+
+// dropStaleExpiry removes a queued expiry that no longer matches its key
+func (s *LocalStore) dropStaleExpiry(e Expiry) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	at, err := s.ExpiryOf(e.Collection, e.Key)
+	if err != nil || at == e.At {
+		return err
+	}
+	return s.db.Delete(expiryQueueKey(e.At, e.Collection, e.Key))
+}
+
+// clearExpiries removes the expiries of every key of a collection. The
+// caller must hold s.mu.
+func (s *LocalStore) clearExpiries(collection string) error {
+	prefix := joinKey(expiryPrefix, []byte(collection), []byte{0})
+	iter := s.db.NewIterator(bytesPrefix(prefix))
+	defer iter.Release()
+
+	batch := new(WriteBatch)
+	for iter.Next() {
+		if v := iter.Value(); len(v) == 8 {
+			key := string(iter.Key()[len(prefix):])
+			batch.Delete(expiryQueueKey(binary.BigEndian.Uint64(v), collection, key))
+		}
+		batch.Delete(append([]byte(nil), iter.Key()...))
+		if batch.Len() >= indexBuildBatchSize {
+			if err := s.db.Write(batch); err != nil {
+				return fmt.Errorf("failed to clear expiries: %w", err)
+			}
+			batch.Reset()
+		}
+	}
+	if err := iter.Error(); err != nil {
+		return fmt.Errorf("iterator error: %w", err)
+	}
+	if err := s.db.Write(batch); err != nil {
+		return fmt.Errorf("failed to clear expiries: %w", err)
+	}
+	return nil
+}
+
+// StartExpiry deletes expired keys and aborts stale uploads every interval
+// until the store is closed. Only the master expires keys; slaves receive
+// the deletes through replication.
+func (s *ReplicatedStore) StartExpiry(interval time.Duration) {
+	if s.config.IsSlave() || interval <= 0 || s.stop != nil {
+		return
+	}
+	s.stop = make(chan struct{})
+	s.stopped = make(chan struct{})
+	go s.expiryLoop(interval)
+}
+
+// expiryLoop runs ExpireKeys until the store is closed
+func (s *ReplicatedStore) expiryLoop(interval time.Duration) {
+	defer close(s.stopped)
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-s.stop:
+			return
+		case <-ticker.C:
+		}
+		if _, err := s.ExpireKeys(time.Now()); err != nil {
+			log.Printf("[Expiry] failed to delete expired keys: %v", err)
+		}
+		if _, err := s.ExpireUploads(time.Now()); err != nil {
+			log.Printf("[Expiry] failed to abort stale uploads: %v", err)
+		}
+	}
+}
+
+// ExpireKeys deletes every key that expired by now using 2PC and returns
+// the number of keys deleted
+func (s *ReplicatedStore) ExpireKeys(now time.Time) (int, error) {
+	total := 0
+	for {
+		due, err := s.store.DueExpiries(now, expireBatchSize)
+		if err != nil || len(due) == 0 {
+			return total, err
+		}
+
+		n, err := s.expire(due)
+		total += n
+		if err != nil || len(due) < expireBatchSize {
+			return total, err
+		}
+	}
+}
+
+// expire deletes the keys in due whose expiry has not changed meanwhile,
+// in a single 2PC round
+func (s *ReplicatedStore) expire(due []Expiry) (int, error) {
+	ops := make([]BulkOp, len(due))
+	for i, e := range due {
+		ops[i] = BulkOp{Op: OpDelete, Collection: e.Collection, Key: e.Key}
+	}
+
+	unlock := s.locks.lockBulk(ops)
+	defer unlock()
+
+	batch := s.store.NewBatch()
+	mutations := make([]replication.Mutation, 0, len(due))
+	for _, e := range due {
+		at, err := s.store.ExpiryOf(e.Collection, e.Key)
+		if err != nil {
+			return 0, err
+		}
+		if at != e.At {
+			if err := s.store.dropStaleExpiry(e); err != nil {
+				return 0, err
+			}
+			continue
+		}
+
+		batch.Delete(e.Collection, e.Key)
+		if s.replicates(e.Collection) {
+			mutations = append(mutations, replication.Mutation{
+				Delete:     true,
+				Collection: e.Collection,
+				Key:        e.Key,
+			})
+		}
+	}
+
+	if batch.Len() == 0 {
+		return 0, nil
+	}
+
+	if len(mutations) > 0 {
+		if err := s.manager.ReplicateBatch(mutations); err != nil {
+			return 0, fmt.Errorf("replication failed: %w", err)
+		}
+	}
+
+	if err := batch.Commit(); err != nil {
+		return 0, fmt.Errorf("local batch write failed after replication (inconsistency possible): %w", err)
+	}
+	return batch.Len(), nil
+}
@@ -0,0 +1,86 @@
+package storage
+
+import (
+	"encoding/binary"
+	"fmt"
+	"strings"
+	"time"
+
+	"kiwi/internal/watch"
+)
+
+// loadFeed restores the last change sequence so numbering continues across
+// restarts
+func (s *LocalStore) loadFeed() error {
+	var last uint64
+
+	data, err := s.db.Get(feedSeqKey)
+	switch {
+	case err == ErrNotFound:
+	case err != nil:
+		return fmt.Errorf("failed to read feed sequence: %w", err)
+	case len(data) == 8:
+		last = binary.BigEndian.Uint64(data)
+	}
+
+	s.seq = last
+	s.feed = watch.NewHub(watch.DefaultBufferSize, last)
+	return nil
+}
+
+// Feed returns the hub publishing committed changes
+func (s *LocalStore) Feed() *watch.Hub {
+	return s.feed
+}
+
+// feedEvents numbers ops as change events and records the new sequence in
+// batch. The caller must hold s.mu.
+func (s *LocalStore) feedEvents(batch *WriteBatch, ops []writeOp) []watch.Event {
+	now := time.Now().UTC()
+	events := make([]watch.Event, len(ops))
+	for i, op := range ops {
+		// Events outlive the request, while collection and key may point
+		// into a reused request buffer
+		e := watch.Event{
+			Seq:        s.seq + uint64(i) + 1,
+			Type:       watch.EventPut,
+			Collection: strings.Clone(op.collection),
+			Key:        strings.Clone(op.key),
+			Timestamp:  now,
+		}
+		if op.delete {
+			e.Type = watch.EventDelete
+		} else if view, err := jsonValue(op.value); err == nil {
+			// Raw values and blobs travel in their JSON form
+			e.Value, e.ContentType, e.Blob = view.JSON, view.ContentType, view.Blob
+		}
+		events[i] = e
+	}
+
+	if len(events) > 0 {
+		seq := make([]byte, 8)
+		binary.BigEndian.PutUint64(seq, events[len(events)-1].Seq)
+		batch.Put(feedSeqKey, seq)
+	}
+	return events
+}
+
+// collectionEvent numbers a collection-wide event and records it in batch.
+// The caller must hold s.mu and publish the event once batch is written.
+func (s *LocalStore) collectionEvent(batch *WriteBatch, typ, collection string) (watch.Event, error) {
+	e := watch.Event{
+		Seq:        s.seq + 1,
+		Type:       typ,
+		Collection: strings.Clone(collection),
+		Timestamp:  time.Now().UTC(),
+	}
+
+	seq := make([]byte, 8)
+	binary.BigEndian.PutUint64(seq, e.Seq)
+	batch.Put(feedSeqKey, seq)
+
+	if err := s.logEvents(batch, []watch.Event{e}); err != nil {
+		return watch.Event{}, err
+	}
+	return e, nil
+}
@@ -0,0 +1,249 @@
+package storage
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"os"
+	"sort"
+	"strconv"
+)
+
+// On-disk formats. Version 1 stored values under "<collection>:<key>", so
+// a collection name containing ':' could collide with another collection.
+// Version 2 stores them under dataKey.
+const (
+	formatLegacy  = 1
+	FormatVersion = 2 // the format written by this build
+)
+
+// ErrFormatMismatch is returned when opening a database in another format
+var ErrFormatMismatch = errors.New("unsupported database format")
+
+// MigrationResult describes a database migration
+type MigrationResult struct {
+	From      int    // format before the migration
+	To        int    // format after the migration
+	Keys      int64  // data keys rewritten
+	Skipped   int64  // data keys that could not be parsed and were left out
+	Ambiguous int64  // data keys that matched more than one known collection
+	Backup    string // the database as it was before, "" if nothing changed
+}
+
+// readFormat returns the format of a database: the stored version, the
+// legacy format for databases with data but no version, which predate
+// it, or 0 for databases without data
+func readFormat(db Engine) (int, error) {
+	data, err := db.Get(formatVersionKey)
+	if err == nil {
+		if len(data) != 4 {
+			return 0, fmt.Errorf("%w: malformed format version", ErrFormatMismatch)
+		}
+		return int(binary.BigEndian.Uint32(data)), nil
+	}
+	if err != ErrNotFound {
+		return 0, fmt.Errorf("failed to read format version: %w", err)
+	}
+
+	iter := db.NewIterator(&KeyRange{Start: []byte{reservedPrefix + 1}})
+	defer iter.Release()
+	if iter.First() {
+		return formatLegacy, nil
+	}
+	return 0, iter.Error()
+}
+
+// checkFormat makes sure a database uses FormatVersion, recording it in
+// databases without data
+func checkFormat(db Engine) error {
+	version, err := readFormat(db)
+	switch {
+	case err != nil:
+		return err
+	case version == 0:
+		if err := db.Put(formatVersionKey, encodeFormat(FormatVersion)); err != nil {
+			return fmt.Errorf("failed to store format version: %w", err)
+		}
+		return nil
+	case version < FormatVersion:
+		return fmt.Errorf("%w: database has format %d, this build needs %d; stop the node and run \"kiwi migrate\"", ErrFormatMismatch, version, FormatVersion)
+	case version > FormatVersion:
+		return fmt.Errorf("%w: database has format %d, newer than %d; upgrade kiwi", ErrFormatMismatch, version, FormatVersion)
+	}
+	return nil
+}
+
+// encodeFormat encodes a format version as 4 big-endian bytes
+func encodeFormat(version int) []byte {
+	return binary.BigEndian.AppendUint32(nil, uint32(version))
+}
+
+// Migrate rewrites the database at path in FormatVersion. The node must be
+// stopped. The migrated database is built next to the original and only
+// swapped in once complete, so an interrupted migration leaves the
+// original untouched; it can simply be run again. The original is kept at
+// path + ".v<format>" until removed by hand. Older formats were only ever
+// written by the LevelDB engine.
+func Migrate(path string) (MigrationResult, error) {
+	src, err := openLevelDBWith(path, nil)
+	if err != nil {
+		return MigrationResult{}, fmt.Errorf("failed to open database: %w", err)
+	}
+	defer src.Close()
+
+	version, err := readFormat(src)
+	if err != nil {
+		return MigrationResult{}, err
+	}
+	result := MigrationResult{From: version, To: version}
+	switch {
+	case version == 0 || version == FormatVersion:
+		return result, nil
+	case version > FormatVersion:
+		return result, fmt.Errorf("%w: database has format %d, newer than %d", ErrFormatMismatch, version, FormatVersion)
+	}
+
+	backup := path + ".v" + strconv.Itoa(version)
+	if _, err := os.Stat(backup); err == nil {
+		return result, fmt.Errorf("%s already exists; move it away first", backup)
+	}
+
+	// Left over by an interrupted run
+	tmp := path + ".migrating"
+	if err := os.RemoveAll(tmp); err != nil {
+		return result, fmt.Errorf("failed to remove %s: %w", tmp, err)
+	}
+	dst, err := openLevelDBWith(tmp, nil)
+	if err != nil {
+		return result, fmt.Errorf("failed to create %s: %w", tmp, err)
+	}
+
+	counts, err := migrateLegacy(src, dst)
+	if cerr := dst.Close(); err == nil && cerr != nil {
+		err = fmt.Errorf("failed to close %s: %w", tmp, cerr)
+	}
+	if err != nil {
+		os.RemoveAll(tmp)
+		return result, err
+	}
+	if err := src.Close(); err != nil {
+		return result, fmt.Errorf("failed to close database: %w", err)
+	}
+
+	if err := os.Rename(path, backup); err != nil {
+		return result, fmt.Errorf("failed to move database to %s: %w", backup, err)
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		return result, fmt.Errorf("failed to move %s into place, the original is at %s: %w", tmp, backup, err)
+	}
+
+	result.To, result.Backup = FormatVersion, backup
+	result.Keys, result.Skipped, result.Ambiguous = counts.Keys, counts.Skipped, counts.Ambiguous
+	return result, nil
+}
+
+// migrateLegacy copies a legacy database into dst, rewriting data keys.
+// A legacy key is split after the longest collection name known from the
+// reserved keyspace that prefixes it, falling back to the first ':' as
+// legacy builds did when listing collections. Key counts are recomputed
+// for the new split. Only the key counts of the result are set.
+func migrateLegacy(src, dst Engine) (MigrationResult, error) {
+	var res MigrationResult
+	known, err := knownCollections(src)
+	if err != nil {
+		return res, err
+	}
+
+	iter := src.NewIterator(nil)
+	defer iter.Release()
+
+	counts := make(map[string]int64)
+	batch := new(WriteBatch)
+	for iter.Next() {
+		key := iter.Key()
+		switch {
+		case key[0] != reservedPrefix:
+			collection, k, matches := splitLegacyKey(key, known)
+			if matches == 0 {
+				res.Skipped++
+				continue
+			}
+			if matches > 1 {
+				res.Ambiguous++
+			}
+			batch.Put(dataKey(collection, k), iter.Value())
+			counts[collection]++
+			res.Keys++
+		case bytes.HasPrefix(key, countPrefix), bytes.Equal(key, countsReadyKey):
+			// Recomputed below
+		default:
+			batch.Put(key, iter.Value())
+		}
+
+		if batch.Len() >= backupBatchSize {
+			if err := dst.Write(batch); err != nil {
+				return res, fmt.Errorf("failed to write database: %w", err)
+			}
+			batch.Reset()
+		}
+	}
+	if err := iter.Error(); err != nil {
+		return res, fmt.Errorf("iterator error: %w", err)
+	}
+
+	for collection, count := range counts {
+		batch.Put(countKey(collection), encodeCount(count))
+	}
+	batch.Put(countsReadyKey, []byte{1})
+	batch.Put(formatVersionKey, encodeFormat(FormatVersion))
+	if err := dst.Write(batch); err != nil {
+		return res, fmt.Errorf("failed to write database: %w", err)
+	}
+	return res, nil
+}
+
+// knownCollections returns the collection names recorded in the reserved
+// keyspace of a legacy database, longest first
+func knownCollections(db Engine) ([]string, error) {
+	seen := make(map[string]bool)
+	for _, prefix := range [][]byte{countPrefix, collectionMetaPrefix, textDefPrefix} {
+		iter := db.NewIterator(bytesPrefix(prefix))
+		for iter.Next() {
+			seen[string(iter.Key()[len(prefix):])] = true
+		}
+		iter.Release()
+		if err := iter.Error(); err != nil {
+			return nil, fmt.Errorf("iterator error: %w", err)
+		}
+	}
+
+	names := make([]string, 0, len(seen))
+	for name := range seen {
+		names = append(names, name)
+	}
+	sort.Slice(names, func(i, j int) bool { return len(names[i]) > len(names[j]) })
+	return names, nil
+}
+
+// splitLegacyKey splits a "<collection>:<key>" data key and returns how
+// many known collections it could belong to, at least 1 unless it has no
+// ':' at all
+func splitLegacyKey(k []byte, known []string) (collection, key string, matches int) {
+	for _, name := range known {
+		if len(k) > len(name) && k[len(name)] == ':' && string(k[:len(name)]) == name {
+			if matches == 0 {
+				collection, key = name, string(k[len(name)+1:])
+			}
+			matches++
+		}
+	}
+	if matches > 0 {
+		return collection, key, matches
+	}
+	i := bytes.IndexByte(k, ':')
+	if i < 0 {
+		return "", "", 0
+	}
+	return string(k[:i]), string(k[i+1:]), 1
+}
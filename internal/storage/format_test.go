@@ -0,0 +1,170 @@
+package storage
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// writeLegacyDB creates a LevelDB database in the legacy format, with data
+// keys stored as "<collection>:<key>"
+func writeLegacyDB(t *testing.T, path string, keys map[string]string) {
+	t.Helper()
+	db, err := openLevelDBWith(path, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	for k, v := range keys {
+		if err := db.Put([]byte(k), []byte(v)); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestMigrate(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "db")
+	legacy := map[string]string{
+		"users:u1":     `{"name":"Ann"}`,
+		"users:u2":     `{"name":"Bob"}`,
+		"users:a:b":    `"key with a colon"`,
+		"a:b:k1":       `1`, // in "a:b" and in "a" as key "b:k1"
+		"a:z":          `2`,
+		"orders:o1":    `3`, // unknown collection, split at the first ':'
+		"nocolon":      `4`, // no collection at all
+		"logs:":        `5`, // empty key
+		"\x00misc\x00": `"reserved"`,
+
+		// Collections known from the reserved keyspace, with stale counts
+		string(countKey("users")):                             string(encodeCount(99)),
+		string(countKey("a:b")):                               string(encodeCount(1)),
+		string(countKey("a")):                                 string(encodeCount(1)),
+		string(joinKey(collectionMetaPrefix, []byte("logs"))): `{"name":"logs"}`,
+	}
+	writeLegacyDB(t, path, legacy)
+
+	// The legacy database cannot be opened until it is migrated
+	if _, err := OpenStore("leveldb", path, EngineOptions{}); err == nil || !strings.Contains(err.Error(), "kiwi migrate") {
+		t.Fatalf("opening a legacy database: got %v, want a format error", err)
+	}
+
+	res, err := Migrate(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := MigrationResult{From: 1, To: FormatVersion, Keys: 7, Skipped: 1, Ambiguous: 1, Backup: path + ".v1"}
+	if res != want {
+		t.Errorf("got %+v, want %+v", res, want)
+	}
+	if _, err := os.Stat(path + ".migrating"); !os.IsNotExist(err) {
+		t.Errorf("temporary database left behind: %v", err)
+	}
+
+	store, err := OpenStore("leveldb", path, EngineOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer store.Close()
+
+	values := []struct {
+		collection, key string
+		want            interface{}
+	}{
+		{"users", "u1", map[string]interface{}{"name": "Ann"}},
+		{"users", "u2", map[string]interface{}{"name": "Bob"}},
+		{"users", "a:b", "key with a colon"},
+		{"a:b", "k1", 1.0},
+		{"a", "z", 2.0},
+		{"orders", "o1", 3.0},
+	}
+	for _, v := range values {
+		got, err := store.Get(v.collection, v.key)
+		if err != nil {
+			t.Errorf("Get(%q, %q): %v", v.collection, v.key, err)
+			continue
+		}
+		if !reflect.DeepEqual(got, v.want) {
+			t.Errorf("Get(%q, %q) = %v, want %v", v.collection, v.key, got, v.want)
+		}
+	}
+	if _, err := store.Get("a", "b:k1"); err != ErrKeyNotFound {
+		t.Errorf(`"a:b:k1" was also migrated into collection "a": %v`, err)
+	}
+
+	counts := map[string]int{"users": 3, "a:b": 1, "a": 1, "orders": 1, "logs": 1}
+	for collection, want := range counts {
+		if n, err := store.Count(collection); err != nil || n != want {
+			t.Errorf("Count(%q) = %d, %v; want %d", collection, n, err, want)
+		}
+	}
+	if _, err := store.Get("logs", ""); err != ErrInvalidKey {
+		t.Errorf("empty key: got %v, want ErrInvalidKey", err)
+	}
+
+	// The reserved keyspace is copied as it was
+	if data, err := store.db.Get([]byte("\x00misc\x00")); err != nil || string(data) != `"reserved"` {
+		t.Errorf("reserved key = %q, %v", data, err)
+	}
+
+	// The original is kept unchanged next to the migrated database
+	backup, err := openLevelDBWith(res.Backup, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for k, v := range legacy {
+		if data, err := backup.Get([]byte(k)); err != nil || string(data) != v {
+			t.Errorf("backup %q = %q, %v; want %q", k, data, err, v)
+		}
+	}
+	if version, err := readFormat(backup); err != nil || version != formatLegacy {
+		t.Errorf("backup format = %d, %v; want %d", version, err, formatLegacy)
+	}
+	backup.Close()
+}
+
+func TestMigrateCurrent(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "db")
+	store, err := OpenStore("leveldb", path, EngineOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := store.Put("users", "u1", "Ann"); err != nil {
+		t.Fatal(err)
+	}
+	store.Close()
+
+	res, err := Migrate(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := (MigrationResult{From: FormatVersion, To: FormatVersion}); res != want {
+		t.Errorf("got %+v, want %+v", res, want)
+	}
+	if _, err := os.Stat(path + ".v1"); !os.IsNotExist(err) {
+		t.Errorf("backup made of a current database: %v", err)
+	}
+}
+
+func TestMigrateExistingBackup(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "db")
+	writeLegacyDB(t, path, map[string]string{"users:u1": `1`})
+	if err := os.Mkdir(path+".v1", 0o755); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := Migrate(path); err == nil || !strings.Contains(err.Error(), "already exists") {
+		t.Fatalf("got %v, want an error about the existing backup", err)
+	}
+
+	// Nothing was changed
+	db, err := openLevelDBWith(path, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	if version, err := readFormat(db); err != nil || version != formatLegacy {
+		t.Errorf("format = %d, %v; want %d", version, err, formatLegacy)
+	}
+}
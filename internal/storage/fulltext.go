@@ -0,0 +1,453 @@
+package storage
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+	"time"
+
+	"kiwi/internal/document"
+	"kiwi/internal/search"
+)
+
+// BM25 ranking parameters
+const (
+	bm25K1 = 1.2
+	bm25B  = 0.75
+)
+
+// TextIndexInfo describes the full-text index of a collection
+type TextIndexInfo struct {
+	Collection string    `json:"collection"`
+	Fields     []string  `json:"fields"`
+	CreatedAt  time.Time `json:"created_at"`
+}
+
+// SearchOptions controls a full-text search
+type SearchOptions struct {
+	MatchAll bool // require every query term instead of any
+	Limit    int  // 0 = no limit
+}
+
+// SearchHit is a single ranked search result
+type SearchHit struct {
+	Key        string
+	Score      float64
+	Value      interface{}
+	Highlights map[string]string // field path -> highlighted fragment
+}
+
+// textStats tracks the totals needed for BM25 length normalization
+type textStats struct {
+	docs   int64
+	tokens int64
+}
+
+// textDefKey returns the key holding a collection's text index definition
+func textDefKey(collection string) []byte {
+	return joinKey(textDefPrefix, []byte(collection))
+}
+
+// textStatsKey returns the key holding a collection's text index totals
+func textStatsKey(collection string) []byte {
+	return joinKey(textStatsPrefix, []byte(collection))
+}
+
+// textCollectionPrefix returns the common prefix of all postings of a collection
+func textCollectionPrefix(collection string) []byte {
+	return joinKey(textPostingPrefix, []byte(collection), []byte{0})
+}
+
+// textTermPrefix returns the common prefix of all postings of a term
+func textTermPrefix(collection, term string) []byte {
+	return joinKey(textCollectionPrefix(collection), []byte(term), []byte{0})
+}
+
+// docTerms returns term frequencies and the token count of a stored value
+func docTerms(info *TextIndexInfo, data []byte) (map[string]int, int) {
+	if data == nil {
+		return nil, 0
+	}
+	doc, err := decodeValue(data)
+	if err != nil {
+		return nil, 0
+	}
+
+	freqs := make(map[string]int)
+	length := 0
+	for _, text := range textValues(doc, info.Fields) {
+		for _, t := range search.Tokenize(text) {
+			freqs[t.Term]++
+			length++
+		}
+	}
+	return freqs, length
+}
+
+// textValues collects the strings stored under the given field paths
+func textValues(doc interface{}, fields []string) []string {
+	var out []string
+	for _, field := range fields {
+		value, ok := document.Get(doc, field)
+		if !ok {
+			continue
+		}
+		switch v := value.(type) {
+		case string:
+			out = append(out, v)
+		case []interface{}:
+			for _, elem := range v {
+				if str, isStr := elem.(string); isStr {
+					out = append(out, str)
+				}
+			}
+		}
+	}
+	return out
+}
+
+// textIndexValue adds postings for data to the batch and records the stats change
+func (s *LocalStore) textIndexValue(batch *WriteBatch, info *TextIndexInfo, key string, data []byte, delta *textStats) {
+	freqs, length := docTerms(info, data)
+	if length == 0 {
+		return
+	}
+	for term, tf := range freqs {
+		value := binary.AppendUvarint(nil, uint64(tf))
+		value = binary.AppendUvarint(value, uint64(length))
+		batch.Put(joinKey(textTermPrefix(info.Collection, term), []byte(key)), value)
+	}
+	delta.docs++
+	delta.tokens += int64(length)
+}
+
+// textUnindexValue removes the postings of data from the batch and records the stats change
+func (s *LocalStore) textUnindexValue(batch *WriteBatch, info *TextIndexInfo, key string, data []byte, delta *textStats) {
+	freqs, length := docTerms(info, data)
+	if length == 0 {
+		return
+	}
+	for term := range freqs {
+		batch.Delete(joinKey(textTermPrefix(info.Collection, term), []byte(key)))
+	}
+	delta.docs--
+	delta.tokens -= int64(length)
+}
+
+// textIndex returns the text index of a collection, or nil
+func (s *LocalStore) textIndex(collection string) *TextIndexInfo {
+	s.indexMu.RLock()
+	defer s.indexMu.RUnlock()
+	return s.textIndexes[collection]
+}
+
+// loadTextIndexes reads text index definitions and totals into memory
+func (s *LocalStore) loadTextIndexes() error {
+	s.textIndexes = make(map[string]*TextIndexInfo)
+	s.textStats = make(map[string]textStats)
+
+	infos, err := s.ListTextIndexes()
+	if err != nil {
+		return err
+	}
+
+	for i := range infos {
+		info := infos[i]
+		s.textIndexes[info.Collection] = &info
+
+		data, err := s.db.Get(textStatsKey(info.Collection))
+		if err != nil && err != ErrNotFound {
+			return fmt.Errorf("failed to read text index stats: %w", err)
+		}
+		if len(data) == 16 {
+			s.textStats[info.Collection] = textStats{
+				docs:   int64(binary.BigEndian.Uint64(data[:8])),
+				tokens: int64(binary.BigEndian.Uint64(data[8:])),
+			}
+		}
+	}
+	return nil
+}
+
+// applyTextDeltas adds updated text stats to batch and returns them for
+// storeTextStats. The caller must hold s.mu.
+func (s *LocalStore) applyTextDeltas(batch *WriteBatch, deltas map[string]*textStats) map[string]textStats {
+	s.textMu.RLock()
+	defer s.textMu.RUnlock()
+
+	updated := make(map[string]textStats, len(deltas))
+	for collection, delta := range deltas {
+		st := s.textStats[collection]
+		st.docs += delta.docs
+		st.tokens += delta.tokens
+		batch.Put(textStatsKey(collection), encodeTextStats(st))
+		updated[collection] = st
+	}
+	return updated
+}
+
+// storeTextStats publishes stats computed by applyTextDeltas
+func (s *LocalStore) storeTextStats(stats map[string]textStats) {
+	if len(stats) == 0 {
+		return
+	}
+	s.textMu.Lock()
+	defer s.textMu.Unlock()
+	for collection, st := range stats {
+		s.textStats[strings.Clone(collection)] = st
+	}
+}
+
+// encodeTextStats encodes text stats as two big-endian uint64s
+func encodeTextStats(st textStats) []byte {
+	out := make([]byte, 16)
+	binary.BigEndian.PutUint64(out[:8], uint64(st.docs))
+	binary.BigEndian.PutUint64(out[8:], uint64(st.tokens))
+	return out
+}
+
+// CreateTextIndex enables full-text search on the given string fields of a
+// collection and indexes existing documents. Recreating the index with
+// different fields rebuilds it.
+func (s *LocalStore) CreateTextIndex(collection string, fields []string) error {
+	if len(fields) == 0 {
+		return ErrInvalidField
+	}
+	for _, f := range fields {
+		if err := validateField(f); err != nil {
+			return err
+		}
+	}
+
+	if existing := s.textIndex(collection); existing != nil {
+		if equalStrings(existing.Fields, fields) {
+			return nil
+		}
+		if err := s.DropTextIndex(collection); err != nil {
+			return err
+		}
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	// Clear entries left by an interrupted build or drop
+	if err := s.deleteRange(bytesPrefix(textCollectionPrefix(collection))); err != nil {
+		return err
+	}
+
+	info := &TextIndexInfo{Collection: collection, Fields: fields, CreatedAt: time.Now().UTC()}
+	stats := &textStats{}
+
+	prefix := collectionKeyPrefix(collection)
+	iter := s.db.NewIterator(bytesPrefix(prefix))
+	batch := new(WriteBatch)
+	for iter.Next() {
+		key := string(iter.Key()[len(prefix):])
+		if value, err := s.openValue(iter.Value()); err == nil {
+			s.textIndexValue(batch, info, key, value, stats)
+		}
+		if batch.Len() >= indexBuildBatchSize {
+			if err := s.db.Write(batch); err != nil {
+				iter.Release()
+				return fmt.Errorf("failed to build text index: %w", err)
+			}
+			batch.Reset()
+		}
+	}
+	iter.Release()
+	if err := iter.Error(); err != nil {
+		return fmt.Errorf("iterator error: %w", err)
+	}
+
+	def, err := json.Marshal(info)
+	if err != nil {
+		return fmt.Errorf("failed to serialize text index: %w", err)
+	}
+	batch.Put(textStatsKey(collection), encodeTextStats(*stats))
+	batch.Put(textDefKey(collection), def)
+	if err := s.db.Write(batch); err != nil {
+		return fmt.Errorf("failed to build text index: %w", err)
+	}
+
+	s.textMu.Lock()
+	s.textStats[collection] = *stats
+	s.textMu.Unlock()
+
+	s.indexMu.Lock()
+	s.textIndexes[collection] = info
+	s.indexMu.Unlock()
+
+	return nil
+}
+
+// DropTextIndex removes the full-text index of a collection.
+// Dropping a missing index is a no-op.
+func (s *LocalStore) DropTextIndex(collection string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.indexMu.Lock()
+	delete(s.textIndexes, collection)
+	s.indexMu.Unlock()
+
+	s.textMu.Lock()
+	delete(s.textStats, collection)
+	s.textMu.Unlock()
+
+	batch := new(WriteBatch)
+	batch.Delete(textDefKey(collection))
+	batch.Delete(textStatsKey(collection))
+	if err := s.db.Write(batch); err != nil {
+		return fmt.Errorf("failed to delete text index: %w", err)
+	}
+
+	return s.deleteRange(bytesPrefix(textCollectionPrefix(collection)))
+}
+
+// ListTextIndexes returns all full-text index definitions
+func (s *LocalStore) ListTextIndexes() ([]TextIndexInfo, error) {
+	iter := s.db.NewIterator(bytesPrefix(textDefPrefix))
+	defer iter.Release()
+
+	result := make([]TextIndexInfo, 0)
+	for iter.Next() {
+		var info TextIndexInfo
+		if err := json.Unmarshal(iter.Value(), &info); err != nil {
+			continue
+		}
+		result = append(result, info)
+	}
+	if err := iter.Error(); err != nil {
+		return nil, fmt.Errorf("iterator error: %w", err)
+	}
+	return result, nil
+}
+
+// Search runs a ranked full-text query against a collection's text index.
+// Results are scored with BM25 and returned best first, together with the
+// total number of matching documents.
+func (s *LocalStore) Search(collection, q string, opts SearchOptions) ([]SearchHit, int, error) {
+	info := s.textIndex(collection)
+	if info == nil {
+		return nil, 0, ErrIndexNotFound
+	}
+
+	terms := search.Terms(q)
+	if len(terms) == 0 {
+		return []SearchHit{}, 0, nil
+	}
+
+	s.textMu.RLock()
+	stats := s.textStats[collection]
+	s.textMu.RUnlock()
+	if stats.docs == 0 {
+		return []SearchHit{}, 0, nil
+	}
+	avgLen := float64(stats.tokens) / float64(stats.docs)
+
+	snap, err := s.db.GetSnapshot()
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to acquire snapshot: %w", err)
+	}
+	defer snap.Release()
+
+	scores := make(map[string]float64)
+	matched := make(map[string]int)
+
+	for _, term := range terms {
+		prefix := textTermPrefix(collection, term)
+
+		type posting struct {
+			key      string
+			tf, dlen uint64
+		}
+		var postings []posting
+
+		iter := snap.NewIterator(bytesPrefix(prefix))
+		for iter.Next() {
+			tf, n := binary.Uvarint(iter.Value())
+			if n <= 0 {
+				continue
+			}
+			dlen, _ := binary.Uvarint(iter.Value()[n:])
+			postings = append(postings, posting{key: string(iter.Key()[len(prefix):]), tf: tf, dlen: dlen})
+		}
+		iter.Release()
+		if err := iter.Error(); err != nil {
+			return nil, 0, fmt.Errorf("iterator error: %w", err)
+		}
+
+		df := float64(len(postings))
+		idf := math.Log(1 + (float64(stats.docs)-df+0.5)/(df+0.5))
+		for _, p := range postings {
+			tf := float64(p.tf)
+			norm := bm25K1 * (1 - bm25B + bm25B*float64(p.dlen)/avgLen)
+			scores[p.key] += idf * tf * (bm25K1 + 1) / (tf + norm)
+			matched[p.key]++
+		}
+	}
+
+	hits := make([]SearchHit, 0, len(scores))
+	for key, score := range scores {
+		if opts.MatchAll && matched[key] < len(terms) {
+			continue
+		}
+		hits = append(hits, SearchHit{Key: key, Score: score})
+	}
+	sort.Slice(hits, func(i, j int) bool {
+		if hits[i].Score != hits[j].Score {
+			return hits[i].Score > hits[j].Score
+		}
+		return hits[i].Key < hits[j].Key
+	})
+
+	total := len(hits)
+	if opts.Limit > 0 && len(hits) > opts.Limit {
+		hits = hits[:opts.Limit]
+	}
+
+	termSet := make(map[string]bool, len(terms))
+	for _, t := range terms {
+		termSet[t] = true
+	}
+
+	for i := range hits {
+		data, err := snap.Get([]byte(s.makeKey(collection, hits[i].Key)))
+		if err != nil {
+			continue
+		}
+		value, err := s.readValue(data)
+		if err != nil {
+			continue
+		}
+		hits[i].Value = value
+		hits[i].Highlights = make(map[string]string)
+		for _, field := range info.Fields {
+			for _, text := range textValues(value, []string{field}) {
+				if fragment, ok := search.Highlight(text, termSet); ok {
+					hits[i].Highlights[field] = fragment
+					break
+				}
+			}
+		}
+	}
+
+	return hits, total, nil
+}
+
+// equalStrings reports whether two string slices are equal
+func equalStrings(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
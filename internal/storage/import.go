@@ -0,0 +1,119 @@
+package storage
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+)
+
+// Conflict policies for imports, applied when an imported key already exists
+const (
+	ConflictOverwrite = "overwrite" // replace the existing value
+	ConflictSkip      = "skip"      // keep the existing value
+	ConflictFail      = "fail"      // stop the import
+)
+
+var (
+	// ErrInvalidConflictPolicy is returned for unknown conflict policies
+	ErrInvalidConflictPolicy = errors.New("conflict policy must be overwrite, skip or fail")
+	// ErrKeyExists is returned by imports with the fail policy when a key
+	// already exists
+	ErrKeyExists = errors.New("key already exists")
+)
+
+// ImportItem is a key and its serialized JSON value
+type ImportItem struct {
+	Key   string
+	Value []byte
+}
+
+// ImportResult counts what an import batch did
+type ImportResult struct {
+	Imported int
+	Skipped  int
+}
+
+// ValidConflictPolicy reports whether policy is a known conflict policy
+func ValidConflictPolicy(policy string) bool {
+	switch policy {
+	case ConflictOverwrite, ConflictSkip, ConflictFail:
+		return true
+	}
+	return false
+}
+
+// Import writes a batch of items into a collection using a single 2PC
+// round through Bulk. Keys that already exist, including keys repeated
+// within items, are handled according to onConflict; with ConflictFail
+// nothing in the batch is written. The result counts what was written even
+// when an error is returned.
+func (s *ReplicatedStore) Import(collection string, items []ImportItem, onConflict string) (ImportResult, error) {
+	if s.config.IsSlave() {
+		return ImportResult{}, fmt.Errorf("writes not allowed on slave nodes, send request to master")
+	}
+	if !ValidConflictPolicy(onConflict) {
+		return ImportResult{}, ErrInvalidConflictPolicy
+	}
+
+	// Checked up front so that an invalid item fails the whole batch,
+	// where Bulk would only skip it
+	ops := make([]BulkOp, len(items))
+	for i, item := range items {
+		if item.Key == "" {
+			return ImportResult{}, ErrInvalidKey
+		}
+		if !json.Valid(item.Value) {
+			return ImportResult{}, fmt.Errorf("invalid value for key %q", item.Key)
+		}
+		if err := s.checkValue(collection, item.Value); err != nil {
+			return ImportResult{}, fmt.Errorf("key %q: %w", item.Key, err)
+		}
+		ops[i] = BulkOp{Op: OpPut, Collection: collection, Key: item.Key, Value: json.RawMessage(item.Value)}
+	}
+
+	unlock := s.locks.lockBulk(ops)
+	defer unlock()
+
+	var result ImportResult
+	if onConflict != ConflictOverwrite {
+		kept := ops[:0]
+		seen := make(map[string]bool, len(ops))
+		for _, op := range ops {
+			exists := seen[op.Key]
+			if !exists {
+				var err error
+				if exists, err = s.store.Has(collection, op.Key); err != nil {
+					return ImportResult{}, err
+				}
+			}
+			if exists {
+				if onConflict == ConflictFail {
+					return ImportResult{}, fmt.Errorf("%w: %s", ErrKeyExists, op.Key)
+				}
+				result.Skipped++
+				continue
+			}
+			seen[op.Key] = true
+			kept = append(kept, op)
+		}
+		ops = kept
+	}
+	if len(ops) == 0 {
+		return result, nil
+	}
+
+	results, err := s.bulk(ops)
+	if err != nil {
+		return ImportResult{}, err
+	}
+	// Only sealing can fail here, and the other items are written anyway
+	var failed error
+	for i, err := range results {
+		if err == nil {
+			result.Imported++
+		} else if failed == nil {
+			failed = fmt.Errorf("key %q: %w", ops[i].Key, err)
+		}
+	}
+	return result, failed
+}
@@ -0,0 +1,475 @@
+package storage
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+	"time"
+
+	"kiwi/internal/document"
+)
+
+var (
+	// ErrIndexExists is returned when creating an index that already exists
+	ErrIndexExists = errors.New("index already exists")
+
+	// ErrIndexNotFound is returned when an index does not exist
+	ErrIndexNotFound = errors.New("index not found")
+
+	// ErrInvalidField is returned for an empty or malformed field path
+	ErrInvalidField = errors.New("invalid field path")
+)
+
+// indexBuildBatchSize is the number of entries written per batch while
+// building an index over existing data
+const indexBuildBatchSize = 1000
+
+// Type tags of encoded index values; they define the cross-type sort order
+const (
+	indexTagNull   byte = 0x01
+	indexTagBool   byte = 0x02
+	indexTagNumber byte = 0x03
+	indexTagString byte = 0x04
+)
+
+// IndexInfo describes a secondary index on a JSON field
+type IndexInfo struct {
+	Collection string    `json:"collection"`
+	Field      string    `json:"field"`
+	CreatedAt  time.Time `json:"created_at"`
+}
+
+// IndexBound is one end of an index range
+type IndexBound struct {
+	Value     interface{}
+	Inclusive bool
+}
+
+// IndexQuery selects documents by the value of an indexed field.
+// A nil bound leaves that side of the range open; an exact match
+// uses the same inclusive bound on both sides.
+type IndexQuery struct {
+	Field string
+	Lower *IndexBound
+	Upper *IndexBound
+	Limit int // 0 = no limit
+}
+
+// KeyValue is a single document returned by a query
+type KeyValue struct {
+	Key   string
+	Value interface{}
+}
+
+// Equal builds an IndexQuery for an exact match on field
+func Equal(field string, value interface{}) IndexQuery {
+	bound := &IndexBound{Value: value, Inclusive: true}
+	return IndexQuery{Field: field, Lower: bound, Upper: bound}
+}
+
+// validateField checks that a field path can be used in index keys
+func validateField(field string) error {
+	if field == "" || strings.IndexByte(field, 0) >= 0 {
+		return ErrInvalidField
+	}
+	return nil
+}
+
+// indexDefKey returns the key holding an index definition
+func indexDefKey(collection, field string) []byte {
+	return joinKey(indexDefPrefix, []byte(collection), []byte{0}, []byte(field))
+}
+
+// indexPrefix returns the common prefix of all entries of an index
+func indexPrefix(collection, field string) []byte {
+	return joinKey(indexEntryPrefix, []byte(collection), []byte{0}, []byte(field), []byte{0})
+}
+
+// encodeIndexValue encodes a scalar so that byte order matches value order.
+// Returns false for values that cannot be indexed (objects and arrays).
+func encodeIndexValue(value interface{}) ([]byte, bool) {
+	switch v := value.(type) {
+	case nil:
+		return []byte{indexTagNull}, true
+	case bool:
+		if v {
+			return []byte{indexTagBool, 1}, true
+		}
+		return []byte{indexTagBool, 0}, true
+	case float64:
+		if v == 0 {
+			v = 0 // normalize negative zero
+		}
+		bits := math.Float64bits(v)
+		if v >= 0 {
+			bits ^= 1 << 63
+		} else {
+			bits = ^bits
+		}
+		out := make([]byte, 9)
+		out[0] = indexTagNumber
+		binary.BigEndian.PutUint64(out[1:], bits)
+		return out, true
+	case string:
+		// Escape 0x00 as 0x00 0xFF and terminate with 0x00 0x01 so that
+		// encoded strings are self-delimiting and keep their order
+		out := make([]byte, 0, len(v)+3)
+		out = append(out, indexTagString)
+		for i := 0; i < len(v); i++ {
+			if v[i] == 0 {
+				out = append(out, 0, 0xFF)
+			} else {
+				out = append(out, v[i])
+			}
+		}
+		return append(out, 0, 1), true
+	default:
+		return nil, false
+	}
+}
+
+// indexEntries returns the index keys for one field of a stored value.
+// Arrays produce one entry per scalar element.
+func indexEntries(collection, key, field string, data []byte) [][]byte {
+	if data == nil {
+		return nil
+	}
+	doc, err := decodeValue(data)
+	if err != nil {
+		return nil
+	}
+	value, ok := document.Get(doc, field)
+	if !ok {
+		return nil
+	}
+
+	values := []interface{}{value}
+	if arr, isArr := value.([]interface{}); isArr {
+		values = arr
+	}
+
+	prefix := indexPrefix(collection, field)
+	var entries [][]byte
+	for _, v := range values {
+		if enc, ok := encodeIndexValue(v); ok {
+			entries = append(entries, joinKey(prefix, enc, []byte(key)))
+		}
+	}
+	return entries
+}
+
+// hasEntry reports whether entries holds entry
+func hasEntry(entries [][]byte, entry []byte) bool {
+	for _, e := range entries {
+		if bytes.Equal(e, entry) {
+			return true
+		}
+	}
+	return false
+}
+
+// indexValue adds index entries for data to the batch
+func (s *LocalStore) indexValue(batch *WriteBatch, collection, key string, fields []string, data []byte) {
+	for _, field := range fields {
+		for _, entry := range indexEntries(collection, key, field, data) {
+			batch.Put(entry, []byte(key))
+		}
+	}
+}
+
+// unindexValue removes the index entries of data from the batch
+func (s *LocalStore) unindexValue(batch *WriteBatch, collection, key string, fields []string, data []byte) {
+	for _, field := range fields {
+		for _, entry := range indexEntries(collection, key, field, data) {
+			batch.Delete(entry)
+		}
+	}
+}
+
+// indexedFields returns the indexed fields of a collection
+func (s *LocalStore) indexedFields(collection string) []string {
+	s.indexMu.RLock()
+	defer s.indexMu.RUnlock()
+	return s.indexes[collection]
+}
+
+// loadIndexes reads index definitions into memory
+func (s *LocalStore) loadIndexes() error {
+	infos, err := s.ListIndexes("")
+	if err != nil {
+		return err
+	}
+
+	s.indexMu.Lock()
+	defer s.indexMu.Unlock()
+	s.indexes = make(map[string][]string)
+	for _, info := range infos {
+		s.indexes[info.Collection] = append(s.indexes[info.Collection], info.Field)
+	}
+	return nil
+}
+
+// HasIndex reports whether field is indexed in collection
+func (s *LocalStore) HasIndex(collection, field string) bool {
+	for _, f := range s.indexedFields(collection) {
+		if f == field {
+			return true
+		}
+	}
+	return false
+}
+
+// CreateIndex creates a secondary index and builds it over existing data.
+// Writes are blocked while the index is built. Creating an index that
+// already exists is a no-op, so replicated creates are idempotent.
+func (s *LocalStore) CreateIndex(collection, field string) error {
+	if err := validateField(field); err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.HasIndex(collection, field) {
+		return nil
+	}
+
+	// Clear entries left by an interrupted build or drop of the same index
+	if err := s.deleteRange(bytesPrefix(indexPrefix(collection, field))); err != nil {
+		return err
+	}
+
+	// Build entries for existing documents
+	prefix := collectionKeyPrefix(collection)
+	iter := s.db.NewIterator(bytesPrefix(prefix))
+	batch := new(WriteBatch)
+	for iter.Next() {
+		key := string(iter.Key()[len(prefix):])
+		if value, err := s.openValue(iter.Value()); err == nil {
+			s.indexValue(batch, collection, key, []string{field}, value)
+		}
+		if batch.Len() >= indexBuildBatchSize {
+			if err := s.db.Write(batch); err != nil {
+				iter.Release()
+				return fmt.Errorf("failed to build index: %w", err)
+			}
+			batch.Reset()
+		}
+	}
+	iter.Release()
+	if err := iter.Error(); err != nil {
+		return fmt.Errorf("iterator error: %w", err)
+	}
+
+	// Publish the definition last so a crash mid-build leaves no index
+	info, err := json.Marshal(IndexInfo{Collection: collection, Field: field, CreatedAt: time.Now().UTC()})
+	if err != nil {
+		return fmt.Errorf("failed to serialize index: %w", err)
+	}
+	batch.Put(indexDefKey(collection, field), info)
+	if err := s.db.Write(batch); err != nil {
+		return fmt.Errorf("failed to build index: %w", err)
+	}
+
+	s.indexMu.Lock()
+	s.indexes[collection] = append(append([]string(nil), s.indexes[collection]...), field)
+	s.indexMu.Unlock()
+
+	return nil
+}
+
+// DropIndex removes a secondary index and all of its entries.
+// Dropping a missing index is a no-op.
+func (s *LocalStore) DropIndex(collection, field string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.indexMu.Lock()
+	fields := make([]string, 0, len(s.indexes[collection]))
+	for _, f := range s.indexes[collection] {
+		if f != field {
+			fields = append(fields, f)
+		}
+	}
+	s.indexes[collection] = fields
+	s.indexMu.Unlock()
+
+	if err := s.db.Delete(indexDefKey(collection, field)); err != nil {
+		return fmt.Errorf("failed to delete index: %w", err)
+	}
+
+	return s.deleteRange(bytesPrefix(indexPrefix(collection, field)))
+}
+
+// deleteRange deletes every key in r in bounded batches
+func (s *LocalStore) deleteRange(r *KeyRange) error {
+	iter := s.db.NewIterator(r)
+	defer iter.Release()
+
+	batch := new(WriteBatch)
+	for iter.Next() {
+		batch.Delete(append([]byte(nil), iter.Key()...))
+		if batch.Len() >= indexBuildBatchSize {
+			if err := s.db.Write(batch); err != nil {
+				return fmt.Errorf("failed to delete range: %w", err)
+			}
+			batch.Reset()
+		}
+	}
+	if err := iter.Error(); err != nil {
+		return fmt.Errorf("iterator error: %w", err)
+	}
+	if batch.Len() > 0 {
+		if err := s.db.Write(batch); err != nil {
+			return fmt.Errorf("failed to delete range: %w", err)
+		}
+	}
+	return nil
+}
+
+// ListIndexes returns index definitions for a collection, or all when collection is empty
+func (s *LocalStore) ListIndexes(collection string) ([]IndexInfo, error) {
+	prefix := indexDefPrefix
+	if collection != "" {
+		prefix = joinKey(indexDefPrefix, []byte(collection), []byte{0})
+	}
+
+	iter := s.db.NewIterator(bytesPrefix(prefix))
+	defer iter.Release()
+
+	result := make([]IndexInfo, 0)
+	for iter.Next() {
+		var info IndexInfo
+		if err := json.Unmarshal(iter.Value(), &info); err != nil {
+			continue
+		}
+		result = append(result, info)
+	}
+	if err := iter.Error(); err != nil {
+		return nil, fmt.Errorf("iterator error: %w", err)
+	}
+
+	sort.Slice(result, func(i, j int) bool {
+		if result[i].Collection != result[j].Collection {
+			return result[i].Collection < result[j].Collection
+		}
+		return result[i].Field < result[j].Field
+	})
+	return result, nil
+}
+
+// indexRange converts query bounds into a key range
+func indexRange(prefix []byte, q IndexQuery) (*KeyRange, error) {
+	encode := func(b *IndexBound) ([]byte, error) {
+		enc, ok := encodeIndexValue(b.Value)
+		if !ok {
+			return nil, fmt.Errorf("%w: only scalar values can be queried", ErrInvalidField)
+		}
+		return enc, nil
+	}
+
+	r := bytesPrefix(prefix)
+
+	if q.Lower != nil {
+		enc, err := encode(q.Lower)
+		if err != nil {
+			return nil, err
+		}
+		if q.Lower.Inclusive {
+			r.Start = joinKey(prefix, enc)
+		} else {
+			r.Start = bytesPrefix(joinKey(prefix, enc)).Limit
+		}
+		if q.Upper == nil {
+			// Open upper bound stays within the lower bound's type
+			r.Limit = joinKey(prefix, []byte{enc[0] + 1})
+		}
+	}
+
+	if q.Upper != nil {
+		enc, err := encode(q.Upper)
+		if err != nil {
+			return nil, err
+		}
+		if q.Upper.Inclusive {
+			r.Limit = bytesPrefix(joinKey(prefix, enc)).Limit
+		} else {
+			r.Limit = joinKey(prefix, enc)
+		}
+		if q.Lower == nil {
+			r.Start = joinKey(prefix, enc[:1])
+		}
+	}
+
+	return r, nil
+}
+
+// QueryIndex returns documents whose indexed field matches q, in index order.
+// Index entries and documents are read from the same snapshot.
+func (s *LocalStore) QueryIndex(collection string, q IndexQuery) ([]KeyValue, error) {
+	if !s.HasIndex(collection, q.Field) {
+		return nil, ErrIndexNotFound
+	}
+
+	r, err := indexRange(indexPrefix(collection, q.Field), q)
+	if err != nil {
+		return nil, err
+	}
+	if bytes.Compare(r.Start, r.Limit) >= 0 {
+		return []KeyValue{}, nil
+	}
+
+	snap, err := s.db.GetSnapshot()
+	if err != nil {
+		return nil, fmt.Errorf("failed to acquire snapshot: %w", err)
+	}
+	defer snap.Release()
+
+	iter := snap.NewIterator(r)
+	defer iter.Release()
+
+	result := make([]KeyValue, 0)
+	seen := make(map[string]bool)
+	for iter.Next() {
+		key := string(iter.Value())
+		if seen[key] {
+			continue
+		}
+
+		data, err := snap.Get([]byte(s.makeKey(collection, key)))
+		if err != nil {
+			if err == ErrNotFound {
+				continue
+			}
+			return nil, fmt.Errorf("failed to retrieve value: %w", err)
+		}
+		open, err := s.openValue(data)
+		if err != nil {
+			continue
+		}
+		// Entries left by a crash may no longer match the document
+		if !hasEntry(indexEntries(collection, key, q.Field, open), iter.Key()) {
+			continue
+		}
+		value, err := decodeValue(open)
+		if err != nil {
+			continue
+		}
+		seen[key] = true
+
+		result = append(result, KeyValue{Key: key, Value: value})
+		if q.Limit > 0 && len(result) >= q.Limit {
+			break
+		}
+	}
+	if err := iter.Error(); err != nil {
+		return nil, fmt.Errorf("iterator error: %w", err)
+	}
+
+	return result, nil
+}
@@ -0,0 +1,117 @@
+package storage
+
+import "encoding/binary"
+
+// Keys starting with reservedPrefix belong to kiwi itself and are never
+// returned as user data. Since the prefix byte sorts before dataKeyPrefix,
+// the whole reserved keyspace sits at the start of the database and can be
+// skipped with a single seek.
+//
+// User data follows the reserved keyspace. Each value is stored under
+// dataKeyPrefix, the length of its collection name as a uvarint, the name
+// and then the key. The length makes the encoding unambiguous: no two
+// collection/key pairs share a data key, and the keys of one collection
+// are exactly those starting with collectionKeyPrefix.
+//
+// Layout:
+//
+//	\x01<len(collection)><collection><key>              -> value (JSON, raw bytes, blob manifest, compressed or encrypted value), length as uvarint
+//	\x00meta\x00format                                 -> on-disk format version (uint32)
+//	\x00idxdef\x00<collection>\x00<field>               -> IndexInfo (JSON)
+//	\x00idx\x00<collection>\x00<field>\x00<value><key>  -> key
+//	\x00ftsdef\x00<collection>                          -> TextIndexInfo (JSON)
+//	\x00fts\x00<collection>\x00<term>\x00<key>          -> term frequency, doc length (uvarints)
+//	\x00ftsstat\x00<collection>                         -> indexed docs, total tokens (2x uint64)
+//	\x00count\x00<collection>                           -> key count (uint64)
+//	\x00meta\x00counts                                  -> marker: counts initialized
+//	\x00meta\x00seq                                     -> last change feed sequence (uint64)
+//	\x00log\x00<seq>                                     -> change event (JSON), seq as big-endian uint64
+//	\x00logoff\x00<collection>\x00<consumer>             -> ConsumerOffset (JSON)
+//	\x00hook\x00<id>                                     -> Webhook (JSON)
+//	\x00hookdlq\x00<id>\x00<seq>                         -> DeadLetter (JSON), seq as big-endian uint64
+//	\x00meta\x00hookseq                                 -> last change handed to webhooks (uint64)
+//	\x00meta\x00backupseq                               -> last change included in a backup (uint64)
+//	\x00colmeta\x00<collection>                         -> CollectionMeta (JSON)
+//	\x00schemav\x00<collection>\x00<version>             -> SchemaVersion (JSON), version as big-endian uint32
+//	\x00exp\x00<collection>\x00<key>                    -> expiry of a key (uint64 unix nanoseconds)
+//	\x00ttl\x00<expiry><collection>\x00<key>            -> empty, expiry as big-endian uint64
+//	\x00blob\x00<collection>\x00<blob id><part><chunk>   -> chunk bytes, part and chunk as big-endian uint32
+//	\x00upload\x00<blob id>                             -> Upload (JSON)
+//	\x00uppart\x00<blob id><part>                       -> BlobPart (JSON), part as big-endian uint32
+//	\x00dek\x00<key id>                                 -> DataKey (JSON), wrapped by the master key
+//	\x00rekey\x00<collection>                           -> marker: collection is being re-encrypted
+//	\x00clear\x00<collection>                           -> marker: collection is being truncated or dropped
+//	\x00rename\x00<collection>                          -> marker: collection is being renamed, to the value
+const reservedPrefix byte = 0x00
+
+// dataKeyPrefix starts every data key
+const dataKeyPrefix byte = 0x01
+
+var (
+	indexDefPrefix       = []byte("\x00idxdef\x00")
+	indexEntryPrefix     = []byte("\x00idx\x00")
+	textDefPrefix        = []byte("\x00ftsdef\x00")
+	textPostingPrefix    = []byte("\x00fts\x00")
+	textStatsPrefix      = []byte("\x00ftsstat\x00")
+	countPrefix          = []byte("\x00count\x00")
+	countsReadyKey       = []byte("\x00meta\x00counts")
+	feedSeqKey           = []byte("\x00meta\x00seq")
+	logEntryPrefix       = []byte("\x00log\x00")
+	consumerOffsetPrefix = []byte("\x00logoff\x00")
+	webhookPrefix        = []byte("\x00hook\x00")
+	deadLetterPrefix     = []byte("\x00hookdlq\x00")
+	webhookCursorKey     = []byte("\x00meta\x00hookseq")
+	lastBackupKey        = []byte("\x00meta\x00backupseq")
+	collectionMetaPrefix = []byte("\x00colmeta\x00")
+	schemaVersionPrefix  = []byte("\x00schemav\x00")
+	expiryPrefix         = []byte("\x00exp\x00")
+	expiryQueuePrefix    = []byte("\x00ttl\x00")
+	formatVersionKey     = []byte("\x00meta\x00format")
+	blobChunkPrefix      = []byte("\x00blob\x00")
+	uploadPrefix         = []byte("\x00upload\x00")
+	uploadPartPrefix     = []byte("\x00uppart\x00")
+	dekPrefix            = []byte("\x00dek\x00")
+	rekeyPrefix          = []byte("\x00rekey\x00")
+	clearPrefix          = []byte("\x00clear\x00")
+	renamePrefix         = []byte("\x00rename\x00")
+)
+
+// dataKey returns the key holding the value of a key in a collection
+func dataKey(collection, key string) []byte {
+	return append(collectionKeyPrefix(collection), key...)
+}
+
+// collectionKeyPrefix returns the prefix shared by every data key of a
+// collection and by no other key
+func collectionKeyPrefix(collection string) []byte {
+	out := make([]byte, 0, 1+binary.MaxVarintLen64+len(collection))
+	out = append(out, dataKeyPrefix)
+	out = binary.AppendUvarint(out, uint64(len(collection)))
+	return append(out, collection...)
+}
+
+// parseDataKey splits a data key into its collection and key
+func parseDataKey(k []byte) (collection, key string, ok bool) {
+	if len(k) == 0 || k[0] != dataKeyPrefix {
+		return "", "", false
+	}
+	n, size := binary.Uvarint(k[1:])
+	if size <= 0 || uint64(len(k)-1-size) < n {
+		return "", "", false
+	}
+	rest := k[1+size:]
+	return string(rest[:n]), string(rest[n:]), true
+}
+
+// joinKey concatenates key parts into a fresh byte slice
+func joinKey(parts ...[]byte) []byte {
+	n := 0
+	for _, p := range parts {
+		n += len(p)
+	}
+	out := make([]byte, 0, n)
+	for _, p := range parts {
+		out = append(out, p...)
+	}
+	return out
+}
@@ -0,0 +1,631 @@
+package storage
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"sync"
+	"time"
+
+	"kiwi/internal/replication"
+	"kiwi/internal/watch"
+)
+
+// LocalStore implements the Store interface on a storage engine
+type LocalStore struct {
+	db     Engine
+	format int // on-disk format, only other than FormatVersion in backups
+
+	// mu serializes writes so secondary index maintenance always
+	// sees the value it is replacing
+	mu sync.Mutex
+
+	indexMu     sync.RWMutex
+	indexes     map[string][]string       // collection -> indexed field paths
+	textIndexes map[string]*TextIndexInfo // collection -> full-text index
+
+	textMu    sync.RWMutex
+	textStats map[string]textStats // collection -> full-text totals
+
+	countMu sync.RWMutex
+	counts  map[string]int64 // collection -> number of keys
+
+	metaMu sync.RWMutex
+	meta   map[string]CollectionMeta // collection -> registered settings
+
+	seq       uint64     // sequence of the last committed change, guarded by mu
+	feed      *watch.Hub // committed changes, for watchers
+	changelog *changelog // durable change log, nil unless enabled; guarded by mu
+
+	keys   *keyring  // encryption keys, nil unless enabled
+	rekeys rekeyJobs // collections being re-encrypted
+
+	cache *readCache // decoded values, nil unless enabled
+	evict *evictor   // memory limit, nil unless enabled
+}
+
+// OpenStore opens a store on the engine registered under engine, with its
+// data at path
+func OpenStore(engine, path string, opts EngineOptions) (*LocalStore, error) {
+	db, err := OpenEngine(engine, path, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	s, err := NewLocalStore(db)
+	if err != nil {
+		db.Close()
+		return nil, err
+	}
+	return s, nil
+}
+
+// NewLocalStore creates a store on an open engine. The store closes the
+// engine when it is closed.
+func NewLocalStore(db Engine) (*LocalStore, error) {
+	if err := checkFormat(db); err != nil {
+		return nil, err
+	}
+
+	s := &LocalStore{db: db, format: FormatVersion}
+	if err := s.load(); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// load reads the indexes, counts, settings and sequence a store keeps in
+// memory
+func (s *LocalStore) load() error {
+	if err := s.loadIndexes(); err != nil {
+		return err
+	}
+	if err := s.loadTextIndexes(); err != nil {
+		return err
+	}
+	if err := s.loadCounts(); err != nil {
+		return err
+	}
+	if err := s.loadCollectionMeta(); err != nil {
+		return err
+	}
+	return s.loadFeed()
+}
+
+// Close closes the database connection
+func (s *LocalStore) Close() error {
+	s.mu.Lock()
+	if s.changelog != nil {
+		close(s.changelog.stop)
+	}
+	s.mu.Unlock()
+
+	s.stopRekeys()
+	s.feed.Close()
+	return s.db.Close()
+}
+
+// PutDirect stores raw bytes directly (used for replication)
+func (s *LocalStore) PutDirect(collection, key string, value []byte) error {
+	if key == "" {
+		return ErrInvalidKey
+	}
+	return s.write([]writeOp{{collection: collection, key: key, value: value}})
+}
+
+// DeleteDirect deletes a key directly (used for replication)
+func (s *LocalStore) DeleteDirect(collection, key string) error {
+	if key == "" {
+		return ErrInvalidKey
+	}
+	return s.write([]writeOp{{collection: collection, key: key, delete: true}})
+}
+
+// ApplyBatch applies replicated mutations atomically (used for replication)
+func (s *LocalStore) ApplyBatch(mutations []replication.Mutation) error {
+	batch := s.NewBatch()
+	for _, m := range mutations {
+		if m.Key == "" {
+			return ErrInvalidKey
+		}
+		if m.Delete {
+			batch.Delete(m.Collection, m.Key)
+		} else {
+			batch.PutDirect(m.Collection, m.Key, m.Value)
+		}
+	}
+	return batch.Commit()
+}
+
+// makeKey creates the data key of a key in a collection
+func (s *LocalStore) makeKey(collection, key string) string {
+	return string(dataKey(collection, key))
+}
+
+// Put stores a key-value pair in the specified collection
+func (s *LocalStore) Put(collection, key string, value interface{}) error {
+	if key == "" {
+		return ErrInvalidKey
+	}
+
+	// Serialize value to JSON
+	data, err := json.Marshal(value)
+	if err != nil {
+		return fmt.Errorf("failed to serialize value: %w", err)
+	}
+
+	// Store with collection prefix
+	if err := s.write([]writeOp{{collection: collection, key: key, value: data}}); err != nil {
+		return fmt.Errorf("failed to store value: %w", err)
+	}
+
+	return nil
+}
+
+// Get retrieves a value by key from the specified collection
+func (s *LocalStore) Get(collection, key string) (interface{}, error) {
+	if key == "" {
+		return nil, ErrInvalidKey
+	}
+
+	cached := s.cachePolicy(collection) != CacheNone
+	var epoch uint64
+	if cached {
+		if value, ok := s.cache.get(collection, key); ok {
+			s.touchKey(collection, key)
+			return value, nil
+		}
+		epoch = s.cache.epoch(collection, key)
+	}
+
+	dbKey := s.makeKey(collection, key)
+	data, err := s.db.Get([]byte(dbKey))
+	if err != nil {
+		if err == ErrNotFound {
+			return nil, ErrKeyNotFound
+		}
+		return nil, fmt.Errorf("failed to retrieve value: %w", err)
+	}
+	s.touchKey(collection, key)
+
+	if !cached {
+		value, err := s.readValue(data)
+		if err != nil {
+			return nil, fmt.Errorf("failed to deserialize value: %w", err)
+		}
+		return value, nil
+	}
+
+	open, err := s.openValue(data)
+	if err != nil {
+		return nil, fmt.Errorf("failed to deserialize value: %w", err)
+	}
+	value, err := decodeValue(open)
+	if err != nil {
+		return nil, fmt.Errorf("failed to deserialize value: %w", err)
+	}
+	s.cache.add(collection, key, value, len(open), epoch)
+	return cloneValue(value), nil
+}
+
+// GetExact retrieves a value like Get, with JSON numbers decoded as
+// json.Number, so integers beyond 2^53 keep every digit. It bypasses the
+// read cache, which holds values with float64 numbers.
+func (s *LocalStore) GetExact(collection, key string) (interface{}, error) {
+	if key == "" {
+		return nil, ErrInvalidKey
+	}
+
+	data, err := s.db.Get([]byte(s.makeKey(collection, key)))
+	if err != nil {
+		if err == ErrNotFound {
+			return nil, ErrKeyNotFound
+		}
+		return nil, fmt.Errorf("failed to retrieve value: %w", err)
+	}
+	s.touchKey(collection, key)
+
+	open, err := s.openValue(data)
+	if err != nil {
+		return nil, fmt.Errorf("failed to deserialize value: %w", err)
+	}
+	if _, ok, _ := decodeBlob(open); ok {
+		return decodeValue(open)
+	}
+	if _, ok, _ := decodeRaw(open); ok {
+		return decodeValue(open)
+	}
+
+	dec := json.NewDecoder(bytes.NewReader(open))
+	dec.UseNumber()
+	var value interface{}
+	if err := dec.Decode(&value); err != nil {
+		return nil, fmt.Errorf("failed to deserialize value: %w", err)
+	}
+	return value, nil
+}
+
+// decodeValue deserializes a stored value. Raw values are returned as a
+// *RawValue that owns its bytes, blobs as their *BlobManifest.
+func decodeValue(data []byte) (interface{}, error) {
+	data, err := decompressValue(data)
+	if err != nil {
+		return nil, err
+	}
+	if m, ok, err := decodeBlob(data); ok {
+		if err != nil {
+			return nil, err
+		}
+		return &m, nil
+	}
+	if raw, ok, err := decodeRaw(data); ok {
+		if err != nil {
+			return nil, err
+		}
+		raw.Data = append([]byte(nil), raw.Data...)
+		return &raw, nil
+	}
+
+	var value interface{}
+	if err := json.Unmarshal(data, &value); err != nil {
+		return nil, err
+	}
+	return value, nil
+}
+
+// GetMany retrieves several keys from a single consistent snapshot.
+// Missing keys are omitted from the result.
+func (s *LocalStore) GetMany(collection string, keys []string) (map[string]interface{}, error) {
+	snap, err := s.db.GetSnapshot()
+	if err != nil {
+		return nil, fmt.Errorf("failed to acquire snapshot: %w", err)
+	}
+	defer snap.Release()
+
+	result := make(map[string]interface{}, len(keys))
+	for _, key := range keys {
+		if key == "" {
+			continue
+		}
+
+		data, err := snap.Get([]byte(s.makeKey(collection, key)))
+		if err != nil {
+			if err == ErrNotFound {
+				continue
+			}
+			return nil, fmt.Errorf("failed to retrieve value: %w", err)
+		}
+
+		value, err := s.readValue(data)
+		if err != nil {
+			return nil, fmt.Errorf("failed to deserialize value: %w", err)
+		}
+		result[key] = value
+	}
+
+	return result, nil
+}
+
+// Has reports whether a key exists in the specified collection
+func (s *LocalStore) Has(collection, key string) (bool, error) {
+	if key == "" {
+		return false, ErrInvalidKey
+	}
+	return s.db.Has([]byte(s.makeKey(collection, key)))
+}
+
+// Delete removes a key from the specified collection
+func (s *LocalStore) Delete(collection, key string) error {
+	if key == "" {
+		return ErrInvalidKey
+	}
+
+	dbKey := s.makeKey(collection, key)
+
+	// Check if key exists
+	_, err := s.db.Get([]byte(dbKey))
+	if err != nil {
+		if err == ErrNotFound {
+			return ErrKeyNotFound
+		}
+		return fmt.Errorf("failed to check key existence: %w", err)
+	}
+
+	// Delete the key
+	if err := s.write([]writeOp{{collection: collection, key: key, delete: true}}); err != nil {
+		return fmt.Errorf("failed to delete key: %w", err)
+	}
+
+	return nil
+}
+
+// List returns all key-value pairs in the specified collection
+func (s *LocalStore) List(collection string) (map[string]interface{}, error) {
+	result := make(map[string]interface{})
+
+	// Create prefix for the collection
+	prefix := collectionKeyPrefix(collection)
+
+	// Create iterator for the collection prefix
+	iter := s.db.NewIterator(bytesPrefix(prefix))
+	defer iter.Release()
+
+	// Iterate through all keys with the prefix
+	for iter.Next() {
+		value := iter.Value()
+
+		// Remove collection prefix from key
+		actualKey := string(iter.Key()[len(prefix):])
+
+		// Deserialize value
+		val, err := s.readValue(value)
+		if err != nil {
+			// Skip malformed entries
+			continue
+		}
+
+		result[actualKey] = val
+	}
+
+	if err := iter.Error(); err != nil {
+		return nil, fmt.Errorf("iterator error: %w", err)
+	}
+
+	return result, nil
+}
+
+// Scan calls fn for every document in a collection in key order, reading
+// from a consistent snapshot, until fn returns false
+func (s *LocalStore) Scan(collection string, fn func(key string, value interface{}) bool) error {
+	snap, err := s.db.GetSnapshot()
+	if err != nil {
+		return fmt.Errorf("failed to acquire snapshot: %w", err)
+	}
+	defer snap.Release()
+
+	prefix := collectionKeyPrefix(collection)
+	iter := snap.NewIterator(bytesPrefix(prefix))
+	defer iter.Release()
+
+	for iter.Next() {
+		value, err := s.readValue(iter.Value())
+		if err != nil {
+			// Skip malformed entries
+			continue
+		}
+		if !fn(string(iter.Key()[len(prefix):]), value) {
+			break
+		}
+	}
+
+	if err := iter.Error(); err != nil {
+		return fmt.Errorf("iterator error: %w", err)
+	}
+	return nil
+}
+
+// ListCollections returns all available collections
+func (s *LocalStore) ListCollections() ([]string, error) {
+	collections := make(map[string]bool)
+
+	// Skip the reserved keyspace used for internal metadata
+	iter := s.db.NewIterator(bytesPrefix([]byte{dataKeyPrefix}))
+	defer iter.Release()
+
+	for ok := iter.First(); ok; {
+		collection, _, valid := parseDataKey(iter.Key())
+		if !valid {
+			ok = iter.Next()
+			continue
+		}
+		collections[collection] = true
+
+		// Jump past the rest of the collection
+		ok = iter.Seek(collectionPrefix(collection).Limit)
+	}
+
+	if err := iter.Error(); err != nil {
+		return nil, fmt.Errorf("iterator error: %w", err)
+	}
+
+	result := make([]string, 0, len(collections))
+	for collection := range collections {
+		result = append(result, collection)
+	}
+
+	return result, nil
+}
+
+// Count returns the number of keys in a collection.
+// Counts are maintained incrementally by write, so this is O(1).
+func (s *LocalStore) Count(collection string) (int, error) {
+	s.countMu.RLock()
+	defer s.countMu.RUnlock()
+	return int(s.counts[collection]), nil
+}
+
+// writeOp is a single put or delete applied by write
+type writeOp struct {
+	collection string
+	key        string
+	value      []byte
+	delete     bool
+}
+
+// write applies ops atomically in one engine batch, together with the
+// secondary index entries they add or remove. Committed ops are published
+// to the change feed in commit order.
+func (s *LocalStore) write(ops []writeOp) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	batch := new(WriteBatch)
+
+	// State of keys already touched by this batch
+	pending := make(map[string]previousValue)
+	expiries := make(map[string]uint64)
+	now := time.Now()
+
+	// Change in key count and full-text totals per collection
+	deltas := make(map[string]int64)
+	textDeltas := make(map[string]*textStats)
+
+	// Ops with their values decrypted, for the change feed, and their
+	// plain values, for the read cache
+	opened := make([]writeOp, len(ops))
+	values := make([][]byte, len(ops))
+
+	for i, op := range ops {
+		dbKey := s.makeKey(op.collection, op.key)
+		fields := s.indexedFields(op.collection)
+		text := s.textIndex(op.collection)
+
+		prev, seen := pending[dbKey]
+		if !seen {
+			var err error
+			if prev, err = s.readPrevious(dbKey); err != nil {
+				return err
+			}
+		}
+
+		// Values are stored encrypted, while everything else sees them as
+		// written: not encrypted nor compressed
+		var stored, value []byte
+		if !op.delete {
+			var err error
+			if stored, op.value, err = s.sealOp(op); err != nil {
+				return err
+			}
+			if value, err = decompressValue(op.value); err != nil {
+				return err
+			}
+		}
+		opened[i] = op
+		values[i] = value
+
+		if err := s.discardReplacedBlob(batch, op, prev.value); err != nil {
+			return err
+		}
+
+		if len(fields) > 0 {
+			s.unindexValue(batch, op.collection, op.key, fields, prev.value)
+			if !op.delete {
+				s.indexValue(batch, op.collection, op.key, fields, value)
+			}
+		}
+
+		if text != nil {
+			delta, ok := textDeltas[op.collection]
+			if !ok {
+				delta = &textStats{}
+				textDeltas[op.collection] = delta
+			}
+			s.textUnindexValue(batch, text, op.key, prev.value, delta)
+			if !op.delete {
+				s.textIndexValue(batch, text, op.key, value, delta)
+			}
+		}
+
+		if err := s.expireValue(batch, op, expiries, now); err != nil {
+			return err
+		}
+
+		if op.delete {
+			batch.Delete([]byte(dbKey))
+			pending[dbKey] = previousValue{}
+			if prev.exists {
+				deltas[op.collection]--
+			}
+		} else {
+			batch.Put([]byte(dbKey), stored)
+			pending[dbKey] = previousValue{value: value, exists: true}
+			if !prev.exists {
+				deltas[op.collection]++
+			}
+		}
+	}
+
+	counts := s.applyCountDeltas(batch, deltas)
+	stats := s.applyTextDeltas(batch, textDeltas)
+	events := s.feedEvents(batch, opened)
+	if err := s.logEvents(batch, events); err != nil {
+		return err
+	}
+
+	if err := s.db.Write(batch); err != nil {
+		return err
+	}
+
+	s.storeCounts(counts)
+	s.storeTextStats(stats)
+	s.updateCache(ops, values)
+	s.trackWrites(ops)
+	s.seq += uint64(len(events))
+	s.feed.Publish(events)
+	return nil
+}
+
+// previousValue is the state of a key before a write
+type previousValue struct {
+	value  []byte
+	exists bool
+}
+
+// readPrevious returns the current state of a key, decrypted and
+// decompressed. The value is needed for index maintenance and to find the
+// chunks of a replaced blob.
+func (s *LocalStore) readPrevious(dbKey string) (previousValue, error) {
+	value, err := s.db.Get([]byte(dbKey))
+	if err == ErrNotFound {
+		return previousValue{}, nil
+	}
+	if err != nil {
+		return previousValue{}, fmt.Errorf("failed to read previous value: %w", err)
+	}
+	if value, err = s.openValue(value); err != nil {
+		return previousValue{}, fmt.Errorf("failed to read previous value: %w", err)
+	}
+	return previousValue{value: value, exists: true}, nil
+}
+
+// LocalBatch represents a batch of write operations
+type LocalBatch struct {
+	store *LocalStore
+	ops   []writeOp
+}
+
+// NewBatch creates a new batch for atomic writes
+func (s *LocalStore) NewBatch() *LocalBatch {
+	return &LocalBatch{store: s}
+}
+
+// Put adds a put operation to the batch
+func (b *LocalBatch) Put(collection, key string, value interface{}) error {
+	data, err := json.Marshal(value)
+	if err != nil {
+		return fmt.Errorf("failed to serialize value: %w", err)
+	}
+
+	b.PutDirect(collection, key, data)
+	return nil
+}
+
+// PutDirect adds a put operation with already serialized bytes to the batch
+func (b *LocalBatch) PutDirect(collection, key string, value []byte) {
+	b.ops = append(b.ops, writeOp{collection: collection, key: key, value: value})
+}
+
+// Delete adds a delete operation to the batch
+func (b *LocalBatch) Delete(collection, key string) {
+	b.ops = append(b.ops, writeOp{collection: collection, key: key, delete: true})
+}
+
+// Len returns the number of operations in the batch
+func (b *LocalBatch) Len() int {
+	return len(b.ops)
+}
+
+// Commit executes all operations in the batch atomically
+func (b *LocalBatch) Commit() error {
+	return b.store.write(b.ops)
+}
@@ -0,0 +1,69 @@
+package storage
+
+import (
+	"hash/fnv"
+	"sort"
+	"sync"
+)
+
+// lockStripes is the number of mutexes keys are hashed onto
+const lockStripes = 256
+
+// keyLocker serializes read-modify-write cycles on individual keys.
+// Keys are hashed onto a fixed set of mutexes, so unrelated keys may
+// occasionally share a stripe.
+type keyLocker struct {
+	stripes [lockStripes]sync.Mutex
+}
+
+// stripe returns the stripe index for a key
+func (l *keyLocker) stripe(collection, key string) int {
+	h := fnv.New32a()
+	h.Write([]byte(collection))
+	h.Write([]byte{0})
+	h.Write([]byte(key))
+	return int(h.Sum32() % lockStripes)
+}
+
+// lock locks a single key and returns its unlock function
+func (l *keyLocker) lock(collection, key string) func() {
+	mu := &l.stripes[l.stripe(collection, key)]
+	mu.Lock()
+	return mu.Unlock
+}
+
+// lockBulk locks every key touched by ops in a fixed order to avoid deadlocks
+func (l *keyLocker) lockBulk(ops []BulkOp) func() {
+	seen := make(map[int]bool, len(ops))
+	idx := make([]int, 0, len(ops))
+	for _, op := range ops {
+		i := l.stripe(op.Collection, op.Key)
+		if !seen[i] {
+			seen[i] = true
+			idx = append(idx, i)
+		}
+	}
+	sort.Ints(idx)
+
+	for _, i := range idx {
+		l.stripes[i].Lock()
+	}
+	return func() {
+		for j := len(idx) - 1; j >= 0; j-- {
+			l.stripes[idx[j]].Unlock()
+		}
+	}
+}
+
+// lockAll locks every stripe, excluding all key writers, for operations
+// that touch whole collections
+func (l *keyLocker) lockAll() func() {
+	for i := range l.stripes {
+		l.stripes[i].Lock()
+	}
+	return func() {
+		for i := len(l.stripes) - 1; i >= 0; i-- {
+			l.stripes[i].Unlock()
+		}
+	}
+}
@@ -0,0 +1,147 @@
+package storage
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+
+	"kiwi/internal/schema"
+)
+
+// rawValueMarker starts every stored raw value. No JSON document starts
+// with it, so raw and JSON values can share the data keyspace.
+//
+// Layout: \x00 <len(content type) as uvarint> <content type> <bytes>
+const rawValueMarker byte = 0x00
+
+// DefaultContentType is the content type of raw values stored without one
+const DefaultContentType = "application/octet-stream"
+
+// RawValue is a value stored as raw bytes instead of JSON. Wherever values
+// are shown as JSON, a raw value appears as its content type and its bytes
+// in base64.
+type RawValue struct {
+	ContentType string `json:"content_type"`
+	Data        []byte `json:"data"`
+}
+
+// encodeRaw serializes raw bytes and their content type for storage
+func encodeRaw(contentType string, data []byte) []byte {
+	out := make([]byte, 0, 1+binary.MaxVarintLen64+len(contentType)+len(data))
+	out = append(out, rawValueMarker)
+	out = binary.AppendUvarint(out, uint64(len(contentType)))
+	out = append(out, contentType...)
+	return append(out, data...)
+}
+
+// decodeRaw parses a stored raw value; ok is false for JSON values. The
+// result shares memory with data.
+func decodeRaw(data []byte) (raw RawValue, ok bool, err error) {
+	if len(data) == 0 || data[0] != rawValueMarker {
+		return RawValue{}, false, nil
+	}
+	n, size := binary.Uvarint(data[1:])
+	if size <= 0 || uint64(len(data)-1-size) < n {
+		return RawValue{}, true, fmt.Errorf("malformed raw value")
+	}
+	rest := data[1+size:]
+	return RawValue{ContentType: string(rest[:n]), Data: rest[n:]}, true, nil
+}
+
+// valueView is a stored value in JSON form
+type valueView struct {
+	JSON        json.RawMessage
+	ContentType string // set for raw values and blobs
+	Blob        bool   // JSON holds a blob manifest
+}
+
+// jsonValue returns a stored value in JSON form. Raw values become their
+// content type and bytes, blobs their manifest.
+func jsonValue(data []byte) (valueView, error) {
+	data, err := decompressValue(data)
+	if err != nil {
+		return valueView{}, err
+	}
+	if m, ok, err := decodeBlob(data); ok {
+		if err != nil {
+			return valueView{}, err
+		}
+		return valueView{JSON: data[1:], ContentType: m.ContentType, Blob: true}, nil
+	}
+
+	raw, ok, err := decodeRaw(data)
+	if !ok || err != nil {
+		return valueView{JSON: data}, err
+	}
+	view, err := json.Marshal(raw)
+	if err != nil {
+		return valueView{}, fmt.Errorf("failed to serialize raw value: %w", err)
+	}
+	return valueView{JSON: view, ContentType: raw.ContentType}, nil
+}
+
+// JSONValue returns a stored value as JSON; raw values and blobs are
+// converted to their JSON form
+func JSONValue(data []byte) (json.RawMessage, error) {
+	view, err := jsonValue(data)
+	return view.JSON, err
+}
+
+// decodeRawView turns the JSON form of a raw value back into its stored
+// encoding
+func decodeRawView(view []byte) ([]byte, error) {
+	var raw RawValue
+	if err := json.Unmarshal(view, &raw); err != nil {
+		return nil, fmt.Errorf("malformed raw value: %w", err)
+	}
+	return encodeRaw(raw.ContentType, raw.Data), nil
+}
+
+// notDocument returns the violation reported for raw values and blobs in
+// collections with a schema, or nil for JSON documents
+func notDocument(value interface{}) []schema.Violation {
+	switch v := value.(type) {
+	case *RawValue:
+		return []schema.Violation{{Message: fmt.Sprintf("expected a JSON document, got raw %s bytes", v.ContentType)}}
+	case *BlobManifest:
+		return []schema.Violation{{Message: fmt.Sprintf("expected a JSON document, got a %s blob", v.ContentType)}}
+	}
+	return nil
+}
+
+// PutRaw stores raw bytes and their content type using 2PC. The bytes
+// are stored and returned exactly as given.
+func (s *ReplicatedStore) PutRaw(collection, key, contentType string, data []byte) error {
+	if s.config.IsSlave() {
+		return fmt.Errorf("writes not allowed on slave nodes, send request to master")
+	}
+	if key == "" {
+		return ErrInvalidKey
+	}
+	if contentType == "" {
+		contentType = DefaultContentType
+	}
+
+	unlock := s.locks.lock(collection, key)
+	defer unlock()
+
+	value := encodeRaw(contentType, data)
+	if err := s.checkValue(collection, value); err != nil {
+		return err
+	}
+	value, err := s.sealValue(collection, value)
+	if err != nil {
+		return err
+	}
+
+	if s.replicates(collection) {
+		if err := s.manager.ReplicatePut(collection, key, value); err != nil {
+			return fmt.Errorf("replication failed: %w", err)
+		}
+	}
+
+	if err := s.store.PutDirect(collection, key, value); err != nil {
+		return fmt.Errorf("local write failed after replication (inconsistency possible): %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,213 @@
+package storage
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"kiwi/internal/schema"
+)
+
+// Replication policies of a collection
+const (
+	ReplicationSync = "sync" // writes reach every slave through 2PC
+	ReplicationNone = "none" // writes stay on the master
+)
+
+var (
+	// ErrInvalidSettings is returned for unusable collection settings
+	ErrInvalidSettings = errors.New("invalid collection settings")
+	// ErrValueTooLarge is returned for values above a collection's max
+	// value size
+	ErrValueTooLarge = errors.New("value exceeds the max value size of the collection")
+	// ErrReplicationMismatch is returned when copying between collections
+	// with different replication policies
+	ErrReplicationMismatch = errors.New("collections have different replication policies")
+)
+
+// CollectionMeta holds the registered settings of a collection. Collections
+// that were never registered behave as if every setting were at its zero
+// value, with the sync replication policy.
+type CollectionMeta struct {
+	Collection   string          `json:"collection"`
+	CreatedAt    time.Time       `json:"created_at"`
+	Owner        string          `json:"owner,omitempty"`
+	DefaultTTL   time.Duration   `json:"default_ttl,omitempty"`    // 0 = keys never expire
+	MaxValueSize int64           `json:"max_value_size,omitempty"` // bytes of serialized JSON, 0 = no limit
+	Schema       json.RawMessage `json:"schema,omitempty"`         // JSON Schema for values
+	Replication  string          `json:"replication"`
+	Compression  string          `json:"compression,omitempty"` // codec for new values, empty = none
+	Cache        string          `json:"cache,omitempty"`       // read cache policy, empty = read
+
+	// Every schema change, including its removal, is a new version
+	SchemaVersion   int       `json:"schema_version,omitempty"`
+	SchemaUpdatedAt time.Time `json:"schema_updated_at"`
+
+	validator *schema.Schema // compiled Schema, nil without one
+}
+
+// Validate checks that the settings can be applied
+func (m *CollectionMeta) Validate() error {
+	if err := ValidateCollection(m.Collection); err != nil {
+		return err
+	}
+	if m.DefaultTTL < 0 {
+		return fmt.Errorf("%w: default TTL must not be negative", ErrInvalidSettings)
+	}
+	if m.MaxValueSize < 0 {
+		return fmt.Errorf("%w: max value size must not be negative", ErrInvalidSettings)
+	}
+	switch m.Replication {
+	case ReplicationSync, ReplicationNone:
+	default:
+		return fmt.Errorf("%w: replication must be %s or %s", ErrInvalidSettings, ReplicationSync, ReplicationNone)
+	}
+	if !ValidCompression(m.Compression) {
+		return fmt.Errorf("%w: compression must be %s, %s, %s or %s", ErrInvalidSettings,
+			CompressionNone, CompressionSnappy, CompressionZstd, CompressionGzip)
+	}
+	if !ValidCachePolicy(m.Cache) {
+		return fmt.Errorf("%w: cache must be %s, %s or %s", ErrInvalidSettings,
+			CacheRead, CacheWriteThrough, CacheNone)
+	}
+	if err := m.compile(); err != nil {
+		return fmt.Errorf("%w: %v", ErrInvalidSettings, err)
+	}
+	return nil
+}
+
+// compile compiles the schema of the collection, if it has one
+func (m *CollectionMeta) compile() error {
+	m.validator = nil
+	if len(m.Schema) == 0 {
+		return nil
+	}
+	v, err := schema.Compile(m.Schema)
+	if err != nil {
+		return err
+	}
+	m.validator = v
+	return nil
+}
+
+// collectionMetaKey returns the key holding a collection's settings
+func collectionMetaKey(collection string) []byte {
+	return joinKey(collectionMetaPrefix, []byte(collection))
+}
+
+// loadCollectionMeta reads the registry into memory
+func (s *LocalStore) loadCollectionMeta() error {
+	iter := s.db.NewIterator(bytesPrefix(collectionMetaPrefix))
+	defer iter.Release()
+
+	meta := make(map[string]CollectionMeta)
+	for iter.Next() {
+		var m CollectionMeta
+		if err := json.Unmarshal(iter.Value(), &m); err != nil {
+			continue
+		}
+		if err := m.compile(); err != nil {
+			log.Printf("[Registry] ignoring schema of %s: %v", m.Collection, err)
+		}
+		meta[m.Collection] = m
+	}
+	if err := iter.Error(); err != nil {
+		return fmt.Errorf("failed to load collection settings: %w", err)
+	}
+
+	s.metaMu.Lock()
+	s.meta = meta
+	s.metaMu.Unlock()
+	return nil
+}
+
+// CollectionMeta returns the registered settings of a collection
+func (s *LocalStore) CollectionMeta(collection string) (CollectionMeta, bool) {
+	s.metaMu.RLock()
+	defer s.metaMu.RUnlock()
+	m, ok := s.meta[collection]
+	return m, ok
+}
+
+// registeredCollections returns the names of all registered collections
+func (s *LocalStore) registeredCollections() []string {
+	s.metaMu.RLock()
+	defer s.metaMu.RUnlock()
+	names := make([]string, 0, len(s.meta))
+	for name := range s.meta {
+		names = append(names, name)
+	}
+	return names
+}
+
+// collectionExists reports whether a collection has keys or is registered
+func (s *LocalStore) collectionExists(collection string) bool {
+	if n, _ := s.Count(collection); n > 0 {
+		return true
+	}
+	_, ok := s.CollectionMeta(collection)
+	return ok
+}
+
+// SetCollectionMeta registers a collection or replaces its settings. The
+// new settings apply to writes made from now on.
+func (s *LocalStore) SetCollectionMeta(meta CollectionMeta) error {
+	if err := meta.Validate(); err != nil {
+		return err
+	}
+	data, err := json.Marshal(meta)
+	if err != nil {
+		return fmt.Errorf("failed to serialize collection settings: %w", err)
+	}
+	return s.SetCollectionMetaDirect(meta.Collection, data)
+}
+
+// SetCollectionMetaDirect stores serialized collection settings (used for
+// replication). A new schema version is added to the schema history.
+func (s *LocalStore) SetCollectionMetaDirect(collection string, data []byte) error {
+	var meta CollectionMeta
+	if err := json.Unmarshal(data, &meta); err != nil {
+		return fmt.Errorf("failed to deserialize collection settings: %w", err)
+	}
+	collection = strings.Clone(collection) // kept in memory past the request
+	meta.Collection = collection
+	if err := meta.compile(); err != nil {
+		return fmt.Errorf("failed to compile schema: %w", err)
+	}
+
+	// Held so no write sees half of the change
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	batch := new(WriteBatch)
+	batch.Put(collectionMetaKey(collection), data)
+
+	prev, _ := s.CollectionMeta(collection)
+	if meta.SchemaVersion > prev.SchemaVersion {
+		version, err := json.Marshal(SchemaVersion{
+			Version:   meta.SchemaVersion,
+			Schema:    meta.Schema,
+			CreatedAt: meta.SchemaUpdatedAt,
+		})
+		if err != nil {
+			return fmt.Errorf("failed to serialize schema version: %w", err)
+		}
+		batch.Put(schemaVersionKey(collection, meta.SchemaVersion), version)
+	}
+
+	if err := s.db.Write(batch); err != nil {
+		return fmt.Errorf("failed to store collection settings: %w", err)
+	}
+
+	s.metaMu.Lock()
+	s.meta[collection] = meta
+	s.metaMu.Unlock()
+
+	if meta.Cache != prev.Cache {
+		s.invalidateCollection(collection)
+	}
+	return nil
+}
@@ -0,0 +1,328 @@
+package storage
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"log"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// rekeyBatchSize is the number of entries re-encrypted per write. s.mu is
+// held for each batch, so writes to the store wait for at most one batch.
+const rekeyBatchSize = 256
+
+// rekeyJobs tracks the collections being re-encrypted in the background
+type rekeyJobs struct {
+	mu      sync.Mutex
+	running map[string]bool // collection -> a newer key arrived during the pass
+	stop    chan struct{}
+	wg      sync.WaitGroup
+}
+
+// EncryptionStatus describes the encryption of a store
+type EncryptionStatus struct {
+	Enabled     bool
+	Fingerprint string          // of the master key
+	Keys        []DataKey       // wrapped data keys
+	Current     map[string]bool // hex key id -> current key of its collection
+	Rekeying    []string        // collections being re-encrypted
+}
+
+// rekeyKey returns the marker of a collection being re-encrypted
+func rekeyKey(collection string) []byte {
+	return joinKey(rekeyPrefix, []byte(collection))
+}
+
+// startRekey re-encrypts a collection with its current data key in the
+// background. A marker records the job, so it is resumed after a restart.
+func (s *LocalStore) startRekey(collection string) error {
+	// Kept in memory past the request
+	collection = strings.Clone(collection)
+
+	j := &s.rekeys
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	if j.stop == nil {
+		j.stop = make(chan struct{})
+		j.running = make(map[string]bool)
+	}
+	if err := s.db.Put(rekeyKey(collection), nil); err != nil {
+		return fmt.Errorf("failed to start re-encryption: %w", err)
+	}
+	if _, ok := j.running[collection]; ok {
+		// Values passed already were sealed with the older key
+		j.running[collection] = true
+		return nil
+	}
+
+	j.running[collection] = false
+	j.wg.Add(1)
+	go s.rekeyLoop(collection)
+	return nil
+}
+
+// resumeRekeys restarts the re-encryption of collections a restart
+// interrupted
+func (s *LocalStore) resumeRekeys() error {
+	iter := s.db.NewIterator(bytesPrefix(rekeyPrefix))
+	var collections []string
+	for iter.Next() {
+		collections = append(collections, string(iter.Key()[len(rekeyPrefix):]))
+	}
+	iter.Release()
+	if err := iter.Error(); err != nil {
+		return fmt.Errorf("iterator error: %w", err)
+	}
+
+	for _, collection := range collections {
+		if err := s.startRekey(collection); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// rekeyLoop passes over a collection until no newer key arrived during
+// the last pass
+func (s *LocalStore) rekeyLoop(collection string) {
+	j := &s.rekeys
+	defer j.wg.Done()
+
+	for {
+		done, err := s.rekey(collection)
+		if err != nil {
+			log.Printf("[encryption] re-encrypting %s failed: %v", collection, err)
+		}
+
+		j.mu.Lock()
+		if err != nil || !done || !j.running[collection] {
+			if err == nil && done {
+				s.db.Delete(rekeyKey(collection))
+				log.Printf("[encryption] re-encrypted %s", collection)
+			}
+			delete(j.running, collection)
+			j.mu.Unlock()
+			return
+		}
+		j.running[collection] = false
+		j.mu.Unlock()
+	}
+}
+
+// stopRekeys stops the re-encryption jobs and waits for them to return.
+// Their markers stay, so they resume on the next start.
+func (s *LocalStore) stopRekeys() {
+	j := &s.rekeys
+	j.mu.Lock()
+	if j.stop != nil {
+		close(j.stop)
+	}
+	j.mu.Unlock()
+	j.wg.Wait()
+}
+
+// rekey seals every value and blob chunk of a collection that is not
+// sealed with its current data key, including values written before
+// encryption was enabled. The node's own key covers the change log and
+// dead letters instead. It reports false when stopped part way.
+func (s *LocalStore) rekey(collection string) (bool, error) {
+	prefixes := [][]byte{collectionKeyPrefix(collection), blobCollectionPrefix(collection)}
+	if collection == "" {
+		prefixes = [][]byte{logEntryPrefix, deadLetterPrefix}
+	}
+
+	for _, prefix := range prefixes {
+		r := bytesPrefix(prefix)
+		for r != nil {
+			select {
+			case <-s.rekeys.stop:
+				return false, nil
+			default:
+			}
+
+			var err error
+			if r, err = s.rekeyBatch(collection, r); err != nil {
+				return false, err
+			}
+		}
+	}
+	return true, nil
+}
+
+// rekeyBatch re-encrypts up to rekeyBatchSize entries of r and returns the
+// range left, nil when done. Entries are read and written under s.mu, so
+// a value written meanwhile is never overwritten with an older one.
+func (s *LocalStore) rekeyBatch(collection string, r *KeyRange) (*KeyRange, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	id, gcm, ok := s.keys.currentKey(collection)
+	if !ok {
+		return nil, nil
+	}
+
+	iter := s.db.NewIterator(r)
+	defer iter.Release()
+
+	var next *KeyRange
+	batch := new(WriteBatch)
+	for n := 0; iter.Next(); n++ {
+		if n == rekeyBatchSize {
+			next = &KeyRange{Start: append([]byte(nil), iter.Key()...), Limit: r.Limit}
+			break
+		}
+
+		value := iter.Value()
+		if current, ok := sealedWith(value); ok && current == id {
+			continue
+		}
+		plain, err := s.decrypt(value)
+		if err != nil {
+			// Unreadable either way; leave it as it is
+			continue
+		}
+		sealed, err := seal(id, gcm, plain)
+		if err != nil {
+			return nil, err
+		}
+		batch.Put(append([]byte(nil), iter.Key()...), sealed)
+	}
+	if err := iter.Error(); err != nil {
+		return nil, fmt.Errorf("iterator error: %w", err)
+	}
+
+	if err := s.db.Write(batch); err != nil {
+		return nil, fmt.Errorf("failed to re-encrypt values: %w", err)
+	}
+	return next, nil
+}
+
+// EncryptionStatus returns the data keys of the store and the collections
+// being re-encrypted
+func (s *LocalStore) EncryptionStatus() EncryptionStatus {
+	if s.keys == nil {
+		return EncryptionStatus{}
+	}
+
+	st := EncryptionStatus{
+		Enabled:     true,
+		Fingerprint: s.keys.fingerprint,
+		Keys:        s.keys.list(),
+		Current:     make(map[string]bool),
+	}
+	for _, rec := range st.Keys {
+		if id, _, _ := s.keys.currentKey(rec.Collection); hex.EncodeToString([]byte(id)) == rec.ID {
+			st.Current[rec.ID] = true
+		}
+	}
+
+	s.rekeys.mu.Lock()
+	for collection := range s.rekeys.running {
+		st.Rekeying = append(st.Rekeying, collection)
+	}
+	s.rekeys.mu.Unlock()
+	sort.Strings(st.Rekeying)
+	return st
+}
+
+// sealValue compresses and encrypts a serialized value as it is stored and
+// replicated. The first value of a collection creates its data key.
+func (s *ReplicatedStore) sealValue(collection string, data []byte) ([]byte, error) {
+	data, err := s.compress(collection, data)
+	if err != nil {
+		return nil, err
+	}
+	if !s.store.Encrypted() {
+		return data, nil
+	}
+	if err := s.ensureDataKey(collection); err != nil {
+		return nil, err
+	}
+	return s.store.encrypt(collection, data)
+}
+
+// ensureDataKey creates the data key of a collection unless it has one
+func (s *ReplicatedStore) ensureDataKey(collection string) error {
+	if _, _, ok := s.store.keys.currentKey(collection); ok {
+		return nil
+	}
+
+	s.keyMu.Lock()
+	defer s.keyMu.Unlock()
+	if _, _, ok := s.store.keys.currentKey(collection); ok {
+		return nil
+	}
+	_, err := s.rotateDataKey(collection)
+	return err
+}
+
+// rotateDataKey creates a new data key for a collection on every node
+func (s *ReplicatedStore) rotateDataKey(collection string) (DataKey, error) {
+	id, rec, key, err := s.store.keys.generate(collection)
+	if err != nil {
+		return DataKey{}, err
+	}
+	data, err := json.Marshal(rec)
+	if err != nil {
+		return DataKey{}, fmt.Errorf("failed to serialize data key: %w", err)
+	}
+
+	// Keys go to every node, whether their collection replicates or not
+	if s.manager != nil && s.manager.SlaveCount() > 0 {
+		if err := s.manager.ReplicatePutDataKey(data); err != nil {
+			return DataKey{}, fmt.Errorf("replication failed: %w", err)
+		}
+	}
+	if err := s.store.storeDataKey(id, rec, key, true); err != nil {
+		return DataKey{}, err
+	}
+	return rec, nil
+}
+
+// RotateDataKeys creates a new data key for a collection, or for every
+// collection and the key of the change log when collection is empty. Every
+// node re-encrypts the collections with their new keys in the background.
+func (s *ReplicatedStore) RotateDataKeys(collection string) ([]DataKey, error) {
+	if s.config.IsSlave() {
+		return nil, fmt.Errorf("writes not allowed on slave nodes, send request to master")
+	}
+	if !s.store.Encrypted() {
+		return nil, ErrEncryptionDisabled
+	}
+
+	collections := []string{collection}
+	if collection != "" && !s.store.collectionExists(collection) {
+		return nil, ErrCollectionNotFound
+	}
+	if collection == "" {
+		var err error
+		if collections, err = s.store.ListCollections(); err != nil {
+			return nil, err
+		}
+		sort.Strings(collections)
+		collections = append(collections, "")
+	}
+
+	s.keyMu.Lock()
+	defer s.keyMu.Unlock()
+
+	keys := make([]DataKey, 0, len(collections))
+	for _, c := range collections {
+		rec, err := s.rotateDataKey(c)
+		if err != nil {
+			return keys, err
+		}
+		keys = append(keys, rec)
+	}
+	return keys, nil
+}
+
+// EncryptionStatus returns the data keys of the store and the collections
+// being re-encrypted
+func (s *ReplicatedStore) EncryptionStatus() EncryptionStatus {
+	return s.store.EncryptionStatus()
+}
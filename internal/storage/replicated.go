@@ -1,26 +1,44 @@
 package storage
 
 import (
+	"bytes"
 	"encoding/json"
 	"fmt"
+	"math"
+	"strconv"
+	"sync"
+	"time"
 
 	"kiwi/internal/config"
+	"kiwi/internal/document"
 	"kiwi/internal/replication"
+	"kiwi/internal/watch"
 )
 
 // ReplicatedStore wraps a store with replication support using 2PC
 type ReplicatedStore struct {
-	store   *LevelDBStore
+	store   *LocalStore
 	config  *config.Config
 	manager *replication.Manager
+	locks   keyLocker
+
+	// Set while expired keys are being deleted, see StartExpiry
+	stop    chan struct{}
+	stopped chan struct{}
+
+	uploadMu  sync.Mutex
+	uploading map[string]bool // uploads in use by a request
+
+	keyMu sync.Mutex // serializes creating data keys
 }
 
 // NewReplicatedStore creates a new replicated store
-func NewReplicatedStore(store *LevelDBStore, cfg *config.Config, manager *replication.Manager) *ReplicatedStore {
+func NewReplicatedStore(store *LocalStore, cfg *config.Config, manager *replication.Manager) *ReplicatedStore {
 	return &ReplicatedStore{
-		store:   store,
-		config:  cfg,
-		manager: manager,
+		store:     store,
+		config:    cfg,
+		manager:   manager,
+		uploading: make(map[string]bool),
 	}
 }
 
@@ -28,9 +46,11 @@ func NewReplicatedStore(store *LevelDBStore, cfg *config.Config, manager *replic
 //
 // 2PC Flow:
 // 1. Phase 1 (Prepare): Master sends prepare to all slaves
-//    - If ANY slave fails: abort all, return error, master doesn't write
+//   - If ANY slave fails: abort all, return error, master doesn't write
+//
 // 2. Phase 2 (Commit): Master sends commit to all slaves
-//    - All slaves apply the operation
+//   - All slaves apply the operation
+//
 // 3. Master writes locally only after all slaves committed
 //
 // This ensures: either ALL nodes have the data, or NONE do.
@@ -40,15 +60,30 @@ func (s *ReplicatedStore) Put(collection, key string, value interface{}) error {
 		return fmt.Errorf("writes not allowed on slave nodes, send request to master")
 	}
 
+	unlock := s.locks.lock(collection, key)
+	defer unlock()
+
+	return s.put(collection, key, value)
+}
+
+// put replicates and stores a value; the caller must hold the key lock
+func (s *ReplicatedStore) put(collection, key string, value interface{}) error {
 	// Serialize value for replication
 	data, err := json.Marshal(value)
 	if err != nil {
 		return fmt.Errorf("failed to serialize value: %w", err)
 	}
+	if err := s.checkValue(collection, data); err != nil {
+		return err
+	}
+	// Slaves receive and store the compressed and encrypted bytes
+	if data, err = s.sealValue(collection, data); err != nil {
+		return err
+	}
 
 	// Step 1: Replicate to all slaves using 2PC
 	// If this fails, slaves will abort and no data is written anywhere
-	if s.manager != nil && s.manager.SlaveCount() > 0 {
+	if s.replicates(collection) {
 		if err := s.manager.ReplicatePut(collection, key, data); err != nil {
 			// 2PC failed - slaves aborted, don't write to master
 			return fmt.Errorf("replication failed: %w", err)
@@ -56,7 +91,7 @@ func (s *ReplicatedStore) Put(collection, key string, value interface{}) error {
 	}
 
 	// Step 2: Write locally on master (only after slaves committed)
-	if err := s.store.Put(collection, key, value); err != nil {
+	if err := s.store.PutDirect(collection, key, data); err != nil {
 		// This is problematic - slaves committed but master failed
 		// In production, we'd need recovery. For now, log and return error.
 		return fmt.Errorf("local write failed after replication (inconsistency possible): %w", err)
@@ -65,17 +100,277 @@ func (s *ReplicatedStore) Put(collection, key string, value interface{}) error {
 	return nil
 }
 
+// replicates reports whether writes to a collection are sent to slaves
+func (s *ReplicatedStore) replicates(collection string) bool {
+	if s.manager == nil || s.manager.SlaveCount() == 0 {
+		return false
+	}
+	meta, ok := s.store.CollectionMeta(collection)
+	return !ok || meta.Replication != ReplicationNone
+}
+
+// checkValue enforces the max value size and the schema of a collection on
+// a serialized value
+func (s *ReplicatedStore) checkValue(collection string, data []byte) error {
+	meta, ok := s.store.CollectionMeta(collection)
+	if !ok {
+		return nil
+	}
+	// Blobs count with the size of their contents
+	size := int64(len(data))
+	if m, ok, _ := decodeBlob(data); ok {
+		size = m.Size
+	}
+	if meta.MaxValueSize > 0 && size > meta.MaxValueSize {
+		return fmt.Errorf("%w: %d bytes, limit is %d", ErrValueTooLarge, size, meta.MaxValueSize)
+	}
+	return meta.validateSchema(data)
+}
+
 // Get retrieves a value by key (reads allowed on all nodes)
 func (s *ReplicatedStore) Get(collection, key string) (interface{}, error) {
 	return s.store.Get(collection, key)
 }
 
+// GetMany retrieves several keys in one round trip (reads allowed on all nodes)
+func (s *ReplicatedStore) GetMany(collection string, keys []string) (map[string]interface{}, error) {
+	return s.store.GetMany(collection, keys)
+}
+
+// Bulk applies a group of puts and deletes using a single 2PC round.
+//
+// Every operation is validated first; invalid ones are reported in the
+// returned slice (indexed like ops) and skipped. The remaining operations
+// are replicated as one BATCH transaction and then committed locally in a
+// single write batch, so they are applied all together or not at all.
+// The second return value is set when the batch as a whole failed.
+func (s *ReplicatedStore) Bulk(ops []BulkOp) ([]error, error) {
+	if s.config.IsSlave() {
+		return nil, fmt.Errorf("writes not allowed on slave nodes, send request to master")
+	}
+
+	unlock := s.locks.lockBulk(ops)
+	defer unlock()
+
+	return s.bulk(ops)
+}
+
+// bulk is Bulk for callers already holding the locks of ops
+func (s *ReplicatedStore) bulk(ops []BulkOp) ([]error, error) {
+	results := make([]error, len(ops))
+	mutations := make([]replication.Mutation, 0, len(ops))
+	batch := s.store.NewBatch()
+
+	// Tracks key existence as changed by earlier operations in this batch
+	exists := make(map[string]bool)
+
+	for i, op := range ops {
+		if op.Key == "" {
+			results[i] = ErrInvalidKey
+			continue
+		}
+		dbKey := s.store.makeKey(op.Collection, op.Key)
+
+		switch op.Op {
+		case OpPut:
+			data, err := json.Marshal(op.Value)
+			if err != nil {
+				results[i] = fmt.Errorf("failed to serialize value: %w", err)
+				continue
+			}
+			if err := s.checkValue(op.Collection, data); err != nil {
+				results[i] = err
+				continue
+			}
+			if data, err = s.sealValue(op.Collection, data); err != nil {
+				results[i] = err
+				continue
+			}
+			batch.PutDirect(op.Collection, op.Key, data)
+			if s.replicates(op.Collection) {
+				mutations = append(mutations, replication.Mutation{
+					Collection: op.Collection,
+					Key:        op.Key,
+					Value:      data,
+				})
+			}
+			exists[dbKey] = true
+
+		case OpDelete:
+			found, seen := exists[dbKey]
+			if !seen {
+				var err error
+				if found, err = s.store.Has(op.Collection, op.Key); err != nil {
+					results[i] = err
+					continue
+				}
+			}
+			if !found {
+				results[i] = ErrKeyNotFound
+				continue
+			}
+			batch.Delete(op.Collection, op.Key)
+			if s.replicates(op.Collection) {
+				mutations = append(mutations, replication.Mutation{
+					Delete:     true,
+					Collection: op.Collection,
+					Key:        op.Key,
+				})
+			}
+			exists[dbKey] = false
+
+		default:
+			results[i] = ErrInvalidOperation
+		}
+	}
+
+	if batch.Len() == 0 {
+		return results, nil
+	}
+
+	// Step 1: Replicate the whole batch to all slaves in one 2PC round
+	if len(mutations) > 0 {
+		if err := s.manager.ReplicateBatch(mutations); err != nil {
+			return nil, fmt.Errorf("replication failed: %w", err)
+		}
+	}
+
+	// Step 2: Commit locally on master (only after slaves committed)
+	if err := batch.Commit(); err != nil {
+		return nil, fmt.Errorf("local batch write failed after replication (inconsistency possible): %w", err)
+	}
+
+	return results, nil
+}
+
+// Update atomically replaces a value with the result of fn.
+//
+// The current value is read and fn is called while holding the key lock,
+// so concurrent writers to the same key cannot interleave. The new value is
+// replicated with the regular 2PC PUT flow, which keeps slaves deterministic.
+// Returns ErrKeyNotFound if the key does not exist.
+func (s *ReplicatedStore) Update(collection, key string, fn func(current interface{}) (interface{}, error)) (interface{}, error) {
+	return s.Upsert(collection, key, func(current interface{}, exists bool) (interface{}, error) {
+		if !exists {
+			return nil, ErrKeyNotFound
+		}
+		return fn(current)
+	})
+}
+
+// Upsert is like Update but also calls fn for missing keys, with exists set
+// to false. fn gets numbers as json.Number, so integers of any size are
+// written back unchanged.
+func (s *ReplicatedStore) Upsert(collection, key string, fn func(current interface{}, exists bool) (interface{}, error)) (interface{}, error) {
+	if s.config.IsSlave() {
+		return nil, fmt.Errorf("writes not allowed on slave nodes, send request to master")
+	}
+
+	unlock := s.locks.lock(collection, key)
+	defer unlock()
+
+	exists := true
+	current, err := s.store.GetExact(collection, key)
+	if err == ErrKeyNotFound {
+		exists = false
+	} else if err != nil {
+		return nil, err
+	}
+
+	updated, err := fn(current, exists)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := s.put(collection, key, updated); err != nil {
+		return nil, err
+	}
+
+	return updated, nil
+}
+
+// Increment atomically adds delta to a numeric value and returns the new value.
+//
+// If field is empty the whole value must be a number; otherwise field is a
+// dotted path to a number inside a JSON document. Missing keys and fields
+// start from zero. When both numbers are integers they are added as int64,
+// so they keep every digit; otherwise they are added as float64. The
+// resulting value is replicated, not the delta, so slaves always converge
+// to the same number.
+func (s *ReplicatedStore) Increment(collection, key, field string, delta json.Number) (json.Number, error) {
+	if s.config.IsSlave() {
+		return "", fmt.Errorf("writes not allowed on slave nodes, send request to master")
+	}
+
+	unlock := s.locks.lock(collection, key)
+	defer unlock()
+
+	exists := true
+	current, err := s.store.GetExact(collection, key)
+	if err == ErrKeyNotFound {
+		exists = false
+	} else if err != nil {
+		return "", err
+	}
+
+	existing, found := document.Get(current, field)
+	if !exists || !found || existing == nil {
+		existing = json.Number("0")
+	}
+	n, ok := existing.(json.Number)
+	if !ok {
+		return "", ErrNotNumeric
+	}
+	result, err := addNumbers(n, delta)
+	if err != nil {
+		return "", err
+	}
+
+	updated, err := document.Set(current, field, result)
+	if err != nil {
+		return "", fmt.Errorf("%w: %v", ErrNotNumeric, err)
+	}
+	if err := s.put(collection, key, updated); err != nil {
+		return "", err
+	}
+	return result, nil
+}
+
+// addNumbers adds two JSON numbers, as int64 when both are integers and
+// the sum does not overflow, as float64 otherwise
+func addNumbers(a, b json.Number) (json.Number, error) {
+	if x, err := a.Int64(); err == nil {
+		if y, err := b.Int64(); err == nil {
+			if sum := x + y; (sum > x) == (y > 0) {
+				return json.Number(strconv.FormatInt(sum, 10)), nil
+			}
+		}
+	}
+
+	x, err := a.Float64()
+	if err != nil {
+		return "", ErrNotNumeric
+	}
+	y, err := b.Float64()
+	if err != nil {
+		return "", ErrNotNumeric
+	}
+	sum := x + y
+	if math.IsInf(sum, 0) || math.IsNaN(sum) {
+		return "", fmt.Errorf("%w: result is out of range", ErrNotNumeric)
+	}
+	return json.Number(strconv.FormatFloat(sum, 'g', -1, 64)), nil
+}
+
 // Delete removes a key using Two-Phase Commit for strong consistency
 func (s *ReplicatedStore) Delete(collection, key string) error {
 	if s.config.IsSlave() {
 		return fmt.Errorf("deletes not allowed on slave nodes, send request to master")
 	}
 
+	unlock := s.locks.lock(collection, key)
+	defer unlock()
+
 	// Verify key exists before attempting delete
 	_, err := s.store.Get(collection, key)
 	if err != nil {
@@ -83,7 +378,7 @@ func (s *ReplicatedStore) Delete(collection, key string) error {
 	}
 
 	// Step 1: Replicate delete to all slaves using 2PC
-	if s.manager != nil && s.manager.SlaveCount() > 0 {
+	if s.replicates(collection) {
 		if err := s.manager.ReplicateDelete(collection, key); err != nil {
 			// 2PC failed - slaves aborted, don't delete from master
 			return fmt.Errorf("replication failed: %w", err)
@@ -98,6 +393,138 @@ func (s *ReplicatedStore) Delete(collection, key string) error {
 	return nil
 }
 
+// CreateIndex creates a secondary index on a JSON field using 2PC.
+// Each node builds the index over its own copy of the data.
+func (s *ReplicatedStore) CreateIndex(collection, field string) error {
+	if s.config.IsSlave() {
+		return fmt.Errorf("writes not allowed on slave nodes, send request to master")
+	}
+	if err := validateField(field); err != nil {
+		return err
+	}
+	if s.store.HasIndex(collection, field) {
+		return ErrIndexExists
+	}
+
+	if s.manager != nil && s.manager.SlaveCount() > 0 {
+		if err := s.manager.ReplicateCreateIndex(collection, field); err != nil {
+			return fmt.Errorf("replication failed: %w", err)
+		}
+	}
+
+	if err := s.store.CreateIndex(collection, field); err != nil {
+		return fmt.Errorf("local index build failed after replication (inconsistency possible): %w", err)
+	}
+
+	return nil
+}
+
+// DropIndex removes a secondary index using 2PC
+func (s *ReplicatedStore) DropIndex(collection, field string) error {
+	if s.config.IsSlave() {
+		return fmt.Errorf("writes not allowed on slave nodes, send request to master")
+	}
+	if !s.store.HasIndex(collection, field) {
+		return ErrIndexNotFound
+	}
+
+	if s.manager != nil && s.manager.SlaveCount() > 0 {
+		if err := s.manager.ReplicateDropIndex(collection, field); err != nil {
+			return fmt.Errorf("replication failed: %w", err)
+		}
+	}
+
+	if err := s.store.DropIndex(collection, field); err != nil {
+		return fmt.Errorf("local index drop failed after replication (inconsistency possible): %w", err)
+	}
+
+	return nil
+}
+
+// ListIndexes returns index definitions (reads allowed on all nodes)
+func (s *ReplicatedStore) ListIndexes(collection string) ([]IndexInfo, error) {
+	return s.store.ListIndexes(collection)
+}
+
+// QueryIndex finds documents by an indexed field (reads allowed on all nodes)
+func (s *ReplicatedStore) QueryIndex(collection string, q IndexQuery) ([]KeyValue, error) {
+	return s.store.QueryIndex(collection, q)
+}
+
+// CreateTextIndex enables full-text search on a collection using 2PC
+func (s *ReplicatedStore) CreateTextIndex(collection string, fields []string) error {
+	if s.config.IsSlave() {
+		return fmt.Errorf("writes not allowed on slave nodes, send request to master")
+	}
+	if len(fields) == 0 {
+		return ErrInvalidField
+	}
+	for _, f := range fields {
+		if err := validateField(f); err != nil {
+			return err
+		}
+	}
+
+	if s.manager != nil && s.manager.SlaveCount() > 0 {
+		if err := s.manager.ReplicateCreateTextIndex(collection, fields); err != nil {
+			return fmt.Errorf("replication failed: %w", err)
+		}
+	}
+
+	if err := s.store.CreateTextIndex(collection, fields); err != nil {
+		return fmt.Errorf("local text index build failed after replication (inconsistency possible): %w", err)
+	}
+
+	return nil
+}
+
+// DropTextIndex removes the full-text index of a collection using 2PC
+func (s *ReplicatedStore) DropTextIndex(collection string) error {
+	if s.config.IsSlave() {
+		return fmt.Errorf("writes not allowed on slave nodes, send request to master")
+	}
+	if s.store.textIndex(collection) == nil {
+		return ErrIndexNotFound
+	}
+
+	if s.manager != nil && s.manager.SlaveCount() > 0 {
+		if err := s.manager.ReplicateDropTextIndex(collection); err != nil {
+			return fmt.Errorf("replication failed: %w", err)
+		}
+	}
+
+	if err := s.store.DropTextIndex(collection); err != nil {
+		return fmt.Errorf("local text index drop failed after replication (inconsistency possible): %w", err)
+	}
+
+	return nil
+}
+
+// ListTextIndexes returns full-text index definitions (reads allowed on all nodes)
+func (s *ReplicatedStore) ListTextIndexes() ([]TextIndexInfo, error) {
+	return s.store.ListTextIndexes()
+}
+
+// Search runs a ranked full-text query (reads allowed on all nodes)
+func (s *ReplicatedStore) Search(collection, q string, opts SearchOptions) ([]SearchHit, int, error) {
+	return s.store.Search(collection, q, opts)
+}
+
+// HasIndex reports whether field is indexed in collection
+func (s *ReplicatedStore) HasIndex(collection, field string) bool {
+	return s.store.HasIndex(collection, field)
+}
+
+// Scan iterates over a collection (reads allowed on all nodes)
+func (s *ReplicatedStore) Scan(collection string, fn func(key string, value interface{}) bool) error {
+	return s.store.Scan(collection, fn)
+}
+
+// Snapshot returns a consistent view of the store (reads allowed on all nodes)
+func (s *ReplicatedStore) Snapshot() (*Snapshot, error) {
+	return s.store.Snapshot()
+}
+
 // List returns all key-value pairs (reads allowed on all nodes)
 func (s *ReplicatedStore) List(collection string) (map[string]interface{}, error) {
 	return s.store.List(collection)
@@ -108,6 +535,320 @@ func (s *ReplicatedStore) Count(collection string) (int, error) {
 	return s.store.Count(collection)
 }
 
+// Watch streams committed changes after seq that match filter to fn. Slaves
+// publish the changes they apply through replication, so any node can be
+// watched.
+func (s *ReplicatedStore) Watch(seq uint64, filter watch.Filter, heartbeat time.Duration, done <-chan struct{}, fn func(*watch.Event) error) error {
+	return s.store.Feed().Follow(seq, filter, heartbeat, done, fn)
+}
+
+// LastSeq returns the sequence of the most recent committed change
+func (s *ReplicatedStore) LastSeq() uint64 {
+	return s.store.Feed().LastSeq()
+}
+
+// CheckSeq reports whether a watcher can resume after seq
+func (s *ReplicatedStore) CheckSeq(seq uint64) error {
+	return s.store.Feed().Check(seq)
+}
+
+// ReadChangelog returns up to limit changelog entries after seq that match filter
+func (s *ReplicatedStore) ReadChangelog(seq uint64, filter watch.Filter, limit int) (ChangelogPage, error) {
+	return s.store.ReadChangelog(seq, filter, limit)
+}
+
+// FirstChangelogSeq returns the oldest retained changelog sequence
+func (s *ReplicatedStore) FirstChangelogSeq() (uint64, error) {
+	return s.store.FirstChangelogSeq()
+}
+
+// WaitForChanges returns a channel that is closed when new changes commit
+func (s *ReplicatedStore) WaitForChanges() <-chan struct{} {
+	return s.store.Feed().Wait()
+}
+
+// ConsumerStart returns the changelog offset a consumer resumes from
+func (s *ReplicatedStore) ConsumerStart(consumer, collection string) (uint64, error) {
+	return s.store.ConsumerStart(consumer, collection)
+}
+
+// CommitOffset stores a consumer's changelog offset. Offsets refer to this
+// node's sequence numbers, so they are kept locally and not replicated.
+func (s *ReplicatedStore) CommitOffset(consumer, collection string, offset uint64) (ConsumerOffset, error) {
+	return s.store.CommitOffset(consumer, collection, offset)
+}
+
+// GetOffset returns a consumer's committed changelog offset
+func (s *ReplicatedStore) GetOffset(consumer, collection string) (ConsumerOffset, error) {
+	return s.store.GetOffset(consumer, collection)
+}
+
+// DeleteConsumer removes a consumer's committed changelog offset
+func (s *ReplicatedStore) DeleteConsumer(consumer, collection string) error {
+	return s.store.DeleteConsumer(consumer, collection)
+}
+
+// ListConsumers returns all committed changelog offsets
+func (s *ReplicatedStore) ListConsumers() ([]ConsumerOffset, error) {
+	return s.store.ListConsumers()
+}
+
+// DescribeCollection returns a collection's key count, size and indexes
+func (s *ReplicatedStore) DescribeCollection(collection string) (CollectionInfo, error) {
+	return s.store.DescribeCollection(collection)
+}
+
+// DescribeCollections returns every collection with its key count, size and indexes
+func (s *ReplicatedStore) DescribeCollections() ([]CollectionInfo, error) {
+	return s.store.DescribeCollections()
+}
+
+// TruncateCollection removes every key of a collection using 2PC. Key
+// writes are held off meanwhile so every node removes the same keys.
+func (s *ReplicatedStore) TruncateCollection(collection string) (int64, error) {
+	if s.config.IsSlave() {
+		return 0, fmt.Errorf("writes not allowed on slave nodes, send request to master")
+	}
+
+	unlock := s.locks.lockAll()
+	defer unlock()
+
+	if !s.store.collectionExists(collection) {
+		return 0, ErrCollectionNotFound
+	}
+
+	if s.manager != nil && s.manager.SlaveCount() > 0 {
+		if err := s.manager.ReplicateTruncateCollection(collection); err != nil {
+			return 0, fmt.Errorf("replication failed: %w", err)
+		}
+	}
+
+	n, err := s.store.TruncateCollection(collection)
+	if err != nil {
+		return 0, fmt.Errorf("local truncate failed after replication (inconsistency possible): %w", err)
+	}
+	return n, nil
+}
+
+// DropCollection removes a collection and its indexes using 2PC
+func (s *ReplicatedStore) DropCollection(collection string) (int64, error) {
+	if s.config.IsSlave() {
+		return 0, fmt.Errorf("writes not allowed on slave nodes, send request to master")
+	}
+
+	unlock := s.locks.lockAll()
+	defer unlock()
+
+	if !s.store.collectionExists(collection) {
+		return 0, ErrCollectionNotFound
+	}
+
+	if s.manager != nil && s.manager.SlaveCount() > 0 {
+		if err := s.manager.ReplicateDropCollection(collection); err != nil {
+			return 0, fmt.Errorf("replication failed: %w", err)
+		}
+	}
+
+	n, err := s.store.DropCollection(collection)
+	if err != nil {
+		return 0, fmt.Errorf("local drop failed after replication (inconsistency possible): %w", err)
+	}
+	return n, nil
+}
+
+// CopyCollection copies a collection and its indexes into an empty
+// collection using 2PC
+func (s *ReplicatedStore) CopyCollection(src, dst string) (int64, error) {
+	return s.moveCollection(src, dst, false)
+}
+
+// RenameCollection moves a collection and its indexes to an empty
+// collection using 2PC
+func (s *ReplicatedStore) RenameCollection(src, dst string) (int64, error) {
+	return s.moveCollection(src, dst, true)
+}
+
+// moveCollection implements CopyCollection and RenameCollection
+func (s *ReplicatedStore) moveCollection(src, dst string, rename bool) (int64, error) {
+	if s.config.IsSlave() {
+		return 0, fmt.Errorf("writes not allowed on slave nodes, send request to master")
+	}
+	if err := ValidateCollection(dst); err != nil {
+		return 0, err
+	}
+
+	unlock := s.locks.lockAll()
+	defer unlock()
+
+	if !s.store.collectionExists(src) {
+		return 0, ErrCollectionNotFound
+	}
+	count, err := s.store.Count(dst)
+	if err != nil {
+		return 0, err
+	}
+	if count > 0 || src == dst {
+		return 0, ErrCollectionExists
+	}
+
+	// dst keeps its own settings, or gets a copy of those of src once the
+	// keys are copied, so a failed copy leaves dst unregistered
+	srcMeta, srcOK := s.store.CollectionMeta(src)
+	_, dstOK := s.store.CollectionMeta(dst)
+	if dstOK && s.replicates(src) != s.replicates(dst) {
+		return 0, ErrReplicationMismatch
+	}
+
+	// Copied blob manifests are sealed with the key of dst
+	if s.store.Encrypted() {
+		if err := s.ensureDataKey(dst); err != nil {
+			return 0, err
+		}
+	}
+
+	if s.manager != nil && s.manager.SlaveCount() > 0 {
+		replicate := s.manager.ReplicateCopyCollection
+		if rename {
+			replicate = s.manager.ReplicateRenameCollection
+		}
+		if err := replicate(src, dst); err != nil {
+			return 0, fmt.Errorf("replication failed: %w", err)
+		}
+	}
+
+	move := s.store.CopyCollection
+	if rename {
+		move = s.store.RenameCollection
+	}
+	n, err := move(src, dst)
+	if err != nil {
+		return n, fmt.Errorf("local copy failed after replication (inconsistency possible): %w", err)
+	}
+
+	if srcOK && !dstOK {
+		srcMeta.Collection = dst
+		srcMeta.CreatedAt = time.Now().UTC()
+		srcMeta.SchemaVersion, srcMeta.SchemaUpdatedAt = 0, time.Time{}
+		if len(srcMeta.Schema) > 0 {
+			srcMeta.SchemaVersion, srcMeta.SchemaUpdatedAt = 1, srcMeta.CreatedAt
+		}
+		if err := s.setMeta(srcMeta); err != nil {
+			return n, err
+		}
+	}
+	return n, nil
+}
+
+// CollectionMeta returns the registered settings of a collection (reads
+// allowed on all nodes)
+func (s *ReplicatedStore) CollectionMeta(collection string) (CollectionMeta, bool) {
+	return s.store.CollectionMeta(collection)
+}
+
+// SchemaVersions returns the schema history of a collection (reads allowed
+// on all nodes)
+func (s *ReplicatedStore) SchemaVersions(collection string) ([]SchemaVersion, error) {
+	return s.store.SchemaVersions(collection)
+}
+
+// CheckSchema reports the documents of a collection that do not match a
+// schema (reads allowed on all nodes)
+func (s *ReplicatedStore) CheckSchema(collection string, data []byte, limit int) (SchemaReport, error) {
+	return s.store.CheckSchema(collection, data, limit)
+}
+
+// CreateCollection registers a new, empty collection with the given
+// settings using 2PC
+func (s *ReplicatedStore) CreateCollection(meta CollectionMeta) (CollectionMeta, error) {
+	if s.config.IsSlave() {
+		return CollectionMeta{}, fmt.Errorf("writes not allowed on slave nodes, send request to master")
+	}
+	meta.CreatedAt = time.Now().UTC()
+	meta.SchemaVersion, meta.SchemaUpdatedAt = 0, time.Time{}
+	if len(meta.Schema) > 0 {
+		meta.SchemaVersion, meta.SchemaUpdatedAt = 1, meta.CreatedAt
+	}
+	if err := meta.Validate(); err != nil {
+		return CollectionMeta{}, err
+	}
+
+	unlock := s.locks.lockAll()
+	defer unlock()
+
+	if s.store.collectionExists(meta.Collection) {
+		return CollectionMeta{}, ErrCollectionExists
+	}
+	if err := s.setMeta(meta); err != nil {
+		return CollectionMeta{}, err
+	}
+	return meta, nil
+}
+
+// UpdateCollection changes the settings of an existing collection using
+// 2PC. fn receives the current settings and edits them in place; a
+// collection that was never registered starts from the defaults and is
+// registered now. A changed schema becomes a new schema version.
+func (s *ReplicatedStore) UpdateCollection(collection string, fn func(meta *CollectionMeta) error) (CollectionMeta, error) {
+	if s.config.IsSlave() {
+		return CollectionMeta{}, fmt.Errorf("writes not allowed on slave nodes, send request to master")
+	}
+
+	// Key writers are held off so each write sees either the old or the new
+	// settings on every node
+	unlock := s.locks.lockAll()
+	defer unlock()
+
+	meta, ok := s.store.CollectionMeta(collection)
+	if !ok {
+		count, err := s.store.Count(collection)
+		if err != nil {
+			return CollectionMeta{}, err
+		}
+		if count == 0 {
+			return CollectionMeta{}, ErrCollectionNotFound
+		}
+		meta = CollectionMeta{Collection: collection, CreatedAt: time.Now().UTC(), Replication: ReplicationSync}
+	}
+
+	schema := meta.Schema
+	if err := fn(&meta); err != nil {
+		return CollectionMeta{}, err
+	}
+	meta.Collection = collection
+	if !bytes.Equal(schema, meta.Schema) {
+		meta.SchemaVersion++
+		meta.SchemaUpdatedAt = time.Now().UTC()
+	}
+	if err := meta.Validate(); err != nil {
+		return CollectionMeta{}, err
+	}
+	if err := s.setMeta(meta); err != nil {
+		return CollectionMeta{}, err
+	}
+	return meta, nil
+}
+
+// setMeta replicates and stores collection settings; the caller must hold
+// every key lock. Settings go to every slave whatever the replication
+// policy, so all nodes agree on it.
+func (s *ReplicatedStore) setMeta(meta CollectionMeta) error {
+	data, err := json.Marshal(meta)
+	if err != nil {
+		return fmt.Errorf("failed to serialize collection settings: %w", err)
+	}
+
+	if s.manager != nil && s.manager.SlaveCount() > 0 {
+		if err := s.manager.ReplicateSetCollectionMeta(meta.Collection, data); err != nil {
+			return fmt.Errorf("replication failed: %w", err)
+		}
+	}
+
+	if err := s.store.SetCollectionMetaDirect(meta.Collection, data); err != nil {
+		return fmt.Errorf("local write failed after replication (inconsistency possible): %w", err)
+	}
+	return nil
+}
+
 // ListCollections returns all available collections
 func (s *ReplicatedStore) ListCollections() ([]string, error) {
 	return s.store.ListCollections()
@@ -115,6 +856,11 @@ func (s *ReplicatedStore) ListCollections() ([]string, error) {
 
 // Close closes the store
 func (s *ReplicatedStore) Close() error {
+	if s.stop != nil {
+		close(s.stop)
+		<-s.stopped
+	}
+	s.stopEviction()
 	if s.manager != nil {
 		s.manager.Close()
 	}
@@ -126,7 +872,7 @@ func (s *ReplicatedStore) GetManager() *replication.Manager {
 	return s.manager
 }
 
-// Underlying returns the underlying LevelDB store (for replication server)
-func (s *ReplicatedStore) Underlying() *LevelDBStore {
+// Underlying returns the underlying local store (for replication server)
+func (s *ReplicatedStore) Underlying() *LocalStore {
 	return s.store
 }
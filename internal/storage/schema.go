@@ -0,0 +1,135 @@
+package storage
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"kiwi/internal/schema"
+)
+
+var (
+	// ErrSchemaViolation is returned for values that do not match the
+	// schema of their collection
+	ErrSchemaViolation = errors.New("value does not match the collection schema")
+	// ErrNoSchema is returned when a collection has no schema
+	ErrNoSchema = errors.New("collection has no schema")
+)
+
+// SchemaError lists the ways a value does not match its collection's schema
+type SchemaError struct {
+	Violations []schema.Violation
+}
+
+// Error implements error
+func (e *SchemaError) Error() string {
+	msg := ErrSchemaViolation.Error()
+	if len(e.Violations) > 0 {
+		msg += ": " + e.Violations[0].String()
+	}
+	if n := len(e.Violations) - 1; n > 0 {
+		msg += fmt.Sprintf(" (and %d more)", n)
+	}
+	return msg
+}
+
+// Unwrap makes errors.Is(err, ErrSchemaViolation) hold
+func (e *SchemaError) Unwrap() error {
+	return ErrSchemaViolation
+}
+
+// SchemaVersion is one entry of a collection's schema history. A version
+// without a schema records the removal of the schema.
+type SchemaVersion struct {
+	Version   int             `json:"version"`
+	Schema    json.RawMessage `json:"schema,omitempty"`
+	CreatedAt time.Time       `json:"created_at"`
+}
+
+// DocumentViolations lists why a stored document does not match a schema
+type DocumentViolations struct {
+	Key        string
+	Violations []schema.Violation
+}
+
+// SchemaReport is the result of checking stored documents against a schema
+type SchemaReport struct {
+	Checked   int
+	Violating int
+	Documents []DocumentViolations // the first violating documents, by key
+}
+
+// schemaVersionKey returns the key holding one version of a schema
+func schemaVersionKey(collection string, version int) []byte {
+	v := make([]byte, 4)
+	binary.BigEndian.PutUint32(v, uint32(version))
+	return joinKey(schemaVersionPrefix, []byte(collection), []byte{0}, v)
+}
+
+// validateSchema checks a serialized value against the schema of its
+// collection, if it has one
+func (m *CollectionMeta) validateSchema(data []byte) error {
+	if m.validator == nil {
+		return nil
+	}
+	value, err := decodeValue(data)
+	if err != nil {
+		return fmt.Errorf("failed to deserialize value: %w", err)
+	}
+	if violations := notDocument(value); violations != nil {
+		return &SchemaError{Violations: violations}
+	}
+	if violations := m.validator.Validate(value); len(violations) > 0 {
+		return &SchemaError{Violations: violations}
+	}
+	return nil
+}
+
+// SchemaVersions returns the schema history of a collection, oldest first
+func (s *LocalStore) SchemaVersions(collection string) ([]SchemaVersion, error) {
+	prefix := joinKey(schemaVersionPrefix, []byte(collection), []byte{0})
+	iter := s.db.NewIterator(bytesPrefix(prefix))
+	defer iter.Release()
+
+	versions := make([]SchemaVersion, 0)
+	for iter.Next() {
+		var v SchemaVersion
+		if err := json.Unmarshal(iter.Value(), &v); err != nil {
+			continue
+		}
+		versions = append(versions, v)
+	}
+	if err := iter.Error(); err != nil {
+		return nil, fmt.Errorf("iterator error: %w", err)
+	}
+	return versions, nil
+}
+
+// CheckSchema validates every document of a collection against a schema
+// without applying it. Up to limit violating documents are returned in
+// full; all of them are counted.
+func (s *LocalStore) CheckSchema(collection string, data []byte, limit int) (SchemaReport, error) {
+	compiled, err := schema.Compile(data)
+	if err != nil {
+		return SchemaReport{}, fmt.Errorf("%w: %v", ErrInvalidSettings, err)
+	}
+
+	var report SchemaReport
+	err = s.Scan(collection, func(key string, value interface{}) bool {
+		report.Checked++
+		violations := notDocument(value)
+		if violations == nil {
+			violations = compiled.Validate(value)
+		}
+		if len(violations) > 0 {
+			report.Violating++
+			if len(report.Documents) < limit {
+				report.Documents = append(report.Documents, DocumentViolations{Key: key, Violations: violations})
+			}
+		}
+		return true
+	})
+	return report, err
+}
@@ -0,0 +1,52 @@
+package storage
+
+import (
+	"fmt"
+)
+
+// Snapshot is a consistent read-only view of the store at one point in
+// time. It must be released when no longer needed.
+type Snapshot struct {
+	store *LocalStore
+	snap  EngineSnapshot
+}
+
+// Snapshot returns a consistent view of the store for long reads such as
+// exports, which may scan a collection more than once
+func (s *LocalStore) Snapshot() (*Snapshot, error) {
+	snap, err := s.db.GetSnapshot()
+	if err != nil {
+		return nil, fmt.Errorf("failed to acquire snapshot: %w", err)
+	}
+	return &Snapshot{store: s, snap: snap}, nil
+}
+
+// ScanRaw calls fn with every key in a collection and its serialized
+// value, decrypted, in key order, until fn returns false. The value is only
+// valid during the call.
+func (sn *Snapshot) ScanRaw(collection string, fn func(key string, value []byte) bool) error {
+	prefix := collectionKeyPrefix(collection)
+	iter := sn.snap.NewIterator(bytesPrefix(prefix))
+	defer iter.Release()
+
+	for iter.Next() {
+		value, err := sn.store.decrypt(iter.Value())
+		if err != nil {
+			// Skip malformed entries
+			continue
+		}
+		if !fn(string(iter.Key()[len(prefix):]), value) {
+			break
+		}
+	}
+
+	if err := iter.Error(); err != nil {
+		return fmt.Errorf("iterator error: %w", err)
+	}
+	return nil
+}
+
+// Release frees the snapshot
+func (sn *Snapshot) Release() {
+	sn.snap.Release()
+}
@@ -0,0 +1,42 @@
+package storage
+
+import "fmt"
+
+// StorageStats describes what a store holds and, for engines that report
+// them, the internals of its engine
+type StorageStats struct {
+	Size        int64 // approximate bytes of everything the store holds
+	Collections []CollectionInfo
+	Engine      *EngineStats // nil if the engine does not report them
+}
+
+// StorageStats returns the size of the store and of each collection, and
+// the internals of the engine
+func (s *LocalStore) StorageStats() (StorageStats, error) {
+	collections, err := s.DescribeCollections()
+	if err != nil {
+		return StorageStats{}, err
+	}
+
+	// Every key of the store sorts below 0xff
+	sizes, err := s.db.SizeOf([]KeyRange{{Limit: []byte{0xff}}})
+	if err != nil {
+		return StorageStats{}, fmt.Errorf("failed to estimate size: %w", err)
+	}
+
+	stats := StorageStats{Size: sizes[0], Collections: collections}
+	if db, ok := s.db.(statsEngine); ok {
+		engine, err := db.Stats()
+		if err != nil {
+			return StorageStats{}, fmt.Errorf("failed to read engine stats: %w", err)
+		}
+		stats.Engine = &engine
+	}
+	return stats, nil
+}
+
+// StorageStats returns the size of the store and of each collection, and
+// the internals of the engine
+func (s *ReplicatedStore) StorageStats() (StorageStats, error) {
+	return s.store.StorageStats()
+}
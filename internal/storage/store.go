@@ -8,8 +8,31 @@ var (
 
 	// ErrInvalidKey is returned when a key is invalid
 	ErrInvalidKey = errors.New("invalid key")
+
+	// ErrInvalidOperation is returned for unknown bulk operation types
+	ErrInvalidOperation = errors.New("invalid operation")
+
+	// ErrNotNumeric is returned when a numeric operation targets a non-numeric value
+	ErrNotNumeric = errors.New("value is not numeric")
+
+	// ErrNotJSON is returned when a JSON operation targets a raw value or a blob
+	ErrNotJSON = errors.New("value is not JSON")
 )
 
+// Bulk operation types
+const (
+	OpPut    = "put"
+	OpDelete = "delete"
+)
+
+// BulkOp is a single put or delete inside a bulk write
+type BulkOp struct {
+	Op         string
+	Collection string
+	Key        string
+	Value      interface{}
+}
+
 // Store defines the interface for key-value storage operations
 type Store interface {
 	// Put stores a key-value pair in the specified collection
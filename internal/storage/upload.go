@@ -0,0 +1,527 @@
+package storage
+
+import (
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"time"
+
+	"kiwi/internal/replication"
+)
+
+// UploadTTL is how long an upload is kept after its last part was stored
+// before it is aborted
+const UploadTTL = 24 * time.Hour
+
+var (
+	// ErrUploadNotFound is returned for unknown uploads
+	ErrUploadNotFound = errors.New("upload not found")
+	// ErrUploadBusy is returned while another request writes to an upload
+	ErrUploadBusy = errors.New("upload is busy with another request")
+	// ErrUploadIncomplete is returned when completing an upload with
+	// missing parts
+	ErrUploadIncomplete = errors.New("upload is incomplete")
+	// ErrInvalidPart is returned for part numbers out of range
+	ErrInvalidPart = fmt.Errorf("part number must be between 1 and %d", MaxBlobParts)
+)
+
+// Upload is a blob being uploaded in parts. Parts can be sent in any
+// order and sent again until the upload is completed; the blob is only
+// stored under its key once it is.
+type Upload struct {
+	ID          string     `json:"id"` // also the ID of the blob
+	Collection  string     `json:"collection"`
+	Key         string     `json:"key"`
+	ContentType string     `json:"content_type"`
+	CreatedAt   time.Time  `json:"created_at"`
+	UpdatedAt   time.Time  `json:"updated_at"`
+	Parts       []BlobPart `json:"-"` // stored parts by number
+}
+
+// uploadKey returns the key holding the state of an upload
+func uploadKey(id string) []byte {
+	return joinKey(uploadPrefix, []byte(id))
+}
+
+// uploadPartKey returns the key holding a stored part of an upload
+func uploadPartKey(id string, part int) []byte {
+	return binary.BigEndian.AppendUint32(joinKey(uploadPartPrefix, []byte(id)), uint32(part))
+}
+
+// putUpload stores the state of an upload
+func (s *LocalStore) putUpload(u Upload) error {
+	data, err := json.Marshal(u)
+	if err != nil {
+		return fmt.Errorf("failed to serialize upload: %w", err)
+	}
+	if err := s.db.Put(uploadKey(u.ID), data); err != nil {
+		return fmt.Errorf("failed to store upload: %w", err)
+	}
+	return nil
+}
+
+// putUploadPart records a stored part of an upload
+func (s *LocalStore) putUploadPart(u Upload, part BlobPart) error {
+	data, err := json.Marshal(part)
+	if err != nil {
+		return fmt.Errorf("failed to serialize part: %w", err)
+	}
+	state, err := json.Marshal(u)
+	if err != nil {
+		return fmt.Errorf("failed to serialize upload: %w", err)
+	}
+
+	batch := new(WriteBatch)
+	batch.Put(uploadPartKey(u.ID, part.Number), data)
+	batch.Put(uploadKey(u.ID), state)
+	if err := s.db.Write(batch); err != nil {
+		return fmt.Errorf("failed to store part: %w", err)
+	}
+	return nil
+}
+
+// deleteUploadPart forgets a stored part of an upload
+func (s *LocalStore) deleteUploadPart(id string, part int) error {
+	if err := s.db.Delete(uploadPartKey(id, part)); err != nil {
+		return fmt.Errorf("failed to delete part: %w", err)
+	}
+	return nil
+}
+
+// GetUpload returns an upload with its stored parts
+func (s *LocalStore) GetUpload(id string) (Upload, error) {
+	data, err := s.db.Get(uploadKey(id))
+	if err == ErrNotFound {
+		return Upload{}, ErrUploadNotFound
+	}
+	if err != nil {
+		return Upload{}, fmt.Errorf("failed to read upload: %w", err)
+	}
+
+	var u Upload
+	if err := json.Unmarshal(data, &u); err != nil {
+		return Upload{}, fmt.Errorf("failed to deserialize upload: %w", err)
+	}
+
+	iter := s.db.NewIterator(bytesPrefix(joinKey(uploadPartPrefix, []byte(id))))
+	defer iter.Release()
+	for iter.Next() {
+		var part BlobPart
+		if err := json.Unmarshal(iter.Value(), &part); err != nil {
+			continue
+		}
+		u.Parts = append(u.Parts, part)
+	}
+	if err := iter.Error(); err != nil {
+		return Upload{}, fmt.Errorf("iterator error: %w", err)
+	}
+	return u, nil
+}
+
+// Uploads returns every upload in progress, by ID
+func (s *LocalStore) Uploads() ([]Upload, error) {
+	iter := s.db.NewIterator(bytesPrefix(uploadPrefix))
+	defer iter.Release()
+
+	var ids []string
+	for iter.Next() {
+		ids = append(ids, string(iter.Key()[len(uploadPrefix):]))
+	}
+	if err := iter.Error(); err != nil {
+		return nil, fmt.Errorf("iterator error: %w", err)
+	}
+
+	uploads := make([]Upload, 0, len(ids))
+	for _, id := range ids {
+		u, err := s.GetUpload(id)
+		if errors.Is(err, ErrUploadNotFound) {
+			continue // finished meanwhile
+		}
+		if err != nil {
+			return nil, err
+		}
+		uploads = append(uploads, u)
+	}
+	return uploads, nil
+}
+
+// deleteUpload removes the state of an upload, but not its chunks
+func (s *LocalStore) deleteUpload(id string) error {
+	if err := s.deleteRange(bytesPrefix(joinKey(uploadPartPrefix, []byte(id)))); err != nil {
+		return err
+	}
+	if err := s.db.Delete(uploadKey(id)); err != nil {
+		return fmt.Errorf("failed to delete upload: %w", err)
+	}
+	return nil
+}
+
+// claimUpload gives the caller sole use of an upload until the returned
+// function is called
+func (s *ReplicatedStore) claimUpload(id string) (func(), error) {
+	s.uploadMu.Lock()
+	defer s.uploadMu.Unlock()
+
+	if s.uploading[id] {
+		return nil, ErrUploadBusy
+	}
+	s.uploading[id] = true
+	return func() {
+		s.uploadMu.Lock()
+		delete(s.uploading, id)
+		s.uploadMu.Unlock()
+	}, nil
+}
+
+// CreateUpload starts an upload of a blob to key
+func (s *ReplicatedStore) CreateUpload(collection, key, contentType string) (Upload, error) {
+	if s.config.IsSlave() {
+		return Upload{}, fmt.Errorf("writes not allowed on slave nodes, send request to master")
+	}
+	if key == "" {
+		return Upload{}, ErrInvalidKey
+	}
+	if contentType == "" {
+		contentType = DefaultContentType
+	}
+
+	id, err := newBlobID()
+	if err != nil {
+		return Upload{}, err
+	}
+	now := time.Now().UTC()
+	u := Upload{
+		ID:          id,
+		Collection:  collection,
+		Key:         key,
+		ContentType: contentType,
+		CreatedAt:   now,
+		UpdatedAt:   now,
+	}
+	if err := s.store.putUpload(u); err != nil {
+		return Upload{}, err
+	}
+	return u, nil
+}
+
+// GetUpload returns an upload with its stored parts
+func (s *ReplicatedStore) GetUpload(id string) (Upload, error) {
+	return s.store.GetUpload(id)
+}
+
+// Uploads returns every upload in progress
+func (s *ReplicatedStore) Uploads() ([]Upload, error) {
+	return s.store.Uploads()
+}
+
+// WritePart stores everything read from r as part number of an upload,
+// replacing an earlier attempt at the same part
+func (s *ReplicatedStore) WritePart(id string, number int, r io.Reader) (BlobPart, error) {
+	if s.config.IsSlave() {
+		return BlobPart{}, fmt.Errorf("writes not allowed on slave nodes, send request to master")
+	}
+	if number < 1 || number > MaxBlobParts {
+		return BlobPart{}, ErrInvalidPart
+	}
+
+	release, err := s.claimUpload(id)
+	if err != nil {
+		return BlobPart{}, err
+	}
+	defer release()
+
+	u, err := s.store.GetUpload(id)
+	if err != nil {
+		return BlobPart{}, err
+	}
+
+	// The other parts count against the size limit too
+	var used int64
+	for _, p := range u.Parts {
+		if p.Number != number {
+			used += p.Size
+		}
+	}
+
+	// An earlier attempt at the part is replaced, and its chunks may
+	// outnumber the new ones
+	if err := s.store.deleteUploadPart(id, number); err != nil {
+		return BlobPart{}, err
+	}
+	if err := s.discardChunks(u.Collection, id, uint32(number)); err != nil {
+		return BlobPart{}, err
+	}
+	part, err := s.writeChunks(u.Collection, id, uint32(number), r, used, s.maxBlobSize(u.Collection))
+	if err != nil {
+		s.discardChunks(u.Collection, id, uint32(number))
+		return BlobPart{}, err
+	}
+
+	u.UpdatedAt = time.Now().UTC()
+	if err := s.store.putUploadPart(u, part); err != nil {
+		return BlobPart{}, err
+	}
+	return part, nil
+}
+
+// CompleteUpload stores the blob of an upload under its key. Parts must be
+// numbered from 1 without gaps. An upload that fails to complete is kept,
+// so it can be completed again or aborted.
+func (s *ReplicatedStore) CompleteUpload(id string) (BlobManifest, error) {
+	if s.config.IsSlave() {
+		return BlobManifest{}, fmt.Errorf("writes not allowed on slave nodes, send request to master")
+	}
+
+	release, err := s.claimUpload(id)
+	if err != nil {
+		return BlobManifest{}, err
+	}
+	defer release()
+
+	u, err := s.store.GetUpload(id)
+	if err != nil {
+		return BlobManifest{}, err
+	}
+	if len(u.Parts) == 0 {
+		return BlobManifest{}, fmt.Errorf("%w: no parts", ErrUploadIncomplete)
+	}
+
+	m := BlobManifest{
+		ID:          u.ID,
+		ContentType: u.ContentType,
+		ChunkSize:   BlobChunkSize,
+		Parts:       u.Parts,
+		CreatedAt:   time.Now().UTC(),
+	}
+	for i, part := range u.Parts {
+		if part.Number != i+1 {
+			return BlobManifest{}, fmt.Errorf("%w: part %d is missing", ErrUploadIncomplete, i+1)
+		}
+		m.Size += part.Size
+	}
+
+	if err := s.commitBlob(u.Collection, u.Key, m); err != nil {
+		return BlobManifest{}, err
+	}
+	if err := s.store.deleteUpload(id); err != nil {
+		log.Printf("[Blob] failed to remove completed upload %s: %v", id, err)
+	}
+	return m, nil
+}
+
+// AbortUpload discards an upload and its chunks
+func (s *ReplicatedStore) AbortUpload(id string) error {
+	if s.config.IsSlave() {
+		return fmt.Errorf("writes not allowed on slave nodes, send request to master")
+	}
+
+	release, err := s.claimUpload(id)
+	if err != nil {
+		return err
+	}
+	defer release()
+
+	u, err := s.store.GetUpload(id)
+	if err != nil {
+		return err
+	}
+	if err := s.discardChunks(u.Collection, id, 0); err != nil {
+		return err
+	}
+	return s.store.deleteUpload(id)
+}
+
+// PutBlob stores everything read from r as a blob under key, in a single
+// part
+func (s *ReplicatedStore) PutBlob(collection, key, contentType string, r io.Reader) (BlobManifest, error) {
+	if s.config.IsSlave() {
+		return BlobManifest{}, fmt.Errorf("writes not allowed on slave nodes, send request to master")
+	}
+	if key == "" {
+		return BlobManifest{}, ErrInvalidKey
+	}
+	if contentType == "" {
+		contentType = DefaultContentType
+	}
+
+	id, err := newBlobID()
+	if err != nil {
+		return BlobManifest{}, err
+	}
+	part, err := s.writeChunks(collection, id, 1, r, 0, s.maxBlobSize(collection))
+	if err == nil {
+		m := BlobManifest{
+			ID:          id,
+			ContentType: contentType,
+			Size:        part.Size,
+			ChunkSize:   BlobChunkSize,
+			Parts:       []BlobPart{part},
+			CreatedAt:   time.Now().UTC(),
+		}
+		if err = s.commitBlob(collection, key, m); err == nil {
+			return m, nil
+		}
+	}
+
+	s.discardChunks(collection, id, 0)
+	return BlobManifest{}, err
+}
+
+// OpenBlob opens the blob stored under a key for reading (reads allowed on
+// all nodes)
+func (s *ReplicatedStore) OpenBlob(collection, key string) (*BlobReader, error) {
+	return s.store.OpenBlob(collection, key)
+}
+
+// maxBlobSize returns the size limit for blobs of a collection, or -1
+func (s *ReplicatedStore) maxBlobSize(collection string) int64 {
+	if meta, ok := s.store.CollectionMeta(collection); ok && meta.MaxValueSize > 0 {
+		return meta.MaxValueSize
+	}
+	return -1
+}
+
+// writeChunks reads r to its end and stores it as the chunks of a blob
+// part, streaming them to the slaves before writing them locally. It fails
+// once used plus the bytes read exceed limit, unless limit is -1.
+func (s *ReplicatedStore) writeChunks(collection, id string, number uint32, r io.Reader, used, limit int64) (BlobPart, error) {
+	// Chunks are encrypted like values, before they are sent to slaves
+	if s.store.Encrypted() {
+		if err := s.ensureDataKey(collection); err != nil {
+			return BlobPart{}, err
+		}
+	}
+
+	var w *replication.ChunkWriter
+	if s.replicates(collection) {
+		var err error
+		if w, err = s.manager.WriteChunks(collection, id, number); err != nil {
+			return BlobPart{}, fmt.Errorf("replication failed: %w", err)
+		}
+	}
+	fail := func(err error) (BlobPart, error) {
+		if w != nil {
+			w.Abort()
+		}
+		return BlobPart{}, err
+	}
+
+	part := BlobPart{Number: int(number)}
+	hash := sha256.New()
+	for {
+		// A fresh buffer per chunk, as the slave streams may still hold
+		// the previous one
+		chunk := make([]byte, BlobChunkSize)
+		n, err := io.ReadFull(r, chunk)
+		if n > 0 {
+			chunk = chunk[:n]
+			part.Size += int64(n)
+			if limit >= 0 && used+part.Size > limit {
+				return fail(fmt.Errorf("%w: more than %d bytes", ErrValueTooLarge, limit))
+			}
+			hash.Write(chunk)
+
+			stored, err := s.store.encrypt(collection, chunk)
+			if err != nil {
+				return fail(err)
+			}
+			if w != nil {
+				if err := w.Write(uint32(part.Chunks), stored); err != nil {
+					return fail(fmt.Errorf("replication failed: %w", err))
+				}
+			}
+			if err := s.store.PutChunk(collection, id, number, uint32(part.Chunks), stored); err != nil {
+				return fail(err)
+			}
+			part.Chunks++
+		}
+		if err == io.EOF || err == io.ErrUnexpectedEOF {
+			break
+		}
+		if err != nil {
+			return fail(fmt.Errorf("failed to read part: %w", err))
+		}
+	}
+
+	if w != nil {
+		if err := w.Close(); err != nil {
+			return BlobPart{}, fmt.Errorf("replication failed: %w", err)
+		}
+	}
+	part.SHA256 = hex.EncodeToString(hash.Sum(nil))
+	return part, nil
+}
+
+// discardChunks removes the chunks of a blob part, or of a whole blob when
+// part is 0, from the slaves and then locally
+func (s *ReplicatedStore) discardChunks(collection, id string, part uint32) error {
+	if s.replicates(collection) {
+		if err := s.manager.DiscardChunks(collection, id, part); err != nil {
+			return fmt.Errorf("replication failed: %w", err)
+		}
+	}
+	return s.store.DiscardChunks(collection, id, part)
+}
+
+// commitBlob stores the manifest of a blob whose chunks are in place under
+// key using 2PC, which makes the blob visible on every node at once
+func (s *ReplicatedStore) commitBlob(collection, key string, m BlobManifest) error {
+	value, err := encodeBlob(m)
+	if err != nil {
+		return err
+	}
+
+	unlock := s.locks.lock(collection, key)
+	defer unlock()
+
+	if err := s.checkValue(collection, value); err != nil {
+		return err
+	}
+	if err := s.store.checkChunks(collection, m); err != nil {
+		return err
+	}
+	if value, err = s.sealValue(collection, value); err != nil {
+		return err
+	}
+
+	if s.replicates(collection) {
+		if err := s.manager.ReplicatePut(collection, key, value); err != nil {
+			return fmt.Errorf("replication failed: %w", err)
+		}
+	}
+
+	if err := s.store.PutDirect(collection, key, value); err != nil {
+		return fmt.Errorf("local write failed after replication (inconsistency possible): %w", err)
+	}
+	return nil
+}
+
+// ExpireUploads aborts the uploads that have not received a part for
+// UploadTTL and returns how many were aborted
+func (s *ReplicatedStore) ExpireUploads(now time.Time) (int, error) {
+	uploads, err := s.store.Uploads()
+	if err != nil {
+		return 0, err
+	}
+
+	n := 0
+	for _, u := range uploads {
+		if now.Sub(u.UpdatedAt) < UploadTTL {
+			continue
+		}
+		err := s.AbortUpload(u.ID)
+		if errors.Is(err, ErrUploadBusy) || errors.Is(err, ErrUploadNotFound) {
+			continue
+		}
+		if err != nil {
+			return n, err
+		}
+		n++
+	}
+	return n, nil
+}
@@ -0,0 +1,204 @@
+package storage
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"kiwi/internal/watch"
+)
+
+// ErrWebhookNotFound is returned when a webhook does not exist
+var ErrWebhookNotFound = errors.New("webhook not found")
+
+// Webhook is a subscription that POSTs matching changes to a URL
+type Webhook struct {
+	ID          string    `json:"id"`
+	URL         string    `json:"url"`
+	Collection  string    `json:"collection"` // empty matches every collection
+	Prefix      string    `json:"prefix"`
+	Events      []string  `json:"events"` // put and/or delete
+	Secret      string    `json:"secret"`
+	MaxAttempts int       `json:"max_attempts"`
+	Active      bool      `json:"active"`
+	CreatedAt   time.Time `json:"created_at"`
+}
+
+// Match reports whether the webhook wants event e
+func (w *Webhook) Match(e watch.Event) bool {
+	if !w.Active || !(watch.Filter{Collection: w.Collection, Prefix: w.Prefix}).Match(e) {
+		return false
+	}
+	for _, t := range w.Events {
+		if t == e.Type {
+			return true
+		}
+	}
+	return false
+}
+
+// DeadLetter records a delivery that failed after all retries
+type DeadLetter struct {
+	WebhookID  string      `json:"webhook_id"`
+	Event      watch.Event `json:"event"`
+	Attempts   int         `json:"attempts"`
+	LastStatus int         `json:"last_status,omitempty"`
+	LastError  string      `json:"last_error"`
+	FailedAt   time.Time   `json:"failed_at"`
+}
+
+// webhookKey returns the key holding a webhook definition
+func webhookKey(id string) []byte {
+	return joinKey(webhookPrefix, []byte(id))
+}
+
+// deadLetterPrefixFor returns the prefix of a webhook's dead letters
+func deadLetterPrefixFor(id string) []byte {
+	return joinKey(deadLetterPrefix, []byte(id), []byte{0})
+}
+
+// deadLetterKey returns the key of the dead letter for event seq
+func deadLetterKey(id string, seq uint64) []byte {
+	key := deadLetterPrefixFor(id)
+	return binary.BigEndian.AppendUint64(key, seq)
+}
+
+// PutWebhook creates or replaces a webhook
+func (s *LocalStore) PutWebhook(w *Webhook) error {
+	data, err := json.Marshal(w)
+	if err != nil {
+		return fmt.Errorf("failed to serialize webhook: %w", err)
+	}
+	if err := s.db.Put(webhookKey(w.ID), data); err != nil {
+		return fmt.Errorf("failed to store webhook: %w", err)
+	}
+	return nil
+}
+
+// GetWebhook returns a webhook by ID
+func (s *LocalStore) GetWebhook(id string) (*Webhook, error) {
+	data, err := s.db.Get(webhookKey(id))
+	if err == ErrNotFound {
+		return nil, ErrWebhookNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read webhook: %w", err)
+	}
+
+	var w Webhook
+	if err := json.Unmarshal(data, &w); err != nil {
+		return nil, fmt.Errorf("failed to decode webhook: %w", err)
+	}
+	return &w, nil
+}
+
+// DeleteWebhook removes a webhook and its dead letters
+func (s *LocalStore) DeleteWebhook(id string) error {
+	exists, err := s.db.Has(webhookKey(id))
+	if err != nil {
+		return fmt.Errorf("failed to read webhook: %w", err)
+	}
+	if !exists {
+		return ErrWebhookNotFound
+	}
+
+	if err := s.db.Delete(webhookKey(id)); err != nil {
+		return fmt.Errorf("failed to delete webhook: %w", err)
+	}
+	return s.ClearDeadLetters(id)
+}
+
+// ListWebhooks returns all webhooks
+func (s *LocalStore) ListWebhooks() ([]*Webhook, error) {
+	iter := s.db.NewIterator(bytesPrefix(webhookPrefix))
+	defer iter.Release()
+
+	hooks := []*Webhook{}
+	for iter.Next() {
+		var w Webhook
+		if err := json.Unmarshal(iter.Value(), &w); err != nil {
+			continue
+		}
+		hooks = append(hooks, &w)
+	}
+	if err := iter.Error(); err != nil {
+		return nil, fmt.Errorf("iterator error: %w", err)
+	}
+	return hooks, nil
+}
+
+// PutDeadLetter records a failed delivery
+func (s *LocalStore) PutDeadLetter(d *DeadLetter) error {
+	data, err := json.Marshal(d)
+	if err != nil {
+		return fmt.Errorf("failed to serialize dead letter: %w", err)
+	}
+	if data, err = s.sealLocal(data); err != nil {
+		return err
+	}
+
+	// Dead letters change under s.mu, so re-encryption never writes back
+	// an older one
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err := s.db.Put(deadLetterKey(d.WebhookID, d.Event.Seq), data); err != nil {
+		return fmt.Errorf("failed to store dead letter: %w", err)
+	}
+	return nil
+}
+
+// ListDeadLetters returns the failed deliveries of a webhook, oldest first
+func (s *LocalStore) ListDeadLetters(id string) ([]DeadLetter, error) {
+	iter := s.db.NewIterator(bytesPrefix(deadLetterPrefixFor(id)))
+	defer iter.Release()
+
+	letters := []DeadLetter{}
+	for iter.Next() {
+		var d DeadLetter
+		data, err := s.decrypt(iter.Value())
+		if err != nil || json.Unmarshal(data, &d) != nil {
+			continue
+		}
+		letters = append(letters, d)
+	}
+	if err := iter.Error(); err != nil {
+		return nil, fmt.Errorf("iterator error: %w", err)
+	}
+	return letters, nil
+}
+
+// DeleteDeadLetter removes a single dead letter
+func (s *LocalStore) DeleteDeadLetter(id string, seq uint64) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.db.Delete(deadLetterKey(id, seq))
+}
+
+// ClearDeadLetters removes all dead letters of a webhook
+func (s *LocalStore) ClearDeadLetters(id string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.deleteRange(bytesPrefix(deadLetterPrefixFor(id)))
+}
+
+// WebhookCursor returns the sequence of the last change handed to webhooks
+func (s *LocalStore) WebhookCursor() (uint64, bool, error) {
+	data, err := s.db.Get(webhookCursorKey)
+	if err == ErrNotFound {
+		return 0, false, nil
+	}
+	if err != nil {
+		return 0, false, fmt.Errorf("failed to read webhook cursor: %w", err)
+	}
+	if len(data) != 8 {
+		return 0, false, nil
+	}
+	return binary.BigEndian.Uint64(data), true, nil
+}
+
+// SetWebhookCursor stores the sequence of the last change handed to webhooks
+func (s *LocalStore) SetWebhookCursor(seq uint64) error {
+	return s.db.Put(webhookCursorKey, binary.BigEndian.AppendUint64(nil, seq))
+}
@@ -0,0 +1,165 @@
+package transfer
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+)
+
+// maxLineSize caps a single NDJSON line
+const maxLineSize = 16 * 1024 * 1024
+
+// Reader decodes records in one of the supported formats
+type Reader struct {
+	format string
+	lines  *bufio.Scanner
+	dec    *json.Decoder
+	csv    *csv.Reader
+	header []string
+	line   int // current record, for error messages
+	done   bool
+}
+
+// NewReader returns a reader for format
+func NewReader(r io.Reader, format string) (*Reader, error) {
+	if !ValidFormat(format) {
+		return nil, ErrInvalidFormat
+	}
+
+	tr := &Reader{format: format}
+	switch format {
+	case FormatCSV:
+		tr.csv = csv.NewReader(r)
+		header, err := tr.csv.Read()
+		if err == io.EOF {
+			tr.done = true
+			return tr, nil
+		}
+		if err != nil {
+			return nil, fmt.Errorf("invalid CSV header: %w", err)
+		}
+		tr.header = header
+		if keyColumn(header) < 0 {
+			return nil, errors.New("CSV header has no key column")
+		}
+	case FormatJSON:
+		tr.dec = json.NewDecoder(r)
+		tok, err := tr.dec.Token()
+		if err == io.EOF {
+			tr.done = true
+			return tr, nil
+		}
+		if delim, ok := tok.(json.Delim); err != nil || !ok || delim != '[' {
+			return nil, errors.New("JSON input must be an array of records")
+		}
+	default:
+		tr.lines = bufio.NewScanner(r)
+		tr.lines.Buffer(make([]byte, 64*1024), maxLineSize)
+	}
+	return tr, nil
+}
+
+// Read returns the next record, or io.EOF at the end of the input
+func (tr *Reader) Read() (Record, error) {
+	if tr.done {
+		return Record{}, io.EOF
+	}
+
+	r, err := tr.next()
+	if err == io.EOF {
+		tr.done = true
+		return Record{}, io.EOF
+	}
+	if err != nil {
+		return Record{}, fmt.Errorf("record %d: %w", tr.line, err)
+	}
+	if r.Key == "" {
+		return Record{}, fmt.Errorf("record %d: key is required", tr.line)
+	}
+	if len(r.Value) == 0 {
+		r.Value = json.RawMessage("null")
+	}
+	return r, nil
+}
+
+// next decodes the next record in the reader's format
+func (tr *Reader) next() (Record, error) {
+	var r Record
+	switch tr.format {
+	case FormatCSV:
+		row, err := tr.csv.Read()
+		if err != nil {
+			return r, err
+		}
+		tr.line++
+		return tr.fromRow(row)
+
+	case FormatJSON:
+		if !tr.dec.More() {
+			if _, err := tr.dec.Token(); err != nil {
+				return r, err
+			}
+			return r, io.EOF
+		}
+		tr.line++
+		err := tr.dec.Decode(&r)
+		return r, err
+
+	default:
+		for tr.lines.Scan() {
+			line := tr.lines.Bytes()
+			if len(bytes.TrimSpace(line)) == 0 {
+				continue
+			}
+			tr.line++
+			err := json.Unmarshal(line, &r)
+			return r, err
+		}
+		if err := tr.lines.Err(); err != nil {
+			return r, err
+		}
+		return r, io.EOF
+	}
+}
+
+// fromRow builds a record from a CSV row. A header of just key and value
+// holds whole values; otherwise every other column is a top-level field.
+func (tr *Reader) fromRow(row []string) (Record, error) {
+	var r Record
+	k := keyColumn(tr.header)
+	r.Key = row[k]
+
+	if len(tr.header) == 2 && tr.header[1-k] == ValueColumn {
+		if value, ok := parseCell(row[1-k]); ok {
+			r.Value = value
+		}
+		return r, nil
+	}
+
+	obj := make(map[string]json.RawMessage, len(row))
+	for i, column := range tr.header {
+		if i == k {
+			continue
+		}
+		if value, ok := parseCell(row[i]); ok {
+			obj[column] = value
+		}
+	}
+	data, err := json.Marshal(obj)
+	r.Value = data
+	return r, err
+}
+
+// keyColumn returns the index of the key column in a CSV header
+func keyColumn(header []string) int {
+	for i, column := range header {
+		if column == KeyColumn {
+			return i
+		}
+	}
+	return -1
+}
@@ -0,0 +1,140 @@
+// Package transfer encodes and decodes collection records for export and
+// import in NDJSON, CSV and JSON formats.
+package transfer
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// Supported formats
+const (
+	FormatNDJSON = "ndjson" // one {"key","value"} object per line
+	FormatJSON   = "json"   // a single array of {"key","value"} objects
+	FormatCSV    = "csv"    // a key column plus one column per field
+)
+
+// KeyColumn and ValueColumn are the CSV columns holding the key, and the
+// whole value for values that are not JSON objects
+const (
+	KeyColumn   = "key"
+	ValueColumn = "value"
+)
+
+// ErrInvalidFormat is returned for unknown formats
+var ErrInvalidFormat = errors.New("format must be ndjson, csv or json")
+
+// Record is a key and its serialized JSON value
+type Record struct {
+	Key   string          `json:"key"`
+	Value json.RawMessage `json:"value"`
+}
+
+// ValidFormat reports whether format is supported
+func ValidFormat(format string) bool {
+	switch format {
+	case FormatNDJSON, FormatJSON, FormatCSV:
+		return true
+	}
+	return false
+}
+
+// ContentType returns the MIME type of a format
+func ContentType(format string) string {
+	switch format {
+	case FormatCSV:
+		return "text/csv"
+	case FormatJSON:
+		return "application/json"
+	default:
+		return "application/x-ndjson"
+	}
+}
+
+// FormatOf guesses a format from a file name or content type, returning ""
+// if it is not recognized
+func FormatOf(name string) string {
+	name = strings.ToLower(name)
+	switch filepath.Ext(name) {
+	case ".csv":
+		return FormatCSV
+	case ".ndjson", ".jsonl":
+		return FormatNDJSON
+	case ".json":
+		return FormatJSON
+	}
+	switch {
+	case strings.HasPrefix(name, "text/csv"):
+		return FormatCSV
+	case strings.HasPrefix(name, "application/x-ndjson"), strings.HasPrefix(name, "application/jsonl"):
+		return FormatNDJSON
+	case strings.HasPrefix(name, "application/json"):
+		return FormatJSON
+	}
+	return ""
+}
+
+// Columns returns the CSV columns for values: the key, the union of the
+// top-level fields of object values in sorted order, and a value column if
+// any value is not an object
+func Columns(values func(fn func(value []byte))) []string {
+	fields := make(map[string]bool)
+	plain := false
+	values(func(value []byte) {
+		var obj map[string]json.RawMessage
+		if err := json.Unmarshal(value, &obj); err != nil || obj == nil {
+			plain = true
+			return
+		}
+		for field := range obj {
+			fields[field] = true
+		}
+	})
+
+	columns := make([]string, 0, len(fields))
+	for field := range fields {
+		if field != KeyColumn {
+			columns = append(columns, field)
+		}
+	}
+	sort.Strings(columns)
+	if plain && !fields[ValueColumn] {
+		columns = append(columns, ValueColumn)
+	}
+	return append([]string{KeyColumn}, columns...)
+}
+
+// formatCell renders a JSON value as a CSV cell: strings as-is, null as
+// empty, anything else as JSON
+func formatCell(raw json.RawMessage) string {
+	raw = bytes.TrimSpace(raw)
+	if len(raw) == 0 || string(raw) == "null" {
+		return ""
+	}
+	if raw[0] == '"' {
+		var s string
+		if err := json.Unmarshal(raw, &s); err == nil {
+			return s
+		}
+	}
+	return string(raw)
+}
+
+// parseCell turns a CSV cell back into a JSON value: numbers, booleans,
+// objects and arrays are parsed as JSON, anything else is a string. It
+// reports false for empty cells.
+func parseCell(cell string) (json.RawMessage, bool) {
+	if cell == "" {
+		return nil, false
+	}
+	trimmed := strings.TrimSpace(cell)
+	if trimmed != "" && trimmed != "null" && trimmed[0] != '"' && json.Valid([]byte(trimmed)) {
+		return json.RawMessage(trimmed), true
+	}
+	data, _ := json.Marshal(cell)
+	return data, true
+}
@@ -0,0 +1,108 @@
+package transfer
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+)
+
+// Writer encodes records in one of the supported formats
+type Writer struct {
+	w       io.Writer
+	format  string
+	csv     *csv.Writer
+	columns []string
+	count   int
+}
+
+// NewWriter returns a writer for format. columns is only used for CSV and
+// should come from Columns.
+func NewWriter(w io.Writer, format string, columns []string) (*Writer, error) {
+	if !ValidFormat(format) {
+		return nil, ErrInvalidFormat
+	}
+
+	tw := &Writer{w: w, format: format, columns: columns}
+	switch format {
+	case FormatCSV:
+		tw.csv = csv.NewWriter(w)
+		if err := tw.csv.Write(columns); err != nil {
+			return nil, err
+		}
+	case FormatJSON:
+		if _, err := io.WriteString(w, "["); err != nil {
+			return nil, err
+		}
+	}
+	return tw, nil
+}
+
+// Write encodes one record
+func (tw *Writer) Write(r Record) error {
+	tw.count++
+
+	switch tw.format {
+	case FormatCSV:
+		return tw.csv.Write(tw.row(r))
+	case FormatJSON:
+		data, err := json.Marshal(r)
+		if err != nil {
+			return fmt.Errorf("failed to encode %q: %w", r.Key, err)
+		}
+		sep := ",\n"
+		if tw.count == 1 {
+			sep = "\n"
+		}
+		_, err = fmt.Fprintf(tw.w, "%s%s", sep, data)
+		return err
+	default:
+		data, err := json.Marshal(r)
+		if err != nil {
+			return fmt.Errorf("failed to encode %q: %w", r.Key, err)
+		}
+		_, err = fmt.Fprintf(tw.w, "%s\n", data)
+		return err
+	}
+}
+
+// Close finishes the output; it does not close the underlying writer
+func (tw *Writer) Close() error {
+	switch tw.format {
+	case FormatCSV:
+		tw.csv.Flush()
+		return tw.csv.Error()
+	case FormatJSON:
+		end := "\n]\n"
+		if tw.count == 0 {
+			end = "]\n"
+		}
+		_, err := io.WriteString(tw.w, end)
+		return err
+	}
+	return nil
+}
+
+// row lays out a record as CSV cells in column order
+func (tw *Writer) row(r Record) []string {
+	row := make([]string, len(tw.columns))
+
+	var obj map[string]json.RawMessage
+	if err := json.Unmarshal(r.Value, &obj); err != nil || obj == nil {
+		obj = nil
+	}
+
+	for i, column := range tw.columns {
+		switch {
+		case column == KeyColumn:
+			row[i] = r.Key
+		case obj == nil:
+			if column == ValueColumn {
+				row[i] = formatCell(r.Value)
+			}
+		default:
+			row[i] = formatCell(obj[column])
+		}
+	}
+	return row
+}
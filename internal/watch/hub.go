@@ -0,0 +1,224 @@
+package watch
+
+import (
+	"encoding/json"
+	"errors"
+	"strings"
+	"sync"
+	"time"
+)
+
+var (
+	// ErrSequenceExpired is returned when resuming from a sequence that is no
+	// longer held in the feed buffer
+	ErrSequenceExpired = errors.New("sequence no longer available, resume from a newer sequence")
+	// ErrSequenceAhead is returned when resuming from a sequence this node
+	// has not reached yet
+	ErrSequenceAhead = errors.New("sequence is ahead of this node")
+	// ErrClosed is returned once the hub has been shut down
+	ErrClosed = errors.New("change feed closed")
+)
+
+// Event types. Truncate and drop events remove every key of a collection
+// at once and carry no key.
+const (
+	EventPut      = "put"
+	EventDelete   = "delete"
+	EventTruncate = "truncate"
+	EventDrop     = "drop"
+)
+
+// DefaultBufferSize is the number of recent events kept for resuming
+const DefaultBufferSize = 10000
+
+// Event is a single committed change
+type Event struct {
+	Seq         uint64          `json:"seq"`
+	Type        string          `json:"type"`
+	Collection  string          `json:"collection"`
+	Key         string          `json:"key"`
+	Value       json.RawMessage `json:"value,omitempty"`
+	ContentType string          `json:"content_type,omitempty"` // set for raw values and blobs, Value holds their JSON form
+	Blob        bool            `json:"blob,omitempty"`         // Value holds a blob manifest
+	Timestamp   time.Time       `json:"timestamp"`
+}
+
+// Filter selects the events a watcher is interested in
+type Filter struct {
+	Collection string // empty matches every collection
+	Prefix     string // key prefix
+}
+
+// Match reports whether e passes the filter
+func (f Filter) Match(e Event) bool {
+	if f.Collection != "" && e.Collection != f.Collection {
+		return false
+	}
+	if e.Type == EventTruncate || e.Type == EventDrop {
+		return true
+	}
+	return strings.HasPrefix(e.Key, f.Prefix)
+}
+
+// Hub keeps a ring buffer of recent events and wakes up watchers when new
+// ones are published. Watchers read at their own pace; one that falls
+// further behind than the buffer gets ErrSequenceExpired.
+type Hub struct {
+	mu     sync.Mutex
+	buf    []Event
+	head   int    // index of the oldest event in buf
+	size   int    // number of events in buf
+	last   uint64 // sequence of the newest event
+	notify chan struct{}
+	closed bool
+}
+
+// NewHub creates a hub holding up to size events. last is the sequence of
+// the most recent committed change, so watchers can resume from it after a
+// restart.
+func NewHub(size int, last uint64) *Hub {
+	if size <= 0 {
+		size = DefaultBufferSize
+	}
+	return &Hub{
+		buf:    make([]Event, size),
+		last:   last,
+		notify: make(chan struct{}),
+	}
+}
+
+// Publish appends events, which must carry consecutive sequence numbers
+func (h *Hub) Publish(events []Event) {
+	if len(events) == 0 {
+		return
+	}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if h.closed {
+		return
+	}
+
+	for _, e := range events {
+		if h.size < len(h.buf) {
+			h.buf[(h.head+h.size)%len(h.buf)] = e
+			h.size++
+		} else {
+			h.buf[h.head] = e
+			h.head = (h.head + 1) % len(h.buf)
+		}
+		h.last = e.Seq
+	}
+
+	close(h.notify)
+	h.notify = make(chan struct{})
+}
+
+// LastSeq returns the sequence of the most recent event
+func (h *Hub) LastSeq() uint64 {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return h.last
+}
+
+// Since returns up to max events after seq, along with a channel that is
+// closed when more events are published
+func (h *Hub) Since(seq uint64, max int) ([]Event, <-chan struct{}, error) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if err := h.check(seq); err != nil {
+		return nil, nil, err
+	}
+
+	n := int(h.last - seq)
+	if max > 0 && n > max {
+		n = max
+	}
+	start := h.size - int(h.last-seq)
+
+	events := make([]Event, n)
+	for i := range events {
+		events[i] = h.buf[(h.head+start+i)%len(h.buf)]
+	}
+	return events, h.notify, nil
+}
+
+// Wait returns a channel that is closed when the next events are published
+func (h *Hub) Wait() <-chan struct{} {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return h.notify
+}
+
+// Check reports whether watchers can resume after seq
+func (h *Hub) Check(seq uint64) error {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return h.check(seq)
+}
+
+// check is Check with h.mu held
+func (h *Hub) check(seq uint64) error {
+	if h.closed {
+		return ErrClosed
+	}
+	if seq > h.last {
+		return ErrSequenceAhead
+	}
+	if seq < h.last-uint64(h.size) {
+		return ErrSequenceExpired
+	}
+	return nil
+}
+
+// Close wakes up all watchers and stops accepting events
+func (h *Hub) Close() {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if !h.closed {
+		h.closed = true
+		close(h.notify)
+	}
+}
+
+// Follow calls fn for every event after seq that matches filter, until fn
+// returns an error, done is closed or the hub shuts down. When no event
+// arrives for heartbeat, fn is called with nil so callers can detect dead
+// connections.
+func (h *Hub) Follow(seq uint64, filter Filter, heartbeat time.Duration, done <-chan struct{}, fn func(*Event) error) error {
+	ticker := time.NewTicker(heartbeat)
+	defer ticker.Stop()
+
+	for {
+		events, wait, err := h.Since(seq, 256)
+		if err != nil {
+			return err
+		}
+
+		for i := range events {
+			seq = events[i].Seq
+			if !filter.Match(events[i]) {
+				continue
+			}
+			if err := fn(&events[i]); err != nil {
+				return err
+			}
+		}
+		if len(events) > 0 {
+			continue
+		}
+
+		select {
+		case <-wait:
+		case <-done:
+			return nil
+		case <-ticker.C:
+			if err := fn(nil); err != nil {
+				return err
+			}
+		}
+	}
+}
@@ -0,0 +1,511 @@
+package webhook
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"kiwi/internal/storage"
+	"kiwi/internal/watch"
+)
+
+// Delivery settings
+const (
+	DefaultMaxAttempts = 5
+	MaxAttemptsLimit   = 20
+
+	queueSize      = 1000
+	requestTimeout = 10 * time.Second
+	cursorInterval = time.Second
+)
+
+// Delays between delivery attempts, variables so tests can shorten them
+var (
+	retryBase = time.Second
+	retryMax  = time.Minute
+)
+
+// EventPing is the event type sent by Ping
+const EventPing = "ping"
+
+// Signature headers sent with every delivery
+const (
+	HeaderEvent     = "X-Kiwi-Event"
+	HeaderDelivery  = "X-Kiwi-Delivery"
+	HeaderSignature = "X-Kiwi-Signature"
+)
+
+// ErrInvalidWebhook is returned for webhook definitions that cannot be used
+var ErrInvalidWebhook = errors.New("invalid webhook")
+
+// Payload is the JSON body POSTed to webhook URLs
+type Payload struct {
+	WebhookID string `json:"webhook_id"`
+	watch.Event
+}
+
+// Result describes a single delivery attempt
+type Result struct {
+	Status   int
+	Error    string
+	Duration time.Duration
+}
+
+// Dispatcher follows the change feed and delivers matching events to
+// webhooks. Each webhook has its own queue and worker, so a slow or failing
+// receiver only delays its own deliveries. Events that still fail after
+// all retries are stored as dead letters. Webhooks and dead letters live in
+// the local store of the master and are not replicated.
+type Dispatcher struct {
+	store  *storage.LocalStore
+	client *http.Client
+
+	mu      sync.RWMutex
+	workers map[string]*worker // webhook ID -> worker
+
+	done    chan struct{}  // closed to stop following the feed
+	quit    chan struct{}  // closed to stop workers, once nothing is dispatched
+	running sync.WaitGroup // feed follower
+	wg      sync.WaitGroup // workers
+}
+
+// worker delivers the events queued for one webhook in order
+type worker struct {
+	hook  atomic.Pointer[storage.Webhook]
+	queue chan watch.Event
+	stop  chan struct{} // closed when the webhook is deleted
+}
+
+// NewDispatcher creates a webhook dispatcher
+func NewDispatcher(store *storage.LocalStore) *Dispatcher {
+	return &Dispatcher{
+		store:   store,
+		client:  &http.Client{Timeout: requestTimeout},
+		workers: make(map[string]*worker),
+		done:    make(chan struct{}),
+		quit:    make(chan struct{}),
+	}
+}
+
+// Start loads the stored webhooks and begins following the change feed
+// from where the last run stopped
+func (d *Dispatcher) Start() error {
+	hooks, err := d.store.ListWebhooks()
+	if err != nil {
+		return err
+	}
+	for _, hook := range hooks {
+		d.startWorker(hook)
+	}
+
+	cursor, ok, err := d.store.WebhookCursor()
+	if err != nil {
+		return err
+	}
+	if !ok {
+		cursor = d.store.Feed().LastSeq()
+	}
+
+	d.running.Add(1)
+	go d.run(cursor)
+	return nil
+}
+
+// Stop stops following the feed. Events still queued are stored as dead
+// letters so they can be redelivered later.
+func (d *Dispatcher) Stop() {
+	close(d.done)
+	d.running.Wait()
+	close(d.quit)
+	d.wg.Wait()
+}
+
+// Create validates and stores a new webhook
+func (d *Dispatcher) Create(hook *storage.Webhook) error {
+	id, err := newID()
+	if err != nil {
+		return err
+	}
+	hook.ID = id
+	hook.CreatedAt = time.Now().UTC()
+
+	if err := normalize(hook); err != nil {
+		return err
+	}
+	if err := d.store.PutWebhook(hook); err != nil {
+		return err
+	}
+
+	d.startWorker(hook)
+	return nil
+}
+
+// Update replaces the definition of an existing webhook
+func (d *Dispatcher) Update(hook *storage.Webhook) error {
+	current, err := d.store.GetWebhook(hook.ID)
+	if err != nil {
+		return err
+	}
+	hook.CreatedAt = current.CreatedAt
+
+	if err := normalize(hook); err != nil {
+		return err
+	}
+	if err := d.store.PutWebhook(hook); err != nil {
+		return err
+	}
+
+	d.mu.RLock()
+	w := d.workers[hook.ID]
+	d.mu.RUnlock()
+	if w != nil {
+		w.hook.Store(hook)
+	}
+	return nil
+}
+
+// Delete removes a webhook, its pending deliveries and its dead letters
+func (d *Dispatcher) Delete(id string) error {
+	if err := d.store.DeleteWebhook(id); err != nil {
+		return err
+	}
+
+	d.mu.Lock()
+	w := d.workers[id]
+	delete(d.workers, id)
+	d.mu.Unlock()
+	if w != nil {
+		close(w.stop)
+	}
+	return nil
+}
+
+// Get returns a webhook by ID
+func (d *Dispatcher) Get(id string) (*storage.Webhook, error) {
+	return d.store.GetWebhook(id)
+}
+
+// List returns all webhooks
+func (d *Dispatcher) List() ([]*storage.Webhook, error) {
+	return d.store.ListWebhooks()
+}
+
+// DeadLetters returns the failed deliveries of a webhook
+func (d *Dispatcher) DeadLetters(id string) ([]storage.DeadLetter, error) {
+	if _, err := d.store.GetWebhook(id); err != nil {
+		return nil, err
+	}
+	return d.store.ListDeadLetters(id)
+}
+
+// ClearDeadLetters discards the failed deliveries of a webhook
+func (d *Dispatcher) ClearDeadLetters(id string) error {
+	if _, err := d.store.GetWebhook(id); err != nil {
+		return err
+	}
+	return d.store.ClearDeadLetters(id)
+}
+
+// Redeliver queues every dead letter of a webhook again and returns how
+// many were queued
+func (d *Dispatcher) Redeliver(id string) (int, error) {
+	letters, err := d.DeadLetters(id)
+	if err != nil {
+		return 0, err
+	}
+
+	d.mu.RLock()
+	w := d.workers[id]
+	d.mu.RUnlock()
+	if w == nil {
+		return 0, storage.ErrWebhookNotFound
+	}
+
+	queued := 0
+	for _, letter := range letters {
+		select {
+		case w.queue <- letter.Event:
+		default:
+			return queued, nil
+		}
+		if err := d.store.DeleteDeadLetter(id, letter.Event.Seq); err != nil {
+			return queued, err
+		}
+		queued++
+	}
+	return queued, nil
+}
+
+// Ping sends a single test event to a webhook and reports the outcome,
+// without retries
+func (d *Dispatcher) Ping(id string) (Result, error) {
+	hook, err := d.store.GetWebhook(id)
+	if err != nil {
+		return Result{}, err
+	}
+
+	event := watch.Event{Type: EventPing, Collection: hook.Collection, Timestamp: time.Now().UTC()}
+	start := time.Now()
+	status, err := d.send(hook, event)
+
+	result := Result{Status: status, Duration: time.Since(start)}
+	if err != nil {
+		result.Error = err.Error()
+	}
+	return result, nil
+}
+
+// startWorker starts delivering events for hook
+func (d *Dispatcher) startWorker(hook *storage.Webhook) {
+	w := &worker{
+		queue: make(chan watch.Event, queueSize),
+		stop:  make(chan struct{}),
+	}
+	w.hook.Store(hook)
+
+	d.mu.Lock()
+	d.workers[hook.ID] = w
+	d.mu.Unlock()
+
+	d.wg.Add(1)
+	go d.work(w)
+}
+
+// run follows the change feed from cursor until the dispatcher stops
+func (d *Dispatcher) run(cursor uint64) {
+	defer d.running.Done()
+
+	saved := cursor
+	lastSave := time.Now()
+	saveCursor := func() {
+		if cursor == saved {
+			return
+		}
+		if err := d.store.SetWebhookCursor(cursor); err != nil {
+			log.Printf("[Webhook] failed to store cursor: %v", err)
+			return
+		}
+		saved, lastSave = cursor, time.Now()
+	}
+	defer saveCursor()
+
+	for {
+		err := d.store.Feed().Follow(cursor, watch.Filter{}, cursorInterval, d.done, func(e *watch.Event) error {
+			if e != nil {
+				d.dispatch(*e)
+				cursor = e.Seq
+			}
+			if time.Since(lastSave) >= cursorInterval {
+				saveCursor()
+			}
+			return nil
+		})
+
+		switch {
+		case err == nil, errors.Is(err, watch.ErrClosed):
+			return
+		case errors.Is(err, watch.ErrSequenceExpired):
+			cursor = d.catchUp(cursor)
+		default:
+			log.Printf("[Webhook] change feed error: %v", err)
+			cursor = d.store.Feed().LastSeq()
+		}
+	}
+}
+
+// catchUp dispatches changes the in-memory feed no longer holds, reading
+// them from the changelog when it is enabled, and returns the new cursor
+func (d *Dispatcher) catchUp(cursor uint64) uint64 {
+	for {
+		page, err := d.store.ReadChangelog(cursor, watch.Filter{}, 256)
+		if err != nil {
+			last := d.store.Feed().LastSeq()
+			log.Printf("[Webhook] changes %d-%d are not available (%v), skipping them", cursor+1, last, err)
+			return last
+		}
+		for _, e := range page.Events {
+			d.dispatch(e)
+		}
+		cursor = page.NextSeq
+		if cursor >= page.LastSeq {
+			return cursor
+		}
+	}
+}
+
+// dispatch queues e for every webhook that wants it
+func (d *Dispatcher) dispatch(e watch.Event) {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+
+	for _, w := range d.workers {
+		hook := w.hook.Load()
+		if !hook.Match(e) {
+			continue
+		}
+		select {
+		case w.queue <- e:
+		default:
+			d.deadLetter(hook, e, 0, 0, errors.New("delivery queue full"))
+		}
+	}
+}
+
+// work delivers queued events until the webhook is deleted or the
+// dispatcher stops
+func (d *Dispatcher) work(w *worker) {
+	defer d.wg.Done()
+
+	for {
+		select {
+		case <-w.stop:
+			return
+		case <-d.quit:
+			d.drain(w)
+			return
+		case e := <-w.queue:
+			d.deliver(w, e)
+		}
+	}
+}
+
+// drain stores the events still queued for w as dead letters
+func (d *Dispatcher) drain(w *worker) {
+	for {
+		select {
+		case e := <-w.queue:
+			d.deadLetter(w.hook.Load(), e, 0, 0, errors.New("not delivered before shutdown"))
+		default:
+			return
+		}
+	}
+}
+
+// deliver sends e, retrying with exponential backoff
+func (d *Dispatcher) deliver(w *worker, e watch.Event) {
+	delay := retryBase
+	for attempt := 1; ; attempt++ {
+		hook := w.hook.Load()
+		status, err := d.send(hook, e)
+		if err == nil {
+			return
+		}
+		if attempt >= hook.MaxAttempts {
+			d.deadLetter(hook, e, attempt, status, err)
+			return
+		}
+
+		select {
+		case <-time.After(delay):
+		case <-w.stop:
+			return
+		case <-d.quit:
+			d.deadLetter(hook, e, attempt, status, err)
+			return
+		}
+		delay = min(delay*2, retryMax)
+	}
+}
+
+// send POSTs a signed event to the webhook URL
+func (d *Dispatcher) send(hook *storage.Webhook, e watch.Event) (int, error) {
+	body, err := json.Marshal(Payload{WebhookID: hook.ID, Event: e})
+	if err != nil {
+		return 0, err
+	}
+
+	req, err := http.NewRequest(http.MethodPost, hook.URL, bytes.NewReader(body))
+	if err != nil {
+		return 0, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "kiwi-webhook")
+	req.Header.Set(HeaderEvent, e.Type)
+	req.Header.Set(HeaderDelivery, fmt.Sprintf("%s-%d", hook.ID, e.Seq))
+	if hook.Secret != "" {
+		req.Header.Set(HeaderSignature, Sign(hook.Secret, body))
+	}
+
+	resp, err := d.client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return resp.StatusCode, fmt.Errorf("unexpected status %d", resp.StatusCode)
+	}
+	return resp.StatusCode, nil
+}
+
+// deadLetter records a failed delivery
+func (d *Dispatcher) deadLetter(hook *storage.Webhook, e watch.Event, attempts, status int, cause error) {
+	log.Printf("[Webhook] delivery of seq %d to %s failed: %v", e.Seq, hook.ID, cause)
+
+	err := d.store.PutDeadLetter(&storage.DeadLetter{
+		WebhookID:  hook.ID,
+		Event:      e,
+		Attempts:   attempts,
+		LastStatus: status,
+		LastError:  cause.Error(),
+		FailedAt:   time.Now().UTC(),
+	})
+	if err != nil {
+		log.Printf("[Webhook] failed to store dead letter: %v", err)
+	}
+}
+
+// Sign returns the signature header value for body: the hex HMAC-SHA256
+// of the body keyed with the webhook secret
+func Sign(secret string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// normalize validates hook and fills in defaults
+func normalize(hook *storage.Webhook) error {
+	u, err := url.Parse(hook.URL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return fmt.Errorf("%w: url must be an absolute http or https URL", ErrInvalidWebhook)
+	}
+
+	if len(hook.Events) == 0 {
+		hook.Events = []string{watch.EventPut, watch.EventDelete}
+	}
+	for _, t := range hook.Events {
+		switch t {
+		case watch.EventPut, watch.EventDelete, watch.EventTruncate, watch.EventDrop:
+		default:
+			return fmt.Errorf("%w: unknown event %q", ErrInvalidWebhook, t)
+		}
+	}
+
+	if hook.MaxAttempts == 0 {
+		hook.MaxAttempts = DefaultMaxAttempts
+	}
+	if hook.MaxAttempts < 1 || hook.MaxAttempts > MaxAttemptsLimit {
+		return fmt.Errorf("%w: max_attempts must be between 1 and %d", ErrInvalidWebhook, MaxAttemptsLimit)
+	}
+	return nil
+}
+
+// newID returns a random webhook ID
+func newID() (string, error) {
+	b := make([]byte, 8)
+	if _, err := rand.Read(b); err != nil {
+		return "", fmt.Errorf("failed to generate webhook id: %w", err)
+	}
+	return "wh_" + hex.EncodeToString(b), nil
+}
@@ -0,0 +1,277 @@
+package webhook
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"kiwi/internal/storage"
+	"kiwi/internal/watch"
+)
+
+// delivery is a request received by a test receiver
+type delivery struct {
+	header http.Header
+	body   []byte
+}
+
+// receiver is a webhook endpoint answering with the statuses it is given,
+// then 200
+type receiver struct {
+	mu       sync.Mutex
+	statuses []int
+	got      []delivery
+	arrived  chan struct{}
+}
+
+func newReceiver(t *testing.T, statuses ...int) (*receiver, *httptest.Server) {
+	r := &receiver{statuses: statuses, arrived: make(chan struct{}, 100)}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		body, _ := io.ReadAll(req.Body)
+
+		r.mu.Lock()
+		r.got = append(r.got, delivery{header: req.Header.Clone(), body: body})
+		status := http.StatusOK
+		if len(r.statuses) > 0 {
+			status, r.statuses = r.statuses[0], r.statuses[1:]
+		}
+		r.mu.Unlock()
+
+		w.WriteHeader(status)
+		r.arrived <- struct{}{}
+	}))
+	t.Cleanup(srv.Close)
+	return r, srv
+}
+
+// wait waits for n more requests
+func (r *receiver) wait(t *testing.T, n int) {
+	t.Helper()
+	for i := 0; i < n; i++ {
+		select {
+		case <-r.arrived:
+		case <-time.After(5 * time.Second):
+			t.Fatalf("timed out waiting for delivery %d of %d", i+1, n)
+		}
+	}
+}
+
+// deliveries returns the requests received so far
+func (r *receiver) deliveries() []delivery {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return append([]delivery(nil), r.got...)
+}
+
+// newTestDispatcher starts a dispatcher over an in-memory store with fast
+// retries
+func newTestDispatcher(t *testing.T) (*Dispatcher, *storage.LocalStore) {
+	t.Helper()
+
+	base, max := retryBase, retryMax
+	retryBase, retryMax = 10*time.Millisecond, 20*time.Millisecond
+	t.Cleanup(func() { retryBase, retryMax = base, max })
+
+	store, err := storage.OpenStore("memory", "", storage.EngineOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { store.Close() })
+
+	d := NewDispatcher(store)
+	if err := d.Start(); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(d.Stop)
+	return d, store
+}
+
+// waitDeadLetters waits until a webhook has n dead letters
+func waitDeadLetters(t *testing.T, d *Dispatcher, id string, n int) []storage.DeadLetter {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		letters, err := d.DeadLetters(id)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(letters) == n {
+			return letters
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("got %d dead letters, want %d", len(letters), n)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestDeliverySigned(t *testing.T) {
+	d, store := newTestDispatcher(t)
+	recv, srv := newReceiver(t)
+
+	hook := &storage.Webhook{URL: srv.URL, Collection: "users", Secret: "s3cret", Active: true}
+	if err := d.Create(hook); err != nil {
+		t.Fatal(err)
+	}
+	if err := store.Put("orders", "o1", map[string]interface{}{"n": 1.0}); err != nil {
+		t.Fatal(err)
+	}
+	if err := store.Put("users", "u1", map[string]interface{}{"name": "Ann"}); err != nil {
+		t.Fatal(err)
+	}
+	recv.wait(t, 1)
+
+	got := recv.deliveries()
+	if len(got) != 1 {
+		t.Fatalf("got %d deliveries, want 1 for the users collection only", len(got))
+	}
+	req := got[0]
+
+	var p Payload
+	if err := json.Unmarshal(req.body, &p); err != nil {
+		t.Fatalf("malformed payload %s: %v", req.body, err)
+	}
+	if p.WebhookID != hook.ID || p.Type != watch.EventPut || p.Collection != "users" || p.Key != "u1" {
+		t.Errorf("unexpected payload %s", req.body)
+	}
+	if string(p.Value) != `{"name":"Ann"}` {
+		t.Errorf("payload value = %s, want {\"name\":\"Ann\"}", p.Value)
+	}
+
+	if sig, want := req.header.Get(HeaderSignature), Sign("s3cret", req.body); sig != want {
+		t.Errorf("signature = %q, want %q", sig, want)
+	}
+	if ev := req.header.Get(HeaderEvent); ev != watch.EventPut {
+		t.Errorf("%s = %q, want %q", HeaderEvent, ev, watch.EventPut)
+	}
+	if id, want := req.header.Get(HeaderDelivery), hook.ID+"-2"; id != want {
+		t.Errorf("%s = %q, want %q", HeaderDelivery, id, want)
+	}
+}
+
+func TestDeliveryUnsigned(t *testing.T) {
+	d, _ := newTestDispatcher(t)
+	recv, srv := newReceiver(t)
+
+	hook := &storage.Webhook{URL: srv.URL, Active: true}
+	if err := d.Create(hook); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := d.Ping(hook.ID); err != nil {
+		t.Fatal(err)
+	}
+	recv.wait(t, 1)
+
+	req := recv.deliveries()[0]
+	if sig := req.header.Get(HeaderSignature); sig != "" {
+		t.Errorf("signature %q sent without a secret", sig)
+	}
+	if ev := req.header.Get(HeaderEvent); ev != EventPing {
+		t.Errorf("%s = %q, want %q", HeaderEvent, ev, EventPing)
+	}
+}
+
+func TestSign(t *testing.T) {
+	// HMAC-SHA256 test case 2 of RFC 4231
+	got := Sign("Jefe", []byte("what do ya want for nothing?"))
+	want := "sha256=5bdcc146bf60754e6a042426089575c75a003f089d2739839dec58b964ec3843"
+	if got != want {
+		t.Errorf("Sign = %q, want %q", got, want)
+	}
+}
+
+func TestDeliveryRetries(t *testing.T) {
+	d, store := newTestDispatcher(t)
+	recv, srv := newReceiver(t, http.StatusInternalServerError, http.StatusServiceUnavailable)
+
+	hook := &storage.Webhook{URL: srv.URL, MaxAttempts: 3, Active: true}
+	if err := d.Create(hook); err != nil {
+		t.Fatal(err)
+	}
+	if err := store.Put("users", "u1", "Ann"); err != nil {
+		t.Fatal(err)
+	}
+	recv.wait(t, 3)
+
+	got := recv.deliveries()
+	for i, req := range got {
+		if string(req.body) != string(got[0].body) {
+			t.Errorf("attempt %d sent %s, first attempt %s", i+1, req.body, got[0].body)
+		}
+		if id := req.header.Get(HeaderDelivery); id != got[0].header.Get(HeaderDelivery) {
+			t.Errorf("attempt %d has delivery ID %q, first attempt %q", i+1, id, got[0].header.Get(HeaderDelivery))
+		}
+	}
+
+	// The third attempt succeeded
+	time.Sleep(50 * time.Millisecond)
+	if letters, err := d.DeadLetters(hook.ID); err != nil || len(letters) != 0 {
+		t.Errorf("got dead letters %v (%v), want none", letters, err)
+	}
+	if n := len(recv.deliveries()); n != 3 {
+		t.Errorf("got %d attempts, want 3", n)
+	}
+}
+
+func TestDeadLetters(t *testing.T) {
+	d, store := newTestDispatcher(t)
+	recv, srv := newReceiver(t, http.StatusInternalServerError, http.StatusBadGateway)
+
+	hook := &storage.Webhook{URL: srv.URL, MaxAttempts: 2, Active: true}
+	if err := d.Create(hook); err != nil {
+		t.Fatal(err)
+	}
+	if err := store.Put("users", "u1", "Ann"); err != nil {
+		t.Fatal(err)
+	}
+	recv.wait(t, 2)
+
+	letters := waitDeadLetters(t, d, hook.ID, 1)
+	l := letters[0]
+	if l.Attempts != 2 || l.LastStatus != http.StatusBadGateway || l.LastError == "" {
+		t.Errorf("dead letter has attempts %d, status %d, error %q; want 2, 502 and an error",
+			l.Attempts, l.LastStatus, l.LastError)
+	}
+	if l.Event.Collection != "users" || l.Event.Key != "u1" {
+		t.Errorf("dead letter holds %s/%s, want users/u1", l.Event.Collection, l.Event.Key)
+	}
+
+	// The receiver now answers 200
+	n, err := d.Redeliver(hook.ID)
+	if err != nil || n != 1 {
+		t.Fatalf("Redeliver = %d, %v; want 1", n, err)
+	}
+	recv.wait(t, 1)
+	waitDeadLetters(t, d, hook.ID, 0)
+
+	got := recv.deliveries()
+	if string(got[2].body) != string(got[0].body) {
+		t.Errorf("redelivered %s, first attempt %s", got[2].body, got[0].body)
+	}
+}
+
+func TestClearDeadLetters(t *testing.T) {
+	d, store := newTestDispatcher(t)
+	recv, srv := newReceiver(t, http.StatusInternalServerError, http.StatusInternalServerError)
+
+	hook := &storage.Webhook{URL: srv.URL, MaxAttempts: 1, Active: true}
+	if err := d.Create(hook); err != nil {
+		t.Fatal(err)
+	}
+	for _, key := range []string{"a", "b"} {
+		if err := store.Put("users", key, 1.0); err != nil {
+			t.Fatal(err)
+		}
+	}
+	recv.wait(t, 2)
+	waitDeadLetters(t, d, hook.ID, 2)
+
+	if err := d.ClearDeadLetters(hook.ID); err != nil {
+		t.Fatal(err)
+	}
+	waitDeadLetters(t, d, hook.ID, 0)
+}
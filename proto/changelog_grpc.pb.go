@@ -0,0 +1,173 @@
+// Code generated by protoc-gen-go-grpc. DO NOT EDIT.
+// versions:
+// - protoc-gen-go-grpc v1.6.0
+// - protoc             v6.33.2
+// source: proto/changelog.proto
+
+package proto
+
+import (
+	context "context"
+	grpc "google.golang.org/grpc"
+	codes "google.golang.org/grpc/codes"
+	status "google.golang.org/grpc/status"
+)
+
+// This is a compile-time assertion to ensure that this generated file
+// is compatible with the grpc package it is being compiled against.
+// Requires gRPC-Go v1.64.0 or later.
+const _ = grpc.SupportPackageIsVersion9
+
+const (
+	ChangelogService_Subscribe_FullMethodName    = "/replication.ChangelogService/Subscribe"
+	ChangelogService_CommitOffset_FullMethodName = "/replication.ChangelogService/CommitOffset"
+)
+
+// ChangelogServiceClient is the client API for ChangelogService service.
+//
+// For semantics around ctx use and closing/ending streaming RPCs, please refer to https://pkg.go.dev/google.golang.org/grpc/?tab=doc#ClientConn.NewStream.
+//
+// ChangelogService streams the durable change log to downstream consumers
+type ChangelogServiceClient interface {
+	// Subscribe streams changelog entries after an offset, then follows new
+	// entries as they are committed
+	Subscribe(ctx context.Context, in *SubscribeRequest, opts ...grpc.CallOption) (grpc.ServerStreamingClient[ChangeEvent], error)
+	// CommitOffset records the offset a named consumer has processed
+	CommitOffset(ctx context.Context, in *CommitOffsetRequest, opts ...grpc.CallOption) (*CommitOffsetResponse, error)
+}
+
+type changelogServiceClient struct {
+	cc grpc.ClientConnInterface
+}
+
+func NewChangelogServiceClient(cc grpc.ClientConnInterface) ChangelogServiceClient {
+	return &changelogServiceClient{cc}
+}
+
+func (c *changelogServiceClient) Subscribe(ctx context.Context, in *SubscribeRequest, opts ...grpc.CallOption) (grpc.ServerStreamingClient[ChangeEvent], error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	stream, err := c.cc.NewStream(ctx, &ChangelogService_ServiceDesc.Streams[0], ChangelogService_Subscribe_FullMethodName, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &grpc.GenericClientStream[SubscribeRequest, ChangeEvent]{ClientStream: stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type ChangelogService_SubscribeClient = grpc.ServerStreamingClient[ChangeEvent]
+
+func (c *changelogServiceClient) CommitOffset(ctx context.Context, in *CommitOffsetRequest, opts ...grpc.CallOption) (*CommitOffsetResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(CommitOffsetResponse)
+	err := c.cc.Invoke(ctx, ChangelogService_CommitOffset_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// ChangelogServiceServer is the server API for ChangelogService service.
+// All implementations must embed UnimplementedChangelogServiceServer
+// for forward compatibility.
+//
+// ChangelogService streams the durable change log to downstream consumers
+type ChangelogServiceServer interface {
+	// Subscribe streams changelog entries after an offset, then follows new
+	// entries as they are committed
+	Subscribe(*SubscribeRequest, grpc.ServerStreamingServer[ChangeEvent]) error
+	// CommitOffset records the offset a named consumer has processed
+	CommitOffset(context.Context, *CommitOffsetRequest) (*CommitOffsetResponse, error)
+	mustEmbedUnimplementedChangelogServiceServer()
+}
+
+// UnimplementedChangelogServiceServer must be embedded to have
+// forward compatible implementations.
+//
+// NOTE: this should be embedded by value instead of pointer to avoid a nil
+// pointer dereference when methods are called.
+type UnimplementedChangelogServiceServer struct{}
+
+func (UnimplementedChangelogServiceServer) Subscribe(*SubscribeRequest, grpc.ServerStreamingServer[ChangeEvent]) error {
+	return status.Error(codes.Unimplemented, "method Subscribe not implemented")
+}
+func (UnimplementedChangelogServiceServer) CommitOffset(context.Context, *CommitOffsetRequest) (*CommitOffsetResponse, error) {
+	return nil, status.Error(codes.Unimplemented, "method CommitOffset not implemented")
+}
+func (UnimplementedChangelogServiceServer) mustEmbedUnimplementedChangelogServiceServer() {}
+func (UnimplementedChangelogServiceServer) testEmbeddedByValue()                          {}
+
+// UnsafeChangelogServiceServer may be embedded to opt out of forward compatibility for this service.
+// Use of this interface is not recommended, as added methods to ChangelogServiceServer will
+// result in compilation errors.
+type UnsafeChangelogServiceServer interface {
+	mustEmbedUnimplementedChangelogServiceServer()
+}
+
+func RegisterChangelogServiceServer(s grpc.ServiceRegistrar, srv ChangelogServiceServer) {
+	// If the following call panics, it indicates UnimplementedChangelogServiceServer was
+	// embedded by pointer and is nil.  This will cause panics if an
+	// unimplemented method is ever invoked, so we test this at initialization
+	// time to prevent it from happening at runtime later due to I/O.
+	if t, ok := srv.(interface{ testEmbeddedByValue() }); ok {
+		t.testEmbeddedByValue()
+	}
+	s.RegisterService(&ChangelogService_ServiceDesc, srv)
+}
+
+func _ChangelogService_Subscribe_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(SubscribeRequest)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(ChangelogServiceServer).Subscribe(m, &grpc.GenericServerStream[SubscribeRequest, ChangeEvent]{ServerStream: stream})
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type ChangelogService_SubscribeServer = grpc.ServerStreamingServer[ChangeEvent]
+
+func _ChangelogService_CommitOffset_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(CommitOffsetRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(ChangelogServiceServer).CommitOffset(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: ChangelogService_CommitOffset_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(ChangelogServiceServer).CommitOffset(ctx, req.(*CommitOffsetRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+// ChangelogService_ServiceDesc is the grpc.ServiceDesc for ChangelogService service.
+// It's only intended for direct use with grpc.RegisterService,
+// and not to be introspected or modified (even as a copy)
+var ChangelogService_ServiceDesc = grpc.ServiceDesc{
+	ServiceName: "replication.ChangelogService",
+	HandlerType: (*ChangelogServiceServer)(nil),
+	Methods: []grpc.MethodDesc{
+		{
+			MethodName: "CommitOffset",
+			Handler:    _ChangelogService_CommitOffset_Handler,
+		},
+	},
+	Streams: []grpc.StreamDesc{
+		{
+			StreamName:    "Subscribe",
+			Handler:       _ChangelogService_Subscribe_Handler,
+			ServerStreams: true,
+		},
+	},
+	Metadata: "proto/changelog.proto",
+}
@@ -25,19 +25,52 @@ const (
 type OperationType int32
 
 const (
-	OperationType_PUT    OperationType = 0
-	OperationType_DELETE OperationType = 1
+	OperationType_PUT                 OperationType = 0
+	OperationType_DELETE              OperationType = 1
+	OperationType_BATCH               OperationType = 2 // Atomic group of PUT/DELETE mutations
+	OperationType_CREATE_INDEX        OperationType = 3
+	OperationType_DROP_INDEX          OperationType = 4
+	OperationType_CREATE_TEXT_INDEX   OperationType = 5
+	OperationType_DROP_TEXT_INDEX     OperationType = 6
+	OperationType_TRUNCATE_COLLECTION OperationType = 7
+	OperationType_DROP_COLLECTION     OperationType = 8
+	OperationType_COPY_COLLECTION     OperationType = 9
+	OperationType_RENAME_COLLECTION   OperationType = 10
+	OperationType_SET_COLLECTION_META OperationType = 11 // Collection settings, JSON-encoded in value
+	OperationType_PUT_DATA_KEY        OperationType = 12 // Wrapped encryption data key, JSON-encoded in value
 )
 
 // Enum value maps for OperationType.
 var (
 	OperationType_name = map[int32]string{
-		0: "PUT",
-		1: "DELETE",
+		0:  "PUT",
+		1:  "DELETE",
+		2:  "BATCH",
+		3:  "CREATE_INDEX",
+		4:  "DROP_INDEX",
+		5:  "CREATE_TEXT_INDEX",
+		6:  "DROP_TEXT_INDEX",
+		7:  "TRUNCATE_COLLECTION",
+		8:  "DROP_COLLECTION",
+		9:  "COPY_COLLECTION",
+		10: "RENAME_COLLECTION",
+		11: "SET_COLLECTION_META",
+		12: "PUT_DATA_KEY",
 	}
 	OperationType_value = map[string]int32{
-		"PUT":    0,
-		"DELETE": 1,
+		"PUT":                 0,
+		"DELETE":              1,
+		"BATCH":               2,
+		"CREATE_INDEX":        3,
+		"DROP_INDEX":          4,
+		"CREATE_TEXT_INDEX":   5,
+		"DROP_TEXT_INDEX":     6,
+		"TRUNCATE_COLLECTION": 7,
+		"DROP_COLLECTION":     8,
+		"COPY_COLLECTION":     9,
+		"RENAME_COLLECTION":   10,
+		"SET_COLLECTION_META": 11,
+		"PUT_DATA_KEY":        12,
 	}
 )
 
@@ -68,6 +101,75 @@ func (OperationType) EnumDescriptor() ([]byte, []int) {
 	return file_proto_replication_proto_rawDescGZIP(), []int{0}
 }
 
+// Mutation is a single write inside a BATCH operation
+type Mutation struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Operation     OperationType          `protobuf:"varint,1,opt,name=operation,proto3,enum=replication.OperationType" json:"operation,omitempty"` // PUT or DELETE
+	Collection    string                 `protobuf:"bytes,2,opt,name=collection,proto3" json:"collection,omitempty"`
+	Key           string                 `protobuf:"bytes,3,opt,name=key,proto3" json:"key,omitempty"`
+	Value         []byte                 `protobuf:"bytes,4,opt,name=value,proto3" json:"value,omitempty"` // JSON-encoded value (for PUT)
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *Mutation) Reset() {
+	*x = Mutation{}
+	mi := &file_proto_replication_proto_msgTypes[0]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *Mutation) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Mutation) ProtoMessage() {}
+
+func (x *Mutation) ProtoReflect() protoreflect.Message {
+	mi := &file_proto_replication_proto_msgTypes[0]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Mutation.ProtoReflect.Descriptor instead.
+func (*Mutation) Descriptor() ([]byte, []int) {
+	return file_proto_replication_proto_rawDescGZIP(), []int{0}
+}
+
+func (x *Mutation) GetOperation() OperationType {
+	if x != nil {
+		return x.Operation
+	}
+	return OperationType_PUT
+}
+
+func (x *Mutation) GetCollection() string {
+	if x != nil {
+		return x.Collection
+	}
+	return ""
+}
+
+func (x *Mutation) GetKey() string {
+	if x != nil {
+		return x.Key
+	}
+	return ""
+}
+
+func (x *Mutation) GetValue() []byte {
+	if x != nil {
+		return x.Value
+	}
+	return nil
+}
+
 // PrepareRequest contains the operation to be prepared
 type PrepareRequest struct {
 	state         protoimpl.MessageState `protogen:"open.v1"`
@@ -77,13 +179,17 @@ type PrepareRequest struct {
 	Key           string                 `protobuf:"bytes,4,opt,name=key,proto3" json:"key,omitempty"`
 	Value         []byte                 `protobuf:"bytes,5,opt,name=value,proto3" json:"value,omitempty"` // JSON-encoded value (for PUT)
 	Sequence      uint64                 `protobuf:"varint,6,opt,name=sequence,proto3" json:"sequence,omitempty"`
+	Mutations     []*Mutation            `protobuf:"bytes,7,rep,name=mutations,proto3" json:"mutations,omitempty"` // Mutations applied atomically (for BATCH)
+	Field         string                 `protobuf:"bytes,8,opt,name=field,proto3" json:"field,omitempty"`         // Indexed field path (for CREATE_INDEX/DROP_INDEX)
+	Fields        []string               `protobuf:"bytes,9,rep,name=fields,proto3" json:"fields,omitempty"`       // Text-indexed field paths (for CREATE_TEXT_INDEX)
+	Target        string                 `protobuf:"bytes,10,opt,name=target,proto3" json:"target,omitempty"`      // Destination collection (for COPY_COLLECTION/RENAME_COLLECTION)
 	unknownFields protoimpl.UnknownFields
 	sizeCache     protoimpl.SizeCache
 }
 
 func (x *PrepareRequest) Reset() {
 	*x = PrepareRequest{}
-	mi := &file_proto_replication_proto_msgTypes[0]
+	mi := &file_proto_replication_proto_msgTypes[1]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
@@ -95,7 +201,7 @@ func (x *PrepareRequest) String() string {
 func (*PrepareRequest) ProtoMessage() {}
 
 func (x *PrepareRequest) ProtoReflect() protoreflect.Message {
-	mi := &file_proto_replication_proto_msgTypes[0]
+	mi := &file_proto_replication_proto_msgTypes[1]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -108,7 +214,7 @@ func (x *PrepareRequest) ProtoReflect() protoreflect.Message {
 
 // Deprecated: Use PrepareRequest.ProtoReflect.Descriptor instead.
 func (*PrepareRequest) Descriptor() ([]byte, []int) {
-	return file_proto_replication_proto_rawDescGZIP(), []int{0}
+	return file_proto_replication_proto_rawDescGZIP(), []int{1}
 }
 
 func (x *PrepareRequest) GetTransactionId() string {
@@ -153,6 +259,34 @@ func (x *PrepareRequest) GetSequence() uint64 {
 	return 0
 }
 
+func (x *PrepareRequest) GetMutations() []*Mutation {
+	if x != nil {
+		return x.Mutations
+	}
+	return nil
+}
+
+func (x *PrepareRequest) GetField() string {
+	if x != nil {
+		return x.Field
+	}
+	return ""
+}
+
+func (x *PrepareRequest) GetFields() []string {
+	if x != nil {
+		return x.Fields
+	}
+	return nil
+}
+
+func (x *PrepareRequest) GetTarget() string {
+	if x != nil {
+		return x.Target
+	}
+	return ""
+}
+
 // PrepareResponse indicates if slave is ready to commit
 type PrepareResponse struct {
 	state         protoimpl.MessageState `protogen:"open.v1"`
@@ -164,7 +298,7 @@ type PrepareResponse struct {
 
 func (x *PrepareResponse) Reset() {
 	*x = PrepareResponse{}
-	mi := &file_proto_replication_proto_msgTypes[1]
+	mi := &file_proto_replication_proto_msgTypes[2]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
@@ -176,7 +310,7 @@ func (x *PrepareResponse) String() string {
 func (*PrepareResponse) ProtoMessage() {}
 
 func (x *PrepareResponse) ProtoReflect() protoreflect.Message {
-	mi := &file_proto_replication_proto_msgTypes[1]
+	mi := &file_proto_replication_proto_msgTypes[2]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -189,7 +323,7 @@ func (x *PrepareResponse) ProtoReflect() protoreflect.Message {
 
 // Deprecated: Use PrepareResponse.ProtoReflect.Descriptor instead.
 func (*PrepareResponse) Descriptor() ([]byte, []int) {
-	return file_proto_replication_proto_rawDescGZIP(), []int{1}
+	return file_proto_replication_proto_rawDescGZIP(), []int{2}
 }
 
 func (x *PrepareResponse) GetReady() bool {
@@ -216,7 +350,7 @@ type CommitRequest struct {
 
 func (x *CommitRequest) Reset() {
 	*x = CommitRequest{}
-	mi := &file_proto_replication_proto_msgTypes[2]
+	mi := &file_proto_replication_proto_msgTypes[3]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
@@ -228,7 +362,7 @@ func (x *CommitRequest) String() string {
 func (*CommitRequest) ProtoMessage() {}
 
 func (x *CommitRequest) ProtoReflect() protoreflect.Message {
-	mi := &file_proto_replication_proto_msgTypes[2]
+	mi := &file_proto_replication_proto_msgTypes[3]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -241,7 +375,7 @@ func (x *CommitRequest) ProtoReflect() protoreflect.Message {
 
 // Deprecated: Use CommitRequest.ProtoReflect.Descriptor instead.
 func (*CommitRequest) Descriptor() ([]byte, []int) {
-	return file_proto_replication_proto_rawDescGZIP(), []int{2}
+	return file_proto_replication_proto_rawDescGZIP(), []int{3}
 }
 
 func (x *CommitRequest) GetTransactionId() string {
@@ -262,7 +396,7 @@ type CommitResponse struct {
 
 func (x *CommitResponse) Reset() {
 	*x = CommitResponse{}
-	mi := &file_proto_replication_proto_msgTypes[3]
+	mi := &file_proto_replication_proto_msgTypes[4]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
@@ -274,7 +408,7 @@ func (x *CommitResponse) String() string {
 func (*CommitResponse) ProtoMessage() {}
 
 func (x *CommitResponse) ProtoReflect() protoreflect.Message {
-	mi := &file_proto_replication_proto_msgTypes[3]
+	mi := &file_proto_replication_proto_msgTypes[4]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -287,7 +421,7 @@ func (x *CommitResponse) ProtoReflect() protoreflect.Message {
 
 // Deprecated: Use CommitResponse.ProtoReflect.Descriptor instead.
 func (*CommitResponse) Descriptor() ([]byte, []int) {
-	return file_proto_replication_proto_rawDescGZIP(), []int{3}
+	return file_proto_replication_proto_rawDescGZIP(), []int{4}
 }
 
 func (x *CommitResponse) GetSuccess() bool {
@@ -314,7 +448,7 @@ type AbortRequest struct {
 
 func (x *AbortRequest) Reset() {
 	*x = AbortRequest{}
-	mi := &file_proto_replication_proto_msgTypes[4]
+	mi := &file_proto_replication_proto_msgTypes[5]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
@@ -326,7 +460,7 @@ func (x *AbortRequest) String() string {
 func (*AbortRequest) ProtoMessage() {}
 
 func (x *AbortRequest) ProtoReflect() protoreflect.Message {
-	mi := &file_proto_replication_proto_msgTypes[4]
+	mi := &file_proto_replication_proto_msgTypes[5]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -339,7 +473,7 @@ func (x *AbortRequest) ProtoReflect() protoreflect.Message {
 
 // Deprecated: Use AbortRequest.ProtoReflect.Descriptor instead.
 func (*AbortRequest) Descriptor() ([]byte, []int) {
-	return file_proto_replication_proto_rawDescGZIP(), []int{4}
+	return file_proto_replication_proto_rawDescGZIP(), []int{5}
 }
 
 func (x *AbortRequest) GetTransactionId() string {
@@ -359,7 +493,7 @@ type AbortResponse struct {
 
 func (x *AbortResponse) Reset() {
 	*x = AbortResponse{}
-	mi := &file_proto_replication_proto_msgTypes[5]
+	mi := &file_proto_replication_proto_msgTypes[6]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
@@ -371,7 +505,7 @@ func (x *AbortResponse) String() string {
 func (*AbortResponse) ProtoMessage() {}
 
 func (x *AbortResponse) ProtoReflect() protoreflect.Message {
-	mi := &file_proto_replication_proto_msgTypes[5]
+	mi := &file_proto_replication_proto_msgTypes[6]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -384,7 +518,7 @@ func (x *AbortResponse) ProtoReflect() protoreflect.Message {
 
 // Deprecated: Use AbortResponse.ProtoReflect.Descriptor instead.
 func (*AbortResponse) Descriptor() ([]byte, []int) {
-	return file_proto_replication_proto_rawDescGZIP(), []int{5}
+	return file_proto_replication_proto_rawDescGZIP(), []int{6}
 }
 
 func (x *AbortResponse) GetSuccess() bool {
@@ -403,7 +537,7 @@ type HealthCheckRequest struct {
 
 func (x *HealthCheckRequest) Reset() {
 	*x = HealthCheckRequest{}
-	mi := &file_proto_replication_proto_msgTypes[6]
+	mi := &file_proto_replication_proto_msgTypes[7]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
@@ -415,7 +549,7 @@ func (x *HealthCheckRequest) String() string {
 func (*HealthCheckRequest) ProtoMessage() {}
 
 func (x *HealthCheckRequest) ProtoReflect() protoreflect.Message {
-	mi := &file_proto_replication_proto_msgTypes[6]
+	mi := &file_proto_replication_proto_msgTypes[7]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -428,7 +562,7 @@ func (x *HealthCheckRequest) ProtoReflect() protoreflect.Message {
 
 // Deprecated: Use HealthCheckRequest.ProtoReflect.Descriptor instead.
 func (*HealthCheckRequest) Descriptor() ([]byte, []int) {
-	return file_proto_replication_proto_rawDescGZIP(), []int{6}
+	return file_proto_replication_proto_rawDescGZIP(), []int{7}
 }
 
 // HealthCheckResponse contains health status
@@ -443,7 +577,7 @@ type HealthCheckResponse struct {
 
 func (x *HealthCheckResponse) Reset() {
 	*x = HealthCheckResponse{}
-	mi := &file_proto_replication_proto_msgTypes[7]
+	mi := &file_proto_replication_proto_msgTypes[8]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
@@ -455,7 +589,7 @@ func (x *HealthCheckResponse) String() string {
 func (*HealthCheckResponse) ProtoMessage() {}
 
 func (x *HealthCheckResponse) ProtoReflect() protoreflect.Message {
-	mi := &file_proto_replication_proto_msgTypes[7]
+	mi := &file_proto_replication_proto_msgTypes[8]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -468,7 +602,7 @@ func (x *HealthCheckResponse) ProtoReflect() protoreflect.Message {
 
 // Deprecated: Use HealthCheckResponse.ProtoReflect.Descriptor instead.
 func (*HealthCheckResponse) Descriptor() ([]byte, []int) {
-	return file_proto_replication_proto_rawDescGZIP(), []int{7}
+	return file_proto_replication_proto_rawDescGZIP(), []int{8}
 }
 
 func (x *HealthCheckResponse) GetHealthy() bool {
@@ -492,11 +626,209 @@ func (x *HealthCheckResponse) GetRole() string {
 	return ""
 }
 
+// Chunk is one piece of a blob part
+type Chunk struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Collection    string                 `protobuf:"bytes,1,opt,name=collection,proto3" json:"collection,omitempty"`
+	BlobId        string                 `protobuf:"bytes,2,opt,name=blob_id,json=blobId,proto3" json:"blob_id,omitempty"`
+	Part          uint32                 `protobuf:"varint,3,opt,name=part,proto3" json:"part,omitempty"`   // part number, from 1
+	Index         uint32                 `protobuf:"varint,4,opt,name=index,proto3" json:"index,omitempty"` // chunk number within the part, from 0
+	Data          []byte                 `protobuf:"bytes,5,opt,name=data,proto3" json:"data,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *Chunk) Reset() {
+	*x = Chunk{}
+	mi := &file_proto_replication_proto_msgTypes[9]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *Chunk) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Chunk) ProtoMessage() {}
+
+func (x *Chunk) ProtoReflect() protoreflect.Message {
+	mi := &file_proto_replication_proto_msgTypes[9]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Chunk.ProtoReflect.Descriptor instead.
+func (*Chunk) Descriptor() ([]byte, []int) {
+	return file_proto_replication_proto_rawDescGZIP(), []int{9}
+}
+
+func (x *Chunk) GetCollection() string {
+	if x != nil {
+		return x.Collection
+	}
+	return ""
+}
+
+func (x *Chunk) GetBlobId() string {
+	if x != nil {
+		return x.BlobId
+	}
+	return ""
+}
+
+func (x *Chunk) GetPart() uint32 {
+	if x != nil {
+		return x.Part
+	}
+	return 0
+}
+
+func (x *Chunk) GetIndex() uint32 {
+	if x != nil {
+		return x.Index
+	}
+	return 0
+}
+
+func (x *Chunk) GetData() []byte {
+	if x != nil {
+		return x.Data
+	}
+	return nil
+}
+
+// DiscardChunksRequest names the chunks to remove
+type DiscardChunksRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Collection    string                 `protobuf:"bytes,1,opt,name=collection,proto3" json:"collection,omitempty"`
+	BlobId        string                 `protobuf:"bytes,2,opt,name=blob_id,json=blobId,proto3" json:"blob_id,omitempty"`
+	Part          uint32                 `protobuf:"varint,3,opt,name=part,proto3" json:"part,omitempty"` // 0 for every part of the blob
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *DiscardChunksRequest) Reset() {
+	*x = DiscardChunksRequest{}
+	mi := &file_proto_replication_proto_msgTypes[10]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *DiscardChunksRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*DiscardChunksRequest) ProtoMessage() {}
+
+func (x *DiscardChunksRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_proto_replication_proto_msgTypes[10]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use DiscardChunksRequest.ProtoReflect.Descriptor instead.
+func (*DiscardChunksRequest) Descriptor() ([]byte, []int) {
+	return file_proto_replication_proto_rawDescGZIP(), []int{10}
+}
+
+func (x *DiscardChunksRequest) GetCollection() string {
+	if x != nil {
+		return x.Collection
+	}
+	return ""
+}
+
+func (x *DiscardChunksRequest) GetBlobId() string {
+	if x != nil {
+		return x.BlobId
+	}
+	return ""
+}
+
+func (x *DiscardChunksRequest) GetPart() uint32 {
+	if x != nil {
+		return x.Part
+	}
+	return 0
+}
+
+// ChunkResponse confirms that chunks were written or removed
+type ChunkResponse struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Success       bool                   `protobuf:"varint,1,opt,name=success,proto3" json:"success,omitempty"`
+	Error         string                 `protobuf:"bytes,2,opt,name=error,proto3" json:"error,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ChunkResponse) Reset() {
+	*x = ChunkResponse{}
+	mi := &file_proto_replication_proto_msgTypes[11]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ChunkResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ChunkResponse) ProtoMessage() {}
+
+func (x *ChunkResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_proto_replication_proto_msgTypes[11]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ChunkResponse.ProtoReflect.Descriptor instead.
+func (*ChunkResponse) Descriptor() ([]byte, []int) {
+	return file_proto_replication_proto_rawDescGZIP(), []int{11}
+}
+
+func (x *ChunkResponse) GetSuccess() bool {
+	if x != nil {
+		return x.Success
+	}
+	return false
+}
+
+func (x *ChunkResponse) GetError() string {
+	if x != nil {
+		return x.Error
+	}
+	return ""
+}
+
 var File_proto_replication_proto protoreflect.FileDescriptor
 
 const file_proto_replication_proto_rawDesc = "" +
 	"\n" +
-	"\x17proto/replication.proto\x12\vreplication\"\xd5\x01\n" +
+	"\x17proto/replication.proto\x12\vreplication\"\x8c\x01\n" +
+	"\bMutation\x128\n" +
+	"\toperation\x18\x01 \x01(\x0e2\x1a.replication.OperationTypeR\toperation\x12\x1e\n" +
+	"\n" +
+	"collection\x18\x02 \x01(\tR\n" +
+	"collection\x12\x10\n" +
+	"\x03key\x18\x03 \x01(\tR\x03key\x12\x14\n" +
+	"\x05value\x18\x04 \x01(\fR\x05value\"\xd0\x02\n" +
 	"\x0ePrepareRequest\x12%\n" +
 	"\x0etransaction_id\x18\x01 \x01(\tR\rtransactionId\x128\n" +
 	"\toperation\x18\x02 \x01(\x0e2\x1a.replication.OperationTypeR\toperation\x12\x1e\n" +
@@ -505,7 +837,12 @@ const file_proto_replication_proto_rawDesc = "" +
 	"collection\x12\x10\n" +
 	"\x03key\x18\x04 \x01(\tR\x03key\x12\x14\n" +
 	"\x05value\x18\x05 \x01(\fR\x05value\x12\x1a\n" +
-	"\bsequence\x18\x06 \x01(\x04R\bsequence\"=\n" +
+	"\bsequence\x18\x06 \x01(\x04R\bsequence\x123\n" +
+	"\tmutations\x18\a \x03(\v2\x15.replication.MutationR\tmutations\x12\x14\n" +
+	"\x05field\x18\b \x01(\tR\x05field\x12\x16\n" +
+	"\x06fields\x18\t \x03(\tR\x06fields\x12\x16\n" +
+	"\x06target\x18\n" +
+	" \x01(\tR\x06target\"=\n" +
 	"\x0fPrepareResponse\x12\x14\n" +
 	"\x05ready\x18\x01 \x01(\bR\x05ready\x12\x14\n" +
 	"\x05error\x18\x02 \x01(\tR\x05error\"6\n" +
@@ -522,16 +859,48 @@ const file_proto_replication_proto_rawDesc = "" +
 	"\x13HealthCheckResponse\x12\x18\n" +
 	"\ahealthy\x18\x01 \x01(\bR\ahealthy\x12\x17\n" +
 	"\anode_id\x18\x02 \x01(\tR\x06nodeId\x12\x12\n" +
-	"\x04role\x18\x03 \x01(\tR\x04role*$\n" +
+	"\x04role\x18\x03 \x01(\tR\x04role\"~\n" +
+	"\x05Chunk\x12\x1e\n" +
+	"\n" +
+	"collection\x18\x01 \x01(\tR\n" +
+	"collection\x12\x17\n" +
+	"\ablob_id\x18\x02 \x01(\tR\x06blobId\x12\x12\n" +
+	"\x04part\x18\x03 \x01(\rR\x04part\x12\x14\n" +
+	"\x05index\x18\x04 \x01(\rR\x05index\x12\x12\n" +
+	"\x04data\x18\x05 \x01(\fR\x04data\"c\n" +
+	"\x14DiscardChunksRequest\x12\x1e\n" +
+	"\n" +
+	"collection\x18\x01 \x01(\tR\n" +
+	"collection\x12\x17\n" +
+	"\ablob_id\x18\x02 \x01(\tR\x06blobId\x12\x12\n" +
+	"\x04part\x18\x03 \x01(\rR\x04part\"?\n" +
+	"\rChunkResponse\x12\x18\n" +
+	"\asuccess\x18\x01 \x01(\bR\asuccess\x12\x14\n" +
+	"\x05error\x18\x02 \x01(\tR\x05error*\x82\x02\n" +
 	"\rOperationType\x12\a\n" +
 	"\x03PUT\x10\x00\x12\n" +
 	"\n" +
-	"\x06DELETE\x10\x012\xaf\x02\n" +
+	"\x06DELETE\x10\x01\x12\t\n" +
+	"\x05BATCH\x10\x02\x12\x10\n" +
+	"\fCREATE_INDEX\x10\x03\x12\x0e\n" +
+	"\n" +
+	"DROP_INDEX\x10\x04\x12\x15\n" +
+	"\x11CREATE_TEXT_INDEX\x10\x05\x12\x13\n" +
+	"\x0fDROP_TEXT_INDEX\x10\x06\x12\x17\n" +
+	"\x13TRUNCATE_COLLECTION\x10\a\x12\x13\n" +
+	"\x0fDROP_COLLECTION\x10\b\x12\x13\n" +
+	"\x0fCOPY_COLLECTION\x10\t\x12\x15\n" +
+	"\x11RENAME_COLLECTION\x10\n" +
+	"\x12\x17\n" +
+	"\x13SET_COLLECTION_META\x10\v\x12\x10\n" +
+	"\fPUT_DATA_KEY\x10\f2\xc0\x03\n" +
 	"\x12ReplicationService\x12D\n" +
 	"\aPrepare\x12\x1b.replication.PrepareRequest\x1a\x1c.replication.PrepareResponse\x12A\n" +
 	"\x06Commit\x12\x1a.replication.CommitRequest\x1a\x1b.replication.CommitResponse\x12>\n" +
 	"\x05Abort\x12\x19.replication.AbortRequest\x1a\x1a.replication.AbortResponse\x12P\n" +
-	"\vHealthCheck\x12\x1f.replication.HealthCheckRequest\x1a .replication.HealthCheckResponseB\fZ\n" +
+	"\vHealthCheck\x12\x1f.replication.HealthCheckRequest\x1a .replication.HealthCheckResponse\x12?\n" +
+	"\vWriteChunks\x12\x12.replication.Chunk\x1a\x1a.replication.ChunkResponse(\x01\x12N\n" +
+	"\rDiscardChunks\x12!.replication.DiscardChunksRequest\x1a\x1a.replication.ChunkResponseB\fZ\n" +
 	"kiwi/protob\x06proto3"
 
 var (
@@ -547,33 +916,43 @@ func file_proto_replication_proto_rawDescGZIP() []byte {
 }
 
 var file_proto_replication_proto_enumTypes = make([]protoimpl.EnumInfo, 1)
-var file_proto_replication_proto_msgTypes = make([]protoimpl.MessageInfo, 8)
+var file_proto_replication_proto_msgTypes = make([]protoimpl.MessageInfo, 12)
 var file_proto_replication_proto_goTypes = []any{
-	(OperationType)(0),          // 0: replication.OperationType
-	(*PrepareRequest)(nil),      // 1: replication.PrepareRequest
-	(*PrepareResponse)(nil),     // 2: replication.PrepareResponse
-	(*CommitRequest)(nil),       // 3: replication.CommitRequest
-	(*CommitResponse)(nil),      // 4: replication.CommitResponse
-	(*AbortRequest)(nil),        // 5: replication.AbortRequest
-	(*AbortResponse)(nil),       // 6: replication.AbortResponse
-	(*HealthCheckRequest)(nil),  // 7: replication.HealthCheckRequest
-	(*HealthCheckResponse)(nil), // 8: replication.HealthCheckResponse
+	(OperationType)(0),           // 0: replication.OperationType
+	(*Mutation)(nil),             // 1: replication.Mutation
+	(*PrepareRequest)(nil),       // 2: replication.PrepareRequest
+	(*PrepareResponse)(nil),      // 3: replication.PrepareResponse
+	(*CommitRequest)(nil),        // 4: replication.CommitRequest
+	(*CommitResponse)(nil),       // 5: replication.CommitResponse
+	(*AbortRequest)(nil),         // 6: replication.AbortRequest
+	(*AbortResponse)(nil),        // 7: replication.AbortResponse
+	(*HealthCheckRequest)(nil),   // 8: replication.HealthCheckRequest
+	(*HealthCheckResponse)(nil),  // 9: replication.HealthCheckResponse
+	(*Chunk)(nil),                // 10: replication.Chunk
+	(*DiscardChunksRequest)(nil), // 11: replication.DiscardChunksRequest
+	(*ChunkResponse)(nil),        // 12: replication.ChunkResponse
 }
 var file_proto_replication_proto_depIdxs = []int32{
-	0, // 0: replication.PrepareRequest.operation:type_name -> replication.OperationType
-	1, // 1: replication.ReplicationService.Prepare:input_type -> replication.PrepareRequest
-	3, // 2: replication.ReplicationService.Commit:input_type -> replication.CommitRequest
-	5, // 3: replication.ReplicationService.Abort:input_type -> replication.AbortRequest
-	7, // 4: replication.ReplicationService.HealthCheck:input_type -> replication.HealthCheckRequest
-	2, // 5: replication.ReplicationService.Prepare:output_type -> replication.PrepareResponse
-	4, // 6: replication.ReplicationService.Commit:output_type -> replication.CommitResponse
-	6, // 7: replication.ReplicationService.Abort:output_type -> replication.AbortResponse
-	8, // 8: replication.ReplicationService.HealthCheck:output_type -> replication.HealthCheckResponse
-	5, // [5:9] is the sub-list for method output_type
-	1, // [1:5] is the sub-list for method input_type
-	1, // [1:1] is the sub-list for extension type_name
-	1, // [1:1] is the sub-list for extension extendee
-	0, // [0:1] is the sub-list for field type_name
+	0,  // 0: replication.Mutation.operation:type_name -> replication.OperationType
+	0,  // 1: replication.PrepareRequest.operation:type_name -> replication.OperationType
+	1,  // 2: replication.PrepareRequest.mutations:type_name -> replication.Mutation
+	2,  // 3: replication.ReplicationService.Prepare:input_type -> replication.PrepareRequest
+	4,  // 4: replication.ReplicationService.Commit:input_type -> replication.CommitRequest
+	6,  // 5: replication.ReplicationService.Abort:input_type -> replication.AbortRequest
+	8,  // 6: replication.ReplicationService.HealthCheck:input_type -> replication.HealthCheckRequest
+	10, // 7: replication.ReplicationService.WriteChunks:input_type -> replication.Chunk
+	11, // 8: replication.ReplicationService.DiscardChunks:input_type -> replication.DiscardChunksRequest
+	3,  // 9: replication.ReplicationService.Prepare:output_type -> replication.PrepareResponse
+	5,  // 10: replication.ReplicationService.Commit:output_type -> replication.CommitResponse
+	7,  // 11: replication.ReplicationService.Abort:output_type -> replication.AbortResponse
+	9,  // 12: replication.ReplicationService.HealthCheck:output_type -> replication.HealthCheckResponse
+	12, // 13: replication.ReplicationService.WriteChunks:output_type -> replication.ChunkResponse
+	12, // 14: replication.ReplicationService.DiscardChunks:output_type -> replication.ChunkResponse
+	9,  // [9:15] is the sub-list for method output_type
+	3,  // [3:9] is the sub-list for method input_type
+	3,  // [3:3] is the sub-list for extension type_name
+	3,  // [3:3] is the sub-list for extension extendee
+	0,  // [0:3] is the sub-list for field type_name
 }
 
 func init() { file_proto_replication_proto_init() }
@@ -587,7 +966,7 @@ func file_proto_replication_proto_init() {
 			GoPackagePath: reflect.TypeOf(x{}).PkgPath(),
 			RawDescriptor: unsafe.Slice(unsafe.StringData(file_proto_replication_proto_rawDesc), len(file_proto_replication_proto_rawDesc)),
 			NumEnums:      1,
-			NumMessages:   8,
+			NumMessages:   12,
 			NumExtensions: 0,
 			NumServices:   1,
 		},
@@ -19,10 +19,12 @@ import (
 const _ = grpc.SupportPackageIsVersion9
 
 const (
-	ReplicationService_Prepare_FullMethodName     = "/replication.ReplicationService/Prepare"
-	ReplicationService_Commit_FullMethodName      = "/replication.ReplicationService/Commit"
-	ReplicationService_Abort_FullMethodName       = "/replication.ReplicationService/Abort"
-	ReplicationService_HealthCheck_FullMethodName = "/replication.ReplicationService/HealthCheck"
+	ReplicationService_Prepare_FullMethodName       = "/replication.ReplicationService/Prepare"
+	ReplicationService_Commit_FullMethodName        = "/replication.ReplicationService/Commit"
+	ReplicationService_Abort_FullMethodName         = "/replication.ReplicationService/Abort"
+	ReplicationService_HealthCheck_FullMethodName   = "/replication.ReplicationService/HealthCheck"
+	ReplicationService_WriteChunks_FullMethodName   = "/replication.ReplicationService/WriteChunks"
+	ReplicationService_DiscardChunks_FullMethodName = "/replication.ReplicationService/DiscardChunks"
 )
 
 // ReplicationServiceClient is the client API for ReplicationService service.
@@ -40,6 +42,11 @@ type ReplicationServiceClient interface {
 	Abort(ctx context.Context, in *AbortRequest, opts ...grpc.CallOption) (*AbortResponse, error)
 	// HealthCheck checks if the slave is alive
 	HealthCheck(ctx context.Context, in *HealthCheckRequest, opts ...grpc.CallOption) (*HealthCheckResponse, error)
+	// WriteChunks streams the chunks of one blob part. Chunks are invisible
+	// until the blob manifest is committed through 2PC, so they skip it.
+	WriteChunks(ctx context.Context, opts ...grpc.CallOption) (grpc.ClientStreamingClient[Chunk, ChunkResponse], error)
+	// DiscardChunks removes the chunks of a blob part, or of a whole blob
+	DiscardChunks(ctx context.Context, in *DiscardChunksRequest, opts ...grpc.CallOption) (*ChunkResponse, error)
 }
 
 type replicationServiceClient struct {
@@ -90,6 +97,29 @@ func (c *replicationServiceClient) HealthCheck(ctx context.Context, in *HealthCh
 	return out, nil
 }
 
+func (c *replicationServiceClient) WriteChunks(ctx context.Context, opts ...grpc.CallOption) (grpc.ClientStreamingClient[Chunk, ChunkResponse], error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	stream, err := c.cc.NewStream(ctx, &ReplicationService_ServiceDesc.Streams[0], ReplicationService_WriteChunks_FullMethodName, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &grpc.GenericClientStream[Chunk, ChunkResponse]{ClientStream: stream}
+	return x, nil
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type ReplicationService_WriteChunksClient = grpc.ClientStreamingClient[Chunk, ChunkResponse]
+
+func (c *replicationServiceClient) DiscardChunks(ctx context.Context, in *DiscardChunksRequest, opts ...grpc.CallOption) (*ChunkResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(ChunkResponse)
+	err := c.cc.Invoke(ctx, ReplicationService_DiscardChunks_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
 // ReplicationServiceServer is the server API for ReplicationService service.
 // All implementations must embed UnimplementedReplicationServiceServer
 // for forward compatibility.
@@ -105,6 +135,11 @@ type ReplicationServiceServer interface {
 	Abort(context.Context, *AbortRequest) (*AbortResponse, error)
 	// HealthCheck checks if the slave is alive
 	HealthCheck(context.Context, *HealthCheckRequest) (*HealthCheckResponse, error)
+	// WriteChunks streams the chunks of one blob part. Chunks are invisible
+	// until the blob manifest is committed through 2PC, so they skip it.
+	WriteChunks(grpc.ClientStreamingServer[Chunk, ChunkResponse]) error
+	// DiscardChunks removes the chunks of a blob part, or of a whole blob
+	DiscardChunks(context.Context, *DiscardChunksRequest) (*ChunkResponse, error)
 	mustEmbedUnimplementedReplicationServiceServer()
 }
 
@@ -127,6 +162,12 @@ func (UnimplementedReplicationServiceServer) Abort(context.Context, *AbortReques
 func (UnimplementedReplicationServiceServer) HealthCheck(context.Context, *HealthCheckRequest) (*HealthCheckResponse, error) {
 	return nil, status.Error(codes.Unimplemented, "method HealthCheck not implemented")
 }
+func (UnimplementedReplicationServiceServer) WriteChunks(grpc.ClientStreamingServer[Chunk, ChunkResponse]) error {
+	return status.Error(codes.Unimplemented, "method WriteChunks not implemented")
+}
+func (UnimplementedReplicationServiceServer) DiscardChunks(context.Context, *DiscardChunksRequest) (*ChunkResponse, error) {
+	return nil, status.Error(codes.Unimplemented, "method DiscardChunks not implemented")
+}
 func (UnimplementedReplicationServiceServer) mustEmbedUnimplementedReplicationServiceServer() {}
 func (UnimplementedReplicationServiceServer) testEmbeddedByValue()                            {}
 
@@ -220,6 +261,31 @@ func _ReplicationService_HealthCheck_Handler(srv interface{}, ctx context.Contex
 	return interceptor(ctx, in, info, handler)
 }
 
+func _ReplicationService_WriteChunks_Handler(srv interface{}, stream grpc.ServerStream) error {
+	return srv.(ReplicationServiceServer).WriteChunks(&grpc.GenericServerStream[Chunk, ChunkResponse]{ServerStream: stream})
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type ReplicationService_WriteChunksServer = grpc.ClientStreamingServer[Chunk, ChunkResponse]
+
+func _ReplicationService_DiscardChunks_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(DiscardChunksRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(ReplicationServiceServer).DiscardChunks(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: ReplicationService_DiscardChunks_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(ReplicationServiceServer).DiscardChunks(ctx, req.(*DiscardChunksRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
 // ReplicationService_ServiceDesc is the grpc.ServiceDesc for ReplicationService service.
 // It's only intended for direct use with grpc.RegisterService,
 // and not to be introspected or modified (even as a copy)
@@ -243,7 +309,17 @@ var ReplicationService_ServiceDesc = grpc.ServiceDesc{
 			MethodName: "HealthCheck",
 			Handler:    _ReplicationService_HealthCheck_Handler,
 		},
+		{
+			MethodName: "DiscardChunks",
+			Handler:    _ReplicationService_DiscardChunks_Handler,
+		},
+	},
+	Streams: []grpc.StreamDesc{
+		{
+			StreamName:    "WriteChunks",
+			Handler:       _ReplicationService_WriteChunks_Handler,
+			ClientStreams: true,
+		},
 	},
-	Streams:  []grpc.StreamDesc{},
 	Metadata: "proto/replication.proto",
 }